@@ -0,0 +1,77 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// EchoModel имя модели офлайн-провайдера по умолчанию
+const EchoModel = "echo"
+
+// EchoClient офлайн-провайдер с детерминированными ответами для разработки и тестов
+type EchoClient struct {
+	model    string
+	template string
+}
+
+// NewEchoClient создает офлайн-провайдер.
+// template - формат ответа с одним %s для последнего сообщения пользователя (пусто - формат по умолчанию).
+func NewEchoClient(template string) *EchoClient {
+	if template == "" {
+		template = "🤖 [echo] %s"
+	}
+	return &EchoClient{
+		model:    EchoModel,
+		template: template,
+	}
+}
+
+// GenerateWithHistory отвечает шаблоном с последним сообщением пользователя
+func (c *EchoClient) GenerateWithHistory(messages []OpenAIMessage) (string, error) {
+	var last string
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			last = messages[i].Content
+			break
+		}
+	}
+	return fmt.Sprintf(c.template, strings.TrimSpace(last)), nil
+}
+
+// Generate отвечает шаблоном с текстом промпта
+func (c *EchoClient) Generate(prompt string) (string, error) {
+	return c.GenerateWithHistory([]OpenAIMessage{{Role: "user", Content: prompt}})
+}
+
+// GenerateStream отдает ответ по словам, имитируя потоковую генерацию
+func (c *EchoClient) GenerateStream(ctx context.Context, messages []OpenAIMessage, onDelta StreamHandler) (string, error) {
+	response, _ := c.GenerateWithHistory(messages)
+
+	for _, word := range strings.SplitAfter(response, " ") {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if onDelta != nil {
+			if err := onDelta(word); err != nil {
+				return response, err
+			}
+		}
+	}
+	return response, nil
+}
+
+// TestConnection всегда успешен
+func (c *EchoClient) TestConnection() error {
+	return nil
+}
+
+// SetModel изменяет имя модели
+func (c *EchoClient) SetModel(model string) {
+	c.model = model
+}
+
+// GetModel возвращает имя модели
+func (c *EchoClient) GetModel() string {
+	return c.model
+}
@@ -0,0 +1,14 @@
+package ai
+
+// Запасные ответы на случай недоступности OpenAI
+const (
+	// FallbackChat ответ в режиме вопросов об отношениях
+	FallbackChat = "💭 Сейчас я не могу подготовить развернутый ответ — мой AI-помощник временно недоступен.\n\n" +
+		"Пока можно сделать маленький шаг: расскажите партнеру, что вас волнует, начиная с «я чувствую...», и внимательно выслушайте ответ без оценок. 🫶🏻\n\n" +
+		"Попробуйте задать вопрос ещё раз через несколько минут."
+
+	// FallbackInsight ответ вместо персонального инсайта
+	FallbackInsight = "🔍 Инсайт сейчас не удалось подготовить — AI-помощник временно недоступен.\n\n" +
+		"Ваши записи сохранены, попробуйте запросить инсайт чуть позже. " +
+		"А пока перечитайте записи этой недели вместе и отметьте одну вещь, которая вас порадовала. 💖"
+)
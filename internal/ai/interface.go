@@ -1,5 +1,7 @@
 package ai
 
+import "context"
+
 // AIClient общий интерфейс для всех AI клиентов
 type AIClient interface {
 	Generate(prompt string) (string, error)
@@ -13,3 +15,9 @@ type HistoryAIClient interface {
 	SetModel(model string)
 	GetModel() string
 }
+
+// StreamingAIClient интерфейс для AI клиентов с потоковой генерацией
+type StreamingAIClient interface {
+	HistoryAIClient
+	GenerateStream(ctx context.Context, messages []OpenAIMessage, onDelta StreamHandler) (string, error)
+}
@@ -4,26 +4,35 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 	"time"
+	"github.com/godofphonk/lovifyy-bot/internal/config"
 	"golang.org/x/net/proxy"
 )
 
 // OpenAIClient клиент для работы с OpenAI API
 type OpenAIClient struct {
-	apiKey  string
-	baseURL string
-	model   string
+	apiKey      string
+	baseURL     string
+	model       string
+	maxTokens   int
+	temperature float64
+	timeout     time.Duration   // Таймаут одной попытки запроса
+	retry       RetryPolicy     // Политика повторных попыток
+	breaker     *CircuitBreaker // Автомат отключения при серии сбоев
 }
 
 // OpenAIMessage представляет сообщение в формате OpenAI
 type OpenAIMessage struct {
 	Role    string `json:"role"`    // "system", "user", "assistant"
 	Content string `json:"content"`
+	Images  []string `json:"-"`     // Изображения для vision-моделей (data URL), см. ImageMessage
 }
 
 // OpenAIRequest структура запроса к OpenAI API
@@ -33,6 +42,19 @@ type OpenAIRequest struct {
 	MaxTokens   int             `json:"max_tokens,omitempty"`
 	Temperature float64         `json:"temperature,omitempty"`
 	Stream      bool            `json:"stream,omitempty"`
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+}
+
+// StreamOptions параметры потокового ответа
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"` // Прислать расход токенов последним фрагментом
+}
+
+// OpenAIUsage расход токенов в ответе OpenAI API
+type OpenAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
 
 // OpenAIResponse структура ответа от OpenAI API
@@ -49,88 +71,117 @@ type OpenAIResponse struct {
 		} `json:"message"`
 		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Usage OpenAIUsage `json:"usage"`
 }
 
-// NewOpenAIClient создает новый клиент OpenAI
-func NewOpenAIClient(model string) *OpenAIClient {
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		return nil // Возвращаем nil вместо panic
+// NewOpenAIClientFromConfig создает клиент OpenAI-совместимого API (OpenAI, Ollama, llama.cpp, vLLM)
+func NewOpenAIClientFromConfig(cfg config.OpenAIConfig) *OpenAIClient {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = config.DefaultOpenAIBaseURL
 	}
 
-	return &OpenAIClient{
-		apiKey:  apiKey,
-		baseURL: "https://api.openai.com/v1",
-		model:   model,
+	client := &OpenAIClient{
+		apiKey:      cfg.APIKey,
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		model:       cfg.Model,
+		maxTokens:   cfg.MaxTokens,
+		temperature: cfg.Temperature,
+		timeout:     30 * time.Second,
+		retry: RetryPolicy{
+			MaxRetries: cfg.MaxRetries,
+			BaseDelay:  cfg.RetryBaseDelay,
+			MaxDelay:   cfg.RetryMaxDelay,
+		},
+		breaker: NewCircuitBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
 	}
+	client.SetTimeout(cfg.Timeout)
+	return client
 }
 
-// Generate генерирует ответ через OpenAI API с историей сообщений
+// GenerateWithHistory генерирует ответ через OpenAI API с историей сообщений
 func (c *OpenAIClient) GenerateWithHistory(messages []OpenAIMessage) (string, error) {
+	response, _, err := c.GenerateWithUsage(messages)
+	return response, err
+}
+
+// GenerateWithUsage генерирует ответ и возвращает расход токенов
+func (c *OpenAIClient) GenerateWithUsage(messages []OpenAIMessage) (string, Usage, error) {
 	// Создаем запрос
 	reqData := OpenAIRequest{
 		Model:       c.model,
 		Messages:    messages,
-		MaxTokens:   1500,
-		Temperature: 0.7,
+		MaxTokens:   c.maxTokens,
+		Temperature: c.temperature,
 		Stream:      false,
 	}
 
 	jsonData, err := json.Marshal(reqData)
 	if err != nil {
-		return "", fmt.Errorf("ошибка создания JSON: %w", err)
+		return "", Usage{}, fmt.Errorf("ошибка создания JSON: %w", err)
 	}
 
 	// Создаем HTTP клиент с поддержкой прокси
 	client := c.createHTTPClient()
 
-	// Создаем контекст с таймаутом
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	var openaiResp OpenAIResponse
+	err = c.withRetry(context.Background(), c.timeout, func(ctx context.Context) error {
+		// Создаем запрос с контекстом попытки
+		req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+		if err != nil {
+			return fmt.Errorf("ошибка создания запроса: %w", err)
+		}
 
-	// Создаем запрос с контекстом
-	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("ошибка создания запроса: %w", err)
-	}
+		// Устанавливаем заголовки
+		req.Header.Set("Content-Type", "application/json")
+		if c.apiKey != "" {
+			req.Header.Set("Authorization", "Bearer "+c.apiKey)
+		}
+
+		// Отправляем запрос
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("ошибка подключения к OpenAI: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return newAPIError(resp)
+		}
 
-	// Устанавливаем заголовки
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+		// Читаем ответ
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("ошибка чтения ответа: %w", err)
+		}
 
-	// Отправляем запрос
-	resp, err := client.Do(req)
+		if err := json.Unmarshal(body, &openaiResp); err != nil {
+			return fmt.Errorf("ошибка парсинга JSON: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("ошибка подключения к OpenAI: %w", err)
+		return "", Usage{}, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("ошибка OpenAI API: статус %d, ответ: %s", resp.StatusCode, string(body))
+	if len(openaiResp.Choices) == 0 {
+		return "", Usage{}, fmt.Errorf("пустой ответ от OpenAI")
 	}
 
-	// Читаем ответ
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("ошибка чтения ответа: %w", err)
-	}
+	response := openaiResp.Choices[0].Message.Content
+	return response, c.usageFrom(&openaiResp.Usage, messages, response), nil
+}
 
-	var openaiResp OpenAIResponse
-	if err := json.Unmarshal(body, &openaiResp); err != nil {
-		return "", fmt.Errorf("ошибка парсинга JSON: %w", err)
+// usageFrom переводит usage ответа в Usage; если сервер его не вернул, оценивает расход
+func (c *OpenAIClient) usageFrom(usage *OpenAIUsage, messages []OpenAIMessage, response string) Usage {
+	if usage == nil || usage.TotalTokens == 0 {
+		return estimateUsage(c.model, messages, response)
 	}
-
-	if len(openaiResp.Choices) == 0 {
-		return "", fmt.Errorf("пустой ответ от OpenAI")
+	return Usage{
+		Model:            c.model,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
 	}
-
-	return openaiResp.Choices[0].Message.Content, nil
 }
 
 // Generate простая генерация для совместимости с существующим кодом
@@ -161,6 +212,97 @@ func (c *OpenAIClient) SetModel(model string) {
 	c.model = model
 }
 
+// SetTimeout изменяет таймаут одной попытки запроса
+func (c *OpenAIClient) SetTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		c.timeout = timeout
+	}
+}
+
+// SetRetryPolicy изменяет политику повторных попыток
+func (c *OpenAIClient) SetRetryPolicy(policy RetryPolicy) {
+	c.retry = policy
+}
+
+// SetCircuitBreaker изменяет автомат отключения (nil - отключить)
+func (c *OpenAIClient) SetCircuitBreaker(breaker *CircuitBreaker) {
+	c.breaker = breaker
+}
+
+// BreakerState возвращает состояние автомата отключения
+func (c *OpenAIClient) BreakerState() string {
+	return c.breaker.State()
+}
+
+// withRetry выполняет attempt с повторами, backoff и учетом автомата отключения.
+// attemptTimeout ограничивает каждую попытку (0 - только родительский контекст).
+func (c *OpenAIClient) withRetry(ctx context.Context, attemptTimeout time.Duration, attempt func(ctx context.Context) error) error {
+	var lastErr error
+
+	for i := 0; i <= c.retry.MaxRetries; i++ {
+		if err := c.breaker.Allow(); err != nil {
+			if lastErr != nil {
+				return fmt.Errorf("%w (последняя ошибка: %v)", err, lastErr)
+			}
+			return err
+		}
+
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if attemptTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, attemptTimeout)
+		}
+		err := attempt(attemptCtx)
+		cancel()
+
+		// Вызывающая сторона отменила запрос - это не сбой сервиса
+		if ctx.Err() != nil {
+			return err
+		}
+		// Ответ получен (пусть и с ошибкой запроса) - сервис доступен
+		if err == nil || !isRetryable(err) {
+			c.breaker.RecordSuccess()
+			return err
+		}
+
+		c.breaker.RecordFailure()
+		lastErr = err
+
+		if i == c.retry.MaxRetries {
+			break
+		}
+
+		var retryAfter time.Duration
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			retryAfter = apiErr.RetryAfter
+		}
+		wait, ok := c.retry.delay(i, retryAfter)
+		if !ok {
+			break
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			return lastErr
+		}
+	}
+
+	return fmt.Errorf("OpenAI не ответил после %d попыток: %w", c.retry.MaxRetries+1, lastErr)
+}
+
+// newAPIError читает тело ответа с ошибкой и заголовок Retry-After
+func newAPIError(resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// SetBaseURL изменяет адрес API (совместимые с OpenAI сервисы, тесты)
+func (c *OpenAIClient) SetBaseURL(baseURL string) {
+	c.baseURL = strings.TrimRight(baseURL, "/")
+}
+
 // GetModel возвращает текущую модель
 func (c *OpenAIClient) GetModel() string {
 	return c.model
@@ -169,7 +311,7 @@ func (c *OpenAIClient) GetModel() string {
 // createHTTPClient создает HTTP клиент с поддержкой прокси
 func (c *OpenAIClient) createHTTPClient() *http.Client {
 	client := &http.Client{
-		Timeout: 30 * time.Second,
+		Timeout: c.timeout,
 	}
 
 	// Проверяем переменные окружения для прокси (OpenAI специфичные)
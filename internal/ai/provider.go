@@ -0,0 +1,26 @@
+package ai
+
+import (
+	"fmt"
+
+	"github.com/godofphonk/lovifyy-bot/internal/config"
+)
+
+// Поддерживаемые AI провайдеры
+const (
+	ProviderOpenAI = "openai" // OpenAI и совместимые API (Ollama, llama.cpp, vLLM)
+	ProviderEcho   = "echo"   // Офлайн-провайдер для разработки и тестов
+)
+
+// NewClient создает AI клиент по конфигурации
+func NewClient(cfg config.OpenAIConfig) (StreamingAIClient, error) {
+	switch cfg.Provider {
+	case "", ProviderOpenAI:
+		return NewOpenAIClientFromConfig(cfg), nil
+	case ProviderEcho:
+		return NewEchoClient(cfg.EchoTemplate), nil
+	default:
+		// Та же ошибка, что и при проверке конфигурации: обычно провайдер отсекается еще при загрузке
+		return nil, fmt.Errorf("unsupported provider: %s (expected %s or %s)", cfg.Provider, ProviderOpenAI, ProviderEcho)
+	}
+}
@@ -0,0 +1,228 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen возвращается, пока автомат отключения разомкнут после серии сбоев
+var ErrCircuitOpen = errors.New("OpenAI временно недоступен: автомат отключения разомкнут")
+
+// APIError ошибка ответа OpenAI API с HTTP статусом
+type APIError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // Значение заголовка Retry-After (0 - не задан)
+}
+
+// Error реализует интерфейс error
+func (e *APIError) Error() string {
+	return fmt.Sprintf("ошибка OpenAI API: статус %d, ответ: %s", e.StatusCode, e.Body)
+}
+
+// Retryable проверяет, имеет ли смысл повторять запрос
+func (e *APIError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// RetryPolicy параметры повторных попыток
+type RetryPolicy struct {
+	MaxRetries int           // Количество повторов после первой попытки
+	BaseDelay  time.Duration // Базовая задержка экспоненциального backoff
+	MaxDelay   time.Duration // Максимальная задержка между попытками
+}
+
+// DefaultRetryPolicy политика повторов по умолчанию
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: 3,
+		BaseDelay:  500 * time.Millisecond,
+		MaxDelay:   10 * time.Second,
+	}
+}
+
+// delay вычисляет задержку перед повтором attempt (с 0) с учетом Retry-After.
+// Возвращает false, если сервер просит ждать дольше MaxDelay.
+func (p RetryPolicy) delay(attempt int, retryAfter time.Duration) (time.Duration, bool) {
+	if retryAfter > 0 {
+		if p.MaxDelay > 0 && retryAfter > p.MaxDelay {
+			return 0, false
+		}
+		return retryAfter, true
+	}
+
+	backoff := p.BaseDelay << attempt
+	if backoff <= 0 || (p.MaxDelay > 0 && backoff > p.MaxDelay) {
+		backoff = p.MaxDelay
+	}
+	if backoff <= 0 {
+		return 0, true
+	}
+
+	// Jitter: случайная задержка в диапазоне [backoff/2, backoff]
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1)), true
+}
+
+// parseRetryAfter разбирает заголовок Retry-After (секунды или HTTP дата)
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// isRetryable проверяет, является ли ошибка временной
+func isRetryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+	// Отмена вызывающей стороной не повторяется
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	// Сетевые ошибки и таймауты попытки
+	return true
+}
+
+// IsUnavailable проверяет, что AI недоступен (а не отклонил запрос), и стоит использовать запасной ответ
+func IsUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, ErrCircuitOpen) || isRetryable(err)
+}
+
+// sleepContext ждет d или отмены контекста
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Состояния автомата отключения
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// CircuitBreaker размыкается после серии сбоев и пропускает пробный запрос по истечении паузы
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mutex    sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+	probeAt  time.Time
+}
+
+// NewCircuitBreaker создает автомат отключения; threshold <= 0 отключает его
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     BreakerClosed,
+	}
+}
+
+// Allow проверяет, можно ли выполнить запрос
+func (cb *CircuitBreaker) Allow() error {
+	if cb == nil || cb.threshold <= 0 {
+		return nil
+	}
+
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	switch cb.state {
+	case BreakerOpen:
+		if time.Since(cb.openedAt) < cb.cooldown {
+			return ErrCircuitOpen
+		}
+		cb.state = BreakerHalfOpen
+	case BreakerHalfOpen:
+		// Пока идет пробный запрос, остальные отклоняются (зависший пробный запрос не блокирует навсегда)
+		if cb.probing && time.Since(cb.probeAt) < cb.cooldown {
+			return ErrCircuitOpen
+		}
+	default:
+		return nil
+	}
+	cb.probing = true
+	cb.probeAt = time.Now()
+	return nil
+}
+
+// RecordSuccess фиксирует успешный запрос и замыкает автомат
+func (cb *CircuitBreaker) RecordSuccess() {
+	if cb == nil {
+		return
+	}
+
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	cb.state = BreakerClosed
+	cb.failures = 0
+	cb.probing = false
+}
+
+// RecordFailure фиксирует сбой и при необходимости размыкает автомат
+func (cb *CircuitBreaker) RecordFailure() {
+	if cb == nil || cb.threshold <= 0 {
+		return
+	}
+
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	cb.failures++
+	cb.probing = false
+	if cb.state == BreakerHalfOpen || cb.failures >= cb.threshold {
+		cb.state = BreakerOpen
+		cb.openedAt = time.Now()
+	}
+}
+
+// State возвращает текущее состояние автомата
+func (cb *CircuitBreaker) State() string {
+	if cb == nil {
+		return BreakerClosed
+	}
+
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	if cb.state == BreakerOpen && time.Since(cb.openedAt) >= cb.cooldown {
+		return BreakerHalfOpen
+	}
+	return cb.state
+}
@@ -0,0 +1,148 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// StreamHandler получает очередной фрагмент ответа модели
+type StreamHandler func(delta string) error
+
+// OpenAIStreamChunk фрагмент потокового ответа chat/completions
+type OpenAIStreamChunk struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index int `json:"index"`
+		Delta struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *OpenAIUsage `json:"usage,omitempty"` // Только в последнем фрагменте при include_usage
+}
+
+// streamDoneMarker маркер завершения SSE потока OpenAI
+const streamDoneMarker = "[DONE]"
+
+// GenerateStream генерирует ответ в потоковом режиме (SSE), вызывая onDelta для каждого фрагмента.
+// Возвращает полный текст ответа.
+func (c *OpenAIClient) GenerateStream(ctx context.Context, messages []OpenAIMessage, onDelta StreamHandler) (string, error) {
+	response, _, err := c.GenerateStreamWithUsage(ctx, messages, onDelta)
+	return response, err
+}
+
+// GenerateStreamWithUsage генерирует потоковый ответ и возвращает расход токенов
+func (c *OpenAIClient) GenerateStreamWithUsage(ctx context.Context, messages []OpenAIMessage, onDelta StreamHandler) (string, Usage, error) {
+	reqData := OpenAIRequest{
+		Model:         c.model,
+		Messages:      messages,
+		MaxTokens:     c.maxTokens,
+		Temperature:   c.temperature,
+		Stream:        true,
+		StreamOptions: &StreamOptions{IncludeUsage: true},
+	}
+
+	jsonData, err := json.Marshal(reqData)
+	if err != nil {
+		return "", Usage{}, fmt.Errorf("ошибка создания JSON: %w", err)
+	}
+
+	// Таймаут всего запроса ограничивает поток, поэтому полагаемся на контекст
+	client := c.createHTTPClient()
+	client.Timeout = 0
+
+	// Повторы возможны только до начала потока
+	var resp *http.Response
+	err = c.withRetry(ctx, 0, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+		if err != nil {
+			return fmt.Errorf("ошибка создания запроса: %w", err)
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "text/event-stream")
+		if c.apiKey != "" {
+			req.Header.Set("Authorization", "Bearer "+c.apiKey)
+		}
+
+		resp, err = client.Do(req)
+		if err != nil {
+			return fmt.Errorf("ошибка подключения к OpenAI: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			return newAPIError(resp)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	response, usage, err := readStream(resp.Body, onDelta)
+	return response, c.usageFrom(usage, messages, response), err
+}
+
+// readStream разбирает SSE поток chat/completions
+func readStream(body io.Reader, onDelta StreamHandler) (string, *OpenAIUsage, error) {
+	var full strings.Builder
+	var usage *OpenAIUsage
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Пустые строки разделяют события, строки с ':' - комментарии (keep-alive)
+		payload, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		payload = strings.TrimSpace(payload)
+
+		if payload == streamDoneMarker {
+			return full.String(), usage, nil
+		}
+
+		var chunk OpenAIStreamChunk
+		if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+			return full.String(), usage, fmt.Errorf("ошибка парсинга фрагмента: %w", err)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			full.WriteString(choice.Delta.Content)
+			if onDelta != nil {
+				if err := onDelta(choice.Delta.Content); err != nil {
+					return full.String(), usage, err
+				}
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return full.String(), usage, fmt.Errorf("ошибка чтения потока: %w", err)
+	}
+
+	if full.Len() == 0 {
+		return "", usage, fmt.Errorf("пустой ответ от OpenAI")
+	}
+
+	// Поток оборвался без [DONE] - возвращаем то, что успели получить
+	return full.String(), usage, nil
+}
@@ -0,0 +1,116 @@
+package ai
+
+import (
+	"context"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/tokens"
+)
+
+// Функции бота, расходующие токены
+const (
+	FeatureChat          = "chat"
+	FeatureWeeklyInsight = "weekly_insight"
+	FeatureFinalInsight  = "final_insight"
+	FeatureNotification  = "notification"
+	FeatureSummary       = "summary"
+	FeatureDiaryTags     = "diary_tags"
+	FeatureDiaryPhoto    = "diary_photo"
+)
+
+// Usage расход токенов одного запроса
+type Usage struct {
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	Duration         time.Duration
+}
+
+// TotalTokens возвращает общее количество токенов запроса
+func (u Usage) TotalTokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// UsageReporter клиент, сообщающий расход токенов каждого запроса
+type UsageReporter interface {
+	GenerateWithUsage(messages []OpenAIMessage) (string, Usage, error)
+	GenerateStreamWithUsage(ctx context.Context, messages []OpenAIMessage, onDelta StreamHandler) (string, Usage, error)
+}
+
+// UsageRecorder учитывает расход токенов (пользователь 0 - системные запросы)
+type UsageRecorder interface {
+	RecordUsage(userID int64, feature string, usage Usage, err error)
+}
+
+// estimateUsage оценивает расход, если провайдер не вернул usage
+func estimateUsage(model string, messages []OpenAIMessage, response string) Usage {
+	usage := Usage{Model: model, CompletionTokens: tokens.Estimate(response)}
+	for _, msg := range messages {
+		usage.PromptTokens += tokens.Estimate(msg.Content) + len(msg.Images)*imageTokens
+	}
+	return usage
+}
+
+// meteredClient приписывает расход токенов пользователю и функции бота
+type meteredClient struct {
+	StreamingAIClient
+	recorder UsageRecorder
+	userID   int64
+	feature  string
+}
+
+// Metered оборачивает клиент для учета токенов запросов пользователя userID в функции feature
+func Metered(client StreamingAIClient, recorder UsageRecorder, userID int64, feature string) StreamingAIClient {
+	if client == nil || recorder == nil {
+		return client
+	}
+	return &meteredClient{
+		StreamingAIClient: client,
+		recorder:          recorder,
+		userID:            userID,
+		feature:           feature,
+	}
+}
+
+// Generate генерирует ответ на промпт с учетом токенов
+func (m *meteredClient) Generate(prompt string) (string, error) {
+	return m.GenerateWithHistory([]OpenAIMessage{{Role: "user", Content: prompt}})
+}
+
+// GenerateWithHistory генерирует ответ с историей с учетом токенов
+func (m *meteredClient) GenerateWithHistory(messages []OpenAIMessage) (string, error) {
+	start := time.Now()
+
+	var response string
+	var usage Usage
+	var err error
+	if reporter, ok := m.StreamingAIClient.(UsageReporter); ok {
+		response, usage, err = reporter.GenerateWithUsage(messages)
+	} else {
+		response, err = m.StreamingAIClient.GenerateWithHistory(messages)
+		usage = estimateUsage(m.GetModel(), messages, response)
+	}
+
+	usage.Duration = time.Since(start)
+	m.recorder.RecordUsage(m.userID, m.feature, usage, err)
+	return response, err
+}
+
+// GenerateStream генерирует потоковый ответ с учетом токенов
+func (m *meteredClient) GenerateStream(ctx context.Context, messages []OpenAIMessage, onDelta StreamHandler) (string, error) {
+	start := time.Now()
+
+	var response string
+	var usage Usage
+	var err error
+	if reporter, ok := m.StreamingAIClient.(UsageReporter); ok {
+		response, usage, err = reporter.GenerateStreamWithUsage(ctx, messages, onDelta)
+	} else {
+		response, err = m.StreamingAIClient.GenerateStream(ctx, messages, onDelta)
+		usage = estimateUsage(m.GetModel(), messages, response)
+	}
+
+	usage.Duration = time.Since(start)
+	m.recorder.RecordUsage(m.userID, m.feature, usage, err)
+	return response, err
+}
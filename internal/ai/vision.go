@@ -0,0 +1,50 @@
+package ai
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// imageTokens оценка расхода токенов на одно изображение, если провайдер не вернул usage
+const imageTokens = 765
+
+// ImageMessage создает сообщение пользователя с текстом и изображением для vision-моделей
+func ImageMessage(text string, image []byte, mimeType string) OpenAIMessage {
+	return OpenAIMessage{
+		Role:    "user",
+		Content: text,
+		Images:  []string{"data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(image)},
+	}
+}
+
+// contentPart часть составного сообщения OpenAI API
+type contentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *imageURL `json:"image_url,omitempty"`
+}
+
+// imageURL изображение в составном сообщении
+type imageURL struct {
+	URL string `json:"url"`
+}
+
+// MarshalJSON кодирует сообщение с изображениями как массив частей content
+func (m OpenAIMessage) MarshalJSON() ([]byte, error) {
+	type plain struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	if len(m.Images) == 0 {
+		return json.Marshal(plain{Role: m.Role, Content: m.Content})
+	}
+
+	parts := []contentPart{{Type: "text", Text: m.Content}}
+	for _, image := range m.Images {
+		parts = append(parts, contentPart{Type: "image_url", ImageURL: &imageURL{URL: image}})
+	}
+	return json.Marshal(struct {
+		Role    string        `json:"role"`
+		Content []contentPart `json:"content"`
+	}{Role: m.Role, Content: parts})
+}
@@ -47,9 +47,6 @@ func (b *EnterpriseBot) handleExerciseWeekCallback(userID int64, week int) error
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("📝 Инструкции для дневника", fmt.Sprintf("admin_week_%d_diary", week)),
 		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🔓 Управление доступом", fmt.Sprintf("admin_week_%d_active", week)),
-		),
 	)
 
 	msg := tgbotapi.NewMessage(userID, response)
@@ -90,9 +87,6 @@ func (b *EnterpriseBot) handleAdminWeekFieldCallback(userID int64, week int, fie
 	case "diary":
 		fieldName = "Инструкции для дневника"
 		example = "/setweek 1 diary Записывайте свои чувства"
-	case "active":
-		fieldName = "Активность недели"
-		example = "/setweek 1 active true"
 	default:
 		msg := tgbotapi.NewMessage(userID, "❌ Неизвестное поле")
 		_, err := b.telegram.Send(msg)
@@ -0,0 +1,55 @@
+package bot
+
+import (
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/exercises"
+	"github.com/godofphonk/lovifyy-bot/internal/history"
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+)
+
+// audienceSource собирает сведения для сегментов аудитории и персональных уведомлений
+// из прогресса, упражнений, пар и дневника
+type audienceSource struct {
+	progress  *exercises.ProgressTracker
+	exercises *exercises.Manager
+	couples   *models.CoupleStorage
+	history   *history.Manager
+}
+
+// CurrentWeek возвращает текущую неделю программы, не начиная ее за пользователя
+func (a audienceSource) CurrentWeek(userID int64) int {
+	return a.progress.PeekWeek(userID)
+}
+
+// PartnerOf возвращает ID партнера (0 - без пары)
+func (a audienceSource) PartnerOf(userID int64) int64 {
+	return a.couples.PartnerOf(userID)
+}
+
+// DiaryEntryCount возвращает количество записей пользователя в дневнике за неделю
+func (a audienceSource) DiaryEntryCount(userID int64, week int) int {
+	entries, err := a.history.QueryDiary(history.DiaryQuery{UserID: userID, Week: week})
+	if err != nil {
+		return 0
+	}
+	return len(entries)
+}
+
+// LastDiaryEntry возвращает время последней записи пользователя в дневнике
+func (a audienceSource) LastDiaryEntry(userID int64) (time.Time, bool) {
+	entries, err := a.history.QueryDiary(history.DiaryQuery{UserID: userID, Limit: 1})
+	if err != nil || len(entries) == 0 {
+		return time.Time{}, false
+	}
+	return entries[len(entries)-1].Timestamp, true
+}
+
+// WeekTitle возвращает заголовок недели программы
+func (a audienceSource) WeekTitle(week int) string {
+	exercise, err := a.exercises.GetWeekExercise(week)
+	if err != nil || exercise == nil {
+		return ""
+	}
+	return exercise.Title
+}
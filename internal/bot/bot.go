@@ -3,10 +3,12 @@ package bot
 import (
     "context"
     "fmt"
+    "path/filepath"
     "time"
 
 	"github.com/godofphonk/lovifyy-bot/internal/ai"
 	"github.com/godofphonk/lovifyy-bot/internal/config"
+	"github.com/godofphonk/lovifyy-bot/internal/content"
 	"github.com/godofphonk/lovifyy-bot/internal/exercises"
 	"github.com/godofphonk/lovifyy-bot/internal/handlers"
 	"github.com/godofphonk/lovifyy-bot/internal/history"
@@ -15,6 +17,7 @@ import (
 	"github.com/godofphonk/lovifyy-bot/internal/middleware"
 	"github.com/godofphonk/lovifyy-bot/internal/models"
 	"github.com/godofphonk/lovifyy-bot/internal/services"
+	"github.com/godofphonk/lovifyy-bot/internal/stt"
 	"github.com/godofphonk/lovifyy-bot/internal/validator"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -24,7 +27,8 @@ import (
 type EnterpriseBot struct {
 	// Core components
 	telegram *tgbotapi.BotAPI
-	ai       *ai.OpenAIClient
+	ai       ai.StreamingAIClient
+	vision   ai.StreamingAIClient // Модель для описания фото из дневника (nil - отключено)
 	
 	// Configuration and logging
 	config *config.Config
@@ -37,6 +41,11 @@ type EnterpriseBot struct {
 	userManager         *models.UserManager
 	historyManager      *history.Manager
 	exerciseManager     *exercises.Manager
+	coupleStorage       *models.CoupleStorage
+	progressTracker     *exercises.ProgressTracker
+	tokenUsage          *services.TokenUsageService
+	transcriber         stt.Transcriber
+	content             *content.Store
 	notificationService *services.NotificationService
 	
 	// Handlers and middleware
@@ -61,15 +70,86 @@ func NewEnterpriseBot(cfg *config.Config, log *logger.Logger) (*EnterpriseBot, e
 	log.WithField("bot_username", telegram.Self.UserName).Info("Telegram bot authorized successfully")
 
 	// Инициализируем AI клиент
-	aiClient := ai.NewOpenAIClient("gpt-4o-mini")
+	aiClient, err := ai.NewClient(cfg.OpenAI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AI client: %w", err)
+	}
+	log.WithFields(map[string]interface{}{
+		"provider": cfg.OpenAI.Provider,
+		"model":    aiClient.GetModel(),
+	}).Info("AI client initialized")
+
+	// Инициализируем vision-модель для описания фото из дневника
+	var visionClient ai.StreamingAIClient
+	if cfg.OpenAI.VisionModel != "" {
+		visionConfig := cfg.OpenAI
+		visionConfig.Model = cfg.OpenAI.VisionModel
+		if visionClient, err = ai.NewClient(visionConfig); err != nil {
+			return nil, fmt.Errorf("failed to create vision AI client: %w", err)
+		}
+		log.WithField("model", visionClient.GetModel()).Info("Vision AI client initialized")
+	}
+
+	// Инициализируем распознавание речи для голосовых записей дневника
+	transcriber, err := stt.NewTranscriber(cfg.STT)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create speech-to-text client: %w", err)
+	}
+	if transcriber != nil {
+		log.WithField("provider", cfg.STT.Provider).Info("Speech-to-text initialized")
+	}
 
 	// Инициализируем менеджеры
 	userManager := models.NewUserManager([]int64{1805441944, 1243795198}) // Список админов
-	historyManager := history.NewManager()
+	statesFile := filepath.Join(cfg.Database.DataDir, "states.json")
+	if err := userManager.EnablePersistence(statesFile, cfg.Database.StateTTL); err != nil {
+		log.WithError(err).Warn("Failed to restore user states, starting with empty states")
+	}
+	historyStore, err := history.NewStore(cfg.Database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create history store: %w", err)
+	}
+	historyManager := history.NewManagerWithStore(historyStore)
+	historyManager.SetSummaryOptions(history.SummaryOptions{
+		TokenThreshold: cfg.OpenAI.SummaryTokenThreshold,
+		KeepTurns:      cfg.OpenAI.SummaryKeepTurns,
+	})
+	log.WithField("driver", cfg.Database.Driver).Info("History store initialized")
 	exerciseManager := exercises.NewManager()
+	coupleStorage := models.NewCoupleStorage(cfg.Database.DataDir)
+	historyManager.SetCoupleResolver(coupleStorage)
+	progressTracker := exercises.NewProgressTracker(cfg.Database.DataDir, coupleStorage)
+	contentStore := content.NewStore(cfg.Database.DataDir, func(key string) string {
+		switch key {
+		case content.KeySystemPrompt:
+			return cfg.Telegram.SystemPrompt
+		case content.KeyWelcome:
+			return content.DefaultWelcome
+		}
+		if week, field, ok := content.ParseWeekKey(key); ok {
+			value, _ := exerciseManager.GetWeekField(week, field)
+			return value
+		}
+		return ""
+	})
 	
+	// Инициализируем метрики
+	var metricsInstance *metrics.Metrics
+	metricsInstance = metrics.NewMetrics()
+
 	// Инициализируем сервисы
-	notificationService := services.NewNotificationService(telegram, aiClient)
+	tokenUsage := services.NewTokenUsageService(cfg.Database.DataDir, services.TokenBudget{
+		Daily:   cfg.OpenAI.DailyTokenBudget,
+		Monthly: cfg.OpenAI.MonthlyTokenBudget,
+	}, metricsInstance)
+	notificationService := services.NewNotificationService(telegram, ai.Metered(aiClient, tokenUsage, 0, ai.FeatureNotification))
+	notificationService.SetBroadcastRate(cfg.Telegram.BroadcastRate)
+	notificationService.SetAudienceSource(audienceSource{
+		progress:  progressTracker,
+		exercises: exerciseManager,
+		couples:   coupleStorage,
+		history:   historyManager,
+	})
 	
 	// Инициализируем middleware
 	rateLimitMiddleware := middleware.NewRateLimitMiddleware(userManager, time.Minute)
@@ -77,10 +157,6 @@ func NewEnterpriseBot(cfg *config.Config, log *logger.Logger) (*EnterpriseBot, e
 		MaxMessageLength: 4000, // Увеличиваем лимит сообщений
 		SanitizeHTML:     true,
 	})
-	
-	// Инициализируем метрики
-	var metricsInstance *metrics.Metrics
-	metricsInstance = metrics.NewMetrics()
 
 	// Создаем контекст
 	ctx, cancel := context.WithCancel(context.Background())
@@ -89,13 +165,19 @@ func NewEnterpriseBot(cfg *config.Config, log *logger.Logger) (*EnterpriseBot, e
 	bot := &EnterpriseBot{
 		telegram:            telegram,
 		ai:                  aiClient,
+		vision:              visionClient,
 		config:              cfg,
 		logger:              log,
 		metrics:             metricsInstance,
 		userManager:         userManager,
 		historyManager:      historyManager,
 		exerciseManager:     exerciseManager,
+		coupleStorage:       coupleStorage,
+		progressTracker:     progressTracker,
 		notificationService: notificationService,
+		tokenUsage:          tokenUsage,
+		transcriber:         transcriber,
+		content:             contentStore,
 		rateLimitMiddleware: rateLimitMiddleware,
 		validator:          validator,
 		ctx:                ctx,
@@ -104,7 +186,7 @@ func NewEnterpriseBot(cfg *config.Config, log *logger.Logger) (*EnterpriseBot, e
 
 	// Инициализируем обработчик команд
 	bot.commandHandler = handlers.NewCommandHandler(
-		telegram, userManager, exerciseManager, notificationService, historyManager, aiClient,
+		telegram, userManager, exerciseManager, notificationService, historyManager, coupleStorage, progressTracker, tokenUsage, contentStore, aiClient,
 	)
 
 	return bot, nil
@@ -124,6 +206,14 @@ func (b *EnterpriseBot) Start() error {
 		go b.startMetricsCollection()
 	}
 
+	// Запускаем очистку истекших состояний
+	go b.startStateCleanup()
+
+	// Запускаем очередь рассылок (продолжает рассылки, прерванные перезапуском)
+	// и отправку запланированных уведомлений
+	go b.notificationService.StartBroadcasts(b.ctx.Done())
+	go b.notificationService.StartScheduler(b.ctx.Done())
+
 	// Настраиваем получение обновлений
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 30
@@ -150,5 +240,9 @@ func (b *EnterpriseBot) Start() error {
 func (b *EnterpriseBot) Stop() error {
 	b.logger.Info("Stopping enterprise bot...")
 	b.cancel()
+
+	if err := b.historyManager.Close(); err != nil {
+		return fmt.Errorf("failed to close history store: %w", err)
+	}
 	return nil
 }
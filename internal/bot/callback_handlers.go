@@ -3,6 +3,8 @@ package bot
 import (
 	"strings"
 
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -39,6 +41,19 @@ func (b *EnterpriseBot) handleCallbackQuery(update tgbotapi.Update) error {
         return b.commandHandler.HandleCallback(update)
     case data == "notifications_menu":
         return b.commandHandler.HandleCallback(update)
+    case data == "prompt" || data == "setprompt_menu" || data == "welcome" || data == "setwelcome_menu":
+        return b.commandHandler.HandleCallback(update)
+    case strings.HasPrefix(data, "content_"):
+        // Делегируем историю версий текстов в CommandHandler
+        return b.commandHandler.HandleCallback(update)
+    case data == "pair" || strings.HasPrefix(data, "pair_"):
+        return b.commandHandler.HandleCallback(update)
+    case strings.HasPrefix(data, "tz_"):
+        // Делегируем выбор часового пояса в CommandHandler
+        return b.commandHandler.HandleCallback(update)
+    case strings.HasPrefix(data, "settings_"):
+        // Делегируем настройки уведомлений в CommandHandler
+        return b.commandHandler.HandleCallback(update)
     case data == "schedule_notification":
         return b.commandHandler.HandleCallback(update)
     case data == "view_notifications":
@@ -57,6 +72,13 @@ func (b *EnterpriseBot) handleCallbackQuery(update tgbotapi.Update) error {
         return b.commandHandler.HandleCallback(update)
     case strings.HasPrefix(data, "schedule_type_"):
         return b.commandHandler.HandleCallback(update)
+    case strings.HasPrefix(data, "cdraft_"):
+        // Делегируем черновики кастомных уведомлений в CommandHandler
+        return b.commandHandler.HandleCallback(update)
+    case strings.HasPrefix(data, "schedule_aud_"):
+        return b.commandHandler.HandleCallback(update)
+    case strings.HasPrefix(data, "schedule_repeat_"):
+        return b.commandHandler.HandleCallback(update)
     case strings.HasPrefix(data, "schedule_custom_time_"):
         return b.commandHandler.HandleCallback(update)
     case strings.HasPrefix(data, "schedule_custom_date"):
@@ -91,9 +113,19 @@ func (b *EnterpriseBot) handleCallbackQuery(update tgbotapi.Update) error {
     case strings.HasPrefix(data, "diary_view_"):
         // Делегируем обработку просмотра записей дневника в CommandHandler
         return b.commandHandler.HandleCallback(update)
+    case strings.HasPrefix(data, "diary_entry_") || strings.HasPrefix(data, "diary_full_") || strings.HasPrefix(data, "diary_file_") || strings.HasPrefix(data, "diary_mood_") ||
+        strings.HasPrefix(data, "diary_edit_") || strings.HasPrefix(data, "diary_del"):
+        // Делегируем просмотр, настроение, исправление и удаление отдельных записей в CommandHandler
+        return b.commandHandler.HandleCallback(update)
     case data == "main_menu":
         // Делегируем обработку главного меню в CommandHandler
         return b.commandHandler.HandleCallback(update)
+    case strings.HasPrefix(data, models.DeepLinkDiary) || strings.HasPrefix(data, models.DeepLinkExercise):
+        // Делегируем обработку кнопок из уведомлений в CommandHandler
+        return b.commandHandler.HandleCallback(update)
+    case strings.HasPrefix(data, "locked_week_"):
+        // Делегируем обработку закрытых недель в CommandHandler
+        return b.commandHandler.HandleCallback(update)
     case strings.HasPrefix(data, "week_"):
         // Делегируем обработку недель в CommandHandler
         return b.commandHandler.HandleCallback(update)
@@ -35,8 +35,40 @@ func (b *EnterpriseBot) handleCommand(update tgbotapi.Update) error {
 		return b.handleDiaryMode(userID)
 	case "advice":
 		return b.handleExercises(userID)
+	case "pair":
+		return b.commandHandler.HandlePair(update)
+	case "mood":
+		return b.commandHandler.HandleMood(update)
+	case "timezone":
+		return b.commandHandler.HandleTimezone(update)
+	case "settings":
+		return b.commandHandler.HandleSettings(update)
 	case "adminhelp":
 		return b.commandHandler.HandleAdmin(update)
+	case "progress":
+		return b.commandHandler.HandleProgress(update)
+	case "budget":
+		return b.commandHandler.HandleBudget(update)
+	case "summary":
+		return b.commandHandler.HandleSummary(update)
+	case "setprompt":
+		return b.commandHandler.HandleSetPrompt(update)
+	case "prompt":
+		return b.commandHandler.HandleShowPrompt(update)
+	case "setwelcome":
+		return b.commandHandler.HandleSetWelcome(update)
+	case "welcome":
+		return b.commandHandler.HandleShowWelcome(update)
+	case "setweek":
+		return b.commandHandler.HandleSetWeek(update)
+	case "versions":
+		return b.commandHandler.HandleVersions(update)
+	case "repeat":
+		return b.commandHandler.HandleRepeat(update)
+	case "segment":
+		return b.commandHandler.HandleSegment(update)
+	case "template":
+		return b.commandHandler.HandleTemplate(update)
 	case "metrics":
 		return b.handleMetricsCommand(update)
 	default:
@@ -71,6 +103,10 @@ func (b *EnterpriseBot) setupCommands() error {
 		{Command: "advice", Description: "💑 Упражнение недели"},
 		{Command: "diary", Description: "📝 Мини-дневник"},
 		{Command: "chat", Description: "💒 Задать вопрос о отношениях"},
+		{Command: "pair", Description: "💞 Связать аккаунт с партнером"},
+		{Command: "mood", Description: "📈 Настроение по неделям"},
+		{Command: "timezone", Description: "🌍 Часовой пояс"},
+		{Command: "settings", Description: "⚙️ Настройки уведомлений"},
 	}
 
 	config := tgbotapi.NewSetMyCommands(commands...)
@@ -21,7 +21,9 @@ func (b *EnterpriseBot) handleDiaryTypeCallback(userID int64, data string) error
 	
 	// Устанавливаем состояние для записи в дневник
 	state := fmt.Sprintf("diary_entry_%s_%s", entryType, gender)
-	b.userManager.SetState(userID, state)
+	if err := b.userManager.SetState(userID, state); err != nil {
+		return err
+	}
 	
 	genderName := "парня"
 	genderEmoji := "👨"
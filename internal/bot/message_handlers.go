@@ -1,12 +1,16 @@
 package bot
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/godofphonk/lovifyy-bot/internal/ai"
+	"github.com/godofphonk/lovifyy-bot/internal/content"
 	"github.com/godofphonk/lovifyy-bot/internal/history"
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+	"github.com/godofphonk/lovifyy-bot/internal/services"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -22,6 +26,27 @@ func (b *EnterpriseBot) handleMessage(update tgbotapi.Update) error {
 	// Обновляем активность пользователя
 	b.notificationService.UpdateUserActivity(userID)
 
+	// Фото и документы принимаются там, где администратор готовит уведомление
+	if media := services.MessageMedia(update.Message); media != nil {
+		caption := services.FormatEntities(update.Message.Caption, update.Message.CaptionEntities)
+		state := b.userManager.GetUserState(userID)
+		switch state.Kind {
+		case models.StateCustomNotification:
+			return b.handleCustomNotificationMedia(userID, caption, *media)
+		case models.StateCustomNotificationSchedule, models.StateScheduleCustomText, models.StateCustomDraftEdit, models.StateCustomDraftMedia:
+			return b.handleCustomDraftMedia(userID, caption, *media, state)
+		case models.StateDiary:
+			return b.handleDiaryMedia(userID, update.Message.Caption, *media, state)
+		}
+	}
+
+	// Голосовые и аудиосообщения в дневнике расшифровываются в текст записи
+	if voice := messageVoice(update.Message); voice != nil {
+		if state := b.userManager.GetUserState(userID); state.Kind == models.StateDiary {
+			return b.handleDiaryVoice(userID, *voice, state)
+		}
+	}
+
 	// Валидируем сообщение
 	if validation := b.validator.ValidateMessage(messageText); !validation.Valid {
 		b.logger.WithFields(map[string]interface{}{
@@ -50,37 +75,43 @@ func (b *EnterpriseBot) handleMessage(update tgbotapi.Update) error {
 	}
 
 	// Получаем состояние пользователя
-	state := b.userManager.GetState(userID)
-
-	switch state {
-	case "chat":
-		return b.handleChatMessage(userID, sanitizedText)
-	case "diary":
-		return b.handleDiaryMessage(userID, sanitizedText)
-	case "custom_notification":
+	state := b.userManager.GetUserState(userID)
+
+	switch state.Kind {
+	case models.StateChat:
+		return b.handleChatMessage(userID, update.Message.From.UserName, sanitizedText)
+	case models.StateDiary:
+		return b.handleDiaryMessage(userID, sanitizedText, state)
+	case models.StateDiaryEdit:
+		return b.handleDiaryEditMessage(userID, sanitizedText, state)
+	case models.StateCustomNotification:
 		return b.handleCustomNotificationMessage(userID, sanitizedText)
-	case "custom_notification_schedule":
-		return b.handleCustomNotificationScheduleMessage(userID, sanitizedText)
-	case "schedule_custom_text":
-		return b.handleScheduleCustomTextMessage(userID, sanitizedText)
+	case models.StateCustomNotificationSchedule, models.StateScheduleCustomText, models.StateCustomDraftEdit:
+		// Текст уведомления сохраняет форматирование Telegram в HTML разметке
+		return b.handleCustomDraftMessage(userID, services.FormatEntities(messageText, update.Message.Entities), state)
+	case models.StateCustomDraftMedia:
+		msg := tgbotapi.NewMessage(userID, "📎 Жду фото или документ. Чтобы вернуться к черновику, нажмите «❌ Отмена».")
+		_, err := b.telegram.Send(msg)
+		return err
+	case models.StateCustomDraftTime:
+		return b.handleCustomDraftTimeMessage(userID, sanitizedText, state)
+	case models.StateCustomTime:
+		return b.handleCustomTimeMessage(userID, sanitizedText, state)
+	case models.StateCustomDate:
+		return b.handleCustomDateMessage(userID, sanitizedText)
+	case models.StateTimezone:
+		return b.commandHandler.HandleTimezoneInput(userID, sanitizedText)
+	case models.StateQuietHours:
+		return b.commandHandler.HandleQuietHoursInput(userID, sanitizedText)
+	case models.StateReminderTime:
+		return b.commandHandler.HandleReminderTimeInput(userID, sanitizedText)
 	default:
-		// Проверяем, не является ли это состоянием дневника
-		if strings.HasPrefix(state, "diary_") {
-			return b.handleDiaryMessage(userID, sanitizedText)
-		}
-		// Проверяем, не является ли это состоянием кастомного времени
-		if strings.HasPrefix(state, "custom_time_") {
-			return b.handleCustomTimeMessage(userID, sanitizedText, state)
-		}
-		if state == "custom_date" {
-			return b.handleCustomDateMessage(userID, sanitizedText)
-		}
 		return b.suggestMode(userID)
 	}
 }
 
 // handleChatMessage обрабатывает сообщения в режиме чата
-func (b *EnterpriseBot) handleChatMessage(userID int64, messageText string) error {
+func (b *EnterpriseBot) handleChatMessage(userID int64, username, messageText string) error {
 	startTime := time.Now()
 	
 	// Проверяем доступность AI
@@ -90,15 +121,27 @@ func (b *EnterpriseBot) handleChatMessage(userID int64, messageText string) erro
 		return err
 	}
 
-	// Получаем историю с системным промптом
-	historyMessages, err := b.historyManager.GetOpenAIHistory(userID, b.config.Telegram.SystemPrompt, 10)
+	// Проверяем бюджет токенов пользователя
+	if err := b.tokenUsage.CheckBudget(userID); err != nil {
+		var exceeded *services.BudgetExceededError
+		if errors.As(err, &exceeded) {
+			msg := tgbotapi.NewMessage(userID, budgetExceededText(exceeded))
+			_, err := b.telegram.Send(msg)
+			return err
+		}
+		b.logger.WithError(err).Warn("Failed to check token budget")
+	}
+
+	// Получаем историю с системным промптом и содержанием старых разговоров
+	systemPrompt := b.content.Get(content.KeySystemPrompt)
+	historyMessages, err := b.historyManager.GetChatContext(userID, systemPrompt)
 	if err != nil {
 		b.logger.WithError(err).Error("Failed to get chat history")
 		// Продолжаем без истории - создаем только системный промпт
 		historyMessages = []history.OpenAIMessage{
 			{
 				Role:    "system",
-				Content: b.config.Telegram.SystemPrompt,
+				Content: systemPrompt,
 			},
 		}
 	}
@@ -118,29 +161,25 @@ func (b *EnterpriseBot) handleChatMessage(userID int64, messageText string) erro
 		}
 	}
 
-	// Генерируем ответ с историей и системным промптом
-	response, err := b.ai.GenerateWithHistory(aiMessages)
+	// Генерируем ответ потоково, редактируя сообщение по мере поступления текста
+	response, err := b.streamChatReply(ai.Metered(b.ai, b.tokenUsage, userID, ai.FeatureChat), userID, aiMessages)
 	if err != nil {
 		b.logger.WithError(err).Error("Failed to generate AI response")
 		
 		if b.metrics != nil {
 			b.metrics.RecordError("ai_generation", "openai")
 		}
-		
-		msg := tgbotapi.NewMessage(userID, "❌ Извините, произошла ошибка при генерации ответа")
-		_, err := b.telegram.Send(msg)
-		return err
+		return nil
 	}
 
 	// Сохраняем в историю
-	if err := b.historyManager.SaveMessage(userID, messageText, response, "chat", "user"); err != nil {
+	if err := b.historyManager.SaveMessage(userID, username, messageText, response, b.ai.GetModel()); err != nil {
 		b.logger.WithError(err).Error("Failed to save message to history")
+	} else {
+		// Сжимаем старые обмены в фоне, не задерживая ответ
+		go b.summarizeChat(userID)
 	}
 
-	// Отправляем ответ
-	msg := tgbotapi.NewMessage(userID, response)
-	_, err = b.telegram.Send(msg)
-
 	// Записываем метрики
 	if b.metrics != nil {
 		duration := time.Since(startTime)
@@ -149,101 +188,209 @@ func (b *EnterpriseBot) handleChatMessage(userID int64, messageText string) erro
 		b.metrics.RecordMessageLength("bot", float64(len(response)))
 	}
 
-	return err
+	return nil
 }
 
-// handleDiaryMessage обрабатывает сообщения в режиме дневника
-func (b *EnterpriseBot) handleDiaryMessage(userID int64, messageText string) error {
-	state := b.userManager.GetState(userID)
-	
-	// Парсим состояние: diary_<gender>_<week>_<type>
-	if strings.HasPrefix(state, "diary_") {
-		parts := strings.Split(state, "_")
-		if len(parts) >= 4 {
-			gender := parts[1]
-			week := parts[2]
-			diaryType := parts[3]
-			
-			// Конвертируем неделю в число
-			weekNum := 1
-			switch week {
-			case "1":
-				weekNum = 1
-			case "2":
-				weekNum = 2
-			case "3":
-				weekNum = 3
-			case "4":
-				weekNum = 4
-			}
-			
-			// Сохраняем запись в дневник с полной информацией
-			err := b.historyManager.SaveDiaryEntryWithGender(userID, "user", messageText, weekNum, diaryType, gender)
-			if err != nil {
-				b.logger.WithError(err).Error("Failed to save diary entry")
-				msg := tgbotapi.NewMessage(userID, "❌ Ошибка при сохранении записи")
-				_, err := b.telegram.Send(msg)
-				return err
-			}
-			
-			// Определяем эмодзи и текст для ответа
-			var genderEmoji string
-			var typeEmoji string
-			var typeText string
-			
-			if gender == "male" {
-				genderEmoji = "👨"
-			} else {
-				genderEmoji = "👩"
-			}
-			
-			switch diaryType {
-			case "personal":
-				typeEmoji = "💭"
-				typeText = "Личные мысли"
-			case "questions":
-				typeEmoji = "❓"
-				typeText = "Ответы на вопросы"
-			case "joint":
-				typeEmoji = "👫"
-				typeText = "Ответы на совместные вопросы"
-			default:
-				typeEmoji = "📝"
-				typeText = "Запись"
-			}
-			
-			response := fmt.Sprintf("✅ Запись сохранена!\n\n"+
-				"%s %s - Неделя %s\n"+
-				"%s %s\n\n"+
-				"📝 Продолжайте писать или используйте /start для возврата в главное меню.", 
-				genderEmoji, 
-				map[string]string{"male": "Парень", "female": "Девушка"}[gender], 
-				week, typeEmoji, typeText)
-			
-			msg := tgbotapi.NewMessage(userID, response)
-			_, err = b.telegram.Send(msg)
-			return err
+// summarizeChat обновляет содержание переписки, если история превысила порог
+func (b *EnterpriseBot) summarizeChat(userID int64) {
+	client := ai.Metered(b.ai, b.tokenUsage, userID, ai.FeatureSummary)
+	updated, err := b.historyManager.SummarizeIfNeeded(userID, func(messages []history.OpenAIMessage) (string, error) {
+		aiMessages := make([]ai.OpenAIMessage, len(messages))
+		for i, msg := range messages {
+			aiMessages[i] = ai.OpenAIMessage{Role: msg.Role, Content: msg.Content}
 		}
+		return client.GenerateWithHistory(aiMessages)
+	})
+	if err != nil {
+		b.logger.WithUserID(userID).WithError(err).Warn("Failed to summarize chat history")
+		return
 	}
-	
-	// Если состояние просто "diary" (старый формат)
-	if state == "diary" {
-		// Сохраняем как общую запись
-		err := b.historyManager.SaveDiaryEntry(userID, "user", messageText, 1, "general")
+	if updated {
+		b.logger.WithUserID(userID).Info("Chat history summarized")
+	}
+}
+
+// budgetExceededText формирует дружелюбное сообщение об исчерпанном лимите
+func budgetExceededText(exceeded *services.BudgetExceededError) string {
+	if exceeded.Period == services.BudgetPeriodMonth {
+		return fmt.Sprintf("💌 Кажется, в этом месяце мы с вами очень много общались — лимит вопросов на месяц исчерпан.\n\n"+
+			"Я снова смогу отвечать с %s. А пока загляните в упражнение недели или мини-дневник — они всегда доступны. 🫶🏻",
+			exceeded.ResetAt.Format("02.01.2006"))
+	}
+	return "💌 На сегодня лимит вопросов исчерпан — давайте немного передохнём.\n\n" +
+		"Я снова смогу отвечать завтра. А пока можно записать мысли в мини-дневник или вернуться к упражнению недели. 🫶🏻"
+}
+
+// handleDiaryMessage обрабатывает сообщения в режиме дневника
+func (b *EnterpriseBot) handleDiaryMessage(userID int64, messageText string, state models.State) error {
+	// Состояние без контекста - старый формат "diary", сохраняем как общую запись
+	if state.Diary == nil {
+		entry, err := b.historyManager.AddDiaryEntry(b.diaryEntryForState(userID, messageText, state))
 		if err != nil {
 			b.logger.WithError(err).Error("Failed to save diary entry")
 			msg := tgbotapi.NewMessage(userID, "❌ Ошибка при сохранении записи")
 			_, err := b.telegram.Send(msg)
 			return err
 		}
+		b.notificationService.RecordEngagement(userID, services.EngagementDiary)
+		b.tagDiaryEntryAsync(entry)
 		
-		msg := tgbotapi.NewMessage(userID, "📝 Записано! Продолжайте писать или используйте /start для возврата в главное меню.")
+		msg := tgbotapi.NewMessage(userID, "📝 Записано! Продолжайте писать или используйте /start для возврата в главное меню."+
+			diaryTagsLine(entry)+moodQuestion)
+		msg.ReplyMarkup = b.commandHandler.DiaryMoodKeyboard(entry.ID)
 		_, err = b.telegram.Send(msg)
 		return err
 	}
+
+	// Сохраняем запись в дневник с полной информацией
+	entry, err := b.historyManager.AddDiaryEntry(b.diaryEntryForState(userID, messageText, state))
+	if err != nil {
+		b.logger.WithError(err).Error("Failed to save diary entry")
+		msg := tgbotapi.NewMessage(userID, "❌ Ошибка при сохранении записи")
+		_, err := b.telegram.Send(msg)
+		return err
+	}
+	b.notificationService.RecordEngagement(userID, services.EngagementDiary)
+	b.tagDiaryEntryAsync(entry)
+
+	gender := entry.Gender
+	weekNum := entry.Week
+	diaryType := entry.Type
+	
+	// Определяем эмодзи и текст для ответа
+	var genderEmoji string
+	var typeEmoji string
+	var typeText string
+	
+	if gender == "male" {
+		genderEmoji = "👨"
+	} else {
+		genderEmoji = "👩"
+	}
+	
+	switch diaryType {
+	case "personal":
+		typeEmoji = "💭"
+		typeText = "Личные мысли"
+	case "questions":
+		typeEmoji = "❓"
+		typeText = "Ответы на вопросы"
+	case "joint":
+		typeEmoji = "👫"
+		typeText = "Ответы на совместные вопросы"
+	default:
+		typeEmoji = "📝"
+		typeText = "Запись"
+	}
+	
+	response := fmt.Sprintf("✅ Запись сохранена!\n\n"+
+		"%s %s - Неделя %d\n"+
+		"%s %s\n\n"+
+		"📝 Продолжайте писать или используйте /start для возврата в главное меню.", 
+		genderEmoji, 
+		map[string]string{"male": "Парень", "female": "Девушка"}[gender], 
+		weekNum, typeEmoji, typeText)
 	
-	// Неизвестное состояние дневника
-	msg := tgbotapi.NewMessage(userID, "❓ Неизвестное состояние дневника. Используйте /start для возврата в главное меню.")
-	_, err := b.telegram.Send(msg)
+	msg := tgbotapi.NewMessage(userID, response+diaryTagsLine(entry)+moodQuestion)
+	msg.ReplyMarkup = b.commandHandler.DiaryMoodKeyboard(entry.ID)
+	_, err = b.telegram.Send(msg)
+
+	if diaryType == "joint" {
+		b.checkWeekCompletion(userID, weekNum)
+	}
+	return err
+}
+
+// diaryEntryForState собирает запись дневника по контексту состояния
+func (b *EnterpriseBot) diaryEntryForState(userID int64, text string, state models.State) history.DiaryEntry {
+	// Состояние без контекста - старый формат "diary", сохраняем как общую запись
+	if state.Diary == nil {
+		return history.DiaryEntry{UserID: userID, Username: "user", Entry: text, Week: 1, Type: "general"}
+	}
+
+	gender := state.Diary.Gender
+	// В паре запись автоматически относится к гендеру автора
+	if pairedGender := b.coupleStorage.GenderOf(userID); pairedGender != "" {
+		gender = pairedGender
+	}
+	return history.DiaryEntry{
+		UserID:   userID,
+		Username: "user",
+		Entry:    text,
+		Week:     state.Diary.Week,
+		Type:     state.Diary.Type,
+		Gender:   gender,
+	}
+}
+
+// moodQuestion приглашение оценить настроение под подтверждением записи
+const moodQuestion = "\n\nКак вы себя чувствуете? Отметьте настроение (по желанию):"
+
+// diaryTagsLine строка с тегами, отмеченными в записи через #
+func diaryTagsLine(entry history.DiaryEntry) string {
+	if len(entry.Tags) == 0 {
+		return ""
+	}
+	return "\n🏷 #" + strings.Join(entry.Tags, " #")
+}
+
+// handleDiaryEditMessage сохраняет исправленный текст записи и заново выделяет ее темы
+func (b *EnterpriseBot) handleDiaryEditMessage(userID int64, text string, state models.State) error {
+	err := b.commandHandler.HandleDiaryEditInput(userID, text, state)
+	if state.Diary == nil || state.Diary.EntryID == "" {
+		return err
+	}
+	// Теги без #хэштегов очищены при исправлении, модель выделяет их по новому тексту
+	if entry, getErr := b.historyManager.GetDiaryEntry(userID, state.Diary.EntryID); getErr == nil && entry.UserID == userID {
+		b.tagDiaryEntryAsync(*entry)
+	}
 	return err
 }
+
+// tagDiaryEntryAsync выделяет темы записи моделью в фоне, если пользователь не отметил их сам
+func (b *EnterpriseBot) tagDiaryEntryAsync(entry history.DiaryEntry) {
+	if b.ai == nil || len(entry.Tags) > 0 {
+		return
+	}
+	go b.tagDiaryEntry(entry)
+}
+
+// tagDiaryEntry выделяет темы записи моделью
+func (b *EnterpriseBot) tagDiaryEntry(entry history.DiaryEntry) {
+	client := ai.Metered(b.ai, b.tokenUsage, entry.UserID, ai.FeatureDiaryTags)
+	if _, err := b.historyManager.TagDiaryEntry(entry.UserID, entry.ID, client.Generate); err != nil {
+		b.logger.WithUserID(entry.UserID).WithError(err).Warn("Failed to tag diary entry")
+	}
+}
+
+// checkWeekCompletion отмечает неделю завершенной, когда все участники пары ответили на совместные вопросы
+func (b *EnterpriseBot) checkWeekCompletion(userID int64, weekNum int) {
+	members := b.coupleStorage.Members(userID)
+	for _, memberID := range members {
+		entries, err := b.historyManager.QueryDiary(history.DiaryQuery{UserID: memberID, Week: weekNum, Type: "joint", Limit: 1})
+		if err != nil {
+			b.logger.WithError(err).Error("Failed to check joint answers")
+			return
+		}
+		if len(entries) == 0 {
+			return
+		}
+	}
+
+	unlocked, err := b.progressTracker.MarkWeekCompleted(userID, weekNum)
+	if err != nil {
+		b.logger.WithError(err).Error("Failed to update program progress")
+		return
+	}
+	if !unlocked {
+		return
+	}
+
+	text := fmt.Sprintf("🔓 Вы завершили совместные вопросы %d недели — открыта неделя %d! 💑\n\n"+
+		"Загляните в «Упражнение недели», чтобы продолжить.", weekNum, b.progressTracker.CurrentWeek(userID))
+	for _, memberID := range members {
+		if _, err := b.telegram.Send(tgbotapi.NewMessage(memberID, text)); err != nil {
+			b.logger.WithError(err).WithField("user_id", memberID).Warn("Failed to send week unlock notification")
+		}
+	}
+}
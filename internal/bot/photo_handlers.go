@@ -0,0 +1,127 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/ai"
+	"github.com/godofphonk/lovifyy-bot/internal/history"
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+	"github.com/godofphonk/lovifyy-bot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// photoDownloadTimeout максимальное время загрузки фото для описания
+	photoDownloadTimeout = time.Minute
+	// maxPhotoSize больше этого фото не отправляется vision-модели
+	maxPhotoSize = 10 << 20
+)
+
+// handleDiaryMedia сохраняет фото с подписью как запись дневника
+func (b *EnterpriseBot) handleDiaryMedia(userID int64, caption string, media models.NotificationMedia, state models.State) error {
+	if b.metrics != nil {
+		b.metrics.RecordMessage(media.Kind, "received")
+	}
+	if media.Kind != models.MediaPhoto {
+		msg := tgbotapi.NewMessage(userID, "📷 В дневник можно добавить фото. Отправьте его как фотографию, а не файлом.")
+		_, err := b.telegram.Send(msg)
+		return err
+	}
+
+	if caption != "" {
+		if validation := b.validator.ValidateMessage(caption); !validation.Valid {
+			b.logger.WithFields(map[string]interface{}{
+				"user_id": userID,
+				"errors":  validation.Errors,
+			}).Warn("Invalid photo caption received")
+			msg := tgbotapi.NewMessage(userID, "❌ Подпись к фото содержит недопустимый контент")
+			_, err := b.telegram.Send(msg)
+			return err
+		}
+		caption = b.validator.SanitizeMessage(caption)
+	}
+
+	entry := b.diaryEntryForState(userID, caption, state)
+	entry.Attachment = &history.DiaryAttachment{Kind: history.AttachmentPhoto, FileID: media.FileID}
+	entry, err := b.historyManager.AddDiaryEntry(entry)
+	if err != nil {
+		b.logger.WithError(err).Error("Failed to save diary entry")
+		msg := tgbotapi.NewMessage(userID, "❌ Ошибка при сохранении записи")
+		_, err := b.telegram.Send(msg)
+		return err
+	}
+	b.notificationService.RecordEngagement(userID, services.EngagementDiary)
+	b.describeDiaryPhotoAsync(entry)
+
+	response := fmt.Sprintf("📷 Фото сохранено в дневник - Неделя %d.", entry.Week)
+	if entry.Entry == "" {
+		response += "\n\nЧтобы добавить подпись, нажмите «✏️ Подпись». Посмотреть фото можно в «👀 Посмотреть записи»."
+	} else {
+		response += "\n\nПосмотреть фото можно в «👀 Посмотреть записи»."
+	}
+	msg := tgbotapi.NewMessage(userID, response+diaryTagsLine(entry)+moodQuestion)
+	keyboard := b.commandHandler.DiaryMoodKeyboard(entry.ID)
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("✏️ Подпись", "diary_edit_"+entry.ID),
+		tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить", "diary_del_"+entry.ID),
+	))
+	msg.ReplyMarkup = keyboard
+	_, err = b.telegram.Send(msg)
+
+	if entry.Type == "joint" {
+		b.checkWeekCompletion(userID, entry.Week)
+	}
+	return err
+}
+
+// describeDiaryPhotoAsync описывает фото vision-моделью в фоне, затем выделяет темы записи
+func (b *EnterpriseBot) describeDiaryPhotoAsync(entry history.DiaryEntry) {
+	if b.vision == nil {
+		b.tagDiaryEntryAsync(entry)
+		return
+	}
+	go func() {
+		client := ai.Metered(b.vision, b.tokenUsage, entry.UserID, ai.FeatureDiaryPhoto)
+		_, err := b.historyManager.DescribeDiaryPhoto(entry.UserID, entry.ID, func(prompt string) (string, error) {
+			image, err := b.downloadPhoto(entry.Attachment.FileID)
+			if err != nil {
+				return "", err
+			}
+			return client.GenerateWithHistory([]ai.OpenAIMessage{ai.ImageMessage(prompt, image, http.DetectContentType(image))})
+		})
+		if err != nil {
+			b.logger.WithUserID(entry.UserID).WithError(err).Warn("Failed to describe diary photo")
+		}
+
+		// Темы выделяются после описания, чтобы учесть содержание фото
+		if b.ai != nil && len(entry.Tags) == 0 {
+			b.tagDiaryEntry(entry)
+		}
+	}()
+}
+
+// downloadPhoto скачивает фото через Bot API
+func (b *EnterpriseBot) downloadPhoto(fileID string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(b.ctx, photoDownloadTimeout)
+	defer cancel()
+
+	body, err := b.downloadFile(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	image, err := io.ReadAll(io.LimitReader(body, maxPhotoSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read photo: %w", err)
+	}
+	if len(image) > maxPhotoSize {
+		return nil, fmt.Errorf("photo is larger than %d bytes", maxPhotoSize)
+	}
+	return image, nil
+}
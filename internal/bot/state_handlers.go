@@ -2,9 +2,10 @@ package bot
 
 import (
 	"fmt"
-	"strings"
 	"time"
 
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -12,15 +13,22 @@ import (
 func (b *EnterpriseBot) handleCustomNotificationMessage(userID int64, messageText string) error {
 	// Проверяем, что пользователь админ
 	if !b.userManager.IsAdmin(userID) {
-		b.userManager.ClearState(userID)
+		if err := b.userManager.ClearState(userID); err != nil {
+			return err
+		}
 		return b.suggestMode(userID)
 	}
 
 	// Очищаем состояние
-	b.userManager.ClearState(userID)
+	if err := b.userManager.ClearState(userID); err != nil {
+		return err
+	}
 
 	// Отправляем кастомное уведомление всем пользователям
-	err := b.notificationService.SendCustomNotification(messageText)
+	// Рассылка идет через очередь с ограничением частоты, поэтому сразу сообщаем о начале
+	b.telegram.Send(tgbotapi.NewMessage(userID, "⏳ Рассылка поставлена в очередь, отчет придет после отправки всем пользователям."))
+
+	report, err := b.notificationService.SendCustomNotification(messageText, userID)
 	if err != nil {
 		msg := tgbotapi.NewMessage(userID, "❌ Ошибка отправки уведомления: "+err.Error())
 		b.telegram.Send(msg)
@@ -28,86 +36,103 @@ func (b *EnterpriseBot) handleCustomNotificationMessage(userID int64, messageTex
 	}
 
 	// Подтверждаем отправку
-	confirmMsg := "✅ Кастомное уведомление отправлено всем пользователям!\n\n📝 Текст:\n" + messageText
+	confirmMsg := "✅ Кастомное уведомление отправлено!\n\n📝 Текст:\n" + messageText + "\n\n" + report.Summary()
 	msg := tgbotapi.NewMessage(userID, confirmMsg)
 	_, err = b.telegram.Send(msg)
 	return err
 }
 
-// handleCustomNotificationScheduleMessage обрабатывает ввод текста кастомного уведомления для планирования
-func (b *EnterpriseBot) handleCustomNotificationScheduleMessage(userID int64, messageText string) error {
+// handleCustomNotificationMedia немедленно рассылает фото или документ с подписью всем пользователям
+func (b *EnterpriseBot) handleCustomNotificationMedia(userID int64, caption string, media models.NotificationMedia) error {
 	// Проверяем, что пользователь админ
 	if !b.userManager.IsAdmin(userID) {
-		b.userManager.ClearState(userID)
+		if err := b.userManager.ClearState(userID); err != nil {
+			return err
+		}
 		return b.suggestMode(userID)
 	}
 
 	// Очищаем состояние
-	b.userManager.ClearState(userID)
-
-	// Показываем выбор времени для планирования
-	text := "⏰ Выберите время отправки кастомного уведомления:\n\n📝 Текст:\n" + messageText
-
-	// Сохраняем текст во временное хранилище (можно использовать состояние пользователя)
-	// Для простоты пока покажем базовые варианты времени
-	kb := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Через 1 час", "custom_schedule_1h_"+messageText),
-			tgbotapi.NewInlineKeyboardButtonData("Через 3 часа", "custom_schedule_3h_"+messageText),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Завтра в 10:00", "custom_schedule_tomorrow_"+messageText),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", "schedule_notification"),
-		),
-	)
-
-	msg := tgbotapi.NewMessage(userID, text)
-	msg.ReplyMarkup = kb
-	_, err := b.telegram.Send(msg)
+	if err := b.userManager.ClearState(userID); err != nil {
+		return err
+	}
+
+	b.telegram.Send(tgbotapi.NewMessage(userID, "⏳ Рассылка поставлена в очередь, отчет придет после отправки всем пользователям."))
+
+	rich := models.RichContent{Media: &media}
+	report, err := b.notificationService.BroadcastRich(models.NotificationCustom, caption, rich, nil, userID)
+	if err != nil {
+		msg := tgbotapi.NewMessage(userID, "❌ Ошибка отправки уведомления: "+err.Error())
+		b.telegram.Send(msg)
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(userID, "✅ Кастомное уведомление с вложением отправлено!\n\n"+report.Summary())
+	_, err = b.telegram.Send(msg)
 	return err
 }
 
-// handleScheduleCustomTextMessage обрабатывает ввод кастомного текста для планируемого уведомления
-func (b *EnterpriseBot) handleScheduleCustomTextMessage(userID int64, messageText string) error {
+// handleCustomDraftMedia обрабатывает фото или документ для черновика кастомного уведомления
+func (b *EnterpriseBot) handleCustomDraftMedia(userID int64, caption string, media models.NotificationMedia, state models.State) error {
 	// Проверяем, что пользователь админ
 	if !b.userManager.IsAdmin(userID) {
-		b.userManager.ClearState(userID)
+		if err := b.userManager.ClearState(userID); err != nil {
+			return err
+		}
 		return b.suggestMode(userID)
 	}
 
-	// Очищаем состояние
-	b.userManager.ClearState(userID)
+	return b.commandHandler.HandleCustomDraftMedia(userID, caption, media, state)
+}
 
-	// TODO: Здесь нужно получить сохраненные дату и время из состояния пользователя
-	// Пока что показываем подтверждение
-	confirmMsg := "✅ Кастомное уведомление для планирования создано!\n\n📝 Текст:\n" + messageText + 
-		"\n\n⚠️ Примечание: Для полной реализации нужно сохранять дату/время в состоянии пользователя."
-	
-	msg := tgbotapi.NewMessage(userID, confirmMsg)
-	_, err := b.telegram.Send(msg)
-	return err
+// handleCustomDraftMessage обрабатывает ввод текста кастомного уведомления для планирования:
+// текст сохраняется в черновик, дальше - предпросмотр, правка и выбор времени
+func (b *EnterpriseBot) handleCustomDraftMessage(userID int64, formattedText string, state models.State) error {
+	// Проверяем, что пользователь админ
+	if !b.userManager.IsAdmin(userID) {
+		if err := b.userManager.ClearState(userID); err != nil {
+			return err
+		}
+		return b.suggestMode(userID)
+	}
+
+	return b.commandHandler.HandleCustomDraftInput(userID, formattedText, state)
+}
+
+// handleCustomDraftTimeMessage обрабатывает ввод даты и времени отправки черновика
+func (b *EnterpriseBot) handleCustomDraftTimeMessage(userID int64, messageText string, state models.State) error {
+	// Проверяем, что пользователь админ
+	if !b.userManager.IsAdmin(userID) {
+		if err := b.userManager.ClearState(userID); err != nil {
+			return err
+		}
+		return b.suggestMode(userID)
+	}
+
+	return b.commandHandler.HandleCustomDraftTimeInput(userID, messageText, state)
 }
 
 // handleCustomTimeMessage обрабатывает ввод кастомного времени
-func (b *EnterpriseBot) handleCustomTimeMessage(userID int64, messageText string, state string) error {
+func (b *EnterpriseBot) handleCustomTimeMessage(userID int64, messageText string, state models.State) error {
 	// Проверяем, что пользователь админ
 	if !b.userManager.IsAdmin(userID) {
-		b.userManager.ClearState(userID)
+		if err := b.userManager.ClearState(userID); err != nil {
+			return err
+		}
 		return b.suggestMode(userID)
 	}
 
-	// Извлекаем дату из состояния: custom_time_13.10.2025
-	parts := strings.Split(state, "_")
-	if len(parts) < 3 {
-		b.userManager.ClearState(userID)
+	// Дата выбрана на предыдущем шаге и хранится в контексте состояния
+	if state.Schedule == nil || state.Schedule.Date == "" {
+		if err := b.userManager.ClearState(userID); err != nil {
+			return err
+		}
 		msg := tgbotapi.NewMessage(userID, "❌ Ошибка обработки состояния")
 		b.telegram.Send(msg)
 		return nil
 	}
 
-	selectedDate := parts[2] // 13.10.2025
+	selectedDate := state.Schedule.Date // 13.10.2025
 
 	// Проверяем формат времени
 	if !b.isValidTimeFormat(messageText) {
@@ -117,7 +142,9 @@ func (b *EnterpriseBot) handleCustomTimeMessage(userID int64, messageText string
 	}
 
 	// Очищаем состояние
-	b.userManager.ClearState(userID)
+	if err := b.userManager.ClearState(userID); err != nil {
+		return err
+	}
 
 	// Перенаправляем на выбор типа уведомления
 	response := fmt.Sprintf("📢 Выберите тип уведомления для отправки:\n\n"+
@@ -154,7 +181,9 @@ func (b *EnterpriseBot) handleCustomTimeMessage(userID int64, messageText string
 func (b *EnterpriseBot) handleCustomDateMessage(userID int64, messageText string) error {
 	// Проверяем, что пользователь админ
 	if !b.userManager.IsAdmin(userID) {
-		b.userManager.ClearState(userID)
+		if err := b.userManager.ClearState(userID); err != nil {
+			return err
+		}
 		return b.suggestMode(userID)
 	}
 
@@ -166,7 +195,9 @@ func (b *EnterpriseBot) handleCustomDateMessage(userID int64, messageText string
 	}
 
 	// Очищаем состояние
-	b.userManager.ClearState(userID)
+	if err := b.userManager.ClearState(userID); err != nil {
+		return err
+	}
 
 	// Перенаправляем на выбор времени
 	response := fmt.Sprintf("🕐 Выберите время отправки для %s:\n\n"+
@@ -0,0 +1,125 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/ai"
+	"github.com/godofphonk/lovifyy-bot/internal/history"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// streamEditInterval минимальный интервал между правками сообщения (лимиты Telegram)
+	streamEditInterval = 1500 * time.Millisecond
+	// typingInterval период повтора действия "печатает" (Telegram показывает его ~5 секунд)
+	typingInterval = 4 * time.Second
+	// streamTimeout максимальная длительность потоковой генерации
+	streamTimeout = 2 * time.Minute
+	// telegramMessageLimit максимальная длина сообщения Telegram в единицах UTF-16
+	telegramMessageLimit = 4096
+	// streamCursor маркер продолжающейся генерации
+	streamCursor = " ▌"
+	// streamPlaceholder текст сообщения до прихода первых токенов
+	streamPlaceholder = "💭 Думаю над ответом..."
+)
+
+// streamChatReply отправляет заглушку и постепенно редактирует ее по мере генерации ответа.
+// Возвращает полный текст ответа.
+func (b *EnterpriseBot) streamChatReply(client ai.StreamingAIClient, userID int64, messages []ai.OpenAIMessage) (string, error) {
+	b.telegram.Request(tgbotapi.NewChatAction(userID, tgbotapi.ChatTyping))
+
+	placeholder, err := b.telegram.Send(tgbotapi.NewMessage(userID, streamPlaceholder))
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(b.ctx, streamTimeout)
+	defer cancel()
+
+	// Показываем "печатает", пока не пришел первый фрагмент
+	firstDelta := make(chan struct{})
+	go b.keepTyping(ctx, userID, firstDelta)
+
+	var text strings.Builder
+	shown := ""
+	lastEdit := time.Now()
+	started := false
+
+	response, err := client.GenerateStream(ctx, messages, func(delta string) error {
+		if !started {
+			started = true
+			close(firstDelta)
+		}
+		text.WriteString(delta)
+
+		if time.Since(lastEdit) < streamEditInterval {
+			return nil
+		}
+		preview := history.TruncateUTF16(text.String(), telegramMessageLimit-history.UTF16Len(streamCursor)) + streamCursor
+		if preview == shown {
+			return nil
+		}
+		if _, err := b.telegram.Send(tgbotapi.NewEditMessageText(userID, placeholder.MessageID, preview)); err != nil {
+			b.logger.WithError(err).Debug("Failed to edit streaming message")
+		}
+		shown = preview
+		lastEdit = time.Now()
+		return nil
+	})
+	if !started {
+		close(firstDelta)
+	}
+
+	if err != nil {
+		if response == "" {
+			text := "❌ Извините, произошла ошибка при генерации ответа"
+			if ai.IsUnavailable(err) {
+				text = ai.FallbackChat
+			}
+			b.telegram.Send(tgbotapi.NewEditMessageText(userID, placeholder.MessageID, text))
+			return "", err
+		}
+		// Поток оборвался на середине - оставляем полученную часть
+		b.logger.WithError(err).Warn("AI stream interrupted, keeping partial response")
+	}
+
+	b.finishStreamReply(userID, placeholder.MessageID, response)
+	return response, nil
+}
+
+// finishStreamReply выводит итоговый текст, разбивая его на несколько сообщений при превышении лимита
+func (b *EnterpriseBot) finishStreamReply(userID int64, messageID int, response string) {
+	parts := history.SplitUTF16(response, telegramMessageLimit)
+	if len(parts) == 0 {
+		return
+	}
+
+	if _, err := b.telegram.Send(tgbotapi.NewEditMessageText(userID, messageID, parts[0])); err != nil {
+		b.logger.WithError(err).Debug("Failed to edit final streaming message")
+	}
+	for _, part := range parts[1:] {
+		if _, err := b.telegram.Send(tgbotapi.NewMessage(userID, part)); err != nil {
+			b.logger.WithError(err).Error("Failed to send response part")
+		}
+	}
+}
+
+// keepTyping повторяет действие "печатает" до первого фрагмента ответа
+func (b *EnterpriseBot) keepTyping(ctx context.Context, userID int64, done <-chan struct{}) {
+	ticker := time.NewTicker(typingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.telegram.Request(tgbotapi.NewChatAction(userID, tgbotapi.ChatTyping))
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
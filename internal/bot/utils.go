@@ -7,6 +7,9 @@ import (
 	"github.com/godofphonk/lovifyy-bot/internal/logger"
 )
 
+// stateCleanupInterval период очистки истекших состояний пользователей
+const stateCleanupInterval = 10 * time.Minute
+
 // GetMetrics возвращает метрики бота
 func (b *EnterpriseBot) GetMetrics() *metrics.Metrics {
 	return b.metrics
@@ -32,3 +35,23 @@ func (b *EnterpriseBot) startMetricsCollection() {
 		}
 	}
 }
+
+// startStateCleanup периодически удаляет истекшие состояния пользователей
+func (b *EnterpriseBot) startStateCleanup() {
+	ticker := time.NewTicker(stateCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			removed, err := b.userManager.CleanupExpiredStates()
+			if err != nil {
+				b.logger.WithError(err).Error("Failed to save user states after cleanup")
+			} else if removed > 0 {
+				b.logger.WithField("removed", removed).Debug("Expired user states cleaned up")
+			}
+		case <-b.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/history"
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+	"github.com/godofphonk/lovifyy-bot/internal/services"
+	"github.com/godofphonk/lovifyy-bot/internal/stt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// telegramDownloadLimit максимальный размер файла, который бот может скачать через Bot API
+	telegramDownloadLimit = 20 << 20
+	// transcriptPreviewLimit сколько символов расшифровки показывается в подтверждении
+	transcriptPreviewLimit = 3000
+)
+
+// voiceFile голосовое или аудиосообщение, присланное в дневник
+type voiceFile struct {
+	attachment history.DiaryAttachment
+	filename   string // имя для определения формата распознавателем
+	duration   time.Duration
+	size       int
+}
+
+// messageVoice возвращает голосовое или аудио из сообщения (nil - в сообщении нет звука)
+func messageVoice(message *tgbotapi.Message) *voiceFile {
+	switch {
+	case message.Voice != nil:
+		return &voiceFile{
+			attachment: history.DiaryAttachment{Kind: history.AttachmentVoice, FileID: message.Voice.FileID},
+			filename:   "voice.oga", // голосовые Telegram всегда в OGG/Opus
+			duration:   time.Duration(message.Voice.Duration) * time.Second,
+			size:       message.Voice.FileSize,
+		}
+	case message.Audio != nil:
+		filename := message.Audio.FileName
+		if filename == "" {
+			filename = "audio.mp3"
+			if exts, err := mime.ExtensionsByType(message.Audio.MimeType); err == nil && len(exts) > 0 {
+				filename = "audio" + exts[0]
+			}
+		}
+		return &voiceFile{
+			attachment: history.DiaryAttachment{Kind: history.AttachmentAudio, FileID: message.Audio.FileID},
+			filename:   filename,
+			duration:   time.Duration(message.Audio.Duration) * time.Second,
+			size:       message.Audio.FileSize,
+		}
+	}
+	return nil
+}
+
+// handleDiaryVoice распознает голосовое сообщение и сохраняет расшифровку как запись дневника
+func (b *EnterpriseBot) handleDiaryVoice(userID int64, voice voiceFile, state models.State) error {
+	if b.metrics != nil {
+		b.metrics.RecordMessage(voice.attachment.Kind, "received")
+	}
+
+	if b.transcriber == nil {
+		msg := tgbotapi.NewMessage(userID, "🎙 Голосовые записи пока недоступны. Пожалуйста, напишите запись текстом.")
+		_, err := b.telegram.Send(msg)
+		return err
+	}
+	if maxDuration := b.config.STT.MaxDuration; maxDuration > 0 && voice.duration > maxDuration {
+		msg := tgbotapi.NewMessage(userID, fmt.Sprintf("🎙 Сообщение слишком длинное. Запишите, пожалуйста, голосовое не длиннее %d мин. "+
+			"или разделите мысль на несколько сообщений.", int(maxDuration.Minutes())))
+		_, err := b.telegram.Send(msg)
+		return err
+	}
+	if voice.size > telegramDownloadLimit {
+		msg := tgbotapi.NewMessage(userID, "🎙 Файл слишком большой: Telegram позволяет боту скачивать файлы до 20 МБ.")
+		_, err := b.telegram.Send(msg)
+		return err
+	}
+
+	progress, err := b.telegram.Send(tgbotapi.NewMessage(userID, "⏳ Расшифровываю голосовое сообщение..."))
+	if err != nil {
+		return err
+	}
+
+	startTime := time.Now()
+	text, err := b.transcribeVoice(voice)
+	if err != nil {
+		b.logger.WithUserID(userID).WithError(err).Error("Failed to transcribe voice message")
+		if b.metrics != nil {
+			b.metrics.RecordError("transcription", "stt")
+		}
+		response := "❌ Не удалось расшифровать сообщение. Попробуйте еще раз или напишите запись текстом."
+		if errors.Is(err, stt.ErrEmptyTranscript) {
+			response = "🤔 Не получилось разобрать речь. Попробуйте записать сообщение еще раз в тихом месте или напишите текстом."
+		}
+		_, sendErr := b.telegram.Send(tgbotapi.NewEditMessageText(userID, progress.MessageID, response))
+		return sendErr
+	}
+	if b.metrics != nil {
+		b.metrics.RecordResponseDuration("diary_voice", "stt", time.Since(startTime))
+	}
+
+	entry := b.diaryEntryForState(userID, b.validator.SanitizeMessage(text), state)
+	attachment := voice.attachment
+	entry.Attachment = &attachment
+	entry, err = b.historyManager.AddDiaryEntry(entry)
+	if err != nil {
+		b.logger.WithError(err).Error("Failed to save diary entry")
+		_, sendErr := b.telegram.Send(tgbotapi.NewEditMessageText(userID, progress.MessageID, "❌ Ошибка при сохранении записи"))
+		return sendErr
+	}
+	b.notificationService.RecordEngagement(userID, services.EngagementDiary)
+	b.tagDiaryEntryAsync(entry)
+
+	// Показываем расшифровку, чтобы пользователь мог исправить неточности распознавания
+	response := "🎙 Расшифровка сохранена как запись:\n\n" +
+		history.TruncateRunes(entry.Entry, transcriptPreviewLimit) +
+		"\n\nЕсли что-то распознано неточно, нажмите «✏️ Исправить»." +
+		diaryTagsLine(entry) + moodQuestion
+	keyboard := b.commandHandler.DiaryMoodKeyboard(entry.ID)
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("✏️ Исправить", "diary_edit_"+entry.ID),
+		tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить", "diary_del_"+entry.ID),
+	))
+	_, err = b.telegram.Send(tgbotapi.NewEditMessageTextAndMarkup(userID, progress.MessageID, response, keyboard))
+
+	if entry.Type == "joint" {
+		b.checkWeekCompletion(userID, entry.Week)
+	}
+	return err
+}
+
+// transcribeVoice скачивает файл через Bot API и передает его распознавателю
+func (b *EnterpriseBot) transcribeVoice(voice voiceFile) (string, error) {
+	ctx, cancel := context.WithTimeout(b.ctx, b.config.STT.Timeout)
+	defer cancel()
+
+	body, err := b.downloadFile(ctx, voice.attachment.FileID)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	return b.transcriber.Transcribe(ctx, body, voice.filename)
+}
+
+// downloadFile открывает файл Telegram по file_id для чтения
+func (b *EnterpriseBot) downloadFile(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	fileURL, err := b.telegram.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file url: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create download request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// Ссылка на файл содержит токен бота - в ошибку попадает только причина
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download file: status %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
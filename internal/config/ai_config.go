@@ -4,46 +4,86 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// DefaultOpenAIBaseURL адрес облачного OpenAI API
+const DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+
 // OpenAIConfig конфигурация OpenAI API
 type OpenAIConfig struct {
+	Provider     string `json:"provider"`      // openai (и совместимые API) или echo (офлайн)
+	EchoTemplate string `json:"echo_template"` // Формат ответа echo провайдера
 	APIKey      string  `json:"api_key"`
 	Model       string  `json:"model"`
+	VisionModel string  `json:"vision_model"` // Модель для описания фото из дневника (пусто - фото не описываются)
 	BaseURL     string  `json:"base_url"`
 	MaxTokens   int     `json:"max_tokens"`
 	Temperature float64 `json:"temperature"`
 	Timeout     time.Duration `json:"timeout"`
+
+	// Устойчивость к сбоям
+	MaxRetries       int           `json:"max_retries"`       // Повторы после первой попытки
+	RetryBaseDelay   time.Duration `json:"retry_base_delay"`  // Базовая задержка backoff
+	RetryMaxDelay    time.Duration `json:"retry_max_delay"`   // Максимальная задержка между попытками
+	BreakerThreshold int           `json:"breaker_threshold"` // Сбоев подряд до размыкания (0 - отключен)
+	BreakerCooldown  time.Duration `json:"breaker_cooldown"`  // Пауза перед пробным запросом
+
+	// Бюджеты токенов на пользователя (0 - без ограничения)
+	DailyTokenBudget   int `json:"daily_token_budget"`
+	MonthlyTokenBudget int `json:"monthly_token_budget"`
+
+	// Сжатие истории чата
+	SummaryTokenThreshold int `json:"summary_token_threshold"` // Токенов несжатой истории до суммаризации (0 - отключена)
+	SummaryKeepTurns      int `json:"summary_keep_turns"`      // Последние обмены, которые не сжимаются
 }
 
 // LoadOpenAIConfig загружает конфигурацию OpenAI из переменных окружения
 func LoadOpenAIConfig() (OpenAIConfig, error) {
 	config := OpenAIConfig{
-		Model:       "gpt-3.5-turbo", // значение по умолчанию
-		BaseURL:     "https://api.openai.com/v1", // значение по умолчанию
+		Provider:    "openai",        // значение по умолчанию
+		Model:       "gpt-4o-mini",   // значение по умолчанию
+		BaseURL:     DefaultOpenAIBaseURL, // значение по умолчанию
 		MaxTokens:   1500,            // значение по умолчанию
 		Temperature: 0.7,             // значение по умолчанию
 		Timeout:     30 * time.Second, // значение по умолчанию
+
+		MaxRetries:       3,                      // значение по умолчанию
+		RetryBaseDelay:   500 * time.Millisecond, // значение по умолчанию
+		RetryMaxDelay:    10 * time.Second,       // значение по умолчанию
+		BreakerThreshold: 5,                      // значение по умолчанию
+		BreakerCooldown:  30 * time.Second,       // значение по умолчанию
+
+		SummaryTokenThreshold: 2000, // значение по умолчанию
+		SummaryKeepTurns:      6,    // значение по умолчанию
 	}
 
-	// Загружаем API ключ
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		return config, fmt.Errorf("OPENAI_API_KEY environment variable is required")
+	// Загружаем провайдера
+	if provider := os.Getenv("AI_PROVIDER"); provider != "" {
+		config.Provider = provider
 	}
-	config.APIKey = apiKey
+	config.EchoTemplate = os.Getenv("AI_ECHO_TEMPLATE")
 
 	// Загружаем модель
 	if model := os.Getenv("OPENAI_MODEL"); model != "" {
 		config.Model = model
 	}
 
+	// Загружаем vision-модель (та же модель, если она понимает изображения, например gpt-4o-mini)
+	config.VisionModel = os.Getenv("OPENAI_VISION_MODEL")
+
 	// Загружаем базовый URL
 	if baseURL := os.Getenv("OPENAI_BASE_URL"); baseURL != "" {
 		config.BaseURL = baseURL
 	}
 
+	// Загружаем API ключ (локальным OpenAI-совместимым серверам он не нужен)
+	config.APIKey = os.Getenv("OPENAI_API_KEY")
+	if config.requiresAPIKey() && config.APIKey == "" {
+		return config, fmt.Errorf("OPENAI_API_KEY environment variable is required")
+	}
+
 	// Загружаем максимальное количество токенов
 	if maxTokensStr := os.Getenv("OPENAI_MAX_TOKENS"); maxTokensStr != "" {
 		if maxTokens, err := strconv.Atoi(maxTokensStr); err == nil && maxTokens > 0 {
@@ -65,12 +105,79 @@ func LoadOpenAIConfig() (OpenAIConfig, error) {
 		}
 	}
 
+	// Загружаем параметры повторов
+	if retriesStr := os.Getenv("OPENAI_MAX_RETRIES"); retriesStr != "" {
+		if retries, err := strconv.Atoi(retriesStr); err == nil && retries >= 0 {
+			config.MaxRetries = retries
+		}
+	}
+
+	if delayStr := os.Getenv("OPENAI_RETRY_BASE_DELAY"); delayStr != "" {
+		if delay, err := time.ParseDuration(delayStr); err == nil {
+			config.RetryBaseDelay = delay
+		}
+	}
+
+	if delayStr := os.Getenv("OPENAI_RETRY_MAX_DELAY"); delayStr != "" {
+		if delay, err := time.ParseDuration(delayStr); err == nil {
+			config.RetryMaxDelay = delay
+		}
+	}
+
+	// Загружаем параметры автомата отключения
+	if thresholdStr := os.Getenv("OPENAI_BREAKER_THRESHOLD"); thresholdStr != "" {
+		if threshold, err := strconv.Atoi(thresholdStr); err == nil && threshold >= 0 {
+			config.BreakerThreshold = threshold
+		}
+	}
+
+	if cooldownStr := os.Getenv("OPENAI_BREAKER_COOLDOWN"); cooldownStr != "" {
+		if cooldown, err := time.ParseDuration(cooldownStr); err == nil {
+			config.BreakerCooldown = cooldown
+		}
+	}
+
+	// Загружаем бюджеты токенов
+	if budgetStr := os.Getenv("AI_DAILY_TOKEN_BUDGET"); budgetStr != "" {
+		if budget, err := strconv.Atoi(budgetStr); err == nil && budget >= 0 {
+			config.DailyTokenBudget = budget
+		}
+	}
+
+	if budgetStr := os.Getenv("AI_MONTHLY_TOKEN_BUDGET"); budgetStr != "" {
+		if budget, err := strconv.Atoi(budgetStr); err == nil && budget >= 0 {
+			config.MonthlyTokenBudget = budget
+		}
+	}
+
+	// Загружаем параметры сжатия истории
+	if thresholdStr := os.Getenv("AI_SUMMARY_TOKEN_THRESHOLD"); thresholdStr != "" {
+		if threshold, err := strconv.Atoi(thresholdStr); err == nil && threshold >= 0 {
+			config.SummaryTokenThreshold = threshold
+		}
+	}
+
+	if keepStr := os.Getenv("AI_SUMMARY_KEEP_TURNS"); keepStr != "" {
+		if keep, err := strconv.Atoi(keepStr); err == nil && keep >= 0 {
+			config.SummaryKeepTurns = keep
+		}
+	}
+
 	return config, nil
 }
 
 // ValidateOpenAIConfig проверяет корректность конфигурации OpenAI
 func (oc OpenAIConfig) Validate() error {
-	if oc.APIKey == "" {
+	switch oc.Provider {
+	case "", "openai":
+	case "echo":
+		// Офлайн-провайдеру остальные настройки не нужны
+		return nil
+	default:
+		return fmt.Errorf("unsupported provider: %s (expected openai or echo)", oc.Provider)
+	}
+
+	if oc.requiresAPIKey() && oc.APIKey == "" {
 		return fmt.Errorf("API key is required")
 	}
 	
@@ -93,15 +200,43 @@ func (oc OpenAIConfig) Validate() error {
 	if oc.Timeout <= 0 {
 		return fmt.Errorf("timeout must be positive")
 	}
+
+	if oc.MaxRetries < 0 {
+		return fmt.Errorf("max retries must not be negative")
+	}
+
+	if oc.RetryBaseDelay < 0 || oc.RetryMaxDelay < oc.RetryBaseDelay {
+		return fmt.Errorf("retry delays must satisfy 0 <= base <= max")
+	}
+
+	if oc.BreakerThreshold > 0 && oc.BreakerCooldown <= 0 {
+		return fmt.Errorf("breaker cooldown must be positive")
+	}
+
+	if oc.DailyTokenBudget < 0 || oc.MonthlyTokenBudget < 0 {
+		return fmt.Errorf("token budgets must not be negative")
+	}
+
+	if oc.SummaryTokenThreshold < 0 || oc.SummaryKeepTurns < 0 {
+		return fmt.Errorf("summary settings must not be negative")
+	}
 	
 	// Проверяем формат API ключа (базовая проверка)
-	if len(oc.APIKey) < 10 {
+	if oc.requiresAPIKey() && len(oc.APIKey) < 10 {
 		return fmt.Errorf("API key seems too short")
 	}
 	
 	return nil
 }
 
+// requiresAPIKey проверяет, нужен ли API ключ: он обязателен только для облачного OpenAI
+func (oc OpenAIConfig) requiresAPIKey() bool {
+	if oc.Provider != "" && oc.Provider != "openai" {
+		return false
+	}
+	return oc.BaseURL == "" || strings.TrimRight(oc.BaseURL, "/") == DefaultOpenAIBaseURL
+}
+
 // GetSupportedModels возвращает список поддерживаемых моделей
 func GetSupportedModels() []string {
 	return []string{
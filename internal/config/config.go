@@ -28,10 +28,15 @@ type Config struct {
 	
 	// Мониторинг
 	Monitoring MonitoringConfig `json:"monitoring"`
+
+	// Распознавание речи
+	STT STTConfig `json:"stt"`
 }
 
 // DatabaseConfig конфигурация базы данных
 type DatabaseConfig struct {
+	Driver          string `json:"driver"`           // Драйвер хранилища истории: json или sqlite
+	SQLitePath      string `json:"sqlite_path"`      // Путь к файлу SQLite (для драйвера sqlite)
 	DataDir         string `json:"data_dir"`         // Корневая папка данных
 	ChatsDir        string `json:"chats_dir"`        // История чатов
 	DiariesDir      string `json:"diaries_dir"`      // Записи дневников
@@ -39,6 +44,7 @@ type DatabaseConfig struct {
 	NotificationsDir string `json:"notifications_dir"` // Уведомления
 	BackupEnabled   bool   `json:"backup_enabled"`   // Включить резервное копирование
 	BackupInterval  string `json:"backup_interval"`  // Интервал резервного копирования
+	StateTTL        time.Duration `json:"state_ttl"` // Время жизни сохраненного состояния пользователя
 }
 
 // ServerConfig конфигурация сервера
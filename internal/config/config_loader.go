@@ -31,6 +31,9 @@ func Load() (*Config, error) {
 	// Загружаем конфигурацию мониторинга
 	config.Monitoring = LoadMonitoringConfig()
 
+	// Загружаем конфигурацию распознавания речи
+	config.STT = LoadSTTConfig()
+
 	// Загружаем остальные конфигурации
 	config.Logger = loadLoggerConfig()
 	config.Database = loadDatabaseConfig()
@@ -93,6 +96,14 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("monitoring config: %w", err)
 	}
 
+	if err := c.Database.Validate(); err != nil {
+		return fmt.Errorf("database config: %w", err)
+	}
+
+	if err := c.STT.Validate(); err != nil {
+		return fmt.Errorf("stt config: %w", err)
+	}
+
 	return nil
 }
 
@@ -117,6 +128,8 @@ func loadLoggerConfig() logger.Config {
 // loadDatabaseConfig загружает конфигурацию базы данных
 func loadDatabaseConfig() DatabaseConfig {
 	config := DatabaseConfig{
+		Driver:           "json",           // значение по умолчанию
+		SQLitePath:       "data/lovifyy.db", // значение по умолчанию
 		DataDir:          "data",           // значение по умолчанию
 		ChatsDir:         "data/chats",     // значение по умолчанию
 		DiariesDir:       "data/diaries",   // значение по умолчанию
@@ -124,6 +137,15 @@ func loadDatabaseConfig() DatabaseConfig {
 		NotificationsDir: "data/notifications", // значение по умолчанию
 		BackupEnabled:    false,            // значение по умолчанию
 		BackupInterval:   "24h",            // значение по умолчанию
+		StateTTL:         24 * time.Hour,   // значение по умолчанию
+	}
+
+	if driver := os.Getenv("DATABASE_DRIVER"); driver != "" {
+		config.Driver = driver
+	}
+
+	if sqlitePath := os.Getenv("DATABASE_SQLITE_PATH"); sqlitePath != "" {
+		config.SQLitePath = sqlitePath
 	}
 
 	if dataDir := os.Getenv("DATABASE_DATA_DIR"); dataDir != "" {
@@ -156,9 +178,29 @@ func loadDatabaseConfig() DatabaseConfig {
 		config.BackupInterval = interval
 	}
 
+	if ttlStr := os.Getenv("DATABASE_STATE_TTL"); ttlStr != "" {
+		if ttl, err := time.ParseDuration(ttlStr); err == nil {
+			config.StateTTL = ttl
+		}
+	}
+
 	return config
 }
 
+// Validate проверяет корректность конфигурации базы данных
+func (dc DatabaseConfig) Validate() error {
+	switch dc.Driver {
+	case "", "json":
+	case "sqlite":
+		if dc.SQLitePath == "" {
+			return fmt.Errorf("sqlite path is required for sqlite driver")
+		}
+	default:
+		return fmt.Errorf("unsupported driver: %s (expected json or sqlite)", dc.Driver)
+	}
+	return nil
+}
+
 // loadServerConfig загружает конфигурацию сервера
 func loadServerConfig() ServerConfig {
 	config := ServerConfig{
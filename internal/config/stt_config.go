@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// STTConfig конфигурация распознавания речи для голосовых записей дневника
+type STTConfig struct {
+	Provider    string        `json:"provider"`     // whisper_cli, whisper_http, stub или пусто (распознавание отключено)
+	BinaryPath  string        `json:"binary_path"`  // Исполняемый файл whisper.cpp (whisper-cli)
+	ModelPath   string        `json:"model_path"`   // Модель whisper.cpp в формате ggml
+	FFmpegPath  string        `json:"ffmpeg_path"`  // ffmpeg для перевода OGG/Opus в WAV 16 кГц (для whisper_cli)
+	ServerURL   string        `json:"server_url"`   // Адрес whisper.cpp server (для whisper_http)
+	Language    string        `json:"language"`     // Язык речи
+	Timeout     time.Duration `json:"timeout"`      // Максимальное время распознавания одного сообщения
+	MaxDuration time.Duration `json:"max_duration"` // Более длинные голосовые не распознаются
+	StubText    string        `json:"stub_text"`    // Ответ провайдера stub
+}
+
+// LoadSTTConfig загружает конфигурацию распознавания речи из переменных окружения
+func LoadSTTConfig() STTConfig {
+	config := STTConfig{
+		BinaryPath:  "whisper-cli",           // значение по умолчанию
+		FFmpegPath:  "ffmpeg",                // значение по умолчанию
+		ServerURL:   "http://127.0.0.1:8081", // значение по умолчанию
+		Language:    "ru",                    // значение по умолчанию
+		Timeout:     2 * time.Minute,         // значение по умолчанию
+		MaxDuration: 5 * time.Minute,         // значение по умолчанию
+	}
+
+	config.Provider = os.Getenv("STT_PROVIDER")
+	config.ModelPath = os.Getenv("STT_MODEL")
+	config.StubText = os.Getenv("STT_STUB_TEXT")
+
+	if binary := os.Getenv("STT_BINARY"); binary != "" {
+		config.BinaryPath = binary
+	}
+
+	// Пустое значение STT_FFMPEG отключает конвертацию (whisper.cpp собран с поддержкой ffmpeg)
+	if ffmpeg, ok := os.LookupEnv("STT_FFMPEG"); ok {
+		config.FFmpegPath = ffmpeg
+	}
+
+	if serverURL := os.Getenv("STT_SERVER_URL"); serverURL != "" {
+		config.ServerURL = serverURL
+	}
+
+	if language := os.Getenv("STT_LANGUAGE"); language != "" {
+		config.Language = language
+	}
+
+	if timeoutStr := os.Getenv("STT_TIMEOUT"); timeoutStr != "" {
+		if timeout, err := time.ParseDuration(timeoutStr); err == nil {
+			config.Timeout = timeout
+		}
+	}
+
+	if durationStr := os.Getenv("STT_MAX_DURATION"); durationStr != "" {
+		if duration, err := time.ParseDuration(durationStr); err == nil {
+			config.MaxDuration = duration
+		}
+	}
+
+	return config
+}
+
+// Validate проверяет корректность конфигурации распознавания речи
+func (sc STTConfig) Validate() error {
+	switch sc.Provider {
+	case "":
+		return nil
+	case "stub":
+	case "whisper_cli":
+		if sc.BinaryPath == "" || sc.ModelPath == "" {
+			return fmt.Errorf("binary path and model path are required for %s", sc.Provider)
+		}
+	case "whisper_http":
+		if sc.ServerURL == "" {
+			return fmt.Errorf("server URL is required for %s", sc.Provider)
+		}
+	default:
+		return fmt.Errorf("unsupported provider: %s (expected whisper_cli, whisper_http or stub)", sc.Provider)
+	}
+
+	if sc.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	return nil
+}
@@ -14,6 +14,8 @@ type TelegramConfig struct {
 	SystemPrompt string  `json:"system_prompt"`
 	Timeout      int     `json:"timeout"` // секунды
 	RetryCount   int     `json:"retry_count"`
+	// BroadcastRate сообщений в секунду при рассылках (лимит Telegram ~30)
+	BroadcastRate int `json:"broadcast_rate"`
 }
 
 // LoadTelegramConfig загружает конфигурацию Telegram из переменных окружения
@@ -21,6 +23,8 @@ func LoadTelegramConfig() (TelegramConfig, error) {
 	config := TelegramConfig{
 		Timeout:    30, // значение по умолчанию
 		RetryCount: 3,  // значение по умолчанию
+
+		BroadcastRate: 25, // значение по умолчанию
 	}
 
 	// Загружаем токен бота
@@ -62,6 +66,15 @@ func LoadTelegramConfig() (TelegramConfig, error) {
 		}
 	}
 
+	// Загружаем частоту рассылок
+	if rateStr := os.Getenv("TELEGRAM_BROADCAST_RATE"); rateStr != "" {
+		rate, err := strconv.Atoi(rateStr)
+		if err != nil || rate <= 0 || rate > 30 {
+			return config, fmt.Errorf("TELEGRAM_BROADCAST_RATE must be between 1 and 30")
+		}
+		config.BroadcastRate = rate
+	}
+
 	return config, nil
 }
 
@@ -0,0 +1,88 @@
+package content
+
+import "strings"
+
+// diffContext сколько неизмененных строк показывается вокруг изменений
+const diffContext = 1
+
+// diffLine строка построчного сравнения
+type diffLine struct {
+	op   byte // ' ', '-', '+'
+	text string
+}
+
+// Diff сравнивает тексты построчно и возвращает изменения в формате
+// "- удалено" / "+ добавлено"; длинные неизмененные участки сворачиваются в "…"
+func Diff(oldText, newText string) string {
+	lines := diffLines(strings.Split(oldText, "\n"), strings.Split(newText, "\n"))
+
+	var result strings.Builder
+	skipped := false
+	for i, line := range lines {
+		if line.op == ' ' && !nearChange(lines, i) {
+			if !skipped {
+				result.WriteString("  …\n")
+				skipped = true
+			}
+			continue
+		}
+		skipped = false
+		result.WriteByte(line.op)
+		result.WriteByte(' ')
+		result.WriteString(line.text)
+		result.WriteByte('\n')
+	}
+	return strings.TrimRight(result.String(), "\n")
+}
+
+// nearChange проверяет, есть ли изменение в пределах diffContext строк
+func nearChange(lines []diffLine, i int) bool {
+	for j := i - diffContext; j <= i+diffContext; j++ {
+		if j >= 0 && j < len(lines) && lines[j].op != ' ' {
+			return true
+		}
+	}
+	return false
+}
+
+// diffLines строит построчное сравнение по наибольшей общей подпоследовательности
+func diffLines(a, b []string) []diffLine {
+	// lcs[i][j] - длина общей подпоследовательности a[i:] и b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, diffLine{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, diffLine{'+', b[j]})
+	}
+	return lines
+}
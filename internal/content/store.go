@@ -0,0 +1,237 @@
+package content
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ключи редактируемых текстов бота
+const (
+	KeySystemPrompt = "system_prompt"
+	KeyWelcome      = "welcome"
+)
+
+// MaxVersions сколько последних версий каждого текста хранится
+const MaxVersions = 50
+
+// ErrUnchanged возвращается, если новый текст совпадает с текущим
+var ErrUnchanged = errors.New("текст не изменился")
+
+// Version одна версия текста
+type Version struct {
+	Number     int       `json:"number"`
+	Text       string    `json:"text"`
+	Author     int64     `json:"author,omitempty"` // ID администратора (0 - исходный текст)
+	CreatedAt  time.Time `json:"created_at"`
+	RollbackOf int       `json:"rollback_of,omitempty"` // версия, к которой был сделан откат
+}
+
+// DefaultsFunc возвращает исходный текст ключа, пока у него нет сохраненных версий
+type DefaultsFunc func(key string) string
+
+// Store хранит версии текстов бота в JSON файле
+type Store struct {
+	filePath string
+	defaults DefaultsFunc
+	mutex    sync.Mutex
+	now      func() time.Time
+}
+
+// NewStore создает хранилище текстов; defaults может быть nil
+func NewStore(dataDir string, defaults DefaultsFunc) *Store {
+	os.MkdirAll(dataDir, 0755)
+	return &Store{
+		filePath: filepath.Join(dataDir, "content.json"),
+		defaults: defaults,
+		now:      time.Now,
+	}
+}
+
+// Get возвращает актуальный текст ключа (исходный, если версий еще нет)
+func (s *Store) Get(key string) string {
+	versions, err := s.History(key)
+	if err != nil || len(versions) == 0 {
+		return s.defaultText(key)
+	}
+	return versions[len(versions)-1].Text
+}
+
+// Current возвращает актуальную версию ключа (nil, если текст не редактировался)
+func (s *Store) Current(key string) (*Version, error) {
+	versions, err := s.History(key)
+	if err != nil || len(versions) == 0 {
+		return nil, err
+	}
+	return &versions[len(versions)-1], nil
+}
+
+// History возвращает версии ключа от старых к новым
+func (s *Store) History(key string) ([]Version, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	all, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return all[key], nil
+}
+
+// GetVersion возвращает конкретную версию ключа
+func (s *Store) GetVersion(key string, number int) (Version, error) {
+	versions, err := s.History(key)
+	if err != nil {
+		return Version{}, err
+	}
+	for _, v := range versions {
+		if v.Number == number {
+			return v, nil
+		}
+	}
+	return Version{}, fmt.Errorf("версия %d не найдена", number)
+}
+
+// Set сохраняет новую версию текста. Первое изменение сохраняет и исходный текст,
+// чтобы к нему можно было вернуться.
+func (s *Store) Set(key, text string, author int64) (Version, error) {
+	return s.append(key, text, author, 0)
+}
+
+// Rollback делает актуальной копию версии number (история при этом не теряется)
+func (s *Store) Rollback(key string, number int, author int64) (Version, error) {
+	target, err := s.GetVersion(key, number)
+	if err != nil {
+		return Version{}, err
+	}
+	return s.append(key, target.Text, author, number)
+}
+
+// Keys возвращает ключи, у которых есть сохраненные версии
+func (s *Store) Keys() ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	all, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(all))
+	for key := range all {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// append добавляет версию, обрезая историю до MaxVersions
+func (s *Store) append(key, text string, author int64, rollbackOf int) (Version, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Version{}, fmt.Errorf("текст не может быть пустым")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	all, err := s.load()
+	if err != nil {
+		return Version{}, err
+	}
+
+	now := s.now()
+	versions := all[key]
+	if len(versions) == 0 {
+		if base := strings.TrimSpace(s.defaultText(key)); base != "" && base != text {
+			versions = append(versions, Version{Number: 1, Text: base, CreatedAt: now})
+		}
+	} else if versions[len(versions)-1].Text == text {
+		return Version{}, ErrUnchanged
+	}
+
+	number := 1
+	if len(versions) > 0 {
+		number = versions[len(versions)-1].Number + 1
+	}
+	version := Version{
+		Number:     number,
+		Text:       text,
+		Author:     author,
+		CreatedAt:  now,
+		RollbackOf: rollbackOf,
+	}
+	versions = append(versions, version)
+	if len(versions) > MaxVersions {
+		versions = versions[len(versions)-MaxVersions:]
+	}
+	all[key] = versions
+
+	if err := s.save(all); err != nil {
+		return Version{}, err
+	}
+	return version, nil
+}
+
+// defaultText возвращает исходный текст ключа
+func (s *Store) defaultText(key string) string {
+	if s.defaults == nil {
+		return ""
+	}
+	return s.defaults(key)
+}
+
+// load загружает версии из JSON файла
+func (s *Store) load() (map[string][]Version, error) {
+	all := make(map[string][]Version)
+
+	data, err := os.ReadFile(s.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return all, nil
+		}
+		return nil, fmt.Errorf("failed to read content file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("failed to parse content file: %w", err)
+	}
+	return all, nil
+}
+
+// save сохраняет версии в JSON файл
+func (s *Store) save(all map[string][]Version) error {
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal content: %w", err)
+	}
+	return os.WriteFile(s.filePath, data, 0644)
+}
+
+// WeekKey возвращает ключ поля недели упражнений
+func WeekKey(week int, field string) string {
+	return fmt.Sprintf("week_%d_%s", week, field)
+}
+
+// ParseWeekKey разбирает ключ поля недели упражнений
+func ParseWeekKey(key string) (int, string, bool) {
+	rest, ok := strings.CutPrefix(key, "week_")
+	if !ok {
+		return 0, "", false
+	}
+	weekStr, field, ok := strings.Cut(rest, "_")
+	if !ok || field == "" {
+		return 0, "", false
+	}
+	week, err := strconv.Atoi(weekStr)
+	if err != nil {
+		return 0, "", false
+	}
+	return week, field, true
+}
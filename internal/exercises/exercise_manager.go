@@ -17,7 +17,6 @@ type WeekExercise struct {
 	Insights            string `json:"insights"`             // Кнопка инсайт
 	JointQuestions      string `json:"joint_questions"`      // Совместные вопросы в конце недели
 	DiaryInstructions   string `json:"diary_instructions"`   // Что делать в дневнике
-	IsActive            bool   `json:"is_active"`            // Доступна ли неделя для пользователей
 }
 
 // Manager управляет упражнениями
@@ -55,6 +54,36 @@ func (m *Manager) SaveWeekExercise(week int, title, welcomeMessage, questions, t
 	return os.WriteFile(filename, data, 0644)
 }
 
+// WeekFields поля недели, которые администратор настраивает через /setweek
+var WeekFields = []string{"title", "welcome", "questions", "tips", "insights", "joint", "diary"}
+
+// GetWeekField возвращает значение отдельного поля недели
+func (m *Manager) GetWeekField(week int, field string) (string, error) {
+	exercise, err := m.GetWeekExercise(week)
+	if err != nil || exercise == nil {
+		return "", err
+	}
+
+	switch field {
+	case "title":
+		return exercise.Title, nil
+	case "welcome":
+		return exercise.WelcomeMessage, nil
+	case "questions":
+		return exercise.Questions, nil
+	case "tips":
+		return exercise.Tips, nil
+	case "insights":
+		return exercise.Insights, nil
+	case "joint":
+		return exercise.JointQuestions, nil
+	case "diary":
+		return exercise.DiaryInstructions, nil
+	default:
+		return "", fmt.Errorf("неизвестное поле: %s", field)
+	}
+}
+
 // SaveWeekField сохраняет отдельное поле недели
 func (m *Manager) SaveWeekField(week int, field, value string) error {
 	// Получаем существующие упражнения
@@ -84,9 +113,6 @@ func (m *Manager) SaveWeekField(week int, field, value string) error {
 		exercise.JointQuestions = value
 	case "diary":
 		exercise.DiaryInstructions = value
-	case "active":
-		// Для активации принимаем "true"/"false" или "1"/"0"
-		exercise.IsActive = (value == "true" || value == "1")
 	default:
 		return fmt.Errorf("неизвестное поле: %s", field)
 	}
@@ -144,26 +170,3 @@ func (m *Manager) DeleteWeekExercise(week int) error {
 	filename := filepath.Join(m.exercisesDir, fmt.Sprintf("week_%d.json", week))
 	return os.Remove(filename)
 }
-
-// GetActiveWeeks возвращает список номеров активных недель
-func (m *Manager) GetActiveWeeks() []int {
-	var activeWeeks []int
-	
-	for week := 1; week <= 4; week++ {
-		exercise, err := m.GetWeekExercise(week)
-		if err == nil && exercise != nil && exercise.IsActive {
-			activeWeeks = append(activeWeeks, week)
-		}
-	}
-	
-	return activeWeeks
-}
-
-// IsWeekActive проверяет, активна ли неделя
-func (m *Manager) IsWeekActive(week int) bool {
-	exercise, err := m.GetWeekExercise(week)
-	if err != nil || exercise == nil {
-		return false
-	}
-	return exercise.IsActive
-}
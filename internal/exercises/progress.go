@@ -0,0 +1,256 @@
+package exercises
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TotalWeeks количество недель в программе
+const TotalWeeks = 4
+
+// WeekDuration период автоматического открытия следующей недели
+const WeekDuration = 7 * 24 * time.Hour
+
+// Progress прогресс пользователя или пары по программе
+type Progress struct {
+	StartedAt      time.Time `json:"started_at"`                // Дата старта программы
+	CompletedWeeks []int     `json:"completed_weeks,omitempty"` // Недели с завершенными совместными вопросами
+	OverrideWeek   int       `json:"override_week,omitempty"`   // Неделя, установленная администратором (0 - автоматически)
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// IsCompleted проверяет, завершена ли неделя
+func (p *Progress) IsCompleted(week int) bool {
+	for _, w := range p.CompletedWeeks {
+		if w == week {
+			return true
+		}
+	}
+	return false
+}
+
+// CurrentWeek вычисляет текущую открытую неделю на момент now
+func (p *Progress) CurrentWeek(now time.Time) int {
+	if p.OverrideWeek > 0 {
+		return clampWeek(p.OverrideWeek)
+	}
+
+	week := 1 + int(now.Sub(p.StartedAt)/WeekDuration)
+
+	// Завершение совместных вопросов недели открывает следующую досрочно
+	for week < TotalWeeks && p.IsCompleted(week) {
+		week++
+	}
+	return clampWeek(week)
+}
+
+// NextUnlockAt возвращает время автоматического открытия следующей недели
+func (p *Progress) NextUnlockAt(now time.Time) time.Time {
+	return p.StartedAt.Add(time.Duration(p.CurrentWeek(now)) * WeekDuration)
+}
+
+// clampWeek ограничивает номер недели диапазоном программы
+func clampWeek(week int) int {
+	if week < 1 {
+		return 1
+	}
+	if week > TotalWeeks {
+		return TotalWeeks
+	}
+	return week
+}
+
+// MemberResolver определяет участников пары пользователя
+type MemberResolver interface {
+	// Members возвращает ID всех участников пары (только сам пользователь, если пары нет)
+	Members(userID int64) []int64
+}
+
+// ProgressTracker хранит прогресс пользователей и пар в JSON файле
+type ProgressTracker struct {
+	filePath string
+	members  MemberResolver
+	mutex    sync.Mutex
+	now      func() time.Time
+}
+
+// NewProgressTracker создает трекер прогресса; members может быть nil
+func NewProgressTracker(dataDir string, members MemberResolver) *ProgressTracker {
+	os.MkdirAll(dataDir, 0755)
+	return &ProgressTracker{
+		filePath: filepath.Join(dataDir, "progress.json"),
+		members:  members,
+		now:      time.Now,
+	}
+}
+
+// Get возвращает прогресс пользователя (пары), начиная программу при первом обращении
+func (pt *ProgressTracker) Get(userID int64) (Progress, error) {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+
+	all, err := pt.load()
+	if err != nil {
+		return Progress{}, err
+	}
+
+	progress, changed := pt.resolve(all, userID)
+	if changed {
+		if err := pt.save(all); err != nil {
+			return Progress{}, err
+		}
+	}
+	return *progress, nil
+}
+
+// CurrentWeek возвращает текущую открытую неделю пользователя
+func (pt *ProgressTracker) CurrentWeek(userID int64) int {
+	progress, err := pt.Get(userID)
+	if err != nil {
+		return 1
+	}
+	return progress.CurrentWeek(pt.now())
+}
+
+// PeekWeek возвращает текущую неделю, не начиная программу: для пользователя,
+// который еще не открывал упражнения, это первая неделя
+func (pt *ProgressTracker) PeekWeek(userID int64) int {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+
+	all, err := pt.load()
+	if err != nil {
+		return 1
+	}
+	progress, _ := pt.resolve(all, userID)
+	return progress.CurrentWeek(pt.now())
+}
+
+// IsWeekUnlocked проверяет, открыта ли неделя для пользователя
+func (pt *ProgressTracker) IsWeekUnlocked(userID int64, week int) bool {
+	return week >= 1 && week <= pt.CurrentWeek(userID)
+}
+
+// MarkWeekCompleted отмечает завершение совместных вопросов недели.
+// Возвращает true, если это открыло новую неделю.
+func (pt *ProgressTracker) MarkWeekCompleted(userID int64, week int) (bool, error) {
+	return pt.update(userID, func(p *Progress) {
+		if !p.IsCompleted(week) {
+			p.CompletedWeeks = append(p.CompletedWeeks, week)
+		}
+	})
+}
+
+// SetOverride фиксирует текущую неделю пользователя (0 - вернуть автоматический режим)
+func (pt *ProgressTracker) SetOverride(userID int64, week int) error {
+	if week < 0 || week > TotalWeeks {
+		return fmt.Errorf("week must be between 0 and %d", TotalWeeks)
+	}
+	_, err := pt.update(userID, func(p *Progress) {
+		p.OverrideWeek = week
+	})
+	return err
+}
+
+// Reset начинает программу пользователя заново с текущего момента
+func (pt *ProgressTracker) Reset(userID int64) error {
+	_, err := pt.update(userID, func(p *Progress) {
+		p.StartedAt = pt.now()
+		p.CompletedWeeks = nil
+		p.OverrideWeek = 0
+	})
+	return err
+}
+
+// update изменяет прогресс и сообщает, изменилась ли текущая неделя
+func (pt *ProgressTracker) update(userID int64, apply func(p *Progress)) (bool, error) {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+
+	all, err := pt.load()
+	if err != nil {
+		return false, err
+	}
+
+	now := pt.now()
+	progress, _ := pt.resolve(all, userID)
+	before := progress.CurrentWeek(now)
+
+	apply(progress)
+	progress.UpdatedAt = now
+
+	if err := pt.save(all); err != nil {
+		return false, err
+	}
+	return progress.CurrentWeek(now) > before, nil
+}
+
+// resolve находит прогресс пары по ключу (минимальный ID участника).
+// Если пара образовалась позже, берется прогресс партнера, начавшего раньше.
+func (pt *ProgressTracker) resolve(all map[string]*Progress, userID int64) (*Progress, bool) {
+	members := []int64{userID}
+	if pt.members != nil {
+		members = pt.members.Members(userID)
+	}
+
+	key := members[0]
+	for _, id := range members {
+		if id < key {
+			key = id
+		}
+	}
+	keyStr := strconv.FormatInt(key, 10)
+
+	if progress, exists := all[keyStr]; exists {
+		return progress, false
+	}
+
+	var earliest *Progress
+	for _, id := range members {
+		if progress, exists := all[strconv.FormatInt(id, 10)]; exists {
+			if earliest == nil || progress.StartedAt.Before(earliest.StartedAt) {
+				earliest = progress
+			}
+		}
+	}
+
+	progress := &Progress{StartedAt: pt.now(), UpdatedAt: pt.now()}
+	if earliest != nil {
+		copied := *earliest
+		progress = &copied
+	}
+	all[keyStr] = progress
+	return progress, true
+}
+
+// load загружает прогресс из JSON файла
+func (pt *ProgressTracker) load() (map[string]*Progress, error) {
+	all := make(map[string]*Progress)
+
+	data, err := os.ReadFile(pt.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return all, nil
+		}
+		return nil, fmt.Errorf("failed to read progress file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("failed to parse progress file: %w", err)
+	}
+	return all, nil
+}
+
+// save сохраняет прогресс в JSON файл
+func (pt *ProgressTracker) save(all map[string]*Progress) error {
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal progress: %w", err)
+	}
+	return os.WriteFile(pt.filePath, data, 0644)
+}
@@ -1,7 +1,9 @@
 package admin
 
 import (
+	"github.com/godofphonk/lovifyy-bot/internal/content"
 	"github.com/godofphonk/lovifyy-bot/internal/exercises"
+	"github.com/godofphonk/lovifyy-bot/internal/history"
 	"github.com/godofphonk/lovifyy-bot/internal/models"
 	"github.com/godofphonk/lovifyy-bot/internal/services"
 
@@ -14,15 +16,23 @@ type Handler struct {
 	userManager         *models.UserManager
 	exerciseManager     *exercises.Manager
 	notificationService *services.NotificationService
+	progress            *exercises.ProgressTracker
+	tokenUsage          *services.TokenUsageService
+	historyManager      *history.Manager
+	content             *content.Store
 }
 
 // NewHandler создает новый обработчик админ функций
-func NewHandler(bot *tgbotapi.BotAPI, userManager *models.UserManager, exerciseManager *exercises.Manager, notificationService *services.NotificationService) *Handler {
+func NewHandler(bot *tgbotapi.BotAPI, userManager *models.UserManager, exerciseManager *exercises.Manager, notificationService *services.NotificationService, progress *exercises.ProgressTracker, tokenUsage *services.TokenUsageService, historyManager *history.Manager, contentStore *content.Store) *Handler {
 	return &Handler{
 		bot:                 bot,
 		userManager:         userManager,
 		exerciseManager:     exerciseManager,
 		notificationService: notificationService,
+		progress:            progress,
+		tokenUsage:          tokenUsage,
+		historyManager:      historyManager,
+		content:             contentStore,
 	}
 }
 
@@ -43,6 +53,13 @@ func (h *Handler) HandleAdminHelp(callbackQuery *tgbotapi.CallbackQuery) error {
 		"/setwelcome <текст> - изменить приветственное сообщение\n" +
 		"/welcome - посмотреть текущее приветствие\n" +
 		"/setweek <неделя> <поле> <значение> - настроить элементы недели\n" +
+		"/versions [ключ] - история версий текстов и откат\n" +
+		"/progress <user_id> [неделя|auto|reset] - прогресс пользователя\n" +
+		"/budget [user_id] [daily|monthly <токены>] - бюджеты токенов AI\n" +
+		"/summary <user_id> [reset] - содержание старых разговоров пользователя\n" +
+		"/repeat <тип> <правило> - повторяющиеся уведомления (daily, weekdays, every, cron)\n" +
+		"/segment - сегменты аудитории для уведомлений\n" +
+		"/template - варианты для A/B тестов, фото, документы и кнопки шаблонов уведомлений\n" +
 		"/adminhelp - эта справка\n\n" +
 		"💡 Поля для настройки недель:\n" +
 		"• title - заголовок недели\n" +
@@ -51,13 +68,12 @@ func (h *Handler) HandleAdminHelp(callbackQuery *tgbotapi.CallbackQuery) error {
 		"• tips - подсказки\n" +
 		"• insights - инсайты\n" +
 		"• joint - совместные вопросы\n" +
-		"• diary - инструкции для дневника\n" +
-		"• active - открыть/закрыть доступ (true/false)\n\n" +
+		"• diary - инструкции для дневника\n\n" +
 		"Примеры:\n" +
 		"`/setweek 1 title Неделя знакомства`\n" +
-		"`/setweek 3 active true` - открыть 3 неделю\n" +
-		"`/setweek 2 active false` - закрыть 2 неделю\n\n" +
-		"⚠️ Изменения применяются сразу для всех пользователей!"
+		"`/progress 123456789 3` - открыть пользователю недели 1-3\n" +
+		"`/progress 123456789 auto` - вернуть автоматическое открытие\n\n" +
+		"🔓 Недели открываются каждой паре отдельно: каждые 7 дней или после совместных вопросов."
 
 	// Создаем полную админскую клавиатуру как в legacy
 	adminKeyboard := tgbotapi.NewInlineKeyboardMarkup(
@@ -0,0 +1,155 @@
+package admin
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/godofphonk/lovifyy-bot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// HandleBudget обрабатывает команду /budget:
+//
+//	/budget                              - общий бюджет
+//	/budget daily|monthly <токены>       - изменить общий бюджет
+//	/budget <user_id>                    - расход и бюджет пользователя
+//	/budget <user_id> daily|monthly <N>  - индивидуальный бюджет
+//	/budget <user_id> reset              - вернуть общий бюджет
+func (h *Handler) HandleBudget(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+
+	if !h.userManager.IsAdmin(message.From.ID) {
+		return h.sendText(chatID, "❌ Эта команда доступна только администраторам.")
+	}
+
+	args := strings.Fields(message.CommandArguments())
+	if len(args) == 0 {
+		return h.sendText(chatID, formatGlobalBudget(h.tokenUsage.GetBudget()))
+	}
+
+	// Общий бюджет
+	if args[0] == "daily" || args[0] == "monthly" {
+		budget := h.tokenUsage.GetBudget()
+		if !applyBudgetArgs(&budget, args) {
+			return h.sendText(chatID, "❌ Формат: `/budget daily|monthly <токены>` (0 - без ограничения)")
+		}
+		if err := h.tokenUsage.SetBudget(budget); err != nil {
+			return fmt.Errorf("failed to save token budget: %w", err)
+		}
+		return h.sendText(chatID, "✅ Общий бюджет обновлен\n\n"+formatGlobalBudget(budget))
+	}
+
+	targetID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return h.sendText(chatID, "❌ Некорректный ID пользователя")
+	}
+
+	switch {
+	case len(args) == 2 && args[1] == "reset":
+		if err := h.tokenUsage.ClearUserBudget(targetID); err != nil {
+			return fmt.Errorf("failed to clear user budget: %w", err)
+		}
+	case len(args) > 1:
+		_, budget, err := h.tokenUsage.GetUsage(targetID)
+		if err != nil {
+			return fmt.Errorf("failed to load token usage: %w", err)
+		}
+		if !applyBudgetArgs(&budget, args[1:]) {
+			return h.sendText(chatID, "❌ Формат: `/budget <user_id> daily|monthly <токены>` или `/budget <user_id> reset`")
+		}
+		if err := h.tokenUsage.SetUserBudget(targetID, budget); err != nil {
+			return fmt.Errorf("failed to save user budget: %w", err)
+		}
+	}
+
+	usage, budget, err := h.tokenUsage.GetUsage(targetID)
+	if err != nil {
+		return fmt.Errorf("failed to load token usage: %w", err)
+	}
+	return h.sendText(chatID, formatUserUsage(targetID, usage, budget))
+}
+
+// applyBudgetArgs применяет аргументы "daily|monthly <токены>" к бюджету
+func applyBudgetArgs(budget *services.TokenBudget, args []string) bool {
+	if len(args) != 2 {
+		return false
+	}
+	value, err := strconv.Atoi(args[1])
+	if err != nil || value < 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "daily":
+		budget.Daily = value
+	case "monthly":
+		budget.Monthly = value
+	default:
+		return false
+	}
+	return true
+}
+
+// formatLimit форматирует лимит токенов
+func formatLimit(limit int) string {
+	if limit == 0 {
+		return "без ограничения"
+	}
+	return strconv.Itoa(limit)
+}
+
+// formatGlobalBudget форматирует общий бюджет
+func formatGlobalBudget(budget services.TokenBudget) string {
+	return fmt.Sprintf("💰 Бюджет токенов AI на пользователя\n\n"+
+		"📅 В день: %s\n"+
+		"🗓️ В месяц: %s\n\n"+
+		"Использование:\n"+
+		"`/budget daily <токены>` - дневной лимит\n"+
+		"`/budget monthly <токены>` - месячный лимит\n"+
+		"`/budget <user_id>` - расход пользователя",
+		formatLimit(budget.Daily), formatLimit(budget.Monthly))
+}
+
+// formatUserUsage форматирует расход токенов пользователя
+func formatUserUsage(userID int64, usage services.UserTokenUsage, budget services.TokenBudget) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "💰 Токены AI пользователя %d\n\n", userID)
+	fmt.Fprintf(&builder, "📅 Сегодня: %d / %s\n", usage.DayTokens, formatLimit(budget.Daily))
+	fmt.Fprintf(&builder, "🗓️ В этом месяце: %d / %s\n", usage.MonthTokens, formatLimit(budget.Monthly))
+	fmt.Fprintf(&builder, "📊 Всего: %d токенов, %d запросов\n", usage.Total.TotalTokens(), usage.Total.Requests)
+
+	if len(usage.Features) > 0 {
+		builder.WriteString("\n🧩 По функциям:\n")
+		writeCounters(&builder, usage.Features)
+	}
+	if len(usage.Models) > 0 {
+		builder.WriteString("\n🤖 По моделям:\n")
+		writeCounters(&builder, usage.Models)
+	}
+	return builder.String()
+}
+
+// writeCounters выводит счетчики в порядке ключей
+func writeCounters(builder *strings.Builder, counters map[string]*services.TokenCounter) {
+	keys := make([]string, 0, len(counters))
+	for key := range counters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		counter := counters[key]
+		fmt.Fprintf(builder, "• %s: %d (вход %d, выход %d), запросов: %d\n",
+			key, counter.TotalTokens(), counter.PromptTokens, counter.CompletionTokens, counter.Requests)
+	}
+}
+
+// sendText отправляет простое текстовое сообщение
+func (h *Handler) sendText(chatID int64, text string) error {
+	msg := tgbotapi.NewMessage(chatID, text)
+	_, err := h.bot.Send(msg)
+	return err
+}
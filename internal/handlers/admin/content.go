@@ -0,0 +1,356 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/godofphonk/lovifyy-bot/internal/content"
+	"github.com/godofphonk/lovifyy-bot/internal/exercises"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// contentPreviewLimit максимальная длина текста в сообщении (лимит Telegram 4096 символов)
+	contentPreviewLimit = 3500
+	// historyPageSize сколько последних версий показывается в истории
+	historyPageSize = 10
+)
+
+// weekFieldNames названия полей недели для админских сообщений
+var weekFieldNames = map[string]string{
+	"title":     "заголовок",
+	"welcome":   "приветствие",
+	"questions": "упражнения",
+	"tips":      "подсказки",
+	"insights":  "инсайт",
+	"joint":     "совместные вопросы",
+	"diary":     "инструкции для дневника",
+}
+
+// HandleSetContent обрабатывает команды /setprompt и /setwelcome
+func (h *Handler) HandleSetContent(message *tgbotapi.Message, key string) error {
+	chatID := message.Chat.ID
+
+	if !h.userManager.IsAdmin(message.From.ID) {
+		return h.sendText(chatID, "❌ Эта команда доступна только администраторам.")
+	}
+
+	text := strings.TrimSpace(message.CommandArguments())
+	if text == "" {
+		return h.sendText(chatID, fmt.Sprintf("❌ Укажите новый текст: /%s <текст>", message.Command()))
+	}
+
+	version, err := h.content.Set(key, text, message.From.ID)
+	if errors.Is(err, content.ErrUnchanged) {
+		return h.sendText(chatID, "ℹ️ Текст совпадает с текущей версией, ничего не изменилось.")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save content %s: %w", key, err)
+	}
+
+	return h.sendWithHistoryButton(chatID, key,
+		fmt.Sprintf("✅ Сохранено: %s, версия %d", contentTitle(key), version.Number))
+}
+
+// HandleSetWeek обрабатывает команду /setweek <неделя> <поле> <значение>
+func (h *Handler) HandleSetWeek(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+
+	if !h.userManager.IsAdmin(message.From.ID) {
+		return h.sendText(chatID, "❌ Эта команда доступна только администраторам.")
+	}
+
+	weekStr, rest := cutWord(message.CommandArguments())
+	field, value := cutWord(rest)
+	week, err := strconv.Atoi(weekStr)
+	if err != nil || week < 1 || week > exercises.TotalWeeks || !slices.Contains(exercises.WeekFields, field) || value == "" {
+		return h.sendText(chatID, fmt.Sprintf("❌ Формат: /setweek <1-%d> <поле> <значение>\n\n"+
+			"Поля: %s", exercises.TotalWeeks, strings.Join(exercises.WeekFields, ", ")))
+	}
+
+	// Версию сохраняем до изменения недели, чтобы исходный текст попал в историю
+	key := content.WeekKey(week, field)
+	version, err := h.content.Set(key, value, message.From.ID)
+	if errors.Is(err, content.ErrUnchanged) {
+		return h.sendText(chatID, "ℹ️ Значение совпадает с текущим, ничего не изменилось.")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save content %s: %w", key, err)
+	}
+	if err := h.exerciseManager.SaveWeekField(week, field, version.Text); err != nil {
+		return fmt.Errorf("failed to save week field: %w", err)
+	}
+
+	return h.sendWithHistoryButton(chatID, key,
+		fmt.Sprintf("✅ Сохранено: %s, версия %d", contentTitle(key), version.Number))
+}
+
+// HandleShowContent обрабатывает команды /prompt и /welcome
+func (h *Handler) HandleShowContent(message *tgbotapi.Message, key string) error {
+	if !h.userManager.IsAdmin(message.From.ID) {
+		return h.sendText(message.Chat.ID, "❌ Эта команда доступна только администраторам.")
+	}
+	return h.showContent(message.Chat.ID, key)
+}
+
+// HandleVersions обрабатывает команду /versions [ключ]
+func (h *Handler) HandleVersions(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+
+	if !h.userManager.IsAdmin(message.From.ID) {
+		return h.sendText(chatID, "❌ Эта команда доступна только администраторам.")
+	}
+
+	if key := strings.TrimSpace(message.CommandArguments()); key != "" {
+		return h.showHistory(chatID, key)
+	}
+
+	keys, err := h.content.Keys()
+	if err != nil {
+		return fmt.Errorf("failed to load content keys: %w", err)
+	}
+	for _, key := range []string{content.KeyWelcome, content.KeySystemPrompt} {
+		if !slices.Contains(keys, key) {
+			keys = append([]string{key}, keys...)
+		}
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, key := range keys {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📜 "+contentTitle(key), "content_history_"+key),
+		))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, "📚 Тексты бота\n\nВыберите текст, чтобы посмотреть историю версий, сравнить их и откатить изменения.")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	_, err = h.bot.Send(msg)
+	return err
+}
+
+// HandleContentCallback обрабатывает кнопки истории версий:
+// content_history_<ключ>, content_diff_<ключ>_<версия>, content_rollback_<ключ>_<версия>
+func (h *Handler) HandleContentCallback(callbackQuery *tgbotapi.CallbackQuery, data string) error {
+	chatID := callbackQuery.Message.Chat.ID
+
+	if !h.userManager.IsAdmin(callbackQuery.From.ID) {
+		return h.sendText(chatID, "❌ Эта команда доступна только администраторам.")
+	}
+
+	if key, ok := strings.CutPrefix(data, "content_history_"); ok {
+		return h.showHistory(chatID, key)
+	}
+	if ref, ok := strings.CutPrefix(data, "content_diff_"); ok {
+		key, number, err := parseVersionRef(ref)
+		if err != nil {
+			return err
+		}
+		return h.showDiff(chatID, key, number)
+	}
+	if ref, ok := strings.CutPrefix(data, "content_rollback_"); ok {
+		key, number, err := parseVersionRef(ref)
+		if err != nil {
+			return err
+		}
+		return h.rollback(chatID, callbackQuery.From.ID, key, number)
+	}
+	return fmt.Errorf("unknown content callback: %s", data)
+}
+
+// showContent показывает актуальный текст с номером версии
+func (h *Handler) showContent(chatID int64, key string) error {
+	current, err := h.content.Current(key)
+	if err != nil {
+		return fmt.Errorf("failed to load content %s: %w", key, err)
+	}
+
+	header := fmt.Sprintf("📄 %s (исходная версия)", contentTitle(key))
+	if current != nil {
+		header = fmt.Sprintf("📄 %s (версия %d от %s)", contentTitle(key), current.Number, current.CreatedAt.Format("02.01.2006 15:04"))
+	}
+
+	hint := ""
+	switch key {
+	case content.KeySystemPrompt:
+		hint = "\n\n💡 Для изменения используйте:\n/setprompt <новый промпт>"
+	case content.KeyWelcome:
+		hint = "\n\n💡 Для изменения используйте:\n/setwelcome <новое приветствие>"
+	}
+
+	return h.sendWithHistoryButton(chatID, key,
+		header+"\n\n"+truncateText(h.content.Get(key), contentPreviewLimit)+hint)
+}
+
+// showHistory показывает последние версии текста
+func (h *Handler) showHistory(chatID int64, key string) error {
+	versions, err := h.content.History(key)
+	if err != nil {
+		return fmt.Errorf("failed to load content history: %w", err)
+	}
+	if len(versions) == 0 {
+		return h.sendText(chatID, fmt.Sprintf("📜 У текста «%s» еще нет сохраненных версий.", contentTitle(key)))
+	}
+
+	current := versions[len(versions)-1]
+	var text strings.Builder
+	fmt.Fprintf(&text, "📜 История: %s\n\n", contentTitle(key))
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for i := len(versions) - 1; i >= 0 && i >= len(versions)-historyPageSize; i-- {
+		v := versions[i]
+		mark := "▫️"
+		if v.Number == current.Number {
+			mark = "✅"
+		}
+		fmt.Fprintf(&text, "%s v%d • %s • %s", mark, v.Number, v.CreatedAt.Format("02.01.2006 15:04"), versionAuthor(v))
+		if v.RollbackOf > 0 {
+			fmt.Fprintf(&text, " • откат к v%d", v.RollbackOf)
+		}
+		fmt.Fprintf(&text, "\n%s\n\n", truncateText(strings.ReplaceAll(v.Text, "\n", " "), 80))
+
+		if v.Number == current.Number {
+			continue
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🔍 v%d", v.Number), fmt.Sprintf("content_diff_%s_%d", key, v.Number)))
+		if len(row) == 4 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	text.WriteString("Нажмите на версию, чтобы сравнить ее с текущей и откатить.")
+
+	msg := tgbotapi.NewMessage(chatID, text.String())
+	if len(rows) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+	_, err = h.bot.Send(msg)
+	return err
+}
+
+// showDiff показывает, что изменится при откате к версии number
+func (h *Handler) showDiff(chatID int64, key string, number int) error {
+	target, err := h.content.GetVersion(key, number)
+	if err != nil {
+		return h.sendText(chatID, "❌ "+err.Error())
+	}
+	current, err := h.content.Current(key)
+	if err != nil || current == nil {
+		return fmt.Errorf("failed to load current content %s: %w", key, err)
+	}
+
+	diff := content.Diff(current.Text, target.Text)
+	text := fmt.Sprintf("🔍 %s: v%d (текущая) → v%d\n\n%s",
+		contentTitle(key), current.Number, target.Number, truncateText(diff, contentPreviewLimit))
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("↩️ Откатить к v%d", target.Number), fmt.Sprintf("content_rollback_%s_%d", key, target.Number)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📜 К истории", "content_history_"+key),
+		),
+	)
+	_, err = h.bot.Send(msg)
+	return err
+}
+
+// rollback возвращает текст к версии number и применяет его
+func (h *Handler) rollback(chatID, adminID int64, key string, number int) error {
+	version, err := h.content.Rollback(key, number, adminID)
+	if errors.Is(err, content.ErrUnchanged) {
+		return h.sendText(chatID, fmt.Sprintf("ℹ️ Версия %d уже актуальна.", number))
+	}
+	if err != nil {
+		return h.sendText(chatID, "❌ Не удалось откатить: "+err.Error())
+	}
+
+	// Поля недель хранятся в упражнениях, остальные тексты читаются из хранилища напрямую
+	if week, field, ok := content.ParseWeekKey(key); ok {
+		if err := h.exerciseManager.SaveWeekField(week, field, version.Text); err != nil {
+			return fmt.Errorf("failed to save week field: %w", err)
+		}
+	}
+
+	return h.sendWithHistoryButton(chatID, key,
+		fmt.Sprintf("↩️ %s: восстановлена версия %d (сохранена как версия %d)", contentTitle(key), number, version.Number))
+}
+
+// sendWithHistoryButton отправляет сообщение с кнопкой истории версий
+func (h *Handler) sendWithHistoryButton(chatID int64, key, text string) error {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📜 История версий", "content_history_"+key),
+		),
+	)
+	_, err := h.bot.Send(msg)
+	return err
+}
+
+// contentTitle возвращает человекочитаемое название текста
+func contentTitle(key string) string {
+	switch key {
+	case content.KeySystemPrompt:
+		return "Системный промпт"
+	case content.KeyWelcome:
+		return "Приветствие"
+	}
+	if week, field, ok := content.ParseWeekKey(key); ok {
+		name := weekFieldNames[field]
+		if name == "" {
+			name = field
+		}
+		return fmt.Sprintf("Неделя %d: %s", week, name)
+	}
+	return key
+}
+
+// versionAuthor описывает автора версии
+func versionAuthor(v content.Version) string {
+	if v.Author == 0 {
+		return "исходный текст"
+	}
+	return fmt.Sprintf("админ %d", v.Author)
+}
+
+// parseVersionRef разбирает "<ключ>_<версия>" из callback данных
+func parseVersionRef(ref string) (string, int, error) {
+	idx := strings.LastIndex(ref, "_")
+	if idx <= 0 {
+		return "", 0, fmt.Errorf("invalid content version reference: %s", ref)
+	}
+	number, err := strconv.Atoi(ref[idx+1:])
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid content version number: %s", ref)
+	}
+	return ref[:idx], number, nil
+}
+
+// cutWord отделяет первое слово от остатка строки (переносы строк в остатке сохраняются)
+func cutWord(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	idx := strings.IndexFunc(s, unicode.IsSpace)
+	if idx < 0 {
+		return s, ""
+	}
+	return s[:idx], strings.TrimSpace(s[idx:])
+}
+
+// truncateText обрезает текст до limit символов, не разрывая UTF-8 последовательности
+func truncateText(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit]) + "…"
+}
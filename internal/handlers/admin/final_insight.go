@@ -40,7 +40,7 @@ func (h *Handler) HandleFinalInsightMenu(callbackQuery *tgbotapi.CallbackQuery)
 }
 
 // HandleGenerateFinalInsight генерирует и отправляет финальный инсайт
-func (h *Handler) HandleGenerateFinalInsight(callbackQuery *tgbotapi.CallbackQuery, historyManager *history.Manager, aiClient *ai.OpenAIClient) error {
+func (h *Handler) HandleGenerateFinalInsight(callbackQuery *tgbotapi.CallbackQuery, historyManager *history.Manager, aiClient ai.AIClient) error {
 	userID := callbackQuery.From.ID
 
 	// Отправляем сообщение о начале генерации
@@ -123,7 +123,7 @@ func (h *Handler) HandleGenerateFinalInsight(callbackQuery *tgbotapi.CallbackQue
 	
 	contextBuilder.WriteString("\n=== ЗАПИСИ В ДНЕВНИКЕ ===\n")
 	for i, entry := range diaryHistory {
-		contextBuilder.WriteString(fmt.Sprintf("Запись %d: %s\n", i+1, entry.Entry))
+		contextBuilder.WriteString(fmt.Sprintf("Запись %d: %s\n", i+1, entry.InsightText()))
 	}
 
 	// Создаем промпт для финального инсайта
@@ -144,8 +144,11 @@ func (h *Handler) HandleGenerateFinalInsight(callbackQuery *tgbotapi.CallbackQue
 	// Генерируем инсайт через AI
 	insight, err := aiClient.Generate(prompt)
 	if err != nil {
-		errorMsg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, 
-			"❌ Ошибка при генерации инсайта. Попробуйте позже.")
+		text := "❌ Ошибка при генерации инсайта. Попробуйте позже."
+		if ai.IsUnavailable(err) {
+			text = ai.FallbackInsight
+		}
+		errorMsg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, text)
 		h.bot.Send(errorMsg)
 		return err
 	}
@@ -0,0 +1,93 @@
+package admin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/exercises"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// HandleProgress обрабатывает команду /progress <user_id> [неделя|auto|reset]
+func (h *Handler) HandleProgress(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+
+	if !h.userManager.IsAdmin(message.From.ID) {
+		msg := tgbotapi.NewMessage(chatID, "❌ Эта команда доступна только администраторам.")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	args := strings.Fields(message.CommandArguments())
+	if len(args) == 0 || len(args) > 2 {
+		msg := tgbotapi.NewMessage(chatID, "📍 Управление прогрессом пользователя\n\n"+
+			"Использование:\n"+
+			"`/progress <user_id>` - посмотреть прогресс\n"+
+			"`/progress <user_id> <1-4>` - зафиксировать неделю\n"+
+			"`/progress <user_id> auto` - вернуть автоматическое открытие\n"+
+			"`/progress <user_id> reset` - начать программу заново")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	targetID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, "❌ Некорректный ID пользователя")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	if len(args) == 2 {
+		switch args[1] {
+		case "auto":
+			err = h.progress.SetOverride(targetID, 0)
+		case "reset":
+			err = h.progress.Reset(targetID)
+		default:
+			week, convErr := strconv.Atoi(args[1])
+			if convErr != nil || week < 1 || week > exercises.TotalWeeks {
+				msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Неделя должна быть числом от 1 до %d, auto или reset", exercises.TotalWeeks))
+				_, err := h.bot.Send(msg)
+				return err
+			}
+			err = h.progress.SetOverride(targetID, week)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update progress: %w", err)
+		}
+	}
+
+	progress, err := h.progress.Get(targetID)
+	if err != nil {
+		return fmt.Errorf("failed to load progress: %w", err)
+	}
+
+	now := time.Now()
+	mode := "автоматически"
+	if progress.OverrideWeek > 0 {
+		mode = "зафиксирована администратором"
+	}
+
+	completed := "нет"
+	if len(progress.CompletedWeeks) > 0 {
+		weeks := make([]string, len(progress.CompletedWeeks))
+		for i, week := range progress.CompletedWeeks {
+			weeks[i] = strconv.Itoa(week)
+		}
+		completed = strings.Join(weeks, ", ")
+	}
+
+	response := fmt.Sprintf("📍 Прогресс пользователя %d\n\n"+
+		"🗓️ Старт программы: %s\n"+
+		"📖 Текущая неделя: %d из %d (%s)\n"+
+		"👫 Совместные вопросы завершены: %s",
+		targetID, progress.StartedAt.Format("02.01.2006"),
+		progress.CurrentWeek(now), exercises.TotalWeeks, mode, completed)
+
+	msg := tgbotapi.NewMessage(chatID, response)
+	_, err = h.bot.Send(msg)
+	return err
+}
@@ -1,6 +1,8 @@
 package admin
 
 import (
+	"github.com/godofphonk/lovifyy-bot/internal/content"
+
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -14,13 +16,7 @@ func (h *Handler) HandlePrompt(callbackQuery *tgbotapi.CallbackQuery) error {
 		return err
 	}
 
-	response := "🤖 Текущий системный промпт:\n\n" +
-		"Ты опытный психолог и консультант по отношениям и личностному росту пар и людей.\n\n" +
-		"💡 Для изменения используйте:\n/setprompt <новый промпт>"
-	
-	msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, response)
-	_, err := h.bot.Send(msg)
-	return err
+	return h.showContent(callbackQuery.Message.Chat.ID, content.KeySystemPrompt)
 }
 
 // HandleSetPromptMenu обрабатывает нажатие кнопки "Изменить промпт"
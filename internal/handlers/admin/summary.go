@@ -0,0 +1,55 @@
+package admin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// HandleSummary обрабатывает команду /summary <user_id> [reset]
+func (h *Handler) HandleSummary(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+
+	if !h.userManager.IsAdmin(message.From.ID) {
+		return h.sendText(chatID, "❌ Эта команда доступна только администраторам.")
+	}
+
+	args := strings.Fields(message.CommandArguments())
+	if len(args) == 0 || len(args) > 2 || (len(args) == 2 && args[1] != "reset") {
+		return h.sendText(chatID, "🧠 Содержание переписки пользователя\n\n"+
+			"Использование:\n"+
+			"`/summary <user_id>` - посмотреть сжатое содержание старых разговоров\n"+
+			"`/summary <user_id> reset` - удалить содержание")
+	}
+
+	targetID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return h.sendText(chatID, "❌ Некорректный ID пользователя")
+	}
+
+	if len(args) == 2 {
+		if err := h.historyManager.ResetChatSummary(targetID); err != nil {
+			return fmt.Errorf("failed to reset chat summary: %w", err)
+		}
+		return h.sendText(chatID, fmt.Sprintf("✅ Содержание переписки пользователя %d удалено", targetID))
+	}
+
+	summary, err := h.historyManager.GetChatSummary(targetID)
+	if err != nil {
+		return fmt.Errorf("failed to load chat summary: %w", err)
+	}
+	if summary == nil {
+		return h.sendText(chatID, fmt.Sprintf("🧠 У пользователя %d пока нет содержания переписки", targetID))
+	}
+
+	return h.sendText(chatID, fmt.Sprintf("🧠 Содержание переписки пользователя %d\n\n"+
+		"Сжато обменов: %d\n"+
+		"Включает сообщения до: %s\n"+
+		"Обновлено: %s\n\n%s",
+		targetID, summary.MessagesCount,
+		summary.SummarizedUntil.Format("02.01.2006 15:04"),
+		summary.UpdatedAt.Format("02.01.2006 15:04"),
+		summary.Summary))
+}
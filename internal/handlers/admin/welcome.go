@@ -1,6 +1,8 @@
 package admin
 
 import (
+	"github.com/godofphonk/lovifyy-bot/internal/content"
+
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -14,13 +16,7 @@ func (h *Handler) HandleWelcome(callbackQuery *tgbotapi.CallbackQuery) error {
 		return err
 	}
 
-	response := "👋 Текущее приветственное сообщение:\n\n" +
-		"Привет, дорогие! 👋💖 Я так рад видеть вас здесь и вместе отправиться в это маленькое путешествие по вашим отношениям! 🫂\n\n" +
-		"💡 Для изменения используйте:\n/setwelcome <новое приветствие>"
-	
-	msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, response)
-	_, err := h.bot.Send(msg)
-	return err
+	return h.showContent(callbackQuery.Message.Chat.ID, content.KeyWelcome)
 }
 
 // HandleSetWelcomeMenu обрабатывает нажатие кнопки "Изменить приветствие"
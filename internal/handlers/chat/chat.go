@@ -23,7 +23,9 @@ func NewHandler(bot *tgbotapi.BotAPI, userManager *models.UserManager) *Handler
 // HandleChat обрабатывает нажатие кнопки "Задать вопрос о отношениях"
 func (h *Handler) HandleChat(callbackQuery *tgbotapi.CallbackQuery) error {
 	userID := callbackQuery.From.ID
-	h.userManager.SetState(userID, "chat")
+	if err := h.userManager.SetUserState(userID, models.State{Kind: models.StateChat}); err != nil {
+		return err
+	}
 
 	response := "💬 Режим обычной беседы активирован!\n\n" +
 		"Теперь просто напишите мне любое сообщение, и я отвечу как обычный собеседник. " +
@@ -2,15 +2,19 @@ package handlers
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/godofphonk/lovifyy-bot/internal/ai"
+	"github.com/godofphonk/lovifyy-bot/internal/content"
 	"github.com/godofphonk/lovifyy-bot/internal/exercises"
 	"github.com/godofphonk/lovifyy-bot/internal/handlers/admin"
 	"github.com/godofphonk/lovifyy-bot/internal/handlers/chat"
+	"github.com/godofphonk/lovifyy-bot/internal/handlers/couple"
 	"github.com/godofphonk/lovifyy-bot/internal/handlers/diary"
 	exerciseHandlers "github.com/godofphonk/lovifyy-bot/internal/handlers/exercises"
 	"github.com/godofphonk/lovifyy-bot/internal/handlers/scheduling"
+	"github.com/godofphonk/lovifyy-bot/internal/handlers/settings"
 	"github.com/godofphonk/lovifyy-bot/internal/history"
 	"github.com/godofphonk/lovifyy-bot/internal/models"
 	"github.com/godofphonk/lovifyy-bot/internal/services"
@@ -25,35 +29,52 @@ type CommandHandler struct {
 	exerciseManager     *exercises.Manager
 	notificationService *services.NotificationService
 	historyManager      *history.Manager
-	ai                  *ai.OpenAIClient
+	coupleStorage       *models.CoupleStorage
+	progress            *exercises.ProgressTracker
+	tokenUsage          *services.TokenUsageService
+	content             *content.Store
+	ai                  ai.StreamingAIClient
 
 	// Специализированные обработчики
 	adminHandler      *admin.Handler
 	exerciseHandler   *exerciseHandlers.Handler
 	diaryHandler      *diary.Handler
 	chatHandler       *chat.Handler
+	coupleHandler     *couple.Handler
 	schedulingHandler *scheduling.Handler
+	settingsHandler   *settings.Handler
 }
 
 // NewCommandHandler создает новый обработчик команд
-func NewCommandHandler(bot *tgbotapi.BotAPI, userManager *models.UserManager, exerciseManager *exercises.Manager, notificationService *services.NotificationService, historyManager *history.Manager, ai *ai.OpenAIClient) *CommandHandler {
+func NewCommandHandler(bot *tgbotapi.BotAPI, userManager *models.UserManager, exerciseManager *exercises.Manager, notificationService *services.NotificationService, historyManager *history.Manager, coupleStorage *models.CoupleStorage, progress *exercises.ProgressTracker, tokenUsage *services.TokenUsageService, contentStore *content.Store, ai ai.StreamingAIClient) *CommandHandler {
 	return &CommandHandler{
 		bot:                 bot,
 		userManager:         userManager,
 		exerciseManager:     exerciseManager,
 		notificationService: notificationService,
 		historyManager:      historyManager,
+		coupleStorage:       coupleStorage,
+		progress:            progress,
+		tokenUsage:          tokenUsage,
+		content:             contentStore,
 		ai:                  ai,
 		
 		// Инициализируем специализированные обработчики
-		adminHandler:      admin.NewHandler(bot, userManager, exerciseManager, notificationService),
-		exerciseHandler:   exerciseHandlers.NewHandler(bot, userManager, exerciseManager),
-		diaryHandler:      diary.NewHandler(bot, userManager, exerciseManager, historyManager),
+		adminHandler:      admin.NewHandler(bot, userManager, exerciseManager, notificationService, progress, tokenUsage, historyManager, contentStore),
+		exerciseHandler:   exerciseHandlers.NewHandler(bot, userManager, exerciseManager, progress),
+		diaryHandler:      diary.NewHandler(bot, userManager, exerciseManager, historyManager, coupleStorage),
 		chatHandler:       chat.NewHandler(bot, userManager),
+		coupleHandler:     couple.NewHandler(bot, userManager, coupleStorage),
 		schedulingHandler: scheduling.NewHandler(bot, userManager, notificationService),
+		settingsHandler:   settings.NewHandler(bot, userManager, notificationService),
 	}
 }
 
+// WelcomeText возвращает приветственное сообщение, которое администраторы редактируют через /setwelcome
+func (ch *CommandHandler) WelcomeText() string {
+	return ch.content.Get(content.KeyWelcome)
+}
+
 // HandleStart обрабатывает команду /start точно как в legacy
 func (ch *CommandHandler) HandleStart(update tgbotapi.Update) error {
 	userID := update.Message.From.ID
@@ -63,21 +84,18 @@ func (ch *CommandHandler) HandleStart(update tgbotapi.Update) error {
 	ch.notificationService.RegisterUser(userID, username)
 	ch.notificationService.UpdateUserActivity(userID)
 	
-	ch.userManager.ClearState(userID)
-
-	// Точное приветственное сообщение из legacy
-	welcomeText := "Привет, дорогие! 👋💖 Я так рад видеть вас здесь и вместе отправиться в это маленькое путешествие по вашим отношениям! 🫂\n\n" +
-		"Этот чат создан для того, чтобы каждый день находить моменты радости, тепла и взаимопонимания, замечать друг друга и вместе делать ваши отношения ещё более счастливыми. Здесь есть несколько мест, которые помогут вам в этом:\n\n" +
-		"1️⃣ Упражнение недели 💑\n" +
-		"Каждую неделю я буду предлагать одно задание, которое помогает лучше понимать друг друга, делиться чувствами и развивать приятные привычки общения.\n" +
-		"Важно: всё, что вы делаете в упражнениях, нужно фиксировать в мини-дневнике, чтобы видеть свой прогресс и маленькие успехи. 💗\n\n" +
-		"2️⃣ Мини-дневник 💌\n" +
-		"Это место для ежедневных коротких заметок о ваших наблюдениях, открытиях и шагах в отношениях. Даже одно предложение в день помогает закреплять навыки, видеть рост ваших отношений и отмечать позитивные изменения.\n\n" +
-		"💡 Совет: не переживайте о форме или идеальности записей — главное, чтобы это было честно и от сердца. Мини-дневник помогает закреплять всё, чему вы учитесь в упражнениях недели, и видеть положительные изменения в отношениях.\n\n" +
-		"3️⃣ Задать вопрос о отношениях 💒\n" +
-		"Вы можете написать мне любой вопрос о ваших отношениях в любое время. Я дам совет или подсказку, чтобы общение и взаимопонимание стало ещё теплее. Это работает отдельно от упражнений и дневника, когда захотите. 🫶🏻\n\n" +
-		"💌 Совет от меня: наслаждайтесь процессом, замечайте маленькие радости, делитесь впечатлениями и фиксируйте всё в мини-дневнике.\n" +
-		"Ваши отношения уникальны, и каждая честная беседа, каждое маленькое внимание друг к другу делает их крепче и теплее. 💒🎀"
+	if err := ch.userManager.ClearState(userID); err != nil {
+		return err
+	}
+
+	// Приглашение в пару по deep link: /start pair_<code>
+	if args := update.Message.CommandArguments(); strings.HasPrefix(args, couple.DeepLinkPrefix) {
+		if err := ch.coupleHandler.AcceptInvite(update.Message.Chat.ID, update.Message.From, strings.TrimPrefix(args, couple.DeepLinkPrefix)); err != nil {
+			return err
+		}
+	}
+
+	welcomeText := ch.WelcomeText()
 
 	// Создаем простую inline клавиатуру с тремя основными функциями
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
@@ -90,6 +108,9 @@ func (ch *CommandHandler) HandleStart(update tgbotapi.Update) error {
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("💒 Задать вопрос о отношениях", "chat"),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("💞 Пара", "pair"),
+		),
 	)
 
 	// Добавляем админские кнопки для администраторов
@@ -102,8 +123,138 @@ func (ch *CommandHandler) HandleStart(update tgbotapi.Update) error {
 
 	msg := tgbotapi.NewMessage(userID, welcomeText)
 	msg.ReplyMarkup = keyboard
-	_, err := ch.bot.Send(msg)
-	return err
+	if _, err := ch.bot.Send(msg); err != nil {
+		return err
+	}
+
+	// Онбординг: просим выбрать часовой пояс, чтобы напоминания приходили вовремя
+	if ch.settingsHandler.NeedsTimezone(userID) {
+		return ch.settingsHandler.ShowTimezonePicker(update.Message.Chat.ID, userID)
+	}
+	return nil
+}
+
+// HandleTimezone обрабатывает команду /timezone
+func (ch *CommandHandler) HandleTimezone(update tgbotapi.Update) error {
+	return ch.settingsHandler.HandleTimezoneCommand(update.Message)
+}
+
+// HandleTimezoneInput обрабатывает ручной ввод часового пояса
+func (ch *CommandHandler) HandleTimezoneInput(userID int64, text string) error {
+	return ch.settingsHandler.HandleTimezoneInput(userID, text)
+}
+
+// HandleSettings обрабатывает команду /settings
+func (ch *CommandHandler) HandleSettings(update tgbotapi.Update) error {
+	return ch.settingsHandler.HandleSettingsCommand(update.Message)
+}
+
+// HandleQuietHoursInput обрабатывает ручной ввод тихих часов
+func (ch *CommandHandler) HandleQuietHoursInput(userID int64, text string) error {
+	return ch.settingsHandler.HandleQuietHoursInput(userID, text)
+}
+
+// HandleReminderTimeInput обрабатывает ручной ввод времени напоминаний
+func (ch *CommandHandler) HandleReminderTimeInput(userID int64, text string) error {
+	return ch.settingsHandler.HandleReminderTimeInput(userID, text)
+}
+
+// HandlePair обрабатывает команду /pair
+func (ch *CommandHandler) HandlePair(update tgbotapi.Update) error {
+	return ch.coupleHandler.HandlePair(update)
+}
+
+// HandleProgress обрабатывает админскую команду /progress
+func (ch *CommandHandler) HandleProgress(update tgbotapi.Update) error {
+	return ch.adminHandler.HandleProgress(update.Message)
+}
+
+// HandleBudget обрабатывает админскую команду /budget
+func (ch *CommandHandler) HandleBudget(update tgbotapi.Update) error {
+	return ch.adminHandler.HandleBudget(update.Message)
+}
+
+// HandleSetPrompt обрабатывает админскую команду /setprompt
+func (ch *CommandHandler) HandleSetPrompt(update tgbotapi.Update) error {
+	return ch.adminHandler.HandleSetContent(update.Message, content.KeySystemPrompt)
+}
+
+// HandleSetWelcome обрабатывает админскую команду /setwelcome
+func (ch *CommandHandler) HandleSetWelcome(update tgbotapi.Update) error {
+	return ch.adminHandler.HandleSetContent(update.Message, content.KeyWelcome)
+}
+
+// HandleShowPrompt обрабатывает админскую команду /prompt
+func (ch *CommandHandler) HandleShowPrompt(update tgbotapi.Update) error {
+	return ch.adminHandler.HandleShowContent(update.Message, content.KeySystemPrompt)
+}
+
+// HandleShowWelcome обрабатывает админскую команду /welcome
+func (ch *CommandHandler) HandleShowWelcome(update tgbotapi.Update) error {
+	return ch.adminHandler.HandleShowContent(update.Message, content.KeyWelcome)
+}
+
+// HandleVersions обрабатывает админскую команду /versions
+func (ch *CommandHandler) HandleVersions(update tgbotapi.Update) error {
+	return ch.adminHandler.HandleVersions(update.Message)
+}
+
+// HandleSummary обрабатывает админскую команду /summary
+func (ch *CommandHandler) HandleSummary(update tgbotapi.Update) error {
+	return ch.adminHandler.HandleSummary(update.Message)
+}
+
+// HandleSegment обрабатывает админскую команду /segment
+func (ch *CommandHandler) HandleSegment(update tgbotapi.Update) error {
+	return ch.schedulingHandler.HandleSegmentCommand(update.Message)
+}
+
+// deepLinkWeek возвращает неделю для кнопки из уведомления: суффикс _<неделя> или текущая неделя пользователя
+func (ch *CommandHandler) deepLinkWeek(userID int64, suffix string) int {
+	if week, err := strconv.Atoi(strings.TrimPrefix(suffix, "_")); err == nil && week >= 1 && week <= exercises.TotalWeeks {
+		return week
+	}
+	return ch.progress.CurrentWeek(userID)
+}
+
+// HandleTemplate обрабатывает админскую команду /template
+func (ch *CommandHandler) HandleTemplate(update tgbotapi.Update) error {
+	return ch.schedulingHandler.HandleTemplateCommand(update.Message)
+}
+
+// HandleCustomDraftInput обрабатывает ввод текста кастомного уведомления для планирования
+func (ch *CommandHandler) HandleCustomDraftInput(userID int64, text string, state models.State) error {
+	return ch.schedulingHandler.HandleCustomDraftInput(userID, text, state)
+}
+
+// HandleCustomDraftMedia обрабатывает фото или документ для черновика кастомного уведомления
+func (ch *CommandHandler) HandleCustomDraftMedia(userID int64, caption string, media models.NotificationMedia, state models.State) error {
+	return ch.schedulingHandler.HandleCustomDraftMedia(userID, caption, media, state)
+}
+
+// HandleMood обрабатывает команду /mood
+func (ch *CommandHandler) HandleMood(update tgbotapi.Update) error {
+	return ch.diaryHandler.HandleMoodCommand(update.Message)
+}
+
+// DiaryMoodKeyboard возвращает кнопки оценки настроения сохраненной записи дневника
+func (ch *CommandHandler) DiaryMoodKeyboard(entryID string) tgbotapi.InlineKeyboardMarkup {
+	return diary.MoodKeyboard(entryID)
+}
+
+// HandleDiaryEditInput сохраняет исправленный текст записи дневника
+func (ch *CommandHandler) HandleDiaryEditInput(userID int64, text string, state models.State) error {
+	return ch.diaryHandler.HandleDiaryEditInput(userID, text, state)
+}
+
+// HandleCustomDraftTimeInput обрабатывает ввод даты и времени отправки черновика
+func (ch *CommandHandler) HandleCustomDraftTimeInput(userID int64, text string, state models.State) error {
+	return ch.schedulingHandler.HandleCustomDraftTimeInput(userID, text, state)
+}
+
+// HandleRepeat обрабатывает админскую команду /repeat
+func (ch *CommandHandler) HandleRepeat(update tgbotapi.Update) error {
+	return ch.schedulingHandler.HandleRepeatCommand(update.Message)
 }
 
 // HandleCallback обрабатывает различные callback queries (главный роутер)
@@ -135,6 +286,8 @@ func (ch *CommandHandler) HandleCallback(update tgbotapi.Update) error {
 		return ch.adminHandler.HandleWelcome(update.CallbackQuery)
 	case data == "setwelcome_menu":
 		return ch.adminHandler.HandleSetWelcomeMenu(update.CallbackQuery)
+	case strings.HasPrefix(data, "content_"):
+		return ch.adminHandler.HandleContentCallback(update.CallbackQuery, data)
 	case data == "exercises_menu":
 		return ch.handleExercisesMenu(update.CallbackQuery)
 	case data == "notifications_menu":
@@ -142,7 +295,7 @@ func (ch *CommandHandler) HandleCallback(update tgbotapi.Update) error {
 	case data == "final_insight_menu":
 		return ch.adminHandler.HandleFinalInsightMenu(update.CallbackQuery)
 	case data == "generate_final_insight":
-		return ch.adminHandler.HandleGenerateFinalInsight(update.CallbackQuery, ch.historyManager, ch.ai)
+		return ch.adminHandler.HandleGenerateFinalInsight(update.CallbackQuery, ch.historyManager, ai.Metered(ch.ai, ch.tokenUsage, update.CallbackQuery.From.ID, ai.FeatureFinalInsight))
 	case data == "schedule_notification":
 		return ch.schedulingHandler.HandleScheduleNotification(update.CallbackQuery)
 	case data == "view_notifications":
@@ -156,6 +309,24 @@ func (ch *CommandHandler) HandleCallback(update tgbotapi.Update) error {
 	case data == "show_recipients":
 		return ch.handleShowRecipients(update.CallbackQuery)
 
+	// Часовой пояс
+	case strings.HasPrefix(data, "tz_"):
+		return ch.settingsHandler.HandleTimezoneCallback(update.CallbackQuery, data)
+	case strings.HasPrefix(data, "settings_"):
+		return ch.settingsHandler.HandleSettingsCallback(update.CallbackQuery, data)
+
+	// Пара
+	case data == "pair":
+		return ch.coupleHandler.ShowPairMenu(update.CallbackQuery.Message.Chat.ID, update.CallbackQuery.From.ID)
+	case data == "pair_gender_male":
+		return ch.coupleHandler.HandlePairGender(update.CallbackQuery, "male")
+	case data == "pair_gender_female":
+		return ch.coupleHandler.HandlePairGender(update.CallbackQuery, "female")
+	case data == "pair_unpair":
+		return ch.coupleHandler.HandleUnpair(update.CallbackQuery)
+	case data == "pair_unpair_confirm":
+		return ch.coupleHandler.HandleUnpairConfirm(update.CallbackQuery)
+
 	// Дневник
 	case data == "diary_gender_male":
 		return ch.diaryHandler.HandleDiaryGender(update.CallbackQuery, "male")
@@ -176,6 +347,30 @@ func (ch *CommandHandler) HandleCallback(update tgbotapi.Update) error {
 		return ch.diaryHandler.HandleDiaryViewWeek(update.CallbackQuery, data)
 	case strings.HasPrefix(data, "diary_view_") && !strings.HasPrefix(data, "diary_view_week_"):
 		return ch.diaryHandler.HandleDiaryViewGender(update.CallbackQuery, data)
+	case strings.HasPrefix(data, "diary_entry_"):
+		return ch.diaryHandler.HandleDiaryEntry(update.CallbackQuery, data)
+	case strings.HasPrefix(data, "diary_mood_"):
+		return ch.diaryHandler.HandleDiaryMood(update.CallbackQuery, data)
+	case strings.HasPrefix(data, "diary_full_"):
+		return ch.diaryHandler.HandleDiaryFull(update.CallbackQuery, data)
+	case strings.HasPrefix(data, "diary_file_"):
+		return ch.diaryHandler.HandleDiaryFile(update.CallbackQuery, data)
+	case strings.HasPrefix(data, "diary_edit_"):
+		return ch.diaryHandler.HandleDiaryEdit(update.CallbackQuery, data)
+	case strings.HasPrefix(data, "diary_del_"):
+		return ch.diaryHandler.HandleDiaryDelete(update.CallbackQuery, data)
+	case strings.HasPrefix(data, "diary_delok_"):
+		return ch.diaryHandler.HandleDiaryDeleteConfirm(update.CallbackQuery, data)
+
+	// Кнопки из уведомлений: открывают текущую неделю получателя или указанную в callback
+	case strings.HasPrefix(data, models.DeepLinkDiary):
+		ch.notificationService.RecordEngagement(update.CallbackQuery.From.ID, services.EngagementClick)
+		week := ch.deepLinkWeek(update.CallbackQuery.From.ID, strings.TrimPrefix(data, models.DeepLinkDiary))
+		return ch.diaryHandler.HandleDiaryOpen(update.CallbackQuery, week)
+	case strings.HasPrefix(data, models.DeepLinkExercise):
+		ch.notificationService.RecordEngagement(update.CallbackQuery.From.ID, services.EngagementClick)
+		week := ch.deepLinkWeek(update.CallbackQuery.From.ID, strings.TrimPrefix(data, models.DeepLinkExercise))
+		return ch.exerciseHandler.HandleWeek(update.CallbackQuery, week)
 
 	// Недели упражнений
 	case data == "week_1":
@@ -188,12 +383,22 @@ func (ch *CommandHandler) HandleCallback(update tgbotapi.Update) error {
 		return ch.exerciseHandler.HandleWeek(update.CallbackQuery, 4)
 
 	// Паттерны callback'ов
+	case strings.HasPrefix(data, "locked_week_"):
+		weekNum, err := strconv.Atoi(strings.TrimPrefix(data, "locked_week_"))
+		if err != nil {
+			return fmt.Errorf("invalid week number: %s", data)
+		}
+		return ch.exerciseHandler.HandleLockedWeek(update.CallbackQuery, weekNum)
 	case strings.HasPrefix(data, "week_"):
 		return ch.exerciseHandler.HandleWeekAction(update.CallbackQuery, data)
 	case strings.HasPrefix(data, "insight_"):
-		return ch.exerciseHandler.HandleInsightGender(update.CallbackQuery, data, ch.historyManager, ch.ai)
-	case strings.HasPrefix(data, "notify_send_all_"):
+		return ch.exerciseHandler.HandleInsightGender(update.CallbackQuery, data, ch.historyManager, ai.Metered(ch.ai, ch.tokenUsage, update.CallbackQuery.From.ID, ai.FeatureWeeklyInsight))
+	case strings.HasPrefix(data, "notify_send_all_") || strings.HasPrefix(data, "notify_send_seg_"):
 		return ch.handleSendAllNotifications(update.CallbackQuery, data)
+	case strings.HasPrefix(data, "notify_audience_"):
+		return ch.showNotifyAudience(update.CallbackQuery, strings.TrimPrefix(data, "notify_audience_"))
+	case strings.HasPrefix(data, "notify_abtest_") || strings.HasPrefix(data, "notify_abwin_"):
+		return ch.schedulingHandler.HandleEngagementCallback(update.CallbackQuery, data)
 	case strings.HasPrefix(data, "notify_"):
 		return ch.handleNotificationCallbacks(update.CallbackQuery.From.ID, data)
 	case strings.HasPrefix(data, "schedule_date_"):
@@ -202,6 +407,12 @@ func (ch *CommandHandler) HandleCallback(update tgbotapi.Update) error {
 		return ch.schedulingHandler.HandleScheduleTimeCallback(update.CallbackQuery, data)
 	case strings.HasPrefix(data, "schedule_type_"):
 		return ch.schedulingHandler.HandleScheduleTypeCallback(update.CallbackQuery, data)
+	case strings.HasPrefix(data, "cdraft_"):
+		return ch.schedulingHandler.HandleDraftCallback(update.CallbackQuery, data)
+	case strings.HasPrefix(data, "schedule_aud_"):
+		return ch.schedulingHandler.HandleScheduleAudienceCallback(update.CallbackQuery, data)
+	case strings.HasPrefix(data, "schedule_repeat_"):
+		return ch.schedulingHandler.HandleScheduleRepeatCallback(update.CallbackQuery, data)
 	case strings.HasPrefix(data, "schedule_custom_time_"):
 		return ch.schedulingHandler.HandleScheduleCustomTimeCallback(update.CallbackQuery, data)
 	case data == "schedule_custom_date":
@@ -279,6 +490,9 @@ func (ch *CommandHandler) handleNotificationsMenu(callbackQuery *tgbotapi.Callba
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("📤 Отправить сейчас", "send_now"),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🧪 A/B тесты шаблонов", "notify_abtest_24"),
+		),
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("🏠 В админ панель", "adminhelp"),
 		),
@@ -381,11 +595,11 @@ func (ch *CommandHandler) handleSendNow(callbackQuery *tgbotapi.CallbackQuery) e
 	text := "📤 Отправить уведомление сейчас\n\nВыберите тип уведомления:"
 	kb := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("💌 Мини-дневник", "notify_send_all_diary"),
-			tgbotapi.NewInlineKeyboardButtonData("👩🏼‍❤️‍👨🏻 Упражнение недели", "notify_send_all_exercise"),
+			tgbotapi.NewInlineKeyboardButtonData("💌 Мини-дневник", "notify_audience_diary"),
+			tgbotapi.NewInlineKeyboardButtonData("👩🏼‍❤️‍👨🏻 Упражнение недели", "notify_audience_exercise"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("💒 Мотивация", "notify_send_all_motivation"),
+			tgbotapi.NewInlineKeyboardButtonData("💒 Мотивация", "notify_audience_motivation"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("✏️ Кастомное уведомление", "notify_custom"),
@@ -412,10 +626,13 @@ func (ch *CommandHandler) handleCustomNotification(callbackQuery *tgbotapi.Callb
 	}
 
 	// Устанавливаем состояние для ввода кастомного текста
-	ch.userManager.SetState(userID, "custom_notification")
+	if err := ch.userManager.SetUserState(userID, models.State{Kind: models.StateCustomNotification}); err != nil {
+		return err
+	}
 
 	text := "✏️ Кастомное уведомление\n\n" +
-		"Напишите текст уведомления, который будет отправлен всем пользователям.\n\n" +
+		"Напишите текст уведомления, который будет отправлен всем пользователям. " +
+		"Можно отправить фото или документ с подписью.\n\n" +
 		"💡 Совет: используйте эмодзи и форматирование для лучшего восприятия."
 
 	kb := tgbotapi.NewInlineKeyboardMarkup(
@@ -441,10 +658,14 @@ func (ch *CommandHandler) handleScheduleCustomNotification(callbackQuery *tgbota
 	}
 
 	// Устанавливаем состояние для ввода кастомного текста для планирования
-	ch.userManager.SetState(userID, "custom_notification_schedule")
+	if err := ch.userManager.SetUserState(userID, models.State{Kind: models.StateCustomNotificationSchedule}); err != nil {
+		return err
+	}
 
 	text := "✏️ Кастомное уведомление для планирования\n\n" +
-		"Напишите текст уведомления, который будет запланирован для отправки.\n\n" +
+		"Напишите текст уведомления, который будет запланирован для отправки. " +
+		"Форматирование Telegram (жирный, курсив, ссылки, спойлеры) сохранится, время отправки выберете после предпросмотра.\n\n" +
+		"📎 Можно отправить фото или документ с подписью, а кнопки (например, «📝 Открыть дневник») добавить в предпросмотре.\n\n" +
 		"💡 Совет: используйте эмодзи и форматирование для лучшего восприятия."
 
 	kb := tgbotapi.NewInlineKeyboardMarkup(
@@ -493,18 +714,21 @@ func (ch *CommandHandler) handleSendAllNotifications(callbackQuery *tgbotapi.Cal
 		return err
 	}
 
-	var notificationType string
-	var typeName string
+	// notify_send_all_diary - всем, notify_send_seg_diary_s1 - сегменту s1, суффикс _p - персонально
+	data, personal := strings.CutSuffix(data, "_p")
+	notificationType := strings.TrimPrefix(data, "notify_send_all_")
+	segmentID := ""
+	if rest, ok := strings.CutPrefix(data, "notify_send_seg_"); ok {
+		notificationType, segmentID, _ = strings.Cut(rest, "_")
+	}
 
-	switch data {
-	case "notify_send_all_diary":
-		notificationType = "diary"
+	var typeName string
+	switch notificationType {
+	case "diary":
 		typeName = "💌 Мини-дневник"
-	case "notify_send_all_exercise":
-		notificationType = "exercise"
+	case "exercise":
 		typeName = "👩🏼‍❤️‍👨🏻 Упражнение недели"
-	case "notify_send_all_motivation":
-		notificationType = "motivation"
+	case "motivation":
 		typeName = "💒 Мотивация"
 	default:
 		msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, "❌ Неизвестный тип уведомления")
@@ -513,8 +737,15 @@ func (ch *CommandHandler) handleSendAllNotifications(callbackQuery *tgbotapi.Cal
 	}
 
 	// Отправляем сообщение о начале отправки
+	audience := "всем пользователям"
+	if segmentID != "" {
+		audience = "сегменту: " + ch.schedulingHandler.AudienceLabel(segmentID)
+	}
+	if personal {
+		audience += " (персонально каждому)"
+	}
 	processingMsg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, 
-		fmt.Sprintf("⏳ Отправляю уведомление %s всем пользователям...", typeName))
+		fmt.Sprintf("⏳ Отправляю уведомление %s %s...", typeName, audience))
 	_, err := ch.bot.Send(processingMsg)
 	if err != nil {
 		return err
@@ -542,8 +773,16 @@ func (ch *CommandHandler) handleSendAllNotifications(callbackQuery *tgbotapi.Cal
 		return err
 	}
 
-	// Отправляем уведомление всем пользователям
-	err = ch.notificationService.SendNotificationToAll(message)
+	// Отправляем уведомление через очередь рассылок
+	var report *services.BroadcastReport
+	switch {
+	case segmentID != "":
+		report, err = ch.notificationService.BroadcastToSegment(notificationTypeModel, message, segmentID, userID, personal)
+	case personal:
+		report, err = ch.notificationService.BroadcastPersonalized(notificationTypeModel, message, nil, userID)
+	default:
+		report, err = ch.notificationService.Broadcast(notificationTypeModel, message, nil, userID)
+	}
 	if err != nil {
 		errorMsg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, 
 			fmt.Sprintf("❌ Ошибка при отправке уведомления: %v", err))
@@ -551,13 +790,8 @@ func (ch *CommandHandler) handleSendAllNotifications(callbackQuery *tgbotapi.Cal
 		return err
 	}
 
-	// Получаем количество пользователей
-	userCount, _ := ch.notificationService.GetUserCount()
-
-	// Отправляем подтверждение с кнопкой для просмотра получателей
-	confirmText := fmt.Sprintf("✅ Уведомление %s успешно отправлено!\n\n"+
-		"👥 Получателей: %d пользователей\n"+
-		"📤 Статус: Доставлено", typeName, userCount)
+	// Отправляем отчет о доставке с кнопкой для просмотра получателей
+	confirmText := fmt.Sprintf("✅ Рассылка уведомления %s завершена!\n\n%s", typeName, report.Summary())
 	
 	// Добавляем кнопку для просмотра списка получателей
 	kb := tgbotapi.NewInlineKeyboardMarkup(
@@ -618,6 +852,18 @@ func (ch *CommandHandler) handleShowRecipients(callbackQuery *tgbotapi.CallbackQ
 		text += fmt.Sprintf("%d. %s\n   📅 Последняя активность: %s\n\n", i+1, userDisplay, lastSeen)
 	}
 
+	// Статистика оттока: кто перестал получать уведомления и почему
+	if stats, err := ch.notificationService.GetChurnStats(); err == nil {
+		text += fmt.Sprintf("📉 **Отток:** %.1f%% (активных %d, неактивных %d)\n", stats.ChurnRate(), stats.Active, stats.Inactive)
+		for _, reason := range []string{models.InactiveBlocked, models.InactiveChatNotFound, models.InactiveDeactivated, ""} {
+			if count := stats.ByReason[reason]; count > 0 {
+				text += fmt.Sprintf("   • %s: %d\n", models.InactiveReasonTitle(reason), count)
+			}
+		}
+		text += fmt.Sprintf("   Ушли за 7 дней: %d, за 30 дней: %d\n", stats.Left7d, stats.Left30d)
+		text += fmt.Sprintf("   Вернулись за 30 дней: %d\n", stats.Returned30)
+	}
+
 	// Добавляем кнопку "Назад"
 	kb := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
@@ -645,12 +891,9 @@ func (ch *CommandHandler) HandleNotify(update tgbotapi.Update) error {
 	return err
 }
 
+// HandleSetWeek обрабатывает админскую команду /setweek
 func (ch *CommandHandler) HandleSetWeek(update tgbotapi.Update) error {
-	userID := update.Message.From.ID
-	text := "⚙️ Команда /setweek временно недоступна"
-	msg := tgbotapi.NewMessage(userID, text)
-	_, err := ch.bot.Send(msg)
-	return err
+	return ch.adminHandler.HandleSetWeek(update.Message)
 }
 
 func (ch *CommandHandler) HandleAdminHelp(update tgbotapi.Update) error {
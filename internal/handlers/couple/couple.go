@@ -0,0 +1,216 @@
+package couple
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// DeepLinkPrefix префикс параметра /start для приглашения в пару
+const DeepLinkPrefix = "pair_"
+
+// Handler обрабатывает связывание партнеров в пару
+type Handler struct {
+	bot           *tgbotapi.BotAPI
+	userManager   *models.UserManager
+	coupleStorage *models.CoupleStorage
+}
+
+// NewHandler создает новый обработчик пар
+func NewHandler(bot *tgbotapi.BotAPI, userManager *models.UserManager, coupleStorage *models.CoupleStorage) *Handler {
+	return &Handler{
+		bot:           bot,
+		userManager:   userManager,
+		coupleStorage: coupleStorage,
+	}
+}
+
+// HandlePair обрабатывает команду /pair. С аргументом (/pair <код>) принимает приглашение.
+func (h *Handler) HandlePair(update tgbotapi.Update) error {
+	userID := update.Message.From.ID
+	chatID := update.Message.Chat.ID
+
+	if code := strings.TrimSpace(update.Message.CommandArguments()); code != "" {
+		return h.AcceptInvite(chatID, update.Message.From, code)
+	}
+
+	return h.ShowPairMenu(chatID, userID)
+}
+
+// ShowPairMenu показывает статус пары или предлагает создать приглашение
+func (h *Handler) ShowPairMenu(chatID, userID int64) error {
+	if couple := h.coupleStorage.GetCouple(userID); couple != nil {
+		return h.sendPairStatus(chatID, userID, couple)
+	}
+
+	response := "💑 Пара\n\n" +
+		"Свяжите свой аккаунт с аккаунтом партнера — тогда каждый будет вести дневник со своего телефона, " +
+		"а записи автоматически попадут в нужный раздел.\n\n" +
+		"Кто вы в паре?"
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("👨 Я парень", "pair_gender_male"),
+			tgbotapi.NewInlineKeyboardButtonData("👩 Я девушка", "pair_gender_female"),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(chatID, response)
+	msg.ReplyMarkup = keyboard
+	_, err := h.bot.Send(msg)
+	return err
+}
+
+// HandlePairGender создает приглашение после выбора гендера
+func (h *Handler) HandlePairGender(callbackQuery *tgbotapi.CallbackQuery, gender string) error {
+	userID := callbackQuery.From.ID
+	chatID := callbackQuery.Message.Chat.ID
+
+	invite, err := h.coupleStorage.CreateInvite(userID, gender)
+	if errors.Is(err, models.ErrAlreadyPaired) {
+		msg := tgbotapi.NewMessage(chatID, "💑 Вы уже состоите в паре. Используйте /pair, чтобы посмотреть статус.")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, "❌ Не удалось создать приглашение. Попробуйте позже.")
+		h.bot.Send(msg)
+		return fmt.Errorf("failed to create pair invite: %w", err)
+	}
+
+	link := fmt.Sprintf("https://t.me/%s?start=%s%s", h.bot.Self.UserName, DeepLinkPrefix, invite.Code)
+	response := fmt.Sprintf("💌 Приглашение для партнера создано!\n\n"+
+		"Отправьте партнеру ссылку:\n%s\n\n"+
+		"или код: %s\n(партнер может ввести /pair %s)\n\n"+
+		"⏳ Приглашение действует %d часов.",
+		link, invite.Code, invite.Code, int(models.InviteTTL.Hours()))
+
+	editMsg := tgbotapi.NewEditMessageText(chatID, callbackQuery.Message.MessageID, response)
+	_, err = h.bot.Send(editMsg)
+	return err
+}
+
+// AcceptInvite принимает приглашение по коду и уведомляет обоих партнеров
+func (h *Handler) AcceptInvite(chatID int64, from *tgbotapi.User, code string) error {
+	couple, err := h.coupleStorage.AcceptInvite(code, from.ID)
+	if err != nil {
+		var text string
+		var unexpected error
+		switch {
+		case errors.Is(err, models.ErrInviteNotFound):
+			text = "❌ Приглашение не найдено. Проверьте код или попросите партнера создать новое через /pair."
+		case errors.Is(err, models.ErrInviteExpired):
+			text = "⏳ Срок действия приглашения истек. Попросите партнера создать новое через /pair."
+		case errors.Is(err, models.ErrSelfInvite):
+			text = "🙂 Это ваше собственное приглашение — отправьте его партнеру."
+		case errors.Is(err, models.ErrAlreadyPaired):
+			text = "💑 Вы или ваш партнер уже состоите в паре."
+		default:
+			text = "❌ Не удалось принять приглашение. Попробуйте позже."
+			unexpected = fmt.Errorf("failed to accept pair invite: %w", err)
+		}
+		msg := tgbotapi.NewMessage(chatID, text)
+		h.bot.Send(msg)
+		return unexpected
+	}
+
+	gender := couple.GenderOf(from.ID)
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("💞 Вы теперь в паре!\n\n"+
+		"Ваши записи в мини-дневнике будут сохраняться как %s, а инсайты будут учитывать записи обоих партнеров.",
+		genderTitle(gender)))
+	if _, err := h.bot.Send(msg); err != nil {
+		return err
+	}
+
+	partnerName := "Ваш партнер"
+	if from.UserName != "" {
+		partnerName = "@" + from.UserName
+	}
+	partnerID := couple.PartnerOf(from.ID)
+	partnerMsg := tgbotapi.NewMessage(partnerID, fmt.Sprintf("💞 %s принял(а) ваше приглашение — теперь вы пара!\n\n"+
+		"Ваши записи в мини-дневнике будут сохраняться как %s.",
+		partnerName, genderTitle(couple.GenderOf(partnerID))))
+	_, err = h.bot.Send(partnerMsg)
+	return err
+}
+
+// HandleUnpair запрашивает подтверждение разрыва пары
+func (h *Handler) HandleUnpair(callbackQuery *tgbotapi.CallbackQuery) error {
+	response := "💔 Вы уверены, что хотите отвязать аккаунт партнера?\n\n" +
+		"Записи дневника сохранятся, но новые записи больше не будут объединяться."
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Да, отвязать", "pair_unpair_confirm"),
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", "main_menu"),
+		),
+	)
+
+	editMsg := tgbotapi.NewEditMessageText(callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID, response)
+	editMsg.ReplyMarkup = &keyboard
+	_, err := h.bot.Send(editMsg)
+	return err
+}
+
+// HandleUnpairConfirm разрывает пару и уведомляет партнера
+func (h *Handler) HandleUnpairConfirm(callbackQuery *tgbotapi.CallbackQuery) error {
+	userID := callbackQuery.From.ID
+	chatID := callbackQuery.Message.Chat.ID
+
+	couple, err := h.coupleStorage.Unpair(userID)
+	if errors.Is(err, models.ErrNotPaired) {
+		editMsg := tgbotapi.NewEditMessageText(chatID, callbackQuery.Message.MessageID, "ℹ️ Вы не состоите в паре.")
+		_, err := h.bot.Send(editMsg)
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to unpair user %d: %w", userID, err)
+	}
+
+	editMsg := tgbotapi.NewEditMessageText(chatID, callbackQuery.Message.MessageID, "💔 Аккаунты отвязаны. Создать новую пару можно через /pair.")
+	if _, err := h.bot.Send(editMsg); err != nil {
+		return err
+	}
+
+	partnerMsg := tgbotapi.NewMessage(couple.PartnerOf(userID), "💔 Партнер отвязал ваши аккаунты. Создать новую пару можно через /pair.")
+	_, err = h.bot.Send(partnerMsg)
+	return err
+}
+
+// sendPairStatus показывает информацию о текущей паре
+func (h *Handler) sendPairStatus(chatID, userID int64, couple *models.Couple) error {
+	response := fmt.Sprintf("💑 Вы состоите в паре с %s\n\n"+
+		"Ваша роль: %s\n"+
+		"📅 Пара создана: %s",
+		formatPartner(couple.PartnerOf(userID)), genderTitle(couple.GenderOf(userID)),
+		couple.CreatedAt.Format("02.01.2006"))
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("💔 Отвязать партнера", "pair_unpair"),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(chatID, response)
+	msg.ParseMode = "HTML"
+	msg.ReplyMarkup = keyboard
+	_, err := h.bot.Send(msg)
+	return err
+}
+
+// formatPartner формирует упоминание партнера со ссылкой на его профиль
+func formatPartner(partnerID int64) string {
+	return fmt.Sprintf("<a href=\"tg://user?id=%d\">партнером</a>", partnerID)
+}
+
+// genderTitle возвращает подпись роли в паре
+func genderTitle(gender string) string {
+	if gender == "male" {
+		return "👨 Парень"
+	}
+	return "👩 Девушка"
+}
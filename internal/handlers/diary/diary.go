@@ -2,6 +2,7 @@ package diary
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/godofphonk/lovifyy-bot/internal/exercises"
@@ -11,26 +12,46 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+const (
+	// diaryPageSize записей на одной странице просмотра. Вместе с diaryExcerptLimit
+	// гарантирует, что страница помещается в одно сообщение Telegram, даже если
+	// фрагменты целиком состоят из эмодзи (по две единицы UTF-16 на символ).
+	diaryPageSize = 5
+	// diaryExcerptLimit длина фрагмента записи в списке, в символах
+	diaryExcerptLimit = 300
+	// telegramMessageLimit максимальная длина сообщения Telegram в единицах UTF-16
+	telegramMessageLimit = 4096
+	// photoCaptionLimit максимальная длина подписи к фото в Telegram в единицах UTF-16
+	photoCaptionLimit = 1024
+)
+
 // Handler обрабатывает функциональность дневника
 type Handler struct {
 	bot             *tgbotapi.BotAPI
 	userManager     *models.UserManager
 	exerciseManager *exercises.Manager
 	historyManager  *history.Manager
+	coupleStorage   *models.CoupleStorage
 }
 
 // NewHandler создает новый обработчик дневника
-func NewHandler(bot *tgbotapi.BotAPI, userManager *models.UserManager, exerciseManager *exercises.Manager, historyManager *history.Manager) *Handler {
+func NewHandler(bot *tgbotapi.BotAPI, userManager *models.UserManager, exerciseManager *exercises.Manager, historyManager *history.Manager, coupleStorage *models.CoupleStorage) *Handler {
 	return &Handler{
 		bot:             bot,
 		userManager:     userManager,
 		exerciseManager: exerciseManager,
 		historyManager:  historyManager,
+		coupleStorage:   coupleStorage,
 	}
 }
 
 // HandleDiary обрабатывает нажатие кнопки "Мини-дневник" как в legacy
 func (h *Handler) HandleDiary(callbackQuery *tgbotapi.CallbackQuery) error {
+	// В паре гендер известен - сразу показываем выбор недели
+	if gender := h.coupleStorage.GenderOf(callbackQuery.From.ID); gender != "" {
+		return h.sendWeekChoice(callbackQuery.Message.Chat.ID, gender, true)
+	}
+
 	response := "📝 Мини дневник\n\n" +
 		"Выберите ваш пол для персонализированных советов и подсказок:"
 
@@ -58,6 +79,20 @@ func (h *Handler) HandleDiaryGender(callbackQuery *tgbotapi.CallbackQuery, gende
 	// Добавляем логирование для отладки
 	fmt.Printf("🔍 HandleDiaryGender called with gender: %s\n", gender)
 	
+	// В паре записи всегда привязаны к гендеру пользователя
+	if pairedGender := h.coupleStorage.GenderOf(callbackQuery.From.ID); pairedGender != "" {
+		gender = pairedGender
+	}
+
+	// Удаляем старое сообщение
+	deleteMsg := tgbotapi.NewDeleteMessage(callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID)
+	h.bot.Send(deleteMsg)
+
+	return h.sendWeekChoice(callbackQuery.Message.Chat.ID, gender, false)
+}
+
+// sendWeekChoice отправляет выбор недели для записей
+func (h *Handler) sendWeekChoice(chatID int64, gender string, paired bool) error {
 	var genderEmoji string
 	var genderText string
 	if gender == "male" {
@@ -82,15 +117,15 @@ func (h *Handler) HandleDiaryGender(callbackQuery *tgbotapi.CallbackQuery, gende
 			tgbotapi.NewInlineKeyboardButtonData("4️⃣ Неделя 4", fmt.Sprintf("diary_week_%s_4", gender)),
 		),
 	}
+	if paired {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("👀 Посмотреть записи", "diary_view"),
+		))
+	}
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	
-	// Удаляем старое сообщение
-	deleteMsg := tgbotapi.NewDeleteMessage(callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID)
-	h.bot.Send(deleteMsg)
-	
-	// Отправляем новое сообщение с новыми кнопками
-	msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, response)
+
+	msg := tgbotapi.NewMessage(chatID, response)
 	msg.ReplyMarkup = keyboard
 	_, err := h.bot.Send(msg)
 	return err
@@ -104,9 +139,39 @@ func (h *Handler) HandleDiaryWeek(callbackQuery *tgbotapi.CallbackQuery, data st
 		return fmt.Errorf("invalid diary week callback data: %s", data)
 	}
 
-	gender := parts[2]
-	week := parts[3]
+	// Удаляем старое сообщение
+	deleteMsg := tgbotapi.NewDeleteMessage(callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID)
+	h.bot.Send(deleteMsg)
+
+	return h.sendTypeChoice(callbackQuery.Message.Chat.ID, parts[2], parts[3])
+}
+
+// HandleDiaryOpen открывает дневник сразу на неделе week (кнопка из уведомления).
+// Сообщение с уведомлением остается в чате.
+func (h *Handler) HandleDiaryOpen(callbackQuery *tgbotapi.CallbackQuery, week int) error {
+	chatID := callbackQuery.Message.Chat.ID
+	if gender := h.coupleStorage.GenderOf(callbackQuery.From.ID); gender != "" {
+		return h.sendTypeChoice(chatID, gender, strconv.Itoa(week))
+	}
+
+	// Пол неизвестен: после выбора сразу откроется нужная неделя
+	response := fmt.Sprintf("📝 Мини дневник - Неделя %d\n\n"+
+		"Выберите ваш пол для персонализированных советов и подсказок:", week)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("👨 Парень", fmt.Sprintf("diary_week_male_%d", week)),
+			tgbotapi.NewInlineKeyboardButtonData("👩 Девушка", fmt.Sprintf("diary_week_female_%d", week)),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(chatID, response)
+	msg.ReplyMarkup = keyboard
+	_, err := h.bot.Send(msg)
+	return err
+}
 
+// sendTypeChoice отправляет выбор типа записи для недели
+func (h *Handler) sendTypeChoice(chatID int64, gender, week string) error {
 	var genderEmoji string
 	var genderText string
 	if gender == "male" {
@@ -134,13 +199,8 @@ func (h *Handler) HandleDiaryWeek(callbackQuery *tgbotapi.CallbackQuery, data st
 	}
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	
-	// Удаляем старое сообщение
-	deleteMsg := tgbotapi.NewDeleteMessage(callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID)
-	h.bot.Send(deleteMsg)
-	
-	// Отправляем новое сообщение с новыми кнопками
-	msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, response)
+
+	msg := tgbotapi.NewMessage(chatID, response)
 	msg.ReplyMarkup = keyboard
 	_, err := h.bot.Send(msg)
 	return err
@@ -162,9 +222,16 @@ func (h *Handler) HandleDiaryType(callbackQuery *tgbotapi.CallbackQuery, data st
 	week := parts[3]
 	diaryType := parts[4]
 
+	weekNum, err := strconv.Atoi(week)
+	if err != nil {
+		return fmt.Errorf("invalid diary week: %s", week)
+	}
+
 	userID := callbackQuery.From.ID
 	// Сохраняем состояние с полной информацией
-	h.userManager.SetState(userID, fmt.Sprintf("diary_%s_%s_%s", gender, week, diaryType))
+	if err := h.userManager.SetUserState(userID, models.NewDiaryState(gender, weekNum, diaryType)); err != nil {
+		return err
+	}
 
 	var genderEmoji string
 	var genderText string
@@ -190,14 +257,6 @@ func (h *Handler) HandleDiaryType(callbackQuery *tgbotapi.CallbackQuery, data st
 	case "questions":
 		typeText = "❓ Ответы на вопросы"
 		// Получаем вопросы недели из упражнений
-		weekNum := 1
-		switch week {
-		case "1": weekNum = 1
-		case "2": weekNum = 2
-		case "3": weekNum = 3
-		case "4": weekNum = 4
-		}
-		
 		weekData, err := h.exerciseManager.GetWeekExercise(weekNum)
 		if err != nil || weekData == nil {
 			response = fmt.Sprintf("📝 Дневник %s %s - Неделя %s\n%s\n\n"+
@@ -214,14 +273,6 @@ func (h *Handler) HandleDiaryType(callbackQuery *tgbotapi.CallbackQuery, data st
 	case "joint":
 		typeText = "👫 Ответы на совместные вопросы"
 		// Получаем совместные вопросы недели из упражнений
-		weekNum := 1
-		switch week {
-		case "1": weekNum = 1
-		case "2": weekNum = 2
-		case "3": weekNum = 3
-		case "4": weekNum = 4
-		}
-		
 		weekData, err := h.exerciseManager.GetWeekExercise(weekNum)
 		if err != nil || weekData == nil {
 			response = fmt.Sprintf("📝 Дневник %s %s - Неделя %s\n%s\n\n"+
@@ -243,7 +294,7 @@ func (h *Handler) HandleDiaryType(callbackQuery *tgbotapi.CallbackQuery, data st
 	}
 	
 	msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, response)
-	_, err := h.bot.Send(msg)
+	_, err = h.bot.Send(msg)
 	return err
 }
 
@@ -319,25 +370,25 @@ func (h *Handler) HandleDiaryViewGender(callbackQuery *tgbotapi.CallbackQuery, d
 	return err
 }
 
-// HandleDiaryViewWeek обрабатывает просмотр записей конкретной недели
+// HandleDiaryViewWeek показывает записи недели постранично: diary_view_week_<gender>_<week>[_<страница>]
 func (h *Handler) HandleDiaryViewWeek(callbackQuery *tgbotapi.CallbackQuery, data string) error {
-	// Парсим данные: diary_view_week_<gender>_<week>
 	parts := strings.Split(data, "_")
 	if len(parts) < 5 {
 		return fmt.Errorf("invalid diary view week callback data: %s", data)
 	}
 
 	gender := parts[3]
-	week := parts[4]
-	
-	weekNum := 1
-	switch week {
-	case "1": weekNum = 1
-	case "2": weekNum = 2
-	case "3": weekNum = 3
-	case "4": weekNum = 4
+	weekNum, err := strconv.Atoi(parts[4])
+	if err != nil || weekNum < 1 {
+		weekNum = 1
 	}
-	
+	page := 1
+	if len(parts) > 5 {
+		if value, err := strconv.Atoi(parts[5]); err == nil {
+			page = value
+		}
+	}
+
 	var genderEmoji string
 	var genderText string
 	if gender == "male" {
@@ -349,9 +400,9 @@ func (h *Handler) HandleDiaryViewWeek(callbackQuery *tgbotapi.CallbackQuery, dat
 	}
 
 	userID := callbackQuery.From.ID
-	
+
 	// Получаем все записи для данной недели и пола
-	allEntries, err := h.historyManager.GetAllDiaryEntriesForWeekAndGender(userID, gender, weekNum)
+	allEntries, err := h.weekEntries(userID, gender, weekNum)
 	if err != nil || len(allEntries) == 0 {
 		response := fmt.Sprintf("👀 Записи дневника %s %s - Неделя %d\n\n"+
 			"📝 Записей не найдено.\n\n"+
@@ -363,7 +414,7 @@ func (h *Handler) HandleDiaryViewWeek(callbackQuery *tgbotapi.CallbackQuery, dat
 			genderEmoji, genderText, weekNum, genderEmoji, genderText, weekNum)
 
 		editMsg := tgbotapi.NewEditMessageText(callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID, response)
-		
+
 		// Добавляем кнопку "Назад"
 		backButton := tgbotapi.NewInlineKeyboardMarkup(
 			tgbotapi.NewInlineKeyboardRow(
@@ -371,62 +422,77 @@ func (h *Handler) HandleDiaryViewWeek(callbackQuery *tgbotapi.CallbackQuery, dat
 			),
 		)
 		editMsg.ReplyMarkup = &backButton
-		
+
 		_, err = h.bot.Send(editMsg)
 		return err
 	}
 
-	// Группируем записи по типам
-	entriesByType := make(map[string][]history.DiaryEntry)
-	for _, entry := range allEntries {
-		entriesByType[entry.Type] = append(entriesByType[entry.Type], entry)
+	pages := (len(allEntries) + diaryPageSize - 1) / diaryPageSize
+	if page < 1 {
+		page = 1
 	}
-
-	// Формируем ответ с записями
-	response := fmt.Sprintf("👀 Записи дневника %s %s - Неделя %d\n\n", genderEmoji, genderText, weekNum)
-	
-	typeNames := map[string]string{
-		"personal": "💭 Личные мысли",
-		"questions": "❓ Ответы на вопросы", 
-		"joint": "👫 Ответы на совместные вопросы",
+	if page > pages {
+		page = pages
 	}
-	
-	entryCount := 0
-	for entryType, entries := range entriesByType {
-		if len(entries) > 0 {
-			typeName, exists := typeNames[entryType]
-			if !exists {
-				typeName = fmt.Sprintf("📝 %s", entryType)
-			}
-			
-			response += fmt.Sprintf("%s:\n", typeName)
-			for i, entry := range entries {
-				entryCount++
-				// Ограничиваем длину записи для краткости
-				entryText := entry.Entry
-				if len(entryText) > 100 {
-					entryText = entryText[:100] + "..."
-				}
-				response += fmt.Sprintf("%d. %s (%s)\n", i+1, entryText, entry.Timestamp.Format("02.01 15:04"))
-			}
-			response += "\n"
+	start := (page - 1) * diaryPageSize
+	end := min(start+diaryPageSize, len(allEntries))
+
+	// Формируем страницу: записи сгруппированы по типам, нумерация сквозная для всей недели
+	var b strings.Builder
+	fmt.Fprintf(&b, "👀 Записи дневника %s %s - Неделя %d\n", genderEmoji, genderText, weekNum)
+	fmt.Fprintf(&b, "📊 Всего записей: %d", len(allEntries))
+	if pages > 1 {
+		fmt.Fprintf(&b, " · страница %d из %d", page, pages)
+	}
+	b.WriteString("\n")
+
+	var entryButtons []tgbotapi.InlineKeyboardButton
+	for i := start; i < end; i++ {
+		entry := allEntries[i]
+		if i == start || allEntries[i-1].Type != entry.Type {
+			fmt.Fprintf(&b, "\n%s:\n", entryTypeName(entry.Type))
+		}
+		excerpt := history.TruncateRunes(strings.Join(strings.Fields(entryText(entry)), " "), diaryExcerptLimit)
+		if entry.Attachment != nil && entry.Attachment.Kind == history.AttachmentPhoto && entry.Entry != "" {
+			excerpt = "📷 " + excerpt
 		}
+		fmt.Fprintf(&b, "%d. %s (%s)", i+1, excerpt, entry.Timestamp.Format("02.01 15:04"))
+		if mood := history.MoodEmoji(entry.Mood); mood != "" {
+			b.WriteString(" " + mood)
+		}
+		if _, edited := entry.EditedAt(); edited {
+			b.WriteString(" ✏️")
+		}
+		b.WriteString("\n")
+		entryButtons = append(entryButtons, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("📖 %d", i+1), "diary_entry_"+entry.ID))
 	}
-	
-	response += fmt.Sprintf("📊 Всего записей: %d", entryCount)
+	b.WriteString("\nНажмите на номер записи, чтобы прочитать ее полностью, изменить или удалить.")
 
-	editMsg := tgbotapi.NewEditMessageText(callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID, response)
-	
 	// Добавляем кнопки навигации
-	buttons := [][]tgbotapi.InlineKeyboardButton{
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", fmt.Sprintf("diary_view_%s", gender)),
-			tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu"),
-		),
+	buttons := [][]tgbotapi.InlineKeyboardButton{entryButtons}
+	var nav []tgbotapi.InlineKeyboardButton
+	if page > 1 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("◀️ Назад", diaryPageData(gender, weekNum, page-1)))
+	}
+	if page < pages {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("Вперед ▶️", diaryPageData(gender, weekNum, page+1)))
+	}
+	if len(nav) > 0 {
+		buttons = append(buttons, nav)
 	}
+	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔙 К неделям", fmt.Sprintf("diary_view_%s", gender)),
+		tgbotapi.NewInlineKeyboardButtonData("🏠 Главное меню", "main_menu"),
+	))
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	editMsg.ReplyMarkup = &keyboard
-	
+
+	response := history.TruncateUTF16(b.String(), telegramMessageLimit)
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID, response, keyboard)
 	_, err = h.bot.Send(editMsg)
 	return err
 }
+
+// diaryPageData callback страницы просмотра записей недели
+func diaryPageData(gender string, week, page int) string {
+	return fmt.Sprintf("diary_view_week_%s_%d_%d", gender, week, page)
+}
@@ -0,0 +1,333 @@
+package diary
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/godofphonk/lovifyy-bot/internal/history"
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// entryCutNotice пометка о сокращенном в сообщении тексте записи
+	entryCutNotice = "\n\n(запись сокращена, нажмите «📄 Показать целиком»)"
+	// entryPreviewLimit длина текста записи в подтверждениях исправления и удаления
+	entryPreviewLimit = 1000
+)
+
+// entryTypeName возвращает название типа записи для показа пользователю
+func entryTypeName(entryType string) string {
+	switch entryType {
+	case "personal":
+		return "💭 Личные мысли"
+	case "questions":
+		return "❓ Ответы на вопросы"
+	case "joint":
+		return "👫 Ответы на совместные вопросы"
+	}
+	return fmt.Sprintf("📝 %s", entryType)
+}
+
+// entryText возвращает текст записи для показа; у фото без подписи текста нет
+func entryText(entry history.DiaryEntry) string {
+	if entry.Entry == "" && entry.Attachment != nil && entry.Attachment.Kind == history.AttachmentPhoto {
+		return "📷 (фото без подписи)"
+	}
+	return entry.Entry
+}
+
+// weekEntries возвращает записи недели в порядке просмотра: по типу, затем по времени
+func (h *Handler) weekEntries(userID int64, gender string, week int) ([]history.DiaryEntry, error) {
+	entries, err := h.historyManager.GetAllDiaryEntriesForWeekAndGender(userID, gender, week)
+	if err != nil {
+		return nil, err
+	}
+	history.SortDiaryForView(entries)
+	return entries, nil
+}
+
+// HandleDiaryEntry показывает одну запись целиком с переходом к соседним записям недели:
+// diary_entry_<id>
+func (h *Handler) HandleDiaryEntry(callbackQuery *tgbotapi.CallbackQuery, data string) error {
+	userID := callbackQuery.From.ID
+	// Переход к записи отменяет начатое исправление
+	if h.userManager.GetUserState(userID).Kind == models.StateDiaryEdit {
+		if err := h.userManager.ClearState(userID); err != nil {
+			return err
+		}
+	}
+	return h.showEntry(callbackQuery, strings.TrimPrefix(data, "diary_entry_"))
+}
+
+// showEntry редактирует сообщение, показывая запись id
+func (h *Handler) showEntry(callbackQuery *tgbotapi.CallbackQuery, id string) error {
+	userID := callbackQuery.From.ID
+
+	entry, err := h.historyManager.GetDiaryEntry(userID, id)
+	if err != nil {
+		return h.editEntryMessage(callbackQuery, "❌ Запись не найдена. Возможно, она уже удалена.",
+			tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "diary_view"),
+			)))
+	}
+
+	entries, err := h.weekEntries(userID, entry.Gender, entry.Week)
+	if err != nil {
+		return err
+	}
+	index := 0
+	for i := range entries {
+		if entries[i].ID == entry.ID {
+			index = i
+			break
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📖 Запись %d из %d - Неделя %d\n", index+1, len(entries), entry.Week)
+	fmt.Fprintf(&b, "%s · %s\n", entryTypeName(entry.Type), entry.Timestamp.Format("02.01 15:04"))
+	if editedAt, ok := entry.EditedAt(); ok {
+		fmt.Fprintf(&b, "✏️ Изменена %s\n", editedAt.Format("02.01 15:04"))
+	}
+	if mood := history.MoodEmoji(entry.Mood); mood != "" {
+		fmt.Fprintf(&b, "Настроение: %s\n", mood)
+	}
+	if len(entry.Tags) > 0 {
+		b.WriteString("🏷 #" + strings.Join(entry.Tags, " #") + "\n")
+	}
+	if entry.UserID != userID {
+		b.WriteString("👫 Запись партнера\n")
+	}
+	if entry.Attachment != nil {
+		switch entry.Attachment.Kind {
+		case history.AttachmentPhoto:
+			b.WriteString("📷 Запись с фото\n")
+			if description := entry.Attachment.Description; description != "" {
+				fmt.Fprintf(&b, "🔎 На фото: %s\n", description)
+			}
+		default:
+			b.WriteString("🎙 Расшифровка голосового сообщения\n")
+		}
+	}
+	b.WriteString("\n")
+
+	// Длинная запись вместе с заголовком может не поместиться в сообщение
+	limit := telegramMessageLimit - history.UTF16Len(b.String()) - history.UTF16Len(entryCutNotice)
+	text := entryText(*entry)
+	truncated := history.UTF16Len(text) > limit
+	if truncated {
+		b.WriteString(history.TruncateUTF16(text, limit) + entryCutNotice)
+	} else {
+		b.WriteString(text)
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var nav []tgbotapi.InlineKeyboardButton
+	if index > 0 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("◀️ Предыдущая", "diary_entry_"+entries[index-1].ID))
+	}
+	if index+1 < len(entries) {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("Следующая ▶️", "diary_entry_"+entries[index+1].ID))
+	}
+	if len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+	if truncated {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📄 Показать целиком", "diary_full_"+entry.ID),
+		))
+	}
+	if entry.Attachment != nil {
+		label := "🎧 Прослушать"
+		if entry.Attachment.Kind == history.AttachmentPhoto {
+			label = "🖼 Показать фото"
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, "diary_file_"+entry.ID),
+		))
+	}
+	// Исправлять и удалять можно только свои записи
+	if entry.UserID == userID {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✏️ Изменить", "diary_edit_"+entry.ID),
+			tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить", "diary_del_"+entry.ID),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔙 К записям недели", diaryPageData(entry.Gender, entry.Week, index/diaryPageSize+1)),
+	))
+
+	return h.editEntryMessage(callbackQuery, b.String(), tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+// HandleDiaryFull присылает длинную запись целиком, несколькими сообщениями: diary_full_<id>
+func (h *Handler) HandleDiaryFull(callbackQuery *tgbotapi.CallbackQuery, data string) error {
+	chatID := callbackQuery.Message.Chat.ID
+	entry, err := h.historyManager.GetDiaryEntry(callbackQuery.From.ID, strings.TrimPrefix(data, "diary_full_"))
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, "❌ Запись не найдена. Возможно, она уже удалена.")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	for _, part := range history.SplitUTF16(entry.Entry, telegramMessageLimit) {
+		if _, err := h.bot.Send(tgbotapi.NewMessage(chatID, part)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HandleDiaryFile присылает фото, голосовое или аудиосообщение записи: diary_file_<id>
+func (h *Handler) HandleDiaryFile(callbackQuery *tgbotapi.CallbackQuery, data string) error {
+	chatID := callbackQuery.Message.Chat.ID
+	entry, err := h.historyManager.GetDiaryEntry(callbackQuery.From.ID, strings.TrimPrefix(data, "diary_file_"))
+	if err != nil || entry.Attachment == nil {
+		msg := tgbotapi.NewMessage(chatID, "❌ Файл записи не найден.")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	file := tgbotapi.FileID(entry.Attachment.FileID)
+	caption := fmt.Sprintf("Неделя %d · %s", entry.Week, entry.Timestamp.Format("02.01 15:04"))
+	switch entry.Attachment.Kind {
+	case history.AttachmentPhoto:
+		photo := tgbotapi.NewPhoto(chatID, file)
+		if entry.Entry != "" {
+			caption = history.TruncateUTF16(entry.Entry, photoCaptionLimit)
+		}
+		photo.Caption = caption
+		_, err = h.bot.Send(photo)
+	case history.AttachmentAudio:
+		audio := tgbotapi.NewAudio(chatID, file)
+		audio.Caption = caption
+		_, err = h.bot.Send(audio)
+	default:
+		voice := tgbotapi.NewVoice(chatID, file)
+		voice.Caption = caption
+		_, err = h.bot.Send(voice)
+	}
+	return err
+}
+
+// editEntryMessage заменяет текст и кнопки сообщения с записью
+func (h *Handler) editEntryMessage(callbackQuery *tgbotapi.CallbackQuery, text string, keyboard tgbotapi.InlineKeyboardMarkup) error {
+	edit := tgbotapi.NewEditMessageTextAndMarkup(callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID, text, keyboard)
+	_, err := h.bot.Send(edit)
+	return err
+}
+
+// HandleDiaryEdit включает режим исправления записи: diary_edit_<id>
+func (h *Handler) HandleDiaryEdit(callbackQuery *tgbotapi.CallbackQuery, data string) error {
+	userID := callbackQuery.From.ID
+	id := strings.TrimPrefix(data, "diary_edit_")
+
+	entry, err := h.historyManager.GetDiaryEntry(userID, id)
+	if err != nil || entry.UserID != userID {
+		msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, "❌ Исправлять можно только свои записи.")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	if err := h.userManager.SetUserState(userID, models.NewDiaryEditState(id)); err != nil {
+		return err
+	}
+
+	response := "✏️ Отправьте исправленный текст записи одним сообщением.\n\n" +
+		"Сейчас в записи:\n" + history.TruncateRunes(entryText(*entry), entryPreviewLimit)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", "diary_entry_"+id),
+	))
+	return h.editEntryMessage(callbackQuery, response, keyboard)
+}
+
+// HandleDiaryEditInput сохраняет исправленный текст записи
+func (h *Handler) HandleDiaryEditInput(userID int64, text string, state models.State) error {
+	if err := h.userManager.ClearState(userID); err != nil {
+		return err
+	}
+	if state.Diary == nil || state.Diary.EntryID == "" {
+		msg := tgbotapi.NewMessage(userID, "❌ Не удалось определить запись. Откройте ее заново в «👀 Посмотреть записи».")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	id := state.Diary.EntryID
+	if err := h.historyManager.EditDiaryEntry(userID, id, text); err != nil {
+		response := "❌ Не удалось сохранить исправление."
+		if errors.Is(err, history.ErrDiaryEntryNotFound) {
+			response = "❌ Запись не найдена. Возможно, она уже удалена."
+		}
+		msg := tgbotapi.NewMessage(userID, response)
+		_, sendErr := h.bot.Send(msg)
+		if sendErr != nil {
+			return sendErr
+		}
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(userID, "✅ Запись исправлена! Инсайты недели будут строиться по новому тексту, "+
+		"прежний сохранен в истории правок.")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("📖 К записи", "diary_entry_"+id),
+	))
+	_, err := h.bot.Send(msg)
+	return err
+}
+
+// HandleDiaryDelete просит подтвердить удаление записи: diary_del_<id>
+func (h *Handler) HandleDiaryDelete(callbackQuery *tgbotapi.CallbackQuery, data string) error {
+	userID := callbackQuery.From.ID
+	id := strings.TrimPrefix(data, "diary_del_")
+
+	entry, err := h.historyManager.GetDiaryEntry(userID, id)
+	if err != nil || entry.UserID != userID {
+		msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, "❌ Удалять можно только свои записи.")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	response := fmt.Sprintf("🗑 Удалить запись от %s?\n\n%s\n\nОтменить удаление будет нельзя.",
+		entry.Timestamp.Format("02.01 15:04"), history.TruncateRunes(entryText(*entry), entryPreviewLimit))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("✅ Да, удалить", "diary_delok_"+id),
+		tgbotapi.NewInlineKeyboardButtonData("❌ Нет", "diary_entry_"+id),
+	))
+	return h.editEntryMessage(callbackQuery, response, keyboard)
+}
+
+// HandleDiaryDeleteConfirm удаляет запись и возвращает к записям недели: diary_delok_<id>
+func (h *Handler) HandleDiaryDeleteConfirm(callbackQuery *tgbotapi.CallbackQuery, data string) error {
+	userID := callbackQuery.From.ID
+	id := strings.TrimPrefix(data, "diary_delok_")
+
+	entry, err := h.historyManager.GetDiaryEntry(userID, id)
+	if err == nil && entry.UserID != userID {
+		err = history.ErrDiaryEntryNotFound
+	}
+	// Запоминаем страницу записи, чтобы вернуться к тому же месту списка
+	page := 1
+	if err == nil {
+		if entries, listErr := h.weekEntries(userID, entry.Gender, entry.Week); listErr == nil {
+			for i := range entries {
+				if entries[i].ID == id {
+					page = i/diaryPageSize + 1
+				}
+			}
+		}
+		err = h.historyManager.DeleteDiaryEntry(userID, id)
+	}
+	if err != nil {
+		msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, "❌ Не удалось удалить запись.")
+		_, sendErr := h.bot.Send(msg)
+		if sendErr != nil {
+			return sendErr
+		}
+		return err
+	}
+
+	return h.HandleDiaryViewWeek(callbackQuery, diaryPageData(entry.Gender, entry.Week, page))
+}
@@ -0,0 +1,150 @@
+package diary
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/godofphonk/lovifyy-bot/internal/exercises"
+	"github.com/godofphonk/lovifyy-bot/internal/history"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// MoodKeyboard кнопки оценки настроения только что сохраненной записи
+func MoodKeyboard(entryID string) tgbotapi.InlineKeyboardMarkup {
+	var row []tgbotapi.InlineKeyboardButton
+	for i, emoji := range history.MoodScale {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(emoji, fmt.Sprintf("diary_mood_%s_%d", entryID, i+1)))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(row)
+}
+
+// HandleDiaryMood сохраняет настроение записи: diary_mood_<id>_<оценка>
+func (h *Handler) HandleDiaryMood(callbackQuery *tgbotapi.CallbackQuery, data string) error {
+	rest := strings.TrimPrefix(data, "diary_mood_")
+	sep := strings.LastIndex(rest, "_")
+	if sep < 0 {
+		return fmt.Errorf("invalid diary mood callback data: %s", data)
+	}
+	id := rest[:sep]
+	score, err := strconv.Atoi(rest[sep+1:])
+	if err != nil {
+		return fmt.Errorf("invalid diary mood score: %s", data)
+	}
+
+	if err := h.historyManager.SetDiaryMood(callbackQuery.From.ID, id, score); err != nil {
+		msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, "❌ Не удалось сохранить настроение.")
+		_, sendErr := h.bot.Send(msg)
+		if sendErr != nil {
+			return sendErr
+		}
+		return err
+	}
+
+	// Убираем кнопки оценки и дописываем выбранное настроение к подтверждению записи
+	text := fmt.Sprintf("%s\n\nНастроение: %s\n📈 Динамика по неделям: /mood", callbackQuery.Message.Text, history.MoodScale[score-1])
+	edit := tgbotapi.NewEditMessageText(callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID, text)
+	if rows := withoutMoodRows(callbackQuery.Message.ReplyMarkup); len(rows) > 0 {
+		edit.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+	}
+	_, err = h.bot.Send(edit)
+	return err
+}
+
+// withoutMoodRows оставляет остальные кнопки подтверждения (например, исправление расшифровки)
+func withoutMoodRows(markup *tgbotapi.InlineKeyboardMarkup) [][]tgbotapi.InlineKeyboardButton {
+	if markup == nil {
+		return nil
+	}
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, row := range markup.InlineKeyboard {
+		if len(row) > 0 && row[0].CallbackData != nil && strings.HasPrefix(*row[0].CallbackData, "diary_mood_") {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// HandleMoodCommand показывает динамику настроения пользователя и партнера по неделям программы
+func (h *Handler) HandleMoodCommand(message *tgbotapi.Message) error {
+	userID := message.From.ID
+	trends, err := h.historyManager.MoodTrends(userID, exercises.TotalWeeks)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "❌ Не удалось загрузить записи дневника.")
+		_, sendErr := h.bot.Send(msg)
+		if sendErr != nil {
+			return sendErr
+		}
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, moodText(trends, userID))
+	_, err = h.bot.Send(msg)
+	return err
+}
+
+// moodText отчет о настроении участников пары
+func moodText(trends []history.MoodTrend, userID int64) string {
+	rated := false
+	for _, trend := range trends {
+		if len(trend.Rated()) > 0 {
+			rated = true
+		}
+	}
+	if !rated {
+		return "📈 Настроение по неделям\n\n" +
+			"Пока нет записей с оценкой настроения. После сохранения записи в мини-дневнике " +
+			"выберите смайлик, который лучше всего описывает ваше состояние, и здесь появится динамика."
+	}
+
+	var b strings.Builder
+	b.WriteString("📈 Настроение по неделям\n")
+	for _, trend := range trends {
+		title := "Вы"
+		if trend.UserID != userID {
+			title = "Партнер"
+		}
+		switch trend.Gender {
+		case "male":
+			title = "👨 " + title
+		case "female":
+			title = "👩 " + title
+		}
+		fmt.Fprintf(&b, "\n%s\n", title)
+
+		for _, week := range trend.Weeks {
+			if week.Count == 0 {
+				fmt.Fprintf(&b, "Неделя %d: нет оценок\n", week.Week)
+				continue
+			}
+			fmt.Fprintf(&b, "Неделя %d: %s %.1f (%d)\n", week.Week, moodBar(week.Average), week.Average, week.Count)
+		}
+
+		if change, ok := trend.Change(); ok {
+			switch {
+			case change >= 0.5:
+				fmt.Fprintf(&b, "Динамика: ↗️ +%.1f\n", change)
+			case change <= -0.5:
+				fmt.Fprintf(&b, "Динамика: ↘️ %.1f\n", change)
+			default:
+				b.WriteString("Динамика: ➡️ стабильно\n")
+			}
+		}
+		if len(trend.TopTags) > 0 {
+			b.WriteString("🏷 Частые темы: #" + strings.Join(trend.TopTags, ", #") + "\n")
+		}
+	}
+	b.WriteString("\nВ скобках - число записей с оценкой за неделю.")
+	return b.String()
+}
+
+// moodBar рисует среднее настроение эмодзи и полосой из делений шкалы
+func moodBar(average float64) string {
+	filled := int(math.Round(average))
+	filled = max(1, min(filled, len(history.MoodScale)))
+	return history.MoodScale[filled-1] + " " +
+		strings.Repeat("▰", filled) + strings.Repeat("▱", len(history.MoodScale)-filled)
+}
@@ -24,6 +24,10 @@ func (h *Handler) HandleWeekAction(callbackQuery *tgbotapi.CallbackQuery, data s
 		return fmt.Errorf("invalid week number: %s", weekStr)
 	}
 
+	if !h.progress.IsWeekUnlocked(callbackQuery.From.ID, weekNum) {
+		return h.HandleLockedWeek(callbackQuery, weekNum)
+	}
+
 	exercise, err := h.exerciseManager.GetWeekExercise(weekNum)
 	if err != nil || exercise == nil {
 		msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, "❌ Упражнения для этой недели не найдены")
@@ -31,7 +31,7 @@ func (h *Handler) HandleInsightGenderChoice(callbackQuery *tgbotapi.CallbackQuer
 }
 
 // HandleInsightGender обрабатывает выбор гендера для инсайта - генерирует реальный AI инсайт
-func (h *Handler) HandleInsightGender(callbackQuery *tgbotapi.CallbackQuery, data string, historyManager *history.Manager, aiClient *ai.OpenAIClient) error {
+func (h *Handler) HandleInsightGender(callbackQuery *tgbotapi.CallbackQuery, data string, historyManager *history.Manager, aiClient ai.AIClient) error {
 	// Парсим данные: insight_<gender>_<week>
 	parts := strings.Split(data, "_")
 	if len(parts) < 3 {
@@ -104,7 +104,27 @@ func (h *Handler) HandleInsightGender(callbackQuery *tgbotapi.CallbackQuery, dat
 `, weekNum, weekData.Title, weekData.Insights, genderName)
 
 	for i, entry := range weekEntries {
-		prompt += fmt.Sprintf("%d. [%s] %s: %s\n", i+1, entry.Timestamp.Format("02.01"), entry.Type, entry.Entry)
+		prompt += fmt.Sprintf("%d. [%s] %s: %s\n", i+1, entry.Timestamp.Format("02.01"), entry.Type, entry.InsightText())
+	}
+
+	// Добавляем ответы партнера на совместные вопросы, чтобы инсайт учитывал взгляд обоих
+	partnerGender, partnerName := "female", "девушки"
+	if gender == "female" {
+		partnerGender, partnerName = "male", "парня"
+	}
+	if partnerEntries, err := historyManager.GetAllDiaryEntriesForWeekAndGender(userID, partnerGender, weekNum); err == nil {
+		var jointEntries []history.DiaryEntry
+		for _, entry := range partnerEntries {
+			if entry.Type == "joint" {
+				jointEntries = append(jointEntries, entry)
+			}
+		}
+		if len(jointEntries) > 0 {
+			prompt += fmt.Sprintf("\nОТВЕТЫ %s НА СОВМЕСТНЫЕ ВОПРОСЫ (для контекста пары):\n", strings.ToUpper(partnerName))
+			for i, entry := range jointEntries {
+				prompt += fmt.Sprintf("%d. [%s] %s\n", i+1, entry.Timestamp.Format("02.01"), entry.InsightText())
+			}
+		}
 	}
 
 	prompt += fmt.Sprintf(`
@@ -133,6 +153,9 @@ func (h *Handler) HandleInsightGender(callbackQuery *tgbotapi.CallbackQuery, dat
 	}
 
 	insight, err := aiClient.Generate(prompt)
+	if err != nil && ai.IsUnavailable(err) {
+		insight, err = ai.FallbackInsight, nil
+	}
 	if err != nil {
 		response := fmt.Sprintf("🔍 Персональный инсайт для %s %s (неделя %d)\n\n"+
 			"❌ Ошибка при генерации инсайта: %v\n\n"+
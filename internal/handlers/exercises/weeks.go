@@ -2,6 +2,7 @@ package exercises
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/godofphonk/lovifyy-bot/internal/exercises"
 	"github.com/godofphonk/lovifyy-bot/internal/models"
@@ -14,41 +15,98 @@ type Handler struct {
 	bot             *tgbotapi.BotAPI
 	userManager     *models.UserManager
 	exerciseManager *exercises.Manager
+	progress        *exercises.ProgressTracker
 }
 
 // NewHandler создает новый обработчик упражнений
-func NewHandler(bot *tgbotapi.BotAPI, userManager *models.UserManager, exerciseManager *exercises.Manager) *Handler {
+func NewHandler(bot *tgbotapi.BotAPI, userManager *models.UserManager, exerciseManager *exercises.Manager, progress *exercises.ProgressTracker) *Handler {
 	return &Handler{
 		bot:             bot,
 		userManager:     userManager,
 		exerciseManager: exerciseManager,
+		progress:        progress,
 	}
 }
 
 // HandleAdvice обрабатывает нажатие кнопки "Упражнение недели"
 func (h *Handler) HandleAdvice(callbackQuery *tgbotapi.CallbackQuery) error {
-	response := "🗓️ Выберите неделю для упражнений:\n\n" +
-		"Каждая неделя содержит специально подобранные упражнения для укрепления ваших отношений."
+	userID := callbackQuery.From.ID
+	currentWeek := h.progress.CurrentWeek(userID)
+
+	response := fmt.Sprintf("🗓️ Выберите неделю для упражнений:\n\n"+
+		"Каждая неделя содержит специально подобранные упражнения для укрепления ваших отношений.\n\n"+
+		"📍 Ваша текущая неделя: %d из %d", currentWeek, exercises.TotalWeeks)
+
+	weekEmojis := []string{"1️⃣", "2️⃣", "3️⃣", "4️⃣"}
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for week := 1; week <= exercises.TotalWeeks; week++ {
+		var button tgbotapi.InlineKeyboardButton
+		if week <= currentWeek {
+			button = tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s Неделя", weekEmojis[week-1]), fmt.Sprintf("week_%d", week))
+		} else {
+			button = tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🔒 Неделя %d", week), fmt.Sprintf("locked_week_%d", week))
+		}
+		row = append(row, button)
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
 
-	weekKeyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("1️⃣ Неделя", "week_1"),
-			tgbotapi.NewInlineKeyboardButtonData("2️⃣ Неделя", "week_2"),
-		),
+	msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, response)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	_, err := h.bot.Send(msg)
+	return err
+}
+
+// HandleLockedWeek объясняет, когда откроется закрытая неделя
+func (h *Handler) HandleLockedWeek(callbackQuery *tgbotapi.CallbackQuery, weekNum int) error {
+	progress, err := h.progress.Get(callbackQuery.From.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load progress: %w", err)
+	}
+
+	now := time.Now()
+	currentWeek := progress.CurrentWeek(now)
+
+	var response string
+	if progress.OverrideWeek > 0 {
+		response = fmt.Sprintf("🔒 Неделя %d пока закрыта\n\n"+
+			"Доступ к неделям для вас настраивает администратор.", weekNum)
+	} else {
+		daysLeft := int(progress.NextUnlockAt(now).Sub(now).Hours()/24) + 1
+		if weekNum == currentWeek+1 {
+			response = fmt.Sprintf("🔒 Неделя %d пока закрыта\n\n"+
+				"Она откроется через %d дн. или сразу после того, как вы оба ответите на совместные вопросы %d недели в мини-дневнике 👫",
+				weekNum, daysLeft, currentWeek)
+		} else {
+			response = fmt.Sprintf("🔒 Неделя %d пока закрыта\n\n"+
+				"Недели открываются по порядку: сначала завершите %d неделю.", weekNum, currentWeek)
+		}
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("3️⃣ Неделя", "week_3"),
-			tgbotapi.NewInlineKeyboardButtonData("4️⃣ Неделя", "week_4"),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("➡️ К %d неделе", currentWeek), fmt.Sprintf("week_%d", currentWeek)),
 		),
 	)
 
 	msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, response)
-	msg.ReplyMarkup = weekKeyboard
-	_, err := h.bot.Send(msg)
+	msg.ReplyMarkup = keyboard
+	_, err = h.bot.Send(msg)
 	return err
 }
 
 // HandleWeek обрабатывает выбор недели упражнений как в legacy
 func (h *Handler) HandleWeek(callbackQuery *tgbotapi.CallbackQuery, weekNum int) error {
+	if !h.progress.IsWeekUnlocked(callbackQuery.From.ID, weekNum) {
+		return h.HandleLockedWeek(callbackQuery, weekNum)
+	}
+
 	// Получаем упражнения для недели
 	exercise, err := h.exerciseManager.GetWeekExercise(weekNum)
 	if err != nil {
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/godofphonk/lovifyy-bot/internal/models"
+	"github.com/godofphonk/lovifyy-bot/internal/services"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -40,6 +41,54 @@ func (ch *CommandHandler) showNotificationTypeActions(userID int64, typ string)
 	return err
 }
 
+// showNotifyAudience — выбор аудитории мгновенной отправки с количеством получателей.
+// Суффикс _p у типа включает персональные тексты для каждого получателя.
+func (ch *CommandHandler) showNotifyAudience(callbackQuery *tgbotapi.CallbackQuery, typ string) error {
+	userID := callbackQuery.From.ID
+	if !ch.userManager.IsAdmin(userID) {
+		return ch.simpleMsg(callbackQuery.Message.Chat.ID, "❌ Эта функция доступна только администраторам.")
+	}
+
+	choices, err := ch.schedulingHandler.AudienceChoices()
+	if err != nil {
+		return ch.simpleMsg(callbackQuery.Message.Chat.ID, fmt.Sprintf("❌ Ошибка загрузки сегментов: %v", err))
+	}
+
+	typ, personal := strings.CutSuffix(typ, "_p")
+	flag := ""
+	toggleText, toggleData := "✨ Персонально каждому: выкл", "notify_audience_"+typ+"_p"
+	if personal {
+		flag = "_p"
+		toggleText, toggleData = "✨ Персонально каждому: вкл", "notify_audience_"+typ
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, choice := range choices {
+		callback := "notify_send_all_" + typ + flag
+		if choice.SegmentID != "" {
+			callback = "notify_send_seg_" + typ + "_" + choice.SegmentID + flag
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(choice.Title, callback)))
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(toggleText, toggleData)),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "send_now")),
+	)
+
+	text := "🎯 Кому отправить уведомление?\n\n" +
+		"В скобках - сколько получателей подходит сейчас. Отправка начнется сразу после выбора.\n" +
+		"Новые сегменты создаются командой /segment"
+	if personal {
+		text += "\n\n✨ Каждый получит свой текст с учетом недели программы, записей в дневнике и партнера. " +
+			"Если AI недоступен, придет общий текст."
+	}
+
+	// Выбор аудитории и переключатель меняют то же сообщение, а не присылают новое
+	edit := tgbotapi.NewEditMessageTextAndMarkup(callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID, text, tgbotapi.NewInlineKeyboardMarkup(rows...))
+	_, err = ch.bot.Send(edit)
+	return err
+}
+
 // previewNotification — GPT предпросмотр
 func (ch *CommandHandler) previewNotification(userID int64, typ string) error {
 	nt := models.NotificationType(typ)
@@ -56,22 +105,24 @@ func (ch *CommandHandler) previewNotification(userID int64, typ string) error {
 // sendNowNotification — мгновенная отправка
 func (ch *CommandHandler) sendNowNotification(userID int64, typ string) error {
 	nt := models.NotificationType(typ)
-	if err := ch.notificationService.SendInstantNotification(nt, nil); err != nil {
+	report, err := ch.notificationService.SendInstantNotification(nt, nil)
+	if err != nil {
 		return ch.simpleMsg(userID, fmt.Sprintf("❌ Ошибка отправки: %v", err))
 	}
-	return ch.simpleMsg(userID, "✅ Уведомление отправлено всем.")
+	return ch.simpleMsg(userID, "✅ Уведомление отправлено всем.\n\n"+report.Summary())
 }
 
 // showSchedulePresets — пресеты времени
 func (ch *CommandHandler) showSchedulePresets(userID int64, typ string) error {
-	now := time.Now()
-	today10 := time.Date(now.Year(), now.Month(), now.Day(), 10, 0, 0, 0, now.Location()).Unix()
-	today20 := time.Date(now.Year(), now.Month(), now.Day(), 20, 0, 0, 0, now.Location()).Unix()
-	tomorrow := now.Add(24 * time.Hour)
-	tomorrow10 := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 10, 0, 0, 0, now.Location()).Unix()
-	tomorrow20 := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 20, 0, 0, 0, now.Location()).Unix()
-
-	text := "⏰ Выберите время отправки:"
+	// Пресеты считаются в часовом поясе расписания, а не в поясе сервера
+	now := time.Now().In(services.ScheduleZone)
+	today10 := time.Date(now.Year(), now.Month(), now.Day(), 10, 0, 0, 0, services.ScheduleZone).Unix()
+	today20 := time.Date(now.Year(), now.Month(), now.Day(), 20, 0, 0, 0, services.ScheduleZone).Unix()
+	tomorrow := now.AddDate(0, 0, 1)
+	tomorrow10 := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 10, 0, 0, 0, services.ScheduleZone).Unix()
+	tomorrow20 := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 20, 0, 0, 0, services.ScheduleZone).Unix()
+
+	text := "⏰ Выберите время отправки (UTC+5):"
 	kb := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("Сегодня 10:00", fmt.Sprintf("notify_schedule_preset_%s_%d", typ, today10)),
@@ -97,7 +148,7 @@ func (ch *CommandHandler) scheduleNotificationAt(userID int64, typ string, at ti
 	if _, err := ch.notificationService.ScheduleNotification(at, nt, nil); err != nil {
 		return ch.simpleMsg(userID, fmt.Sprintf("❌ Ошибка планирования: %v", err))
 	}
-	return ch.simpleMsg(userID, fmt.Sprintf("✅ Запланировано на %s", at.Format("02.01 15:04")))
+	return ch.simpleMsg(userID, fmt.Sprintf("✅ Запланировано на %s (UTC+5)", at.In(services.ScheduleZone).Format("02.01 15:04")))
 }
 
 // showScheduledNotifications — список задач
@@ -113,12 +164,13 @@ func (ch *CommandHandler) showScheduledNotifications(userID int64) error {
 	text := "📋 Запланированные уведомления:\n\n"
 	var rows [][]tgbotapi.InlineKeyboardButton
 	
-	// Часовой пояс UTC+5 для отображения
-	utc5 := time.FixedZone("UTC+5", 5*60*60)
-	
 	for i, it := range items {
 		// Конвертируем время в UTC+5 для отображения
-		localTime := it.SendAt.In(utc5)
+		localTime := it.SendAt.In(services.ScheduleZone)
+		zone := ""
+		if it.LocalTime {
+			zone = " (местное время получателей)"
+		}
 		
 		// Определяем тип уведомления
 		var typeEmoji, typeName string
@@ -142,7 +194,18 @@ func (ch *CommandHandler) showScheduledNotifications(userID int64) error {
 		
 		// Заголовок уведомления
 		text += fmt.Sprintf("🔹 **Уведомление #%d**\n", i+1)
-		text += fmt.Sprintf("📅 **Дата:** %s\n", localTime.Format("02.01.2006 15:04"))
+		if it.Recurrence != nil {
+			text += fmt.Sprintf("⏭ **Следующая отправка:** %s%s\n", localTime.Format("02.01.2006 15:04"), zone)
+			text += fmt.Sprintf("🔁 **Повтор:** `%s`\n", it.Recurrence.Describe())
+			if it.SentCount > 0 {
+				text += fmt.Sprintf("📨 **Отправлено:** %d раз\n", it.SentCount)
+			}
+		} else {
+			text += fmt.Sprintf("📅 **Дата:** %s%s\n", localTime.Format("02.01.2006 15:04"), zone)
+		}
+		if len(it.SentZones) > 0 {
+			text += fmt.Sprintf("🌍 **Отправлено поясам:** %d\n", len(it.SentZones))
+		}
 		text += fmt.Sprintf("📢 **Тип:** %s %s\n", typeEmoji, typeName)
 		
 		// Показываем текст уведомления
@@ -158,6 +221,12 @@ func (ch *CommandHandler) showScheduledNotifications(userID int64) error {
 		}
 		
 		text += fmt.Sprintf("💬 **Текст:** %s\n", messageText)
+		if it.Media != nil {
+			text += "📎 **Вложение:** фото или документ\n"
+		}
+		if len(it.Buttons) > 0 {
+			text += fmt.Sprintf("🔘 **Кнопок:** %d\n", len(it.Buttons))
+		}
 		text += fmt.Sprintf("🆔 **ID:** `%s`\n\n", it.ID)
 		
 		// Кнопка отмены
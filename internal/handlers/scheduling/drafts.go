@@ -0,0 +1,453 @@
+package scheduling
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+	"github.com/godofphonk/lovifyy-bot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// customTextPrompt подсказка при вводе текста кастомного уведомления
+const customTextPrompt = "Напишите текст уведомления. Форматирование Telegram (жирный, курсив, ссылки, спойлеры) сохранится.\n" +
+	"Можно отправить фото или документ с подписью. Перед планированием покажем предпросмотр."
+
+// HandleCustomDraftInput сохраняет введенный текст кастомного уведомления в черновик и показывает предпросмотр.
+// text уже переведен в HTML разметку.
+func (h *Handler) HandleCustomDraftInput(userID int64, text string, state models.State) error {
+	if !h.userManager.IsAdmin(userID) {
+		return h.userManager.ClearState(userID)
+	}
+	if strings.TrimSpace(text) == "" {
+		msg := tgbotapi.NewMessage(userID, "❌ Текст уведомления не может быть пустым. "+customTextPrompt)
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	schedule := state.Schedule
+	if schedule == nil {
+		schedule = &models.ScheduleContext{}
+	}
+
+	// Состояние очищаем до сохранения, чтобы повторный ввод не создал второй черновик
+	if err := h.userManager.ClearState(userID); err != nil {
+		return err
+	}
+
+	var draft services.NotificationDraft
+	var err error
+	if schedule.DraftID != "" {
+		// Правка текста существующего черновика
+		draft, err = h.notificationService.GetDraft(schedule.DraftID)
+		if err == nil {
+			draft.Text = text
+			err = h.notificationService.UpdateDraft(draft)
+		}
+	} else {
+		draft, err = h.notificationService.CreateDraft(userID, text, schedule.Date, schedule.Time)
+	}
+	if err != nil {
+		msg := tgbotapi.NewMessage(userID, "❌ Ошибка сохранения черновика: "+err.Error())
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	return h.sendDraft(userID, 0, draft)
+}
+
+// HandleCustomDraftMedia прикрепляет фото или документ к черновику кастомного уведомления.
+// Подпись (уже в HTML разметке), если она есть, становится текстом уведомления.
+func (h *Handler) HandleCustomDraftMedia(userID int64, caption string, media models.NotificationMedia, state models.State) error {
+	if !h.userManager.IsAdmin(userID) {
+		return h.userManager.ClearState(userID)
+	}
+
+	schedule := state.Schedule
+	if schedule == nil {
+		schedule = &models.ScheduleContext{}
+	}
+
+	if err := h.userManager.ClearState(userID); err != nil {
+		return err
+	}
+
+	var draft services.NotificationDraft
+	var err error
+	if schedule.DraftID != "" {
+		draft, err = h.notificationService.GetDraft(schedule.DraftID)
+	} else {
+		draft, err = h.notificationService.CreateDraft(userID, "", schedule.Date, schedule.Time)
+	}
+	if err == nil {
+		draft.Media = &media
+		if strings.TrimSpace(caption) != "" {
+			draft.Text = caption
+		}
+		err = h.notificationService.UpdateDraft(draft)
+	}
+	if err != nil {
+		msg := tgbotapi.NewMessage(userID, "❌ Ошибка сохранения черновика: "+err.Error())
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	return h.sendDraft(userID, 0, draft)
+}
+
+// HandleCustomDraftTimeInput задает дату и время отправки черновика, введенные вручную
+func (h *Handler) HandleCustomDraftTimeInput(userID int64, text string, state models.State) error {
+	if !h.userManager.IsAdmin(userID) || state.Schedule == nil || state.Schedule.DraftID == "" {
+		return h.userManager.ClearState(userID)
+	}
+
+	sendAt, err := time.ParseInLocation("02.01.2006 15:04", strings.TrimSpace(text), services.ScheduleZone)
+	if err != nil {
+		msg := tgbotapi.NewMessage(userID, "❌ Неверный формат. Введите дату и время как ДД.ММ.ГГГГ ЧЧ:ММ (например: 15.10.2025 14:30)")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+	if !sendAt.After(time.Now()) {
+		msg := tgbotapi.NewMessage(userID, "❌ Это время уже прошло. Введите дату и время в будущем.")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	if err := h.userManager.ClearState(userID); err != nil {
+		return err
+	}
+	draft, err := h.notificationService.GetDraft(state.Schedule.DraftID)
+	if err == nil {
+		draft.SetSendAt(sendAt)
+		err = h.notificationService.UpdateDraft(draft)
+	}
+	if err != nil {
+		msg := tgbotapi.NewMessage(userID, "❌ "+err.Error())
+		_, err := h.bot.Send(msg)
+		return err
+	}
+	return h.sendDraft(userID, 0, draft)
+}
+
+// draftQuickTimes варианты быстрого выбора времени отправки черновика
+var draftQuickTimes = []struct {
+	key   string
+	title string
+}{
+	{"1h", "Через 1 час"},
+	{"3h", "Через 3 часа"},
+	{"tm", "Завтра в 10:00"},
+}
+
+// draftQuickTime возвращает момент отправки для варианта быстрого выбора
+func draftQuickTime(key string, now time.Time) (time.Time, bool) {
+	now = now.In(services.ScheduleZone).Truncate(time.Minute)
+	switch key {
+	case "1h":
+		return now.Add(time.Hour), true
+	case "3h":
+		return now.Add(3 * time.Hour), true
+	case "tm":
+		tomorrow := now.AddDate(0, 0, 1)
+		return time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 10, 0, 0, 0, services.ScheduleZone), true
+	}
+	return time.Time{}, false
+}
+
+// draftButtons готовые кнопки, которые можно добавить к уведомлению из черновика
+var draftButtons = []struct {
+	key    string
+	button models.NotificationButton
+}{
+	{"d", models.DiaryButton},
+	{"e", models.ExerciseButton},
+}
+
+// draftButtonsKeyboard клавиатура выбора кнопок уведомления
+func draftButtonsKeyboard(draft services.NotificationDraft) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, option := range draftButtons {
+		mark := "⬜️"
+		if draft.HasButton(option.button.Data) {
+			mark = "✅"
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(mark+" "+option.button.Text, "cdraft_tgl_"+draft.ID+"_"+option.key),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔙 К черновику", "cdraft_show_"+draft.ID),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// HandleDraftCallback обрабатывает действия с черновиком: cdraft_<действие>_<ID>[_<параметр>]
+func (h *Handler) HandleDraftCallback(callbackQuery *tgbotapi.CallbackQuery, data string) error {
+	userID := callbackQuery.From.ID
+	chatID := callbackQuery.Message.Chat.ID
+
+	if !h.userManager.IsAdmin(userID) {
+		msg := tgbotapi.NewMessage(chatID, "❌ Эта функция доступна только администраторам.")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	parts := strings.Split(strings.TrimPrefix(data, "cdraft_"), "_")
+	if len(parts) < 2 {
+		msg := tgbotapi.NewMessage(chatID, "❌ Неверный формат данных")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+	action, draftID := parts[0], parts[1]
+
+	draft, err := h.notificationService.GetDraft(draftID)
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, "❌ "+err.Error())
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	switch action {
+	case "ok":
+		if draft.IsEmpty() {
+			break
+		}
+		scheduleID, sendAt, err := h.notificationService.ScheduleDraft(draftID, time.Now())
+		if err != nil {
+			msg := tgbotapi.NewMessage(chatID, "❌ Ошибка планирования уведомления: "+err.Error())
+			_, err := h.bot.Send(msg)
+			return err
+		}
+		response := fmt.Sprintf("✅ Кастомное уведомление запланировано!\n\n"+
+			"🆔 ID задачи: %s\n"+
+			"📅 Отправка: %s (UTC+5)\n"+
+			"🌍 UTC время: %s\n\n"+
+			"Уведомление будет отправлено автоматически в указанное время.",
+			scheduleID, sendAt.Format("02.01.2006 15:04"), sendAt.UTC().Format("02.01.2006 15:04"))
+		edit := tgbotapi.NewEditMessageText(chatID, callbackQuery.Message.MessageID, response)
+		_, err = h.bot.Send(edit)
+		return err
+
+	case "edit":
+		if err := h.userManager.SetUserState(userID, models.NewDraftState(models.StateCustomDraftEdit, draftID)); err != nil {
+			return err
+		}
+		msg := tgbotapi.NewMessage(chatID, "✏️ Отправьте новый текст уведомления.\n\n"+customTextPrompt)
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", "cdraft_show_"+draftID),
+		))
+		_, err := h.bot.Send(msg)
+		return err
+
+	case "now":
+		if draft.IsEmpty() {
+			break
+		}
+		// Рассылка идет через очередь с ограничением частоты, поэтому сразу сообщаем о начале
+		edit := tgbotapi.NewEditMessageText(chatID, callbackQuery.Message.MessageID, "⏳ Рассылка поставлена в очередь, отчет придет после отправки всем пользователям.")
+		h.bot.Send(edit)
+
+		report, err := h.notificationService.SendDraftNow(draftID, userID)
+		if err != nil {
+			msg := tgbotapi.NewMessage(chatID, "❌ Ошибка отправки уведомления: "+err.Error())
+			_, err := h.bot.Send(msg)
+			return err
+		}
+		msg := tgbotapi.NewMessage(chatID, "✅ Кастомное уведомление отправлено!\n\n"+report.Summary())
+		_, err = h.bot.Send(msg)
+		return err
+
+	case "look":
+		// Уведомление в точности как его увидят пользователи
+		for _, part := range services.RichMessages(chatID, draft.Text, "HTML", draft.RichContent) {
+			if _, err := h.bot.Send(part); err != nil {
+				msg := tgbotapi.NewMessage(chatID, "❌ Не удалось показать уведомление: "+err.Error())
+				_, err := h.bot.Send(msg)
+				return err
+			}
+		}
+		return h.sendDraft(chatID, 0, draft)
+
+	case "media":
+		if err := h.userManager.SetUserState(userID, models.NewDraftState(models.StateCustomDraftMedia, draftID)); err != nil {
+			return err
+		}
+		msg := tgbotapi.NewMessage(chatID, "📎 Отправьте фото или документ (например, PDF с упражнением).\n\n"+
+			"Подпись к файлу, если она есть, заменит текст уведомления.")
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", "cdraft_show_"+draftID),
+		))
+		_, err := h.bot.Send(msg)
+		return err
+
+	case "unmedia":
+		if draft.Text == "" {
+			msg := tgbotapi.NewMessage(chatID, "❌ В уведомлении нет текста: без вложения отправлять будет нечего. Сначала добавьте текст.")
+			_, err := h.bot.Send(msg)
+			return err
+		}
+		draft.Media = nil
+		if err := h.notificationService.UpdateDraft(draft); err != nil {
+			msg := tgbotapi.NewMessage(chatID, "❌ "+err.Error())
+			_, err := h.bot.Send(msg)
+			return err
+		}
+		return h.sendDraft(chatID, callbackQuery.Message.MessageID, draft)
+
+	case "btn":
+		edit := tgbotapi.NewEditMessageReplyMarkup(chatID, callbackQuery.Message.MessageID, draftButtonsKeyboard(draft))
+		_, err := h.bot.Send(edit)
+		return err
+
+	case "tgl":
+		if len(parts) < 3 {
+			break
+		}
+		for _, option := range draftButtons {
+			if option.key == parts[2] {
+				draft.ToggleButton(option.button)
+			}
+		}
+		if err := h.notificationService.UpdateDraft(draft); err != nil {
+			msg := tgbotapi.NewMessage(chatID, "❌ "+err.Error())
+			_, err := h.bot.Send(msg)
+			return err
+		}
+		edit := tgbotapi.NewEditMessageReplyMarkup(chatID, callbackQuery.Message.MessageID, draftButtonsKeyboard(draft))
+		_, err := h.bot.Send(edit)
+		return err
+
+	case "time":
+		var rows [][]tgbotapi.InlineKeyboardButton
+		for _, option := range draftQuickTimes {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(option.title, "cdraft_at_"+draftID+"_"+option.key),
+			))
+		}
+		rows = append(rows,
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("📅 Ввести дату и время", "cdraft_input_"+draftID)),
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔙 К черновику", "cdraft_show_"+draftID)),
+		)
+		edit := tgbotapi.NewEditMessageReplyMarkup(chatID, callbackQuery.Message.MessageID, tgbotapi.NewInlineKeyboardMarkup(rows...))
+		_, err := h.bot.Send(edit)
+		return err
+
+	case "at":
+		if len(parts) < 3 {
+			break
+		}
+		sendAt, ok := draftQuickTime(parts[2], time.Now())
+		if !ok {
+			break
+		}
+		draft.SetSendAt(sendAt)
+		if err := h.notificationService.UpdateDraft(draft); err != nil {
+			msg := tgbotapi.NewMessage(chatID, "❌ "+err.Error())
+			_, err := h.bot.Send(msg)
+			return err
+		}
+		return h.sendDraft(chatID, callbackQuery.Message.MessageID, draft)
+
+	case "input":
+		if err := h.userManager.SetUserState(userID, models.NewDraftState(models.StateCustomDraftTime, draftID)); err != nil {
+			return err
+		}
+		msg := tgbotapi.NewMessage(chatID, "📅 Введите дату и время отправки в формате ДД.ММ.ГГГГ ЧЧ:ММ (UTC+5)\n\nНапример: 15.10.2025 14:30")
+		_, err := h.bot.Send(msg)
+		return err
+
+	case "show":
+		if err := h.userManager.ClearState(userID); err != nil {
+			return err
+		}
+		return h.sendDraft(chatID, 0, draft)
+
+	case "del":
+		if err := h.notificationService.DeleteDraft(draftID); err != nil {
+			msg := tgbotapi.NewMessage(chatID, "❌ "+err.Error())
+			_, err := h.bot.Send(msg)
+			return err
+		}
+		edit := tgbotapi.NewEditMessageText(chatID, callbackQuery.Message.MessageID, "🗑 Черновик уведомления удален.")
+		_, err := h.bot.Send(edit)
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(chatID, "❌ Неизвестное действие с черновиком")
+	_, err = h.bot.Send(msg)
+	return err
+}
+
+// sendDraft показывает предпросмотр черновика с действиями. messageID != 0 - заменить это сообщение.
+func (h *Handler) sendDraft(chatID int64, messageID int, draft services.NotificationDraft) error {
+	sendAt := "⏰ Время отправки не выбрано"
+	if draft.HasSendTime() {
+		sendAt = fmt.Sprintf("📅 Отправка: %s %s (UTC+5)", draft.Date, draft.Time)
+	}
+	body := draft.Text
+	if body == "" {
+		body = "<i>без текста</i>"
+	}
+	text := "👀 Предпросмотр уведомления\n\n" + body + "\n\n"
+	if !draft.RichContent.IsEmpty() {
+		text += describeRich(draft.RichContent) + "\n"
+	}
+	if draft.Media != nil && utf8.RuneCountInString(draft.Text) > 1024 {
+		text += "⚠️ Текст длиннее подписи к файлу: файл придет отдельным сообщением перед текстом\n"
+	}
+	text += sendAt
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if draft.HasSendTime() && !draft.IsEmpty() {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Запланировать", "cdraft_ok_"+draft.ID),
+		))
+	}
+	mediaButton := tgbotapi.NewInlineKeyboardButtonData("📎 Фото или файл", "cdraft_media_"+draft.ID)
+	if draft.Media != nil {
+		mediaButton = tgbotapi.NewInlineKeyboardButtonData("📎 Убрать вложение", "cdraft_unmedia_"+draft.ID)
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✏️ Изменить текст", "cdraft_edit_"+draft.ID),
+			tgbotapi.NewInlineKeyboardButtonData("🕐 Время отправки", "cdraft_time_"+draft.ID),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			mediaButton,
+			tgbotapi.NewInlineKeyboardButtonData("🔘 Кнопки", "cdraft_btn_"+draft.ID),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("👁 Как увидят пользователи", "cdraft_look_"+draft.ID),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🚀 Отправить сейчас", "cdraft_now_"+draft.ID),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить черновик", "cdraft_del_"+draft.ID),
+		),
+	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	if messageID != 0 {
+		edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, keyboard)
+		edit.ParseMode = "HTML"
+		_, err := h.bot.Send(edit)
+		return err
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "HTML"
+	msg.ReplyMarkup = keyboard
+	_, err := h.bot.Send(msg)
+	return err
+}
+
+// draftMediaTitle описание вложения черновика
+func draftMediaTitle(media *models.NotificationMedia) string {
+	if media.Kind == models.MediaDocument {
+		return "📄 Вложение: документ"
+	}
+	return "🖼 Вложение: фото"
+}
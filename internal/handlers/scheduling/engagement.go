@@ -0,0 +1,165 @@
+package scheduling
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+	"github.com/godofphonk/lovifyy-bot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// engagementWindows окна наблюдения за действиями после уведомления, в часах
+var engagementWindows = []int{24, 48, 72}
+
+// engagementMinSample меньше доставок на вариант - выводы делать рано
+const engagementMinSample = 50
+
+// HandleEngagementCallback показывает отчет A/B тестов шаблонов и выбирает победителя:
+// notify_abtest_<часы>, notify_abwin_<часы>_<тип>_<вариант>
+func (h *Handler) HandleEngagementCallback(callbackQuery *tgbotapi.CallbackQuery, data string) error {
+	chatID := callbackQuery.Message.Chat.ID
+	if !h.userManager.IsAdmin(callbackQuery.From.ID) {
+		msg := tgbotapi.NewMessage(chatID, "❌ Эта функция доступна только администраторам.")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	hours := engagementWindows[0]
+	notice := ""
+	if rest, ok := strings.CutPrefix(data, "notify_abwin_"); ok {
+		parts := strings.Split(rest, "_")
+		if len(parts) != 3 {
+			msg := tgbotapi.NewMessage(chatID, "❌ Неверный формат данных")
+			_, err := h.bot.Send(msg)
+			return err
+		}
+		hours, _ = strconv.Atoi(parts[0])
+		typ, variant := models.NotificationType(parts[1]), parts[2]
+		if err := h.notificationService.PickTemplateWinner(typ, variant); err != nil {
+			notice = "❌ " + err.Error() + "\n\n"
+		} else {
+			notice = fmt.Sprintf("🏆 Уведомления %s теперь отправляются только по варианту %s.\n\n", typ, variant)
+		}
+	} else if value, err := strconv.Atoi(strings.TrimPrefix(data, "notify_abtest_")); err == nil {
+		hours = value
+	}
+	if hours <= 0 || hours > int(services.EngagementMaxWindow/time.Hour) {
+		hours = engagementWindows[0]
+	}
+
+	report := h.notificationService.EngagementReport(time.Duration(hours) * time.Hour)
+	text := notice + engagementText(report, hours)
+	keyboard := h.engagementKeyboard(hours)
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, callbackQuery.Message.MessageID, text, keyboard)
+	_, err := h.bot.Send(edit)
+	return err
+}
+
+// engagementText отчет о вовлеченности по вариантам шаблонов
+func engagementText(report []services.VariantEngagement, hours int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🧪 A/B тесты шаблонов\n\nДействия в течение %d ч после уведомления: "+
+		"зашли в бота / запись в дневнике / нажали кнопку.\n", hours)
+	if len(report) == 0 {
+		b.WriteString("\nДоставок еще не было.")
+		return b.String()
+	}
+
+	for i := 0; i < len(report); {
+		typ := report[i].Type
+		j := i
+		for j < len(report) && report[j].Type == typ {
+			j++
+		}
+		group := report[i:j]
+		leader := engagementLeader(group)
+
+		_, title := notificationTypeInfo(string(typ))
+		if title == "" {
+			title = string(typ)
+		}
+		fmt.Fprintf(&b, "\n%s\n", title)
+		pending := 0
+		for _, v := range group {
+			mark := ""
+			if v.Variant == leader {
+				mark = " 🏆"
+			}
+			fmt.Fprintf(&b, "%s - %d: %.0f%% / %.0f%% / %.0f%%%s\n",
+				v.Variant, v.Sent, v.Rate(v.Opened), v.Rate(v.Diary), v.Rate(v.Clicked), mark)
+			pending += v.Pending
+		}
+		if pending > 0 {
+			fmt.Fprintf(&b, "⏳ Окно еще не закончилось: %d\n", pending)
+		}
+		i = j
+	}
+	fmt.Fprintf(&b, "\n🏆 - лидер по записям в дневнике, затем по заходам. Выводы надежны от %d доставок на вариант.", engagementMinSample)
+	return b.String()
+}
+
+// engagementLeader вариант с лучшей вовлеченностью. Пусто, если сравнивать не с чем
+// или у какого-то варианта мало доставок.
+func engagementLeader(group []services.VariantEngagement) string {
+	if len(group) < 2 {
+		return ""
+	}
+	best := -1
+	for i, v := range group {
+		if v.Sent < engagementMinSample {
+			return ""
+		}
+		if best < 0 || v.Rate(v.Diary) > group[best].Rate(group[best].Diary) ||
+			v.Rate(v.Diary) == group[best].Rate(group[best].Diary) && v.Rate(v.Opened) > group[best].Rate(group[best].Opened) {
+			best = i
+		}
+	}
+	return group[best].Variant
+}
+
+// engagementKeyboard переключатель окна и выбор победителя для типов с несколькими активными вариантами
+func (h *Handler) engagementKeyboard(hours int) tgbotapi.InlineKeyboardMarkup {
+	var windows []tgbotapi.InlineKeyboardButton
+	for _, window := range engagementWindows {
+		title := fmt.Sprintf("%d ч", window)
+		if window == hours {
+			title = "• " + title + " •"
+		}
+		windows = append(windows, tgbotapi.NewInlineKeyboardButtonData(title, fmt.Sprintf("notify_abtest_%d", window)))
+	}
+	rows := [][]tgbotapi.InlineKeyboardButton{windows}
+
+	active := make(map[models.NotificationType][]string)
+	var order []models.NotificationType
+	for _, template := range h.notificationService.GetTemplates() {
+		if !template.IsActive {
+			continue
+		}
+		if _, ok := active[template.Type]; !ok {
+			order = append(order, template.Type)
+		}
+		active[template.Type] = append(active[template.Type], template.VariantID())
+	}
+	for _, typ := range order {
+		if len(active[typ]) < 2 {
+			continue
+		}
+		var row []tgbotapi.InlineKeyboardButton
+		for _, variant := range active[typ] {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("🏆 %s: %s", typ, variant),
+				fmt.Sprintf("notify_abwin_%d_%s_%s", hours, typ, variant),
+			))
+		}
+		rows = append(rows, row)
+	}
+
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "notifications_menu"),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
@@ -0,0 +1,79 @@
+package scheduling
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// repeatUsage справка по команде /repeat
+const repeatUsage = "🔁 Повторяющиеся уведомления\n\n" +
+	"/repeat <тип> daily <ЧЧ:ММ>\n" +
+	"/repeat <тип> weekdays <пн,ср,пт> <ЧЧ:ММ>\n" +
+	"/repeat <тип> every <N> <ЧЧ:ММ>\n" +
+	"/repeat <тип> cron <мин> <час> <день> <месяц> <день_недели>\n\n" +
+	"Типы: diary, exercise, motivation\n" +
+	"Параметры: from=ДД.ММ.ГГГГ - дата начала, until=ДД.ММ.ГГГГ - дата окончания, count=N - не более N отправок\n" +
+	"Время указывается в UTC+5, с параметром local - по местному времени каждого получателя.\n" +
+	"segment=ID - отправлять только сегменту аудитории (см. /segment).\n" +
+	"personal - каждому получателю свой текст с учетом его прогресса.\n\n" +
+	"Примеры:\n" +
+	"/repeat diary daily 20:00 local until=31.12.2025\n" +
+	"/repeat exercise weekdays пн,чт 10:00 count=8 segment=s1\n" +
+	"/repeat motivation cron 0 9 * * 1-5 personal"
+
+// HandleRepeatCommand создает повторяющееся уведомление по команде /repeat
+func (h *Handler) HandleRepeatCommand(message *tgbotapi.Message) error {
+	if !h.userManager.IsAdmin(message.From.ID) {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "❌ Эта команда доступна только администраторам.")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	notificationType, spec, _ := strings.Cut(strings.TrimSpace(message.CommandArguments()), " ")
+	if notificationType == "" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, repeatUsage)
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	modelType, typeName := notificationTypeInfo(notificationType)
+	if modelType == "" || notificationType == "custom" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "❌ Неизвестный тип уведомления: "+notificationType+"\n\n"+repeatUsage)
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	// local - время суток по местному времени каждого получателя, segment=ID - аудитория,
+	// personal - персональный текст каждому получателю
+	fields := strings.Fields(spec)
+	localTime := slices.Contains(fields, "local")
+	personal := slices.Contains(fields, "personal")
+	segmentID := ""
+	fields = slices.DeleteFunc(fields, func(field string) bool {
+		if value, ok := strings.CutPrefix(field, "segment="); ok {
+			segmentID = value
+			return true
+		}
+		return field == "local" || field == "personal"
+	})
+
+	rule, start, err := services.ParseRecurrenceSpec(strings.Join(fields, " "), time.Now())
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "❌ "+err.Error()+"\n\n"+repeatUsage)
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	item, err := h.notificationService.Schedule(start, modelType, services.ScheduleOptions{Recurrence: &rule, LocalTime: localTime, Segment: segmentID, Personalized: personal})
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "❌ Ошибка планирования уведомления: "+err.Error())
+		_, err := h.bot.Send(msg)
+		return err
+	}
+	return h.sendScheduled(message.Chat.ID, item, typeName)
+}
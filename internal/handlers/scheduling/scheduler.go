@@ -44,9 +44,8 @@ func (h *Handler) HandleScheduleNotification(callbackQuery *tgbotapi.CallbackQue
 	// Создаем кнопки с датами (сегодня + следующие 6 дней) как в legacy
 	var buttons [][]tgbotapi.InlineKeyboardButton
 
-	// Получаем текущее время в UTC+5 (фиксированный offset)
-	utc5 := time.FixedZone("UTC+5", 5*60*60) // 5 часов в секундах
-	nowUTC5 := time.Now().In(utc5)
+	// Получаем текущее время в часовом поясе расписания (UTC+5)
+	nowUTC5 := time.Now().In(services.ScheduleZone)
 
 	for i := 0; i < 7; i++ {
 		date := nowUTC5.AddDate(0, 0, i)
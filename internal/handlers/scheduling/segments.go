@@ -0,0 +1,184 @@
+package scheduling
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// segmentUsage справка по команде /segment
+const segmentUsage = "🎯 Сегменты аудитории\n\n" +
+	"/segment - список сегментов и количество получателей\n" +
+	"/segment add <название>: <условия> - создать сегмент\n" +
+	"/segment del <ID> - удалить сегмент\n\n" +
+	"Условия (можно сочетать):\n" +
+	"week=N - текущая неделя программы\n" +
+	"inactive=N - не заходили в бота N дней и больше\n" +
+	"nodiary - нет записей в дневнике за текущую неделю\n" +
+	"paired / single - в паре / без пары\n" +
+	"joined=ДД.ММ.ГГГГ - пришли в бота начиная с даты\n\n" +
+	"Пример:\n" +
+	"/segment add Вторая неделя без записей: week=2 nodiary\n\n" +
+	"Сегмент выбирается при отправке и планировании уведомлений, получатели отбираются в момент отправки."
+
+// AudienceChoice вариант аудитории для кнопок выбора: пустой SegmentID - все активные пользователи
+type AudienceChoice struct {
+	SegmentID string
+	Title     string
+}
+
+// AudienceChoices возвращает варианты аудитории с текущим количеством получателей
+func (h *Handler) AudienceChoices() ([]AudienceChoice, error) {
+	total, err := h.notificationService.GetUserCount()
+	if err != nil {
+		return nil, err
+	}
+	choices := []AudienceChoice{{Title: fmt.Sprintf("👥 Все активные (%d)", total)}}
+
+	segments, err := h.notificationService.Segments()
+	if err != nil {
+		return nil, err
+	}
+	for _, segment := range segments {
+		choices = append(choices, AudienceChoice{
+			SegmentID: segment.ID,
+			Title:     fmt.Sprintf("🎯 %s (%d)", segment.Name, h.segmentSize(segment.ID)),
+		})
+	}
+	return choices, nil
+}
+
+// AudienceLabel описывает выбранную аудиторию с количеством получателей
+func (h *Handler) AudienceLabel(segmentID string) string {
+	if segmentID == "" {
+		total, _ := h.notificationService.GetUserCount()
+		return fmt.Sprintf("все активные пользователи (%d)", total)
+	}
+	segment, err := h.notificationService.GetSegment(segmentID)
+	if err != nil {
+		return "❌ " + err.Error()
+	}
+	return fmt.Sprintf("%s (%d получателей)", segment.Name, h.segmentSize(segmentID))
+}
+
+// segmentSize текущее количество получателей сегмента
+func (h *Handler) segmentSize(segmentID string) int {
+	recipients, err := h.notificationService.SegmentRecipients(segmentID)
+	if err != nil {
+		return 0
+	}
+	return len(recipients)
+}
+
+// HandleSegmentCommand управляет сегментами аудитории по команде /segment
+func (h *Handler) HandleSegmentCommand(message *tgbotapi.Message) error {
+	if !h.userManager.IsAdmin(message.From.ID) {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "❌ Эта команда доступна только администраторам.")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	action, rest, _ := strings.Cut(strings.TrimSpace(message.CommandArguments()), " ")
+	var response string
+	switch action {
+	case "":
+		response = h.segmentList()
+	case "add":
+		name, spec, ok := strings.Cut(rest, ":")
+		if !ok {
+			response = "❌ Отделите название от условий двоеточием.\n\n" + segmentUsage
+			break
+		}
+		rules, err := models.ParseSegmentRules(spec)
+		if err != nil {
+			response = "❌ " + err.Error() + "\n\n" + segmentUsage
+			break
+		}
+		segment, err := h.notificationService.CreateSegment(strings.TrimSpace(name), rules, message.From.ID)
+		if err != nil {
+			response = "❌ " + err.Error()
+			break
+		}
+		response = fmt.Sprintf("✅ Сегмент создан\n\n🆔 %s\n🎯 %s\n📋 %s\n👥 Сейчас подходят: %d",
+			segment.ID, segment.Name, segment.Rules.Describe(), h.segmentSize(segment.ID))
+	case "del":
+		if err := h.notificationService.DeleteSegment(strings.TrimSpace(rest)); err != nil {
+			response = "❌ " + err.Error()
+			break
+		}
+		response = "✅ Сегмент удален. Запланированные на него уведомления не будут отправлены."
+	default:
+		response = segmentUsage
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, response)
+	_, err := h.bot.Send(msg)
+	return err
+}
+
+// segmentList описывает сохраненные сегменты
+func (h *Handler) segmentList() string {
+	segments, err := h.notificationService.Segments()
+	if err != nil {
+		return "❌ Ошибка загрузки сегментов: " + err.Error()
+	}
+	if len(segments) == 0 {
+		return "🎯 Сегментов пока нет.\n\n" + segmentUsage
+	}
+
+	var b strings.Builder
+	b.WriteString("🎯 Сегменты аудитории\n\n")
+	for _, segment := range segments {
+		fmt.Fprintf(&b, "%s · %s\n📋 %s\n👥 Сейчас подходят: %d\n\n",
+			segment.ID, segment.Name, segment.Rules.Describe(), h.segmentSize(segment.ID))
+	}
+	b.WriteString("Создать или удалить: /segment add, /segment del")
+	return b.String()
+}
+
+// HandleScheduleAudienceCallback предлагает выбрать аудиторию планируемого уведомления
+func (h *Handler) HandleScheduleAudienceCallback(callbackQuery *tgbotapi.CallbackQuery, data string) error {
+	chatID := callbackQuery.Message.Chat.ID
+
+	if !h.userManager.IsAdmin(callbackQuery.From.ID) {
+		msg := tgbotapi.NewMessage(chatID, "❌ Эта функция доступна только администраторам.")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	// Парсим данные: schedule_aud_13.10.2025_10:00_diary_флаги
+	parts := strings.Split(data, "_")
+	if len(parts) < 6 {
+		msg := tgbotapi.NewMessage(chatID, "❌ Неверный формат данных")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+	back := fmt.Sprintf("schedule_type_%s_%s_%s_%s", parts[2], parts[3], parts[4], parts[5])
+
+	choices, err := h.AudienceChoices()
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, "❌ Ошибка загрузки сегментов: "+err.Error())
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, choice := range choices {
+		callback := back
+		if choice.SegmentID != "" {
+			callback += "_" + choice.SegmentID
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(choice.Title, callback)))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", back)))
+
+	text := "🎯 Кому отправить уведомление?\n\n" +
+		"В скобках - сколько получателей подходит сейчас. Получатели сегмента отбираются заново при каждой отправке.\n" +
+		"Новые сегменты создаются командой /segment"
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, callbackQuery.Message.MessageID, text, tgbotapi.NewInlineKeyboardMarkup(rows...))
+	_, err = h.bot.Send(edit)
+	return err
+}
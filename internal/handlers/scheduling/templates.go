@@ -0,0 +1,174 @@
+package scheduling
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+	"github.com/godofphonk/lovifyy-bot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// templateUsage справка по команде /template
+const templateUsage = "🧩 Шаблоны уведомлений\n\n" +
+	"/template - шаблоны, их варианты и вложения\n" +
+	"/template <тип> add <промпт> - добавить вариант для A/B теста\n" +
+	"/template <тип> del <вариант> - удалить вариант\n" +
+	"/template <тип> win <вариант> - оставить активным только этот вариант\n" +
+	"/template <тип> buttons diary exercise - кнопки под уведомлением (none - без кнопок)\n" +
+	"/template <тип> media - ответом на сообщение с фото или документом\n" +
+	"/template <тип> nomedia - убрать фото или документ\n\n" +
+	"Типы: diary, exercise, motivation\n" +
+	"Кнопки: diary - «📝 Открыть дневник», exercise - «💪 Упражнение недели». " +
+	"Обе открывают текущую неделю получателя.\n\n" +
+	"Вложения прикладываются к каждому сгенерированному уведомлению этого типа (всем вариантам), " +
+	"если при планировании не выбраны свои.\n\n" +
+	"Если у типа несколько активных вариантов, получатели делятся между ними. " +
+	"Сравнить варианты: 📢 Уведомления → 🧪 A/B тесты."
+
+// templateButtons кнопки, которые можно задать шаблону, по ключу команды
+var templateButtons = map[string]models.NotificationButton{
+	"diary":    models.DiaryButton,
+	"exercise": models.ExerciseButton,
+}
+
+// HandleTemplateCommand управляет вариантами, медиа и кнопками шаблонов уведомлений по команде /template
+func (h *Handler) HandleTemplateCommand(message *tgbotapi.Message) error {
+	if !h.userManager.IsAdmin(message.From.ID) {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "❌ Эта команда доступна только администраторам.")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	args := strings.Fields(message.CommandArguments())
+	if len(args) == 0 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, h.templateList())
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	typ := models.NotificationType(args[0])
+	var response string
+	switch {
+	case len(args) >= 3 && args[1] == "add":
+		// Промпт берем из исходного текста, чтобы сохранить пробелы и переносы
+		_, prompt, _ := strings.Cut(message.CommandArguments(), " add ")
+		template, err := h.notificationService.AddTemplateVariant(typ, strings.TrimSpace(prompt))
+		if err != nil {
+			response = "❌ " + err.Error()
+			break
+		}
+		response = fmt.Sprintf("✅ Добавлен вариант %s шаблона «%s». Получатели будут делиться между активными вариантами.",
+			template.VariantID(), template.Name)
+	case len(args) == 3 && args[1] == "del":
+		if err := h.notificationService.DeleteTemplateVariant(typ, strings.ToUpper(args[2])); err != nil {
+			response = "❌ " + err.Error()
+			break
+		}
+		response = "✅ Вариант удален."
+	case len(args) == 3 && args[1] == "win":
+		if err := h.notificationService.PickTemplateWinner(typ, strings.ToUpper(args[2])); err != nil {
+			response = "❌ " + err.Error()
+			break
+		}
+		response = fmt.Sprintf("🏆 Теперь уведомления %s отправляются только по варианту %s.", typ, strings.ToUpper(args[2]))
+	default:
+		response = h.updateTemplateRich(typ, args[1:], message.ReplyToMessage)
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, response)
+	_, err := h.bot.Send(msg)
+	return err
+}
+
+// updateTemplateRich применяет действие команды к шаблону и возвращает ответ администратору
+func (h *Handler) updateTemplateRich(typ models.NotificationType, args []string, reply *tgbotapi.Message) string {
+	var template *models.NotificationTemplate
+	for _, t := range h.notificationService.GetTemplates() {
+		if t.Type == typ {
+			template = &t
+			break
+		}
+	}
+	if template == nil || len(args) == 0 {
+		return templateUsage
+	}
+
+	rich := template.RichContent
+	switch args[0] {
+	case "buttons":
+		rich.Buttons = nil
+		for _, key := range args[1:] {
+			if key == "none" {
+				continue
+			}
+			button, ok := templateButtons[key]
+			if !ok {
+				return fmt.Sprintf("❌ Неизвестная кнопка: %s\n\n%s", key, templateUsage)
+			}
+			rich.ToggleButton(button)
+		}
+	case "media":
+		var media *models.NotificationMedia
+		if reply != nil {
+			media = services.MessageMedia(reply)
+		}
+		if media == nil {
+			return "❌ Отправьте команду ответом на сообщение с фото или документом."
+		}
+		rich.Media = media
+	case "nomedia":
+		rich.Media = nil
+	default:
+		return templateUsage
+	}
+
+	if err := h.notificationService.SetTemplateRich(typ, rich); err != nil {
+		return "❌ " + err.Error()
+	}
+	return fmt.Sprintf("✅ Шаблон «%s» обновлен\n\n%s", template.Name, describeRich(rich))
+}
+
+// templateList список шаблонов с их вложениями
+func (h *Handler) templateList() string {
+	var b strings.Builder
+	b.WriteString("🧩 Шаблоны уведомлений\n")
+	for _, template := range h.notificationService.GetTemplates() {
+		status := "активен"
+		if !template.IsActive {
+			status = "выключен"
+		}
+		fmt.Fprintf(&b, "\n%s (%s), вариант %s - %s\n%s\n%s\n",
+			template.Name, template.Type, template.VariantID(), status, shortPrompt(template.Prompt), describeRich(template.RichContent))
+	}
+	b.WriteString("\nИзменить: /template <тип> add | del | win | buttons | media | nomedia")
+	return b.String()
+}
+
+// describeRich описывает медиа и кнопки уведомления
+func describeRich(rich models.RichContent) string {
+	if rich.IsEmpty() {
+		return "Без вложений"
+	}
+	var lines []string
+	if rich.Media != nil {
+		lines = append(lines, draftMediaTitle(rich.Media))
+	}
+	if len(rich.Buttons) > 0 {
+		titles := make([]string, 0, len(rich.Buttons))
+		for _, button := range rich.Buttons {
+			titles = append(titles, button.Text)
+		}
+		lines = append(lines, "🔘 Кнопки: "+strings.Join(titles, ", "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// shortPrompt сокращает промпт шаблона для списка
+func shortPrompt(prompt string) string {
+	runes := []rune(prompt)
+	if len(runes) <= 80 {
+		return "📝 " + prompt
+	}
+	return "📝 " + string(runes[:80]) + "…"
+}
@@ -6,11 +6,12 @@ import (
 	"time"
 
 	"github.com/godofphonk/lovifyy-bot/internal/models"
+	"github.com/godofphonk/lovifyy-bot/internal/services"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-// HandleScheduleTypeCallback обрабатывает выбор типа уведомления и создает задачу
+// HandleScheduleTypeCallback обрабатывает выбор типа уведомления и предлагает выбрать повторение
 func (h *Handler) HandleScheduleTypeCallback(callbackQuery *tgbotapi.CallbackQuery, data string) error {
 	userID := callbackQuery.From.ID
 
@@ -20,7 +21,7 @@ func (h *Handler) HandleScheduleTypeCallback(callbackQuery *tgbotapi.CallbackQue
 		return err
 	}
 
-	// Парсим данные: schedule_type_13.10.2025_10:00_diary
+	// Парсим данные: schedule_type_13.10.2025_10:00_diary[_флаги[_s1]]
 	parts := strings.Split(data, "_")
 	if len(parts) < 5 {
 		msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, "❌ Неверный формат данных")
@@ -31,22 +32,26 @@ func (h *Handler) HandleScheduleTypeCallback(callbackQuery *tgbotapi.CallbackQue
 	selectedDate := parts[2] // 13.10.2025
 	selectedTime := parts[3] // 10:00
 	notificationType := parts[4] // diary/exercise/motivation/custom
+	// Необязательный шестой параметр - флаги: l/u - местное время получателей вкл/выкл,
+	// p - персональный текст каждому получателю
+	toggled := len(parts) > 5
+	flags := scheduleFlags{}
+	if toggled {
+		flags = parseScheduleFlags(parts[5])
+	}
+	// Необязательный седьмой параметр - сегмент аудитории
+	segmentID := ""
+	if len(parts) > 6 {
+		segmentID = parts[6]
+	}
 
-	// Парсим дату и время в UTC+5, затем конвертируем в UTC
-	utc5 := time.FixedZone("UTC+5", 5*60*60) // 5 часов в секундах
-
-	// Парсим дату и время
-	dateTimeStr := fmt.Sprintf("%s %s", selectedDate, selectedTime)
-	scheduledTime, err := time.ParseInLocation("02.01.2006 15:04", dateTimeStr, utc5)
-	if err != nil {
+	// Проверяем дату и время заранее, чтобы не предлагать повторение для некорректных данных
+	if _, err := time.ParseInLocation("02.01.2006 15:04", selectedDate+" "+selectedTime, services.ScheduleZone); err != nil {
 		msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, "❌ Ошибка парсинга даты/времени")
 		_, err := h.bot.Send(msg)
 		return err
 	}
 
-	// Конвертируем в UTC (отнимаем 5 часов)
-	scheduledTimeUTC := scheduledTime.UTC()
-
 	var typeName string
 	switch notificationType {
 	case "diary":
@@ -57,12 +62,17 @@ func (h *Handler) HandleScheduleTypeCallback(callbackQuery *tgbotapi.CallbackQue
 		typeName = "💒 Мотивация"
 	case "custom":
 		// Для кастомных уведомлений нужен отдельный обработчик
-		h.userManager.SetState(userID, "schedule_custom_text")
+		if err := h.userManager.SetUserState(userID, models.State{
+			Kind:     models.StateScheduleCustomText,
+			Schedule: &models.ScheduleContext{Date: selectedDate, Time: selectedTime},
+		}); err != nil {
+			return err
+		}
 		msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, 
 			fmt.Sprintf("✏️ Введите текст кастомного уведомления:\n\n"+
 				"📅 Дата: %s\n"+
 				"🕐 Время: %s (UTC+5)\n\n"+
-				"Напишите текст уведомления:", selectedDate, selectedTime))
+				"%s", selectedDate, selectedTime, customTextPrompt))
 		_, err := h.bot.Send(msg)
 		return err
 	default:
@@ -71,40 +81,263 @@ func (h *Handler) HandleScheduleTypeCallback(callbackQuery *tgbotapi.CallbackQue
 		return err
 	}
 
-	// Создаем запланированное уведомление через NotificationService
-	var modelType models.NotificationType
-	switch notificationType {
-	case "diary":
-		modelType = models.NotificationDiary
-	case "exercise":
-		modelType = models.NotificationExercise
-	case "motivation":
-		modelType = models.NotificationMotivation
+	response := fmt.Sprintf("🔁 Как часто отправлять?\n\n"+
+		"📢 Тип: %s\n"+
+		"📅 Первая дата: %s\n"+
+		"🕐 Время: %s %s\n"+
+		"🎯 Аудитория: %s\n"+
+		"✨ Текст: %s\n\n"+
+		"💡 Срок окончания, количество отправок и cron-выражения настраиваются командой /repeat",
+		typeName, selectedDate, selectedTime, zoneLabel(flags.localTime), h.AudienceLabel(segmentID), personalLabel(flags.personal))
+
+	zoneToggle, personalToggle := flags, flags
+	zoneToggle.localTime = !flags.localTime
+	personalToggle.personal = !flags.personal
+	zoneText := "🌍 По местному времени получателей: выкл"
+	if flags.localTime {
+		zoneText = "🌍 По местному времени получателей: вкл"
+	}
+	personalText := "✨ Персонально каждому: выкл"
+	if flags.personal {
+		personalText = "✨ Персонально каждому: вкл"
+	}
+	segmentSuffix := ""
+	if segmentID != "" {
+		segmentSuffix = "_" + segmentID
+	}
+	base := fmt.Sprintf("%s_%s_%s", selectedDate, selectedTime, notificationType)
+	prefix := "schedule_repeat_" + base + "_"
+	suffix := "_" + flags.String() + segmentSuffix
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("1️⃣ Один раз", prefix+"once"+suffix),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📆 Каждый день", prefix+"daily"+suffix),
+			tgbotapi.NewInlineKeyboardButtonData("💼 По будням", prefix+"wd"+suffix),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔂 Через день", prefix+"n2"+suffix),
+			tgbotapi.NewInlineKeyboardButtonData("🗓️ Раз в неделю", prefix+"n7"+suffix),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(zoneText, "schedule_type_"+base+"_"+zoneToggle.String()+segmentSuffix),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(personalText, "schedule_type_"+base+"_"+personalToggle.String()+segmentSuffix),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🎯 Выбрать аудиторию", "schedule_aud_"+base+"_"+flags.String()),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад к типам", fmt.Sprintf("schedule_time_%s_%s", selectedDate, selectedTime)),
+		),
+	)
+
+	// Переключатель меняет то же сообщение, а не присылает новое
+	if toggled {
+		edit := tgbotapi.NewEditMessageTextAndMarkup(callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID, response, keyboard)
+		_, err := h.bot.Send(edit)
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, response)
+	msg.ReplyMarkup = keyboard
+	_, err := h.bot.Send(msg)
+	return err
+}
+
+// scheduleFlags переключатели планируемого уведомления, передаваемые в callback data
+type scheduleFlags struct {
+	localTime bool // по местному времени каждого получателя
+	personal  bool // персональный текст каждому получателю
+}
+
+// parseScheduleFlags разбирает флаги: l - местное время (u - время UTC+5), p - персонально
+func parseScheduleFlags(value string) scheduleFlags {
+	return scheduleFlags{
+		localTime: strings.Contains(value, "l"),
+		personal:  strings.Contains(value, "p"),
 	}
+}
 
-	// Планируем уведомление
-	scheduleID, err := h.notificationService.ScheduleNotification(scheduledTimeUTC, modelType, nil)
+// String кодирует флаги для callback data
+func (f scheduleFlags) String() string {
+	value := "u"
+	if f.localTime {
+		value = "l"
+	}
+	if f.personal {
+		value += "p"
+	}
+	return value
+}
+
+// personalLabel подпись режима текста уведомления
+func personalLabel(personal bool) string {
+	if personal {
+		return "персональный для каждого получателя"
+	}
+	return "общий для всех"
+}
+
+// zoneLabel подпись часового пояса времени отправки
+func zoneLabel(localTime bool) string {
+	if localTime {
+		return "(местное время каждого получателя)"
+	}
+	return "(UTC+5)"
+}
+
+// repeatPresets правила повторения, доступные кнопками
+var repeatPresets = map[string]services.Recurrence{
+	"daily": {Kind: services.RepeatDaily},
+	"wd":    {Kind: services.RepeatWeekdays, Weekdays: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}},
+	"n2":    {Kind: services.RepeatEvery, Interval: 2},
+	"n7":    {Kind: services.RepeatEvery, Interval: 7},
+}
+
+// HandleScheduleRepeatCallback обрабатывает выбор повторения и создает задачу
+func (h *Handler) HandleScheduleRepeatCallback(callbackQuery *tgbotapi.CallbackQuery, data string) error {
+	userID := callbackQuery.From.ID
+	chatID := callbackQuery.Message.Chat.ID
+
+	if !h.userManager.IsAdmin(userID) {
+		msg := tgbotapi.NewMessage(chatID, "❌ Эта функция доступна только администраторам.")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	// Парсим данные: schedule_repeat_13.10.2025_10:00_diary_daily[_флаги[_s1]]
+	parts := strings.Split(data, "_")
+	if len(parts) < 6 {
+		msg := tgbotapi.NewMessage(chatID, "❌ Неверный формат данных")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+	selectedDate, selectedTime, notificationType, repeat := parts[2], parts[3], parts[4], parts[5]
+	flags := scheduleFlags{}
+	if len(parts) > 6 {
+		flags = parseScheduleFlags(parts[6])
+	}
+	segmentID := ""
+	if len(parts) > 7 {
+		segmentID = parts[7]
+	}
+
+	modelType, typeName := notificationTypeInfo(notificationType)
+	if modelType == "" {
+		msg := tgbotapi.NewMessage(chatID, "❌ Неизвестный тип уведомления")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	scheduledTime, err := time.ParseInLocation("02.01.2006 15:04", selectedDate+" "+selectedTime, services.ScheduleZone)
 	if err != nil {
-		response := fmt.Sprintf("❌ Ошибка планирования уведомления: %v", err)
-		msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, response)
+		msg := tgbotapi.NewMessage(chatID, "❌ Ошибка парсинга даты/времени")
 		_, err := h.bot.Send(msg)
 		return err
 	}
 
-	response := fmt.Sprintf("✅ Уведомление запланировано!\n\n"+
-		"🆔 ID задачи: %s\n"+
-		"📢 Тип: %s\n"+
-		"📅 Дата: %s\n"+
-		"🕐 Время: %s (UTC+5)\n"+
-		"🌍 UTC время: %s\n\n"+
-		"Уведомление будет отправлено автоматически в указанное время.",
-		scheduleID, typeName, selectedDate, selectedTime, scheduledTimeUTC.Format("02.01.2006 15:04"))
+	opts := services.ScheduleOptions{LocalTime: flags.localTime, Segment: segmentID, Personalized: flags.personal}
+	if repeat == "once" && (flags.localTime || flags.personal || segmentID != "") {
+		item, err := h.notificationService.Schedule(scheduledTime, modelType, opts)
+		if err != nil {
+			msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Ошибка планирования уведомления: %v", err))
+			_, err := h.bot.Send(msg)
+			return err
+		}
+		return h.sendScheduled(chatID, item, typeName)
+	}
 
-	msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, response)
-	_, err = h.bot.Send(msg)
+	if repeat == "once" {
+		scheduleID, err := h.notificationService.ScheduleNotification(scheduledTime.UTC(), modelType, nil)
+		if err != nil {
+			msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Ошибка планирования уведомления: %v", err))
+			_, err := h.bot.Send(msg)
+			return err
+		}
+
+		response := fmt.Sprintf("✅ Уведомление запланировано!\n\n"+
+			"🆔 ID задачи: %s\n"+
+			"📢 Тип: %s\n"+
+			"📅 Дата: %s\n"+
+			"🕐 Время: %s (UTC+5)\n"+
+			"🌍 UTC время: %s\n\n"+
+			"Уведомление будет отправлено автоматически в указанное время.",
+			scheduleID, typeName, selectedDate, selectedTime, scheduledTime.UTC().Format("02.01.2006 15:04"))
+		msg := tgbotapi.NewMessage(chatID, response)
+		_, err = h.bot.Send(msg)
+		return err
+	}
+
+	rule, ok := repeatPresets[repeat]
+	if !ok {
+		msg := tgbotapi.NewMessage(chatID, "❌ Неизвестное правило повторения")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	opts.Recurrence = &rule
+	item, err := h.notificationService.Schedule(scheduledTime, modelType, opts)
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Ошибка планирования уведомления: %v", err))
+		_, err := h.bot.Send(msg)
+		return err
+	}
+	return h.sendScheduled(chatID, item, typeName)
+}
+
+// sendScheduled подтверждает создание задачи (повторяющейся или по местному времени получателей)
+func (h *Handler) sendScheduled(chatID int64, item services.ScheduledNotification, typeName string) error {
+	sendAt := item.SendAt.In(services.ScheduleZone).Format("02.01.2006 15:04")
+
+	var response string
+	if item.Recurrence != nil {
+		response = fmt.Sprintf("✅ Повторяющееся уведомление запланировано!\n\n"+
+			"🆔 ID задачи: %s\n"+
+			"📢 Тип: %s\n"+
+			"🔁 Повтор: %s\n"+
+			"⏭ Первая отправка: %s %s\n\n"+
+			"После каждой отправки задача переносится на следующий запуск.",
+			item.ID, typeName, item.Recurrence.Describe(), sendAt, zoneLabel(item.LocalTime))
+	} else {
+		response = fmt.Sprintf("✅ Уведомление запланировано!\n\n"+
+			"🆔 ID задачи: %s\n"+
+			"📢 Тип: %s\n"+
+			"📅 Отправка: %s %s",
+			item.ID, typeName, sendAt, zoneLabel(item.LocalTime))
+	}
+	if item.Segment != "" {
+		response += "\n🎯 Аудитория: " + h.AudienceLabel(item.Segment)
+	}
+	if item.Personalized {
+		response += "\n✨ Текст: " + personalLabel(true)
+	}
+	if item.LocalTime {
+		response += "\n\n🌍 Получатели разных часовых поясов получат уведомление волнами, каждый в свое местное время."
+	}
+
+	msg := tgbotapi.NewMessage(chatID, response)
+	_, err := h.bot.Send(msg)
 	return err
 }
 
+// notificationTypeInfo возвращает тип уведомления и его название для администратора
+func notificationTypeInfo(notificationType string) (models.NotificationType, string) {
+	switch notificationType {
+	case "diary":
+		return models.NotificationDiary, "💌 Мини-дневник"
+	case "exercise":
+		return models.NotificationExercise, "👩🏼‍❤️‍👨🏻 Упражнение недели"
+	case "motivation":
+		return models.NotificationMotivation, "💒 Мотивация"
+	case "custom":
+		return models.NotificationCustom, "✏️ Кастомное"
+	}
+	return "", ""
+}
+
 // HandleScheduleCustomTimeCallback обрабатывает кнопку "Свое время"
 func (h *Handler) HandleScheduleCustomTimeCallback(callbackQuery *tgbotapi.CallbackQuery, data string) error {
 	userID := callbackQuery.From.ID
@@ -126,7 +359,9 @@ func (h *Handler) HandleScheduleCustomTimeCallback(callbackQuery *tgbotapi.Callb
 	selectedDate := parts[3] // 13.10.2025
 
 	// Устанавливаем состояние для ввода времени
-	h.userManager.SetState(userID, fmt.Sprintf("custom_time_%s", selectedDate))
+	if err := h.userManager.SetUserState(userID, models.NewCustomTimeState(selectedDate)); err != nil {
+		return err
+	}
 
 	text := fmt.Sprintf("⏰ Введите время для %s\n\n"+
 		"Формат: ЧЧ:ММ (например: 14:30)\n"+
@@ -155,7 +390,9 @@ func (h *Handler) HandleScheduleCustomDateCallback(callbackQuery *tgbotapi.Callb
 	}
 
 	// Устанавливаем состояние для ввода даты
-	h.userManager.SetState(userID, "custom_date")
+	if err := h.userManager.SetUserState(userID, models.State{Kind: models.StateCustomDate}); err != nil {
+		return err
+	}
 
 	text := "📅 Введите дату\n\n" +
 		"Формат: ДД.ММ.ГГГГ (например: 15.10.2025)\n" +
@@ -0,0 +1,332 @@
+package settings
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Префиксы callback data настроек уведомлений
+const (
+	CallbackSettingsMenu     = "settings_menu"
+	CallbackSettingsToggle   = "settings_toggle_"
+	CallbackSettingsQuiet    = "settings_quiet"
+	CallbackSettingsReminder = "settings_reminder"
+	CallbackSettingsTimezone = "settings_tz"
+)
+
+// quietPresets тихие часы, предлагаемые кнопками
+var quietPresets = []string{"22:00-07:00", "23:00-08:00", "00:00-09:00"}
+
+// reminderPresets время напоминаний, предлагаемое кнопками
+var reminderPresets = []string{"09:00", "12:00", "18:00", "20:00", "21:00", "22:00"}
+
+// HandleSettingsCommand обрабатывает команду /settings
+func (h *Handler) HandleSettingsCommand(message *tgbotapi.Message) error {
+	return h.ShowSettings(message.Chat.ID, message.From.ID)
+}
+
+// ShowSettings показывает настройки уведомлений пользователя
+func (h *Handler) ShowSettings(chatID, userID int64) error {
+	text, markup, err := h.settingsView(userID)
+	if err != nil {
+		return err
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = markup
+	_, err = h.bot.Send(msg)
+	return err
+}
+
+// HandleSettingsCallback обрабатывает кнопки настроек уведомлений
+func (h *Handler) HandleSettingsCallback(callbackQuery *tgbotapi.CallbackQuery, data string) error {
+	chatID := callbackQuery.Message.Chat.ID
+	messageID := callbackQuery.Message.MessageID
+	userID := callbackQuery.From.ID
+
+	switch {
+	case data == CallbackSettingsMenu:
+		return h.editSettings(chatID, messageID, userID)
+
+	case data == CallbackSettingsTimezone:
+		return h.ShowTimezonePicker(chatID, userID)
+
+	case strings.HasPrefix(data, CallbackSettingsToggle):
+		typ := models.NotificationType(strings.TrimPrefix(data, CallbackSettingsToggle))
+		if !knownType(typ) {
+			return fmt.Errorf("неизвестный тип уведомления: %s", typ)
+		}
+		if err := h.updatePreferences(userID, func(prefs *models.NotificationPreferences) error {
+			prefs.Toggle(typ)
+			return nil
+		}); err != nil {
+			return h.sendError(chatID, err)
+		}
+		return h.editSettings(chatID, messageID, userID)
+
+	case data == CallbackSettingsQuiet:
+		return h.showQuietPresets(chatID, messageID)
+	case data == CallbackSettingsQuiet+"_manual":
+		if err := h.userManager.SetUserState(userID, models.State{Kind: models.StateQuietHours}); err != nil {
+			return err
+		}
+		msg := tgbotapi.NewMessage(chatID, "⌨️ Напишите тихие часы по вашему местному времени, например 23:30-08:00.\n\n"+
+			"Чтобы отключить тихие часы, напишите «нет».")
+		_, err := h.bot.Send(msg)
+		return err
+	case strings.HasPrefix(data, CallbackSettingsQuiet+"_"):
+		if err := h.applyQuietHours(userID, strings.TrimPrefix(data, CallbackSettingsQuiet+"_")); err != nil {
+			return h.sendError(chatID, err)
+		}
+		return h.editSettings(chatID, messageID, userID)
+
+	case data == CallbackSettingsReminder:
+		return h.showReminderPresets(chatID, messageID)
+	case data == CallbackSettingsReminder+"_manual":
+		if err := h.userManager.SetUserState(userID, models.State{Kind: models.StateReminderTime}); err != nil {
+			return err
+		}
+		msg := tgbotapi.NewMessage(chatID, "⌨️ Напишите, во сколько вам удобно получать напоминания, например 19:30.\n\n"+
+			"Чтобы получать напоминания сразу, напишите «нет».")
+		_, err := h.bot.Send(msg)
+		return err
+	case strings.HasPrefix(data, CallbackSettingsReminder+"_"):
+		if err := h.applyReminderTime(userID, strings.TrimPrefix(data, CallbackSettingsReminder+"_")); err != nil {
+			return h.sendError(chatID, err)
+		}
+		return h.editSettings(chatID, messageID, userID)
+	}
+
+	return fmt.Errorf("неизвестная кнопка настроек: %s", data)
+}
+
+// HandleQuietHoursInput обрабатывает ручной ввод тихих часов
+func (h *Handler) HandleQuietHoursInput(userID int64, text string) error {
+	if err := h.applyQuietHours(userID, text); err != nil {
+		return h.sendError(userID, err)
+	}
+	if err := h.userManager.ClearState(userID); err != nil {
+		return err
+	}
+	return h.ShowSettings(userID, userID)
+}
+
+// HandleReminderTimeInput обрабатывает ручной ввод времени напоминаний
+func (h *Handler) HandleReminderTimeInput(userID int64, text string) error {
+	if err := h.applyReminderTime(userID, text); err != nil {
+		return h.sendError(userID, err)
+	}
+	if err := h.userManager.ClearState(userID); err != nil {
+		return err
+	}
+	return h.ShowSettings(userID, userID)
+}
+
+// applyQuietHours сохраняет тихие часы "ЧЧ:ММ-ЧЧ:ММ" ("off" или "нет" - отключить)
+func (h *Handler) applyQuietHours(userID int64, value string) error {
+	value = strings.TrimSpace(value)
+	if isOff(value) {
+		return h.updatePreferences(userID, func(prefs *models.NotificationPreferences) error {
+			prefs.QuietFrom, prefs.QuietTo = "", ""
+			return nil
+		})
+	}
+
+	from, to, err := models.ParseQuietHours(value)
+	if err != nil {
+		return err
+	}
+	return h.updatePreferences(userID, func(prefs *models.NotificationPreferences) error {
+		prefs.QuietFrom, prefs.QuietTo = from, to
+		return nil
+	})
+}
+
+// applyReminderTime сохраняет время напоминаний "ЧЧ:ММ" ("off" или "нет" - отключить)
+func (h *Handler) applyReminderTime(userID int64, value string) error {
+	value = strings.TrimSpace(value)
+	if isOff(value) {
+		return h.updatePreferences(userID, func(prefs *models.NotificationPreferences) error {
+			prefs.ReminderTime = ""
+			return nil
+		})
+	}
+
+	clock, err := models.NormalizeClock(value)
+	if err != nil {
+		return err
+	}
+	return h.updatePreferences(userID, func(prefs *models.NotificationPreferences) error {
+		prefs.ReminderTime = clock
+		return nil
+	})
+}
+
+// updatePreferences изменяет и сохраняет настройки уведомлений пользователя
+func (h *Handler) updatePreferences(userID int64, update func(prefs *models.NotificationPreferences) error) error {
+	prefs, err := h.notificationService.GetPreferences(userID)
+	if err != nil {
+		return err
+	}
+	if err := update(&prefs); err != nil {
+		return err
+	}
+	return h.notificationService.SetPreferences(userID, prefs)
+}
+
+// settingsView формирует экран настроек уведомлений
+func (h *Handler) settingsView(userID int64) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	user, err := h.notificationService.GetUser(userID)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+	var prefs models.NotificationPreferences
+	zone := models.DefaultTimezone
+	if user != nil {
+		prefs = user.Prefs()
+		zone = user.Location().String()
+	}
+
+	var b strings.Builder
+	b.WriteString("⚙️ Настройки уведомлений\n\n")
+	for _, option := range models.NotificationTypeOptions {
+		status := "включены"
+		if !prefs.Allows(option.Type) {
+			status = "отключены"
+		}
+		fmt.Fprintf(&b, "%s: %s\n", option.Title, status)
+	}
+
+	quiet := "не заданы"
+	if prefs.HasQuietHours() {
+		quiet = prefs.QuietHours()
+	}
+	reminder := "сразу, как запланировано"
+	if prefs.ReminderTime != "" {
+		reminder = "не раньше " + prefs.ReminderTime
+	}
+	fmt.Fprintf(&b, "\n🌙 Тихие часы: %s\n", quiet)
+	fmt.Fprintf(&b, "⏰ Напоминания: %s\n", reminder)
+	fmt.Fprintf(&b, "🌍 Часовой пояс: %s\n", describeTimezone(zone))
+	b.WriteString("\nНажмите на тип уведомлений, чтобы включить или отключить его. " +
+		"В тихие часы уведомления не приходят: они будут доставлены, когда тихие часы закончатся.")
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, option := range models.NotificationTypeOptions {
+		mark := "✅ "
+		if !prefs.Allows(option.Type) {
+			mark = "🔕 "
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(mark+option.Title, CallbackSettingsToggle+string(option.Type)))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🌙 Тихие часы", CallbackSettingsQuiet),
+			tgbotapi.NewInlineKeyboardButtonData("⏰ Время напоминаний", CallbackSettingsReminder),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🌍 Часовой пояс", CallbackSettingsTimezone),
+		),
+	)
+	return b.String(), tgbotapi.NewInlineKeyboardMarkup(rows...), nil
+}
+
+// editSettings обновляет экран настроек в сообщении с кнопками
+func (h *Handler) editSettings(chatID int64, messageID int, userID int64) error {
+	text, markup, err := h.settingsView(userID)
+	if err != nil {
+		return err
+	}
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, markup)
+	_, err = h.bot.Send(editMsg)
+	return err
+}
+
+// showQuietPresets предлагает варианты тихих часов
+func (h *Handler) showQuietPresets(chatID int64, messageID int) error {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, preset := range quietPresets {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(strings.Replace(preset, "-", "–", 1), CallbackSettingsQuiet+"_"+preset),
+		))
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⌨️ Свое время", CallbackSettingsQuiet+"_manual"),
+			tgbotapi.NewInlineKeyboardButtonData("🚫 Отключить", CallbackSettingsQuiet+"_off"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", CallbackSettingsMenu),
+		),
+	)
+
+	text := "🌙 Тихие часы\n\nВ это время бот не будет присылать уведомления, " +
+		"они придут сразу после окончания тихих часов. Время указывается по вашему часовому поясу."
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, tgbotapi.NewInlineKeyboardMarkup(rows...))
+	_, err := h.bot.Send(editMsg)
+	return err
+}
+
+// showReminderPresets предлагает варианты времени напоминаний
+func (h *Handler) showReminderPresets(chatID int64, messageID int) error {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i := 0; i < len(reminderPresets); i += 3 {
+		var row []tgbotapi.InlineKeyboardButton
+		for _, preset := range reminderPresets[i:min(i+3, len(reminderPresets))] {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(preset, CallbackSettingsReminder+"_"+preset))
+		}
+		rows = append(rows, row)
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⌨️ Свое время", CallbackSettingsReminder+"_manual"),
+			tgbotapi.NewInlineKeyboardButtonData("🚫 Без времени", CallbackSettingsReminder+"_off"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", CallbackSettingsMenu),
+		),
+	)
+
+	text := "⏰ Время напоминаний\n\nНапоминания о дневнике, упражнениях и мотивации будут приходить " +
+		"не раньше выбранного времени в тот же день."
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, tgbotapi.NewInlineKeyboardMarkup(rows...))
+	_, err := h.bot.Send(editMsg)
+	return err
+}
+
+// sendError сообщает пользователю об ошибке в настройках
+func (h *Handler) sendError(chatID int64, err error) error {
+	msg := tgbotapi.NewMessage(chatID, "❌ "+err.Error())
+	_, sendErr := h.bot.Send(msg)
+	return sendErr
+}
+
+// knownType проверяет, что тип уведомления можно настраивать
+func knownType(typ models.NotificationType) bool {
+	for _, option := range models.NotificationTypeOptions {
+		if option.Type == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// isOff проверяет, что пользователь хочет отключить настройку
+func isOff(value string) bool {
+	switch strings.ToLower(value) {
+	case "off", "нет", "выкл", "отключить":
+		return true
+	}
+	return false
+}
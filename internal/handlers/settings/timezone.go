@@ -0,0 +1,162 @@
+package settings
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+	"github.com/godofphonk/lovifyy-bot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Префиксы callback data выбора часового пояса
+const (
+	CallbackTimezoneSet    = "tz_set_"
+	CallbackTimezoneManual = "tz_manual"
+)
+
+// Handler обрабатывает пользовательские настройки
+type Handler struct {
+	bot                 *tgbotapi.BotAPI
+	userManager         *models.UserManager
+	notificationService *services.NotificationService
+}
+
+// NewHandler создает новый обработчик настроек
+func NewHandler(bot *tgbotapi.BotAPI, userManager *models.UserManager, notificationService *services.NotificationService) *Handler {
+	return &Handler{
+		bot:                 bot,
+		userManager:         userManager,
+		notificationService: notificationService,
+	}
+}
+
+// NeedsTimezone проверяет, что пользователь еще не выбрал часовой пояс
+func (h *Handler) NeedsTimezone(userID int64) bool {
+	user, err := h.notificationService.GetUser(userID)
+	return err == nil && user != nil && user.Timezone == ""
+}
+
+// HandleTimezoneCommand обрабатывает команду /timezone [пояс]
+func (h *Handler) HandleTimezoneCommand(message *tgbotapi.Message) error {
+	if zone := strings.TrimSpace(message.CommandArguments()); zone != "" {
+		return h.applyTimezoneInput(message.Chat.ID, message.From.ID, zone)
+	}
+	return h.ShowTimezonePicker(message.Chat.ID, message.From.ID)
+}
+
+// ShowTimezonePicker предлагает выбрать часовой пояс
+func (h *Handler) ShowTimezonePicker(chatID, userID int64) error {
+	response := "🌍 Выберите ваш часовой пояс\n\n" +
+		"Так напоминания и уведомления будут приходить в удобное для вас время, а не ночью."
+	if user, err := h.notificationService.GetUser(userID); err == nil && user != nil && user.Timezone != "" {
+		response += fmt.Sprintf("\n\nСейчас выбран: %s", describeTimezone(user.Timezone))
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i := 0; i < len(models.TimezoneOptions); i += 2 {
+		var row []tgbotapi.InlineKeyboardButton
+		for _, option := range models.TimezoneOptions[i:min(i+2, len(models.TimezoneOptions))] {
+			title := option.Title
+			if loc, err := time.LoadLocation(option.Zone); err == nil {
+				title += " (" + models.FormatUTCOffset(loc, time.Now()) + ")"
+			}
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(title, CallbackTimezoneSet+option.Zone))
+		}
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⌨️ Другой пояс", CallbackTimezoneManual),
+	))
+
+	msg := tgbotapi.NewMessage(chatID, response)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	_, err := h.bot.Send(msg)
+	return err
+}
+
+// HandleTimezoneCallback обрабатывает выбор часового пояса кнопкой
+func (h *Handler) HandleTimezoneCallback(callbackQuery *tgbotapi.CallbackQuery, data string) error {
+	chatID := callbackQuery.Message.Chat.ID
+	userID := callbackQuery.From.ID
+
+	if data == CallbackTimezoneManual {
+		if err := h.userManager.SetUserState(userID, models.State{Kind: models.StateTimezone}); err != nil {
+			return err
+		}
+		msg := tgbotapi.NewMessage(chatID, "⌨️ Напишите, сколько у вас сейчас времени (например, 14:35, или с датой: 13.10 14:35), "+
+			"или название часового пояса (например, Europe/Berlin).")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	zone := strings.TrimPrefix(data, CallbackTimezoneSet)
+	if err := h.notificationService.SetUserTimezone(userID, zone); err != nil {
+		msg := tgbotapi.NewMessage(chatID, "❌ "+err.Error())
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	editMsg := tgbotapi.NewEditMessageText(chatID, callbackQuery.Message.MessageID, confirmation(zone))
+	_, err := h.bot.Send(editMsg)
+	return err
+}
+
+// HandleTimezoneInput обрабатывает ручной ввод часового пояса или местного времени
+func (h *Handler) HandleTimezoneInput(userID int64, text string) error {
+	return h.applyTimezoneInput(userID, userID, text)
+}
+
+// applyTimezoneInput определяет пояс по тексту пользователя и сохраняет его
+func (h *Handler) applyTimezoneInput(chatID, userID int64, text string) error {
+	text = strings.TrimSpace(text)
+
+	zone := text
+	// Местное время "ЧЧ:ММ" или "ДД.ММ ЧЧ:ММ" (в названиях поясов двоеточия нет)
+	if strings.Contains(text, ":") {
+		detected, err := models.DetectTimezone(text, time.Now())
+		if err != nil {
+			msg := tgbotapi.NewMessage(chatID, "❌ "+err.Error())
+			_, err := h.bot.Send(msg)
+			return err
+		}
+		zone = detected
+	}
+
+	if err := h.notificationService.SetUserTimezone(userID, zone); err != nil {
+		msg := tgbotapi.NewMessage(chatID, "❌ "+err.Error()+"\n\nНапишите текущее время (ЧЧ:ММ) или название пояса, например Europe/Moscow.")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	if err := h.userManager.ClearState(userID); err != nil {
+		return err
+	}
+	msg := tgbotapi.NewMessage(chatID, confirmation(zone))
+	_, err := h.bot.Send(msg)
+	return err
+}
+
+// confirmation сообщение о сохраненном часовом поясе
+func confirmation(zone string) string {
+	return fmt.Sprintf("✅ Часовой пояс сохранен: %s\n\nИзменить его можно командой /timezone", describeTimezone(zone))
+}
+
+// describeTimezone описывает пояс: название, смещение и текущее местное время
+func describeTimezone(zone string) string {
+	loc, err := models.LoadTimezone(zone)
+	if err != nil {
+		return zone
+	}
+	title := zone
+	for _, option := range models.TimezoneOptions {
+		if option.Zone == zone {
+			title = option.Title
+			break
+		}
+	}
+	now := time.Now()
+	return fmt.Sprintf("%s (%s, сейчас %s)", title, models.FormatUTCOffset(loc, now), now.In(loc).Format("15:04"))
+}
@@ -23,44 +23,37 @@ func (m *Manager) SaveMessage(userID int64, username, message, response, model s
 		Model:     model,
 	}
 
-	filename := m.getUserChatFile(userID)
-	
-	// Загружаем существующую историю
-	var history []ChatMessage
-	if err := m.loadFromFile(filename, &history); err != nil {
-		return fmt.Errorf("failed to load existing history: %w", err)
-	}
-
-	// Добавляем новое сообщение
-	history = append(history, chatMsg)
-
 	// Ограничиваем размер истории (например, последние 1000 сообщений)
 	const maxHistorySize = 1000
-	if len(history) > maxHistorySize {
-		history = history[len(history)-maxHistorySize:]
+	if err := m.store.AppendChatMessage(chatMsg, maxHistorySize); err != nil {
+		return fmt.Errorf("failed to save message: %w", err)
 	}
-
-	// Сохраняем обновленную историю
-	return m.saveToFile(filename, history)
+	return nil
 }
 
 // GetUserHistory получает историю пользователя
 func (m *Manager) GetUserHistory(userID int64, limit int) ([]ChatMessage, error) {
-	filename := m.getUserChatFile(userID)
-	
-	var history []ChatMessage
-	if err := m.loadFromFile(filename, &history); err != nil {
+	history, err := m.store.ChatHistory(userID, limit)
+	if err != nil {
 		return nil, fmt.Errorf("failed to load history: %w", err)
 	}
 
-	// Применяем лимит если указан
-	if limit > 0 && len(history) > limit {
-		history = history[len(history)-limit:]
+	for i := range history {
+		history[i] = normalizeChatMessage(history[i])
 	}
-
 	return history, nil
 }
 
+// normalizeChatMessage исправляет записи, сохраненные старыми версиями бота
+// с перепутанными аргументами: (username=вопрос, message=ответ, response="chat", model="user")
+func normalizeChatMessage(msg ChatMessage) ChatMessage {
+	if msg.Response == "chat" && msg.Model == "user" {
+		msg.Message, msg.Response = msg.Username, msg.Message
+		msg.Username, msg.Model = "", ""
+	}
+	return msg
+}
+
 // GetRecentContext получает недавний контекст для ИИ
 func (m *Manager) GetRecentContext(userID int64, contextLimit int) string {
 	history, err := m.GetUserHistory(userID, contextLimit)
@@ -76,16 +69,18 @@ func (m *Manager) GetRecentContext(userID int64, contextLimit int) string {
 	return context.String()
 }
 
-// ClearUserHistory очищает историю конкретного пользователя
+// ClearUserHistory очищает историю конкретного пользователя вместе с ее содержанием
 func (m *Manager) ClearUserHistory(userID int64) error {
-	filename := m.getUserChatFile(userID)
-	return m.removeFile(filename)
+	if err := m.store.ClearChatHistory(userID); err != nil {
+		return err
+	}
+	return m.store.ClearChatSummary(userID)
 }
 
 // GetOpenAIHistory возвращает историю в формате OpenAI с ограничением
 func (m *Manager) GetOpenAIHistory(userID int64, systemPrompt string, limit int) ([]OpenAIMessage, error) {
-	// Загружаем обычную историю
-	messages, err := m.GetUserHistory(userID, 0)
+	// Загружаем последние limit сообщений
+	messages, err := m.GetUserHistory(userID, limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user history: %w", err)
 	}
@@ -100,11 +95,6 @@ func (m *Manager) GetOpenAIHistory(userID int64, systemPrompt string, limit int)
 		})
 	}
 
-	// Применяем лимит к сообщениям пользователя
-	if limit > 0 && len(messages) > limit {
-		messages = messages[len(messages)-limit:]
-	}
-
 	// Конвертируем в формат OpenAI
 	for _, msg := range messages {
 		// Добавляем сообщение пользователя
@@ -2,190 +2,141 @@ package history
 
 import (
 	"fmt"
-	"path/filepath"
 	"time"
 )
 
-// SaveDiaryEntry сохраняет запись в дневник (в отдельные файлы по типам)
+// SaveDiaryEntry сохраняет запись в дневник без указания гендера
 func (m *Manager) SaveDiaryEntry(userID int64, username, entry string, week int, entryType string) error {
-	diaryEntry := DiaryEntry{
-		Timestamp: time.Now(),
-		UserID:    userID,
-		Username:  username,
-		Entry:     entry,
-		Week:      week,
-		Type:      entryType,
-	}
-
-	filename := m.getDiaryTypeFile(userID, entryType)
-	
-	// Загружаем существующие записи
-	var entries []DiaryEntry
-	if err := m.loadFromFile(filename, &entries); err != nil {
-		return fmt.Errorf("failed to load existing diary entries: %w", err)
-	}
-
-	// Добавляем новую запись
-	entries = append(entries, diaryEntry)
-
-	// Сохраняем обновленные записи
-	return m.saveToFile(filename, entries)
+	return m.SaveDiaryEntryWithGender(userID, username, entry, week, entryType, "")
 }
 
-// SaveDiaryEntryWithGender сохраняет запись в дневник с указанием гендера (новый структурированный формат)
+// SaveDiaryEntryWithGender сохраняет запись в дневник с указанием гендера
 func (m *Manager) SaveDiaryEntryWithGender(userID int64, username, entry string, week int, entryType, gender string) error {
-	diaryEntry := DiaryEntry{
-		Timestamp: time.Now(),
-		UserID:    userID,
-		Username:  username,
-		Entry:     entry,
-		Week:      week,
-		Type:      entryType,
-	}
+	_, err := m.AddDiaryEntry(DiaryEntry{
+		UserID:   userID,
+		Username: username,
+		Entry:    entry,
+		Week:     week,
+		Type:     entryType,
+		Gender:   gender,
+	})
+	return err
+}
 
-	// Используем новый структурированный формат: gender/week/type/
-	filename := m.getDiaryStructuredFile(userID, gender, week, entryType)
-	
-	// Загружаем существующие записи
-	var entries []DiaryEntry
-	if err := m.loadFromFile(filename, &entries); err != nil {
-		return fmt.Errorf("failed to load existing diary entries: %w", err)
+// AddDiaryEntry сохраняет запись в дневник и возвращает ее с присвоенным идентификатором.
+// #хэштеги из текста становятся тегами записи.
+func (m *Manager) AddDiaryEntry(entry DiaryEntry) (DiaryEntry, error) {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	if len(entry.Tags) == 0 {
+		entry.Tags = ExtractHashtags(entry.Entry)
 	}
 
-	// Добавляем новую запись
-	entries = append(entries, diaryEntry)
+	id, err := m.store.AppendDiaryEntry(entry)
+	if err != nil {
+		return entry, fmt.Errorf("failed to save diary entry: %w", err)
+	}
+	entry.ID = id
+	return entry, nil
+}
 
-	// Сохраняем обновленные записи
-	return m.saveToFile(filename, entries)
+// QueryDiary возвращает записи дневника по произвольному фильтру
+func (m *Manager) QueryDiary(query DiaryQuery) ([]DiaryEntry, error) {
+	entries, err := m.store.DiaryEntries(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load diary entries: %w", err)
+	}
+	return entries, nil
 }
 
 // GetDiaryEntriesByWeek получает записи дневника для конкретной недели (только questions и personal)
 func (m *Manager) GetDiaryEntriesByWeek(userID int64, week int) ([]DiaryEntry, error) {
-	var allWeekEntries []DiaryEntry
-	
-	// Читаем записи из папки "diary_questions"
-	questionsFile := m.getDiaryTypeFile(userID, "questions")
-	var questionsEntries []DiaryEntry
-	if err := m.loadFromFile(questionsFile, &questionsEntries); err == nil {
-		for _, entry := range questionsEntries {
-			if entry.Week == week {
-				allWeekEntries = append(allWeekEntries, entry)
-			}
-		}
+	entries, err := m.QueryDiary(DiaryQuery{UserID: userID, Week: week})
+	if err != nil {
+		return nil, err
 	}
-	
-	// Читаем записи из папки "diary_personal"
-	personalFile := m.getDiaryTypeFile(userID, "personal")
-	var personalEntries []DiaryEntry
-	if err := m.loadFromFile(personalFile, &personalEntries); err == nil {
-		for _, entry := range personalEntries {
-			if entry.Week == week {
-				allWeekEntries = append(allWeekEntries, entry)
-			}
+
+	var weekEntries []DiaryEntry
+	for _, entry := range entries {
+		if entry.Type == "questions" || entry.Type == "personal" {
+			weekEntries = append(weekEntries, entry)
 		}
 	}
-	
-	return allWeekEntries, nil
+	return weekEntries, nil
 }
 
 // GetUserDiary получает записи дневника пользователя
 func (m *Manager) GetUserDiary(userID int64, limit int) ([]DiaryEntry, error) {
-	filename := m.getUserDiaryFile(userID)
-	
-	var diary []DiaryEntry
-	if err := m.loadFromFile(filename, &diary); err != nil {
-		return nil, fmt.Errorf("failed to load diary: %w", err)
-	}
+	return m.QueryDiary(DiaryQuery{UserID: userID, Limit: limit})
+}
 
-	// Применяем лимит если указан
-	if limit > 0 && len(diary) > limit {
-		diary = diary[len(diary)-limit:]
+// GetDiaryEntry возвращает запись дневника по идентификатору. Для пары запись ищется
+// и среди записей партнера.
+func (m *Manager) GetDiaryEntry(userID int64, id string) (*DiaryEntry, error) {
+	for _, memberID := range m.members(userID) {
+		entries, err := m.QueryDiary(DiaryQuery{UserID: memberID, ID: id})
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > 0 {
+			return &entries[0], nil
+		}
 	}
-
-	return diary, nil
+	return nil, ErrDiaryEntryNotFound
 }
 
-// ClearUserDiary очищает дневник конкретного пользователя (все типы файлов)
-func (m *Manager) ClearUserDiary(userID int64) error {
-	// Удаляем файлы пользователя из всех папок типов
-	typeDirs := []string{
-		"diary_questions",
-		"diary_personal", 
-		"diary_joint",
-		"diary_questions_male",
-		"diary_questions_female",
-		"diary_personal_male",
-		"diary_personal_female",
+// EditDiaryEntry исправляет текст собственной записи пользователя. Прежний текст остается
+// в истории правок, а инсайты строятся уже по исправленному. Теги пересобираются
+// из #хэштегов нового текста; если их нет, теги очищаются, чтобы темы выделились заново.
+func (m *Manager) EditDiaryEntry(userID int64, id, text string) error {
+	if err := m.store.UpdateDiaryEntry(userID, id, text, time.Now()); err != nil {
+		return fmt.Errorf("failed to edit diary entry: %w", err)
 	}
-
-	var lastError error
-	for _, typeDir := range typeDirs {
-		filename := filepath.Join(m.diaryDir, typeDir, fmt.Sprintf("user_%d.json", userID))
-		if err := m.removeFile(filename); err != nil {
-			lastError = err // Сохраняем последнюю ошибку, но продолжаем удаление
-		}
+	if err := m.store.SetDiaryTags(userID, id, ExtractHashtags(text)); err != nil {
+		return fmt.Errorf("failed to update diary tags: %w", err)
 	}
+	return nil
+}
 
-	// Также удаляем основной файл дневника
-	mainFile := m.getUserDiaryFile(userID)
-	if err := m.removeFile(mainFile); err != nil {
-		lastError = err
+// DeleteDiaryEntry удаляет одну собственную запись пользователя
+func (m *Manager) DeleteDiaryEntry(userID int64, id string) error {
+	if err := m.store.DeleteDiaryEntry(userID, id); err != nil {
+		return fmt.Errorf("failed to delete diary entry: %w", err)
 	}
+	return nil
+}
 
-	return lastError
+// ClearUserDiary очищает дневник конкретного пользователя (все типы записей)
+func (m *Manager) ClearUserDiary(userID int64) error {
+	return m.store.ClearDiary(userID)
 }
 
 // GetDiaryEntriesByType получает записи дневника по типу
 func (m *Manager) GetDiaryEntriesByType(userID int64, entryType string) ([]DiaryEntry, error) {
-	filename := m.getDiaryTypeFile(userID, entryType)
-	
-	var entries []DiaryEntry
-	if err := m.loadFromFile(filename, &entries); err != nil {
-		return nil, fmt.Errorf("failed to load diary entries for type %s: %w", entryType, err)
-	}
-
-	return entries, nil
+	return m.QueryDiary(DiaryQuery{UserID: userID, Type: entryType})
 }
 
-// GetDiaryEntriesByTypeAndGender получает записи дневника по типу и гендеру (старый формат)
+// GetDiaryEntriesByTypeAndGender получает записи дневника по типу и гендеру
 func (m *Manager) GetDiaryEntriesByTypeAndGender(userID int64, entryType, gender string) ([]DiaryEntry, error) {
-	filename := m.getDiaryGenderFile(userID, entryType, gender)
-	
-	var entries []DiaryEntry
-	if err := m.loadFromFile(filename, &entries); err != nil {
-		return nil, fmt.Errorf("failed to load diary entries for type %s and gender %s: %w", entryType, gender, err)
-	}
-
-	return entries, nil
+	return m.QueryDiary(DiaryQuery{UserID: userID, Type: entryType, Gender: gender})
 }
 
-// GetDiaryEntriesStructured получает записи дневника по структурированному формату: gender/week/type
+// GetDiaryEntriesStructured получает записи дневника по гендеру, неделе и типу
 func (m *Manager) GetDiaryEntriesStructured(userID int64, gender string, week int, entryType string) ([]DiaryEntry, error) {
-	filename := m.getDiaryStructuredFile(userID, gender, week, entryType)
-	
-	var entries []DiaryEntry
-	if err := m.loadFromFile(filename, &entries); err != nil {
-		return nil, fmt.Errorf("failed to load diary entries: %w", err)
-	}
-	
-	return entries, nil
+	return m.QueryDiary(DiaryQuery{UserID: userID, Gender: gender, Week: week, Type: entryType})
 }
 
-// GetAllDiaryEntriesForWeekAndGender получает ВСЕ записи дневника для конкретной недели и гендера (все типы)
+// GetAllDiaryEntriesForWeekAndGender получает ВСЕ записи дневника для конкретной недели и гендера (все типы).
+// Для пары учитываются записи обоих партнеров.
 func (m *Manager) GetAllDiaryEntriesForWeekAndGender(userID int64, gender string, week int) ([]DiaryEntry, error) {
 	var allEntries []DiaryEntry
-	
-	// Получаем записи всех типов для данной недели и гендера
-	types := []string{"personal", "questions", "joint"}
-	
-	for _, entryType := range types {
-		entries, err := m.GetDiaryEntriesStructured(userID, gender, week, entryType)
+	for _, memberID := range m.members(userID) {
+		entries, err := m.QueryDiary(DiaryQuery{UserID: memberID, Gender: gender, Week: week})
 		if err != nil {
-			// Если файл не существует, это нормально - просто пропускаем
-			continue
+			return nil, err
 		}
 		allEntries = append(allEntries, entries...)
 	}
-	
-	return allEntries, nil
+	return sortDiaryEntries(allEntries, 0), nil
 }
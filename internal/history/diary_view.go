@@ -0,0 +1,115 @@
+package history
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// diaryTypeOrder порядок типов записей при просмотре дневника
+var diaryTypeOrder = map[string]int{
+	"personal":  0,
+	"questions": 1,
+	"joint":     2,
+}
+
+// diaryTypeRank возвращает место типа в порядке просмотра; прочие типы идут после известных
+func diaryTypeRank(entryType string) int {
+	if rank, ok := diaryTypeOrder[entryType]; ok {
+		return rank
+	}
+	return len(diaryTypeOrder)
+}
+
+// SortDiaryForView упорядочивает записи для просмотра: по типу (личные мысли, ответы на вопросы,
+// совместные вопросы, затем остальные по алфавиту), внутри типа - по времени создания
+func SortDiaryForView(entries []DiaryEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Type != b.Type {
+			if ra, rb := diaryTypeRank(a.Type), diaryTypeRank(b.Type); ra != rb {
+				return ra < rb
+			}
+			return a.Type < b.Type
+		}
+		if !a.Timestamp.Equal(b.Timestamp) {
+			return a.Timestamp.Before(b.Timestamp)
+		}
+		return a.ID < b.ID
+	})
+}
+
+// TruncateRunes обрезает текст до limit символов вместе с многоточием, не разрывая
+// многобайтовые UTF-8 последовательности
+func TruncateRunes(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	if limit <= 0 {
+		return ""
+	}
+	runes := []rune(text)
+	return string(runes[:limit-1]) + "…"
+}
+
+// UTF16Len возвращает длину текста в единицах UTF-16: так Telegram считает длину сообщений
+// и подписей, эмодзи и другие символы вне BMP занимают две единицы
+func UTF16Len(text string) int {
+	n := 0
+	for _, r := range text {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// utf16Prefix возвращает, сколько первых рун помещается в limit единиц UTF-16
+func utf16Prefix(runes []rune, limit int) int {
+	units := 0
+	for i, r := range runes {
+		units += utf16.RuneLen(r)
+		if units > limit {
+			return i
+		}
+	}
+	return len(runes)
+}
+
+// TruncateUTF16 обрезает текст до limit единиц UTF-16 вместе с многоточием,
+// чтобы он гарантированно поместился в ограничение Telegram
+func TruncateUTF16(text string, limit int) string {
+	if UTF16Len(text) <= limit {
+		return text
+	}
+	if limit <= 0 {
+		return ""
+	}
+	runes := []rune(text)
+	return string(runes[:utf16Prefix(runes, limit-1)]) + "…"
+}
+
+// SplitUTF16 разбивает текст на части не длиннее limit единиц UTF-16, предпочитая границы строк
+func SplitUTF16(text string, limit int) []string {
+	var parts []string
+	runes := []rune(strings.TrimSpace(text))
+
+	for UTF16Len(string(runes)) > limit {
+		fit := utf16Prefix(runes, limit)
+		if fit == 0 {
+			break
+		}
+		cut := fit
+		for i := fit; i > fit/2; i-- {
+			if runes[i-1] == '\n' {
+				cut = i
+				break
+			}
+		}
+		parts = append(parts, strings.TrimSpace(string(runes[:cut])))
+		runes = runes[cut:]
+	}
+	if len(runes) > 0 {
+		parts = append(parts, string(runes))
+	}
+	return parts
+}
@@ -1,7 +1,7 @@
 package history
 
 import (
-	"os"
+	"sync"
 	"time"
 )
 
@@ -15,32 +15,122 @@ type ChatMessage struct {
 	Model     string    `json:"model"`
 }
 
+// ChatSummary сжатое содержание старой части переписки пользователя
+type ChatSummary struct {
+	UserID          int64     `json:"user_id"`
+	Summary         string    `json:"summary"`
+	SummarizedUntil time.Time `json:"summarized_until"` // время последнего сжатого сообщения
+	MessagesCount   int       `json:"messages_count"`   // сколько обменов вошло в содержание
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
 // DiaryEntry представляет одну запись в дневнике
 type DiaryEntry struct {
-	Timestamp time.Time `json:"timestamp"`
-	UserID    int64     `json:"user_id"`
-	Username  string    `json:"username"`
-	Entry     string    `json:"entry"`
-	Week      int       `json:"week"`               // номер недели (1-4)
-	Type      string    `json:"type"`               // тип записи: questions, joint, personal
-	Mood      string    `json:"mood,omitempty"`     // настроение (опционально)
-	Tags      []string  `json:"tags,omitempty"`     // теги (опционально)
+	ID        string      `json:"id,omitempty"` // стабильный идентификатор записи
+	Timestamp time.Time   `json:"timestamp"`
+	UserID    int64       `json:"user_id"`
+	Username  string      `json:"username"`
+	Entry     string      `json:"entry"`
+	Week      int         `json:"week"`             // номер недели (1-4)
+	Type      string      `json:"type"`             // тип записи: questions, joint, personal
+	Gender    string      `json:"gender,omitempty"` // гендер автора: male, female (пусто для старых записей)
+	Mood      string      `json:"mood,omitempty"`   // оценка настроения по шкале MoodScale: "1"-"5" (опционально)
+	Tags      []string    `json:"tags,omitempty"`   // теги без # (опционально)
+	Edits     []DiaryEdit `json:"edits,omitempty"`  // прежние версии текста, от старых к новым
+
+	Attachment *DiaryAttachment `json:"attachment,omitempty"` // файл записи (голосовое, аудио, фото)
+}
+
+// Виды вложений записи дневника
+const (
+	AttachmentVoice = "voice"
+	AttachmentAudio = "audio"
+	AttachmentPhoto = "photo"
+)
+
+// DiaryAttachment файл Telegram, приложенный к записи или из которого она получена
+type DiaryAttachment struct {
+	Kind        string `json:"kind"`                  // AttachmentVoice, AttachmentAudio, AttachmentPhoto
+	FileID      string `json:"file_id"`               // file_id Telegram для повторной отправки
+	Description string `json:"description,omitempty"` // описание фото vision-моделью (опционально)
+}
+
+// InsightText возвращает текст записи для анализа моделью: вместе с описанием приложенного фото
+func (e DiaryEntry) InsightText() string {
+	if e.Attachment == nil || e.Attachment.Kind != AttachmentPhoto {
+		return e.Entry
+	}
+	photo := "[Фото]"
+	if e.Attachment.Description != "" {
+		photo = "[Фото: " + e.Attachment.Description + "]"
+	}
+	if e.Entry == "" {
+		return photo
+	}
+	return e.Entry + " " + photo
+}
+
+// DiaryEdit прежняя версия текста записи дневника
+type DiaryEdit struct {
+	Entry    string    `json:"entry"`
+	EditedAt time.Time `json:"edited_at"` // когда текст был заменен
+}
+
+// EditedAt возвращает время последней правки записи (false - запись не правилась)
+func (e DiaryEntry) EditedAt() (time.Time, bool) {
+	if len(e.Edits) == 0 {
+		return time.Time{}, false
+	}
+	return e.Edits[len(e.Edits)-1].EditedAt, true
 }
 
 // Manager управляет историей переписки и дневниками
 type Manager struct {
-	historyDir string
-	diaryDir   string
+	store   Store
+	couples CoupleResolver
+
+	summary     SummaryOptions
+	summarizing sync.Map // userID -> struct{}, сжатие уже выполняется
 }
 
-// NewManager создает новый менеджер истории
+// CoupleResolver определяет участников пары пользователя
+type CoupleResolver interface {
+	// Members возвращает ID всех участников пары (только сам пользователь, если пары нет)
+	Members(userID int64) []int64
+}
+
+// NewManager создает новый менеджер истории с JSON хранилищем по умолчанию
 func NewManager() *Manager {
-	historyDir := "data/chats"
-	diaryDir := "data/diaries"
-	os.MkdirAll(historyDir, 0755)
-	os.MkdirAll(diaryDir, 0755)
+	store, err := NewJSONStore("data/chats", "data/diaries")
+	if err != nil {
+		// Каталоги создадутся при первой записи
+		store = &jsonStore{historyDir: "data/chats", diaryDir: "data/diaries"}
+	}
+	return NewManagerWithStore(store)
+}
+
+// NewManagerWithStore создает менеджер истории поверх переданного хранилища
+func NewManagerWithStore(store Store) *Manager {
 	return &Manager{
-		historyDir: historyDir,
-		diaryDir:   diaryDir,
+		store:   store,
+		summary: DefaultSummaryOptions(),
+	}
+}
+
+// SetCoupleResolver подключает определение пар, чтобы выборки по гендеру включали записи партнера
+func (m *Manager) SetCoupleResolver(resolver CoupleResolver) {
+	m.couples = resolver
+}
+
+// members возвращает пользователей, чьи записи относятся к дневнику пары
+func (m *Manager) members(userID int64) []int64 {
+	if m.couples == nil {
+		return []int64{userID}
 	}
+	return m.couples.Members(userID)
+}
+
+// Close закрывает хранилище истории
+func (m *Manager) Close() error {
+	return m.store.Close()
 }
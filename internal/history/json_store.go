@@ -0,0 +1,375 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jsonStore хранит историю в JSON файлах (исходная раскладка каталогов data/chats и data/diaries):
+//
+//	chats/user_<id>/chat.json                         - история чата
+//	diaries/<gender>/week_<n>/<type>/user_<id>.json   - записи с указанием гендера
+//	diaries/diary_<type>/user_<id>.json               - записи без гендера
+//	diaries/diary_<type>_<gender>/user_<id>.json      - старый формат, только чтение
+type jsonStore struct {
+	historyDir string
+	diaryDir   string
+	mutex      sync.RWMutex
+}
+
+// NewJSONStore создает файловое JSON хранилище
+func NewJSONStore(historyDir, diaryDir string) (Store, error) {
+	if err := os.MkdirAll(historyDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create chats directory: %w", err)
+	}
+	if err := os.MkdirAll(diaryDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create diaries directory: %w", err)
+	}
+	return &jsonStore{
+		historyDir: historyDir,
+		diaryDir:   diaryDir,
+	}, nil
+}
+
+// AppendChatMessage добавляет сообщение в историю чата
+func (s *jsonStore) AppendChatMessage(msg ChatMessage, maxHistory int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	filename := s.chatFile(msg.UserID)
+
+	var history []ChatMessage
+	if err := loadFromFile(filename, &history); err != nil {
+		return fmt.Errorf("failed to load existing history: %w", err)
+	}
+
+	history = append(history, msg)
+	if maxHistory > 0 && len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+
+	return saveToFile(filename, history)
+}
+
+// ChatHistory возвращает историю чата пользователя
+func (s *jsonStore) ChatHistory(userID int64, limit int) ([]ChatMessage, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var history []ChatMessage
+	if err := loadFromFile(s.chatFile(userID), &history); err != nil {
+		return nil, fmt.Errorf("failed to load history: %w", err)
+	}
+
+	if limit > 0 && len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	return history, nil
+}
+
+// ClearChatHistory удаляет историю чата пользователя
+func (s *jsonStore) ClearChatHistory(userID int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return removeFile(s.chatFile(userID))
+}
+
+// ChatSummary возвращает сжатое содержание переписки пользователя
+func (s *jsonStore) ChatSummary(userID int64) (*ChatSummary, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var summary ChatSummary
+	if err := loadFromFile(s.summaryFile(userID), &summary); err != nil {
+		return nil, fmt.Errorf("failed to load chat summary: %w", err)
+	}
+	if summary.Summary == "" {
+		return nil, nil
+	}
+	return &summary, nil
+}
+
+// SaveChatSummary сохраняет сжатое содержание переписки пользователя
+func (s *jsonStore) SaveChatSummary(summary ChatSummary) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return saveToFile(s.summaryFile(summary.UserID), summary)
+}
+
+// ClearChatSummary удаляет сжатое содержание переписки пользователя
+func (s *jsonStore) ClearChatSummary(userID int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return removeFile(s.summaryFile(userID))
+}
+
+// AppendDiaryEntry добавляет запись в дневник
+func (s *jsonStore) AppendDiaryEntry(entry DiaryEntry) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	filename := s.diaryFile(entry)
+
+	var entries []DiaryEntry
+	if err := loadFromFile(filename, &entries); err != nil {
+		return "", fmt.Errorf("failed to load existing diary entries: %w", err)
+	}
+
+	if entry.ID == "" {
+		// Записи ищутся по ID во всех файлах пользователя, поэтому ID уникален среди всех его записей
+		used, err := s.diaryIDs(entry.UserID)
+		if err != nil {
+			return "", err
+		}
+		entry.ID = uniqueDiaryID(used, entry.Timestamp)
+	}
+	entries = append(entries, entry)
+	if err := saveToFile(filename, entries); err != nil {
+		return "", err
+	}
+	return entry.ID, nil
+}
+
+// DiaryEntries возвращает записи дневника по фильтру
+func (s *jsonStore) DiaryEntries(query DiaryQuery) ([]DiaryEntry, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	files, err := s.diaryFiles(query.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []DiaryEntry
+	for _, filename := range files {
+		gender := s.genderFromPath(filename)
+		if query.Gender != "" && gender != "" && gender != query.Gender {
+			continue
+		}
+
+		var entries []DiaryEntry
+		if err := loadFromFile(filename, &entries); err != nil {
+			return nil, fmt.Errorf("failed to load diary entries: %w", err)
+		}
+
+		for _, entry := range entries {
+			if entry.Gender == "" {
+				entry.Gender = gender
+			}
+			if entry.ID == "" {
+				entry.ID = diaryID(entry.Timestamp)
+			}
+			if query.matches(entry) {
+				result = append(result, entry)
+			}
+		}
+	}
+
+	return sortDiaryEntries(result, query.Limit), nil
+}
+
+// UpdateDiaryEntry заменяет текст записи, сохраняя прежний в истории правок
+func (s *jsonStore) UpdateDiaryEntry(userID int64, id, text string, editedAt time.Time) error {
+	return s.modifyDiaryEntry(userID, id, func(entries []DiaryEntry, i int) []DiaryEntry {
+		entries[i].Edits = append(entries[i].Edits, DiaryEdit{Entry: entries[i].Entry, EditedAt: editedAt})
+		entries[i].Entry = text
+		return entries
+	})
+}
+
+// SetDiaryMood сохраняет оценку настроения записи
+func (s *jsonStore) SetDiaryMood(userID int64, id, mood string) error {
+	return s.modifyDiaryEntry(userID, id, func(entries []DiaryEntry, i int) []DiaryEntry {
+		entries[i].Mood = mood
+		return entries
+	})
+}
+
+// SetDiaryTags заменяет теги записи
+func (s *jsonStore) SetDiaryTags(userID int64, id string, tags []string) error {
+	return s.modifyDiaryEntry(userID, id, func(entries []DiaryEntry, i int) []DiaryEntry {
+		entries[i].Tags = tags
+		return entries
+	})
+}
+
+// SetDiaryAttachmentDescription сохраняет описание вложения записи
+func (s *jsonStore) SetDiaryAttachmentDescription(userID int64, id, description string) error {
+	return s.modifyDiaryEntry(userID, id, func(entries []DiaryEntry, i int) []DiaryEntry {
+		if entries[i].Attachment != nil {
+			entries[i].Attachment.Description = description
+		}
+		return entries
+	})
+}
+
+// DeleteDiaryEntry удаляет одну запись дневника
+func (s *jsonStore) DeleteDiaryEntry(userID int64, id string) error {
+	return s.modifyDiaryEntry(userID, id, func(entries []DiaryEntry, i int) []DiaryEntry {
+		return append(entries[:i], entries[i+1:]...)
+	})
+}
+
+// modifyDiaryEntry находит файл с записью id и сохраняет его после изменения modify
+func (s *jsonStore) modifyDiaryEntry(userID int64, id string, modify func(entries []DiaryEntry, i int) []DiaryEntry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	files, err := s.diaryFiles(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, filename := range files {
+		var entries []DiaryEntry
+		if err := loadFromFile(filename, &entries); err != nil {
+			return fmt.Errorf("failed to load diary entries: %w", err)
+		}
+		for i := range entries {
+			// Старые записи получают идентификатор, вычисленный при чтении
+			if entries[i].ID == "" {
+				entries[i].ID = diaryID(entries[i].Timestamp)
+			}
+			if entries[i].ID == id {
+				return saveToFile(filename, modify(entries, i))
+			}
+		}
+	}
+	return ErrDiaryEntryNotFound
+}
+
+// ClearDiary удаляет все записи дневника пользователя
+func (s *jsonStore) ClearDiary(userID int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	files, err := s.diaryFiles(userID)
+	if err != nil {
+		return err
+	}
+
+	var lastError error
+	for _, filename := range files {
+		if err := removeFile(filename); err != nil {
+			lastError = err // Сохраняем последнюю ошибку, но продолжаем удаление
+		}
+	}
+	return lastError
+}
+
+// Close ничего не делает для файлового хранилища
+func (s *jsonStore) Close() error {
+	return nil
+}
+
+// diaryID вычисляет идентификатор записи по времени создания
+func diaryID(timestamp time.Time) string {
+	return strconv.FormatInt(timestamp.UnixNano(), 36)
+}
+
+// diaryIDs возвращает идентификаторы всех записей пользователя (вызывается под блокировкой)
+func (s *jsonStore) diaryIDs(userID int64) (map[string]bool, error) {
+	files, err := s.diaryFiles(userID)
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[string]bool)
+	for _, filename := range files {
+		var entries []DiaryEntry
+		if err := loadFromFile(filename, &entries); err != nil {
+			return nil, fmt.Errorf("failed to load diary entries: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.ID == "" {
+				entry.ID = diaryID(entry.Timestamp)
+			}
+			used[entry.ID] = true
+		}
+	}
+	return used, nil
+}
+
+// uniqueDiaryID выдает идентификатор новой записи, не совпадающий с уже выданными
+func uniqueDiaryID(used map[string]bool, timestamp time.Time) string {
+	id := diaryID(timestamp)
+	for used[id] {
+		timestamp = timestamp.Add(time.Nanosecond)
+		id = diaryID(timestamp)
+	}
+	return id
+}
+
+// chatFile возвращает путь к файлу чата пользователя
+func (s *jsonStore) chatFile(userID int64) string {
+	return filepath.Join(s.historyDir, fmt.Sprintf("user_%d", userID), "chat.json")
+}
+
+// summaryFile возвращает путь к файлу сжатого содержания переписки
+func (s *jsonStore) summaryFile(userID int64) string {
+	return filepath.Join(s.historyDir, fmt.Sprintf("user_%d", userID), "summary.json")
+}
+
+// diaryFile возвращает путь к файлу, в который пишется запись
+func (s *jsonStore) diaryFile(entry DiaryEntry) string {
+	userFile := fmt.Sprintf("user_%d.json", entry.UserID)
+	if entry.Gender == "" {
+		return filepath.Join(s.diaryDir, fmt.Sprintf("diary_%s", entry.Type), userFile)
+	}
+	return filepath.Join(s.diaryDir, entry.Gender, fmt.Sprintf("week_%d", entry.Week), entry.Type, userFile)
+}
+
+// diaryFiles находит все файлы дневника (userID == 0 - всех пользователей)
+func (s *jsonStore) diaryFiles(userID int64) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(s.diaryDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		name := d.Name()
+		if userID != 0 {
+			if name != fmt.Sprintf("user_%d.json", userID) {
+				return nil
+			}
+		} else if !strings.HasPrefix(name, "user_") || !strings.HasSuffix(name, ".json") {
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan diary directory: %w", err)
+	}
+	return files, nil
+}
+
+// genderFromPath определяет гендер по расположению файла
+func (s *jsonStore) genderFromPath(filename string) string {
+	rel, err := filepath.Rel(s.diaryDir, filename)
+	if err != nil {
+		return ""
+	}
+	top := strings.Split(filepath.ToSlash(rel), "/")[0]
+	switch {
+	case top == "male" || top == "female":
+		return top
+	case strings.HasSuffix(top, "_male"):
+		return "male"
+	case strings.HasSuffix(top, "_female"):
+		return "female"
+	}
+	return ""
+}
@@ -0,0 +1,228 @@
+package history
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MoodScale шкала настроения записи: оценка равна индексу эмодзи + 1
+var MoodScale = []string{"😢", "😕", "😐", "🙂", "😍"}
+
+const (
+	// maxDiaryTags максимальное число тегов записи
+	maxDiaryTags = 5
+	// maxTagLength максимальная длина тега в символах
+	maxTagLength = 30
+	// topTagsLimit сколько самых частых тегов показывается в динамике настроения
+	topTagsLimit = 3
+)
+
+// hashtagRegex находит #хэштеги в тексте записи
+var hashtagRegex = regexp.MustCompile(`#([\p{L}\p{N}_]+)`)
+
+// tagPrompt промпт для выделения тегов записи моделью
+const tagPrompt = "Выдели из записи в дневнике отношений 1-3 темы в виде коротких тегов " +
+	"(одно-два слова, строчными буквами, на русском, например: ссора, быт, поддержка, планы). " +
+	"Ответь только тегами через запятую, без пояснений.\n\nЗапись:\n"
+
+// MoodScore возвращает оценку настроения записи (false - настроение не указано)
+func MoodScore(mood string) (int, bool) {
+	score, err := strconv.Atoi(mood)
+	if err != nil || score < 1 || score > len(MoodScale) {
+		return 0, false
+	}
+	return score, true
+}
+
+// MoodEmoji возвращает эмодзи оценки настроения (пусто, если оценка вне шкалы)
+func MoodEmoji(mood string) string {
+	score, ok := MoodScore(mood)
+	if !ok {
+		return ""
+	}
+	return MoodScale[score-1]
+}
+
+// ExtractHashtags возвращает теги, отмеченные в тексте через #, в нижнем регистре и без повторов
+func ExtractHashtags(text string) []string {
+	var tags []string
+	for _, match := range hashtagRegex.FindAllStringSubmatch(text, -1) {
+		tags = appendTag(tags, match[1])
+	}
+	return tags
+}
+
+// parseTags разбирает ответ модели со списком тегов
+func parseTags(response string) []string {
+	var tags []string
+	fields := strings.FieldsFunc(response, func(r rune) bool {
+		return r == ',' || r == ';' || r == '\n'
+	})
+	for _, field := range fields {
+		field = strings.Trim(strings.TrimSpace(field), "#.-•*\"'«»")
+		tags = appendTag(tags, field)
+	}
+	return tags
+}
+
+// appendTag добавляет нормализованный тег, пропуская пустые, слишком длинные и повторы
+func appendTag(tags []string, tag string) []string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" || utf8.RuneCountInString(tag) > maxTagLength || len(tags) >= maxDiaryTags {
+		return tags
+	}
+	for _, existing := range tags {
+		if existing == tag {
+			return tags
+		}
+	}
+	return append(tags, tag)
+}
+
+// SetDiaryMood сохраняет оценку настроения собственной записи пользователя
+func (m *Manager) SetDiaryMood(userID int64, id string, score int) error {
+	if score < 1 || score > len(MoodScale) {
+		return fmt.Errorf("mood score out of range: %d", score)
+	}
+	if err := m.store.SetDiaryMood(userID, id, strconv.Itoa(score)); err != nil {
+		return fmt.Errorf("failed to save diary mood: %w", err)
+	}
+	return nil
+}
+
+// TagDiaryEntry выделяет теги записи с помощью модели, если пользователь не отметил их сам.
+// Возвращает сохраненные теги.
+func (m *Manager) TagDiaryEntry(userID int64, id string, generate func(prompt string) (string, error)) ([]string, error) {
+	entry, err := m.GetDiaryEntry(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(entry.Tags) > 0 {
+		return entry.Tags, nil
+	}
+	// У фото без подписи темы появляются только после описания
+	if entry.Entry == "" && (entry.Attachment == nil || entry.Attachment.Description == "") {
+		return nil, nil
+	}
+
+	response, err := generate(tagPrompt + entry.InsightText())
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate diary tags: %w", err)
+	}
+	tags := parseTags(m.cleanResponse(response))
+	if len(tags) > topTagsLimit {
+		tags = tags[:topTagsLimit]
+	}
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	if err := m.store.SetDiaryTags(entry.UserID, id, tags); err != nil {
+		return nil, fmt.Errorf("failed to save diary tags: %w", err)
+	}
+	return tags, nil
+}
+
+// WeekMood среднее настроение участника за неделю программы
+type WeekMood struct {
+	Week    int
+	Average float64
+	Count   int // записи с оценкой настроения
+}
+
+// MoodTrend динамика настроения одного участника пары по неделям
+type MoodTrend struct {
+	UserID  int64
+	Gender  string
+	Weeks   []WeekMood // все недели программы по порядку, включая недели без оценок
+	TopTags []string   // самые частые теги записей
+}
+
+// Rated возвращает недели, за которые есть оценки настроения
+func (t MoodTrend) Rated() []WeekMood {
+	var rated []WeekMood
+	for _, week := range t.Weeks {
+		if week.Count > 0 {
+			rated = append(rated, week)
+		}
+	}
+	return rated
+}
+
+// Change возвращает изменение среднего настроения от первой оцененной недели к последней
+// (false - оцененных недель меньше двух)
+func (t MoodTrend) Change() (float64, bool) {
+	rated := t.Rated()
+	if len(rated) < 2 {
+		return 0, false
+	}
+	return rated[len(rated)-1].Average - rated[0].Average, true
+}
+
+// MoodTrends считает динамику настроения пользователя и его партнера за weeks недель программы
+func (m *Manager) MoodTrends(userID int64, weeks int) ([]MoodTrend, error) {
+	var trends []MoodTrend
+	for _, memberID := range m.members(userID) {
+		entries, err := m.QueryDiary(DiaryQuery{UserID: memberID})
+		if err != nil {
+			return nil, err
+		}
+		trends = append(trends, moodTrend(memberID, entries, weeks))
+	}
+	return trends, nil
+}
+
+// moodTrend собирает динамику настроения по записям одного пользователя
+func moodTrend(userID int64, entries []DiaryEntry, weeks int) MoodTrend {
+	trend := MoodTrend{UserID: userID, Weeks: make([]WeekMood, weeks)}
+	sums := make([]int, weeks)
+	genders := make(map[string]int)
+	tags := make(map[string]int)
+
+	for _, entry := range entries {
+		if entry.Gender != "" {
+			genders[entry.Gender]++
+		}
+		for _, tag := range entry.Tags {
+			tags[tag]++
+		}
+		score, ok := MoodScore(entry.Mood)
+		if !ok || entry.Week < 1 || entry.Week > weeks {
+			continue
+		}
+		sums[entry.Week-1] += score
+		trend.Weeks[entry.Week-1].Count++
+	}
+
+	for i := range trend.Weeks {
+		trend.Weeks[i].Week = i + 1
+		if count := trend.Weeks[i].Count; count > 0 {
+			trend.Weeks[i].Average = float64(sums[i]) / float64(count)
+		}
+	}
+
+	// Гендер участника - тот, с которым он чаще делал записи
+	for gender, count := range genders {
+		if count > genders[trend.Gender] || count == genders[trend.Gender] && gender < trend.Gender {
+			trend.Gender = gender
+		}
+	}
+
+	for tag := range tags {
+		trend.TopTags = append(trend.TopTags, tag)
+	}
+	sort.Slice(trend.TopTags, func(i, j int) bool {
+		a, b := trend.TopTags[i], trend.TopTags[j]
+		if tags[a] != tags[b] {
+			return tags[a] > tags[b]
+		}
+		return a < b
+	})
+	if len(trend.TopTags) > topTagsLimit {
+		trend.TopTags = trend.TopTags[:topTagsLimit]
+	}
+	return trend
+}
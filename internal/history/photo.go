@@ -0,0 +1,45 @@
+package history
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxPhotoDescriptionLength максимальная длина описания фото в символах
+const maxPhotoDescriptionLength = 500
+
+// photoPrompt промпт для описания фото из дневника vision-моделью
+const photoPrompt = "Опиши фотографию из дневника отношений пары в 1-2 предложениях на русском: " +
+	"что или кто на ней, обстановка и настроение. Не придумывай деталей, которых не видно, и не гадай, кто эти люди."
+
+// DescribeDiaryPhoto описывает фото записи с помощью vision-модели, чтобы его содержание учитывалось в инсайтах.
+// describe получает промпт и отправляет его модели вместе с изображением. Возвращает сохраненное описание.
+func (m *Manager) DescribeDiaryPhoto(userID int64, id string, describe func(prompt string) (string, error)) (string, error) {
+	entry, err := m.GetDiaryEntry(userID, id)
+	if err != nil {
+		return "", err
+	}
+	if entry.Attachment == nil || entry.Attachment.Kind != AttachmentPhoto {
+		return "", fmt.Errorf("diary entry %s has no photo", id)
+	}
+	if entry.Attachment.Description != "" {
+		return entry.Attachment.Description, nil
+	}
+
+	prompt := photoPrompt
+	if entry.Entry != "" {
+		prompt += "\n\nПодпись автора: " + entry.Entry
+	}
+	response, err := describe(prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to describe diary photo: %w", err)
+	}
+	description := TruncateRunes(strings.Join(strings.Fields(m.cleanResponse(response)), " "), maxPhotoDescriptionLength)
+	if description == "" {
+		return "", nil
+	}
+	if err := m.store.SetDiaryAttachmentDescription(entry.UserID, id, description); err != nil {
+		return "", fmt.Errorf("failed to save photo description: %w", err)
+	}
+	return description, nil
+}
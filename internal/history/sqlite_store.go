@@ -0,0 +1,459 @@
+package history
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // pure Go драйвер, работает с CGO_ENABLED=0
+)
+
+// sqliteMigrations содержит миграции схемы, номер версии = индекс + 1
+var sqliteMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS chat_messages (
+		id        INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id   INTEGER NOT NULL,
+		username  TEXT    NOT NULL DEFAULT '',
+		message   TEXT    NOT NULL DEFAULT '',
+		response  TEXT    NOT NULL DEFAULT '',
+		model     TEXT    NOT NULL DEFAULT '',
+		timestamp INTEGER NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_chat_messages_user ON chat_messages (user_id, id);
+
+	CREATE TABLE IF NOT EXISTS diary_entries (
+		id        INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id   INTEGER NOT NULL,
+		username  TEXT    NOT NULL DEFAULT '',
+		entry     TEXT    NOT NULL DEFAULT '',
+		week      INTEGER NOT NULL DEFAULT 0,
+		type      TEXT    NOT NULL DEFAULT '',
+		gender    TEXT    NOT NULL DEFAULT '',
+		mood      TEXT    NOT NULL DEFAULT '',
+		tags      TEXT    NOT NULL DEFAULT '',
+		timestamp INTEGER NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_diary_entries_user_week ON diary_entries (user_id, week, gender, type);
+	CREATE INDEX IF NOT EXISTS idx_diary_entries_week_gender ON diary_entries (week, gender, type);
+	CREATE INDEX IF NOT EXISTS idx_diary_entries_type ON diary_entries (type);`,
+
+	`CREATE TABLE IF NOT EXISTS chat_summaries (
+		user_id          INTEGER PRIMARY KEY,
+		summary          TEXT    NOT NULL DEFAULT '',
+		summarized_until INTEGER NOT NULL DEFAULT 0,
+		messages_count   INTEGER NOT NULL DEFAULT 0,
+		updated_at       INTEGER NOT NULL
+	);`,
+
+	`ALTER TABLE diary_entries ADD COLUMN edits TEXT NOT NULL DEFAULT '';`,
+
+	`ALTER TABLE diary_entries ADD COLUMN attachment_kind TEXT NOT NULL DEFAULT '';
+	ALTER TABLE diary_entries ADD COLUMN attachment_file_id TEXT NOT NULL DEFAULT '';`,
+
+	`ALTER TABLE diary_entries ADD COLUMN attachment_description TEXT NOT NULL DEFAULT '';`,
+}
+
+// sqliteStore хранит историю в SQLite базе данных
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore открывает (или создает) базу SQLite и применяет миграции
+func NewSQLiteStore(path string) (Store, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
+		}
+	}
+
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	// SQLite допускает одного писателя, поэтому сериализуем доступ на уровне пула
+	db.SetMaxOpenConns(1)
+
+	store := &sqliteStore{db: db}
+	if err := store.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// migrate применяет недостающие миграции, версия хранится в PRAGMA user_version
+func (s *sqliteStore) migrate() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for i := version; i < len(sqliteMigrations); i++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(sqliteMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to update schema version: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// AppendChatMessage добавляет сообщение в историю чата
+func (s *sqliteStore) AppendChatMessage(msg ChatMessage, maxHistory int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT INTO chat_messages (user_id, username, message, response, model, timestamp) VALUES (?, ?, ?, ?, ?, ?)`,
+		msg.UserID, msg.Username, msg.Message, msg.Response, msg.Model, msg.Timestamp.UnixNano(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert chat message: %w", err)
+	}
+
+	if maxHistory > 0 {
+		_, err = tx.Exec(
+			`DELETE FROM chat_messages WHERE user_id = ? AND id NOT IN (
+				SELECT id FROM chat_messages WHERE user_id = ? ORDER BY id DESC LIMIT ?
+			)`,
+			msg.UserID, msg.UserID, maxHistory,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to trim chat history: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ChatHistory возвращает историю чата пользователя
+func (s *sqliteStore) ChatHistory(userID int64, limit int) ([]ChatMessage, error) {
+	query := `SELECT user_id, username, message, response, model, timestamp FROM chat_messages WHERE user_id = ? ORDER BY id DESC`
+	args := []interface{}{userID}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chat history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []ChatMessage
+	for rows.Next() {
+		var msg ChatMessage
+		var ts int64
+		if err := rows.Scan(&msg.UserID, &msg.Username, &msg.Message, &msg.Response, &msg.Model, &ts); err != nil {
+			return nil, fmt.Errorf("failed to scan chat message: %w", err)
+		}
+		msg.Timestamp = time.Unix(0, ts)
+		history = append(history, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read chat history: %w", err)
+	}
+
+	// Выборка идет от новых к старым, возвращаем в хронологическом порядке
+	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+		history[i], history[j] = history[j], history[i]
+	}
+	return history, nil
+}
+
+// ClearChatHistory удаляет историю чата пользователя
+func (s *sqliteStore) ClearChatHistory(userID int64) error {
+	if _, err := s.db.Exec(`DELETE FROM chat_messages WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to clear chat history: %w", err)
+	}
+	return nil
+}
+
+// ChatSummary возвращает сжатое содержание переписки пользователя
+func (s *sqliteStore) ChatSummary(userID int64) (*ChatSummary, error) {
+	summary := ChatSummary{UserID: userID}
+	var until, updated int64
+	err := s.db.QueryRow(
+		`SELECT summary, summarized_until, messages_count, updated_at FROM chat_summaries WHERE user_id = ?`, userID,
+	).Scan(&summary.Summary, &until, &summary.MessagesCount, &updated)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chat summary: %w", err)
+	}
+	summary.SummarizedUntil = time.Unix(0, until)
+	summary.UpdatedAt = time.Unix(0, updated)
+	return &summary, nil
+}
+
+// SaveChatSummary сохраняет сжатое содержание переписки пользователя
+func (s *sqliteStore) SaveChatSummary(summary ChatSummary) error {
+	_, err := s.db.Exec(
+		`INSERT INTO chat_summaries (user_id, summary, summarized_until, messages_count, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET summary = excluded.summary, summarized_until = excluded.summarized_until,
+			messages_count = excluded.messages_count, updated_at = excluded.updated_at`,
+		summary.UserID, summary.Summary, summary.SummarizedUntil.UnixNano(), summary.MessagesCount, summary.UpdatedAt.UnixNano(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save chat summary: %w", err)
+	}
+	return nil
+}
+
+// ClearChatSummary удаляет сжатое содержание переписки пользователя
+func (s *sqliteStore) ClearChatSummary(userID int64) error {
+	if _, err := s.db.Exec(`DELETE FROM chat_summaries WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to clear chat summary: %w", err)
+	}
+	return nil
+}
+
+// AppendDiaryEntry добавляет запись в дневник
+func (s *sqliteStore) AppendDiaryEntry(entry DiaryEntry) (string, error) {
+	tags, err := encodeTags(entry.Tags)
+	if err != nil {
+		return "", err
+	}
+	edits := ""
+	if len(entry.Edits) > 0 {
+		data, err := json.Marshal(entry.Edits)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode diary edits: %w", err)
+		}
+		edits = string(data)
+	}
+	var attachment DiaryAttachment
+	if entry.Attachment != nil {
+		attachment = *entry.Attachment
+	}
+
+	result, err := s.db.Exec(
+		`INSERT INTO diary_entries (user_id, username, entry, week, type, gender, mood, tags, edits, attachment_kind, attachment_file_id, attachment_description, timestamp) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.UserID, entry.Username, entry.Entry, entry.Week, entry.Type, entry.Gender, entry.Mood, tags, edits, attachment.Kind, attachment.FileID, attachment.Description, entry.Timestamp.UnixNano(),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to insert diary entry: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return "", fmt.Errorf("failed to read diary entry id: %w", err)
+	}
+	return strconv.FormatInt(id, 10), nil
+}
+
+// DiaryEntries возвращает записи дневника по фильтру
+func (s *sqliteStore) DiaryEntries(query DiaryQuery) ([]DiaryEntry, error) {
+	var conditions []string
+	var args []interface{}
+	if query.ID != "" {
+		id, err := strconv.ParseInt(query.ID, 10, 64)
+		if err != nil {
+			return nil, nil // идентификаторы записей в SQLite - числа
+		}
+		conditions = append(conditions, "id = ?")
+		args = append(args, id)
+	}
+	if query.UserID != 0 {
+		conditions = append(conditions, "user_id = ?")
+		args = append(args, query.UserID)
+	}
+	if query.Week != 0 {
+		conditions = append(conditions, "week = ?")
+		args = append(args, query.Week)
+	}
+	if query.Gender != "" {
+		conditions = append(conditions, "gender = ?")
+		args = append(args, query.Gender)
+	}
+	if query.Type != "" {
+		conditions = append(conditions, "type = ?")
+		args = append(args, query.Type)
+	}
+
+	sqlQuery := `SELECT id, user_id, username, entry, week, type, gender, mood, tags, edits, attachment_kind, attachment_file_id, attachment_description, timestamp FROM diary_entries`
+	if len(conditions) > 0 {
+		sqlQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	sqlQuery += " ORDER BY timestamp DESC, id DESC"
+	if query.Limit > 0 {
+		sqlQuery += " LIMIT ?"
+		args = append(args, query.Limit)
+	}
+
+	rows, err := s.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query diary entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []DiaryEntry
+	for rows.Next() {
+		var entry DiaryEntry
+		var id int64
+		var tags, edits string
+		var attachment DiaryAttachment
+		var ts int64
+		if err := rows.Scan(&id, &entry.UserID, &entry.Username, &entry.Entry, &entry.Week, &entry.Type, &entry.Gender, &entry.Mood, &tags, &edits,
+			&attachment.Kind, &attachment.FileID, &attachment.Description, &ts); err != nil {
+			return nil, fmt.Errorf("failed to scan diary entry: %w", err)
+		}
+		entry.ID = strconv.FormatInt(id, 10)
+		entry.Timestamp = time.Unix(0, ts)
+		if tags != "" {
+			if err := json.Unmarshal([]byte(tags), &entry.Tags); err != nil {
+				return nil, fmt.Errorf("failed to decode diary tags: %w", err)
+			}
+		}
+		if edits != "" {
+			if err := json.Unmarshal([]byte(edits), &entry.Edits); err != nil {
+				return nil, fmt.Errorf("failed to decode diary edits: %w", err)
+			}
+		}
+		if attachment.Kind != "" {
+			entry.Attachment = &attachment
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read diary entries: %w", err)
+	}
+
+	return sortDiaryEntries(entries, 0), nil
+}
+
+// UpdateDiaryEntry заменяет текст записи, сохраняя прежний в истории правок
+func (s *sqliteStore) UpdateDiaryEntry(userID int64, id, text string, editedAt time.Time) error {
+	rowID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return ErrDiaryEntryNotFound
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var current, encoded string
+	err = tx.QueryRow(`SELECT entry, edits FROM diary_entries WHERE id = ? AND user_id = ?`, rowID, userID).Scan(&current, &encoded)
+	if err == sql.ErrNoRows {
+		return ErrDiaryEntryNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query diary entry: %w", err)
+	}
+
+	var edits []DiaryEdit
+	if encoded != "" {
+		if err := json.Unmarshal([]byte(encoded), &edits); err != nil {
+			return fmt.Errorf("failed to decode diary edits: %w", err)
+		}
+	}
+	edits = append(edits, DiaryEdit{Entry: current, EditedAt: editedAt})
+	data, err := json.Marshal(edits)
+	if err != nil {
+		return fmt.Errorf("failed to encode diary edits: %w", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE diary_entries SET entry = ?, edits = ? WHERE id = ?`, text, string(data), rowID); err != nil {
+		return fmt.Errorf("failed to update diary entry: %w", err)
+	}
+	return tx.Commit()
+}
+
+// SetDiaryMood сохраняет оценку настроения записи
+func (s *sqliteStore) SetDiaryMood(userID int64, id, mood string) error {
+	return s.updateDiaryColumn(userID, id, "mood", mood)
+}
+
+// SetDiaryTags заменяет теги записи
+func (s *sqliteStore) SetDiaryTags(userID int64, id string, tags []string) error {
+	encoded, err := encodeTags(tags)
+	if err != nil {
+		return err
+	}
+	return s.updateDiaryColumn(userID, id, "tags", encoded)
+}
+
+// updateDiaryColumn обновляет текстовую колонку записи пользователя
+func (s *sqliteStore) updateDiaryColumn(userID int64, id, column, value string) error {
+	rowID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return ErrDiaryEntryNotFound
+	}
+	result, err := s.db.Exec(`UPDATE diary_entries SET `+column+` = ? WHERE id = ? AND user_id = ?`, value, rowID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update diary %s: %w", column, err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrDiaryEntryNotFound
+	}
+	return nil
+}
+
+// SetDiaryAttachmentDescription сохраняет описание вложения записи
+func (s *sqliteStore) SetDiaryAttachmentDescription(userID int64, id, description string) error {
+	return s.updateDiaryColumn(userID, id, "attachment_description", description)
+}
+
+// DeleteDiaryEntry удаляет одну запись дневника
+func (s *sqliteStore) DeleteDiaryEntry(userID int64, id string) error {
+	rowID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return ErrDiaryEntryNotFound
+	}
+	result, err := s.db.Exec(`DELETE FROM diary_entries WHERE id = ? AND user_id = ?`, rowID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete diary entry: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrDiaryEntryNotFound
+	}
+	return nil
+}
+
+// ClearDiary удаляет все записи дневника пользователя
+func (s *sqliteStore) ClearDiary(userID int64) error {
+	if _, err := s.db.Exec(`DELETE FROM diary_entries WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to clear diary: %w", err)
+	}
+	return nil
+}
+
+// Close закрывает соединение с базой
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// encodeTags сериализует теги в JSON для хранения в текстовой колонке
+func encodeTags(tags []string) (string, error) {
+	if len(tags) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode diary tags: %w", err)
+	}
+	return string(data), nil
+}
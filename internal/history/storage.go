@@ -9,8 +9,8 @@ import (
 	"strings"
 )
 
-// saveToFile сохраняет данные в JSON файл
-func (m *Manager) saveToFile(filename string, data interface{}) error {
+// saveToFile атомарно сохраняет данные в JSON файл (запись во временный файл и переименование)
+func saveToFile(filename string, data interface{}) error {
 	// Создаем директорию если не существует
 	dir := filepath.Dir(filename)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -23,16 +23,21 @@ func (m *Manager) saveToFile(filename string, data interface{}) error {
 		return fmt.Errorf("failed to marshal data: %w", err)
 	}
 
-	// Записываем в файл
-	if err := os.WriteFile(filename, jsonData, 0644); err != nil {
-		return fmt.Errorf("failed to write file %s: %w", filename, err)
+	// Записываем во временный файл и переименовываем, чтобы не оставить файл недописанным
+	tmpFile := filename + ".tmp"
+	if err := os.WriteFile(tmpFile, jsonData, 0644); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", tmpFile, err)
+	}
+	if err := os.Rename(tmpFile, filename); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("failed to replace file %s: %w", filename, err)
 	}
 
 	return nil
 }
 
 // loadFromFile загружает данные из JSON файла
-func (m *Manager) loadFromFile(filename string, data interface{}) error {
+func loadFromFile(filename string, data interface{}) error {
 	// Проверяем существование файла
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
 		return nil // Файл не существует, это нормально
@@ -52,49 +57,12 @@ func (m *Manager) loadFromFile(filename string, data interface{}) error {
 	return nil
 }
 
-// appendToFile добавляет данные в JSON файл (для массивов)
-func (m *Manager) appendToFile(filename string, newItem interface{}) error {
-	// Загружаем существующие данные
-	var existingData []interface{}
-	if err := m.loadFromFile(filename, &existingData); err != nil {
+// removeFile удаляет файл
+func removeFile(filename string) error {
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
 		return err
 	}
-
-	// Добавляем новый элемент
-	existingData = append(existingData, newItem)
-
-	// Сохраняем обновленные данные
-	return m.saveToFile(filename, existingData)
-}
-
-// getUserChatFile возвращает путь к файлу чата пользователя
-func (m *Manager) getUserChatFile(userID int64) string {
-	userDir := filepath.Join(m.historyDir, fmt.Sprintf("user_%d", userID))
-	return filepath.Join(userDir, "chat.json")
-}
-
-// getUserDiaryFile возвращает путь к файлу дневника пользователя
-func (m *Manager) getUserDiaryFile(userID int64) string {
-	return filepath.Join(m.diaryDir, fmt.Sprintf("diary_%d.json", userID))
-}
-
-// getDiaryTypeFile возвращает путь к файлу дневника по типу
-func (m *Manager) getDiaryTypeFile(userID int64, entryType string) string {
-	typeDir := filepath.Join(m.diaryDir, fmt.Sprintf("diary_%s", entryType))
-	return filepath.Join(typeDir, fmt.Sprintf("user_%d.json", userID))
-}
-
-// getDiaryGenderFile возвращает путь к файлу дневника с учетом гендера (старый формат)
-func (m *Manager) getDiaryGenderFile(userID int64, entryType, gender string) string {
-	typeDir := filepath.Join(m.diaryDir, fmt.Sprintf("diary_%s_%s", entryType, gender))
-	return filepath.Join(typeDir, fmt.Sprintf("user_%d.json", userID))
-}
-
-// getDiaryStructuredFile возвращает путь к файлу дневника в структурированном формате: gender/week/type/
-func (m *Manager) getDiaryStructuredFile(userID int64, gender string, week int, entryType string) string {
-	// Создаем структуру: gender/week/type/user_id.json
-	structuredDir := filepath.Join(m.diaryDir, gender, fmt.Sprintf("week_%d", week), entryType)
-	return filepath.Join(structuredDir, fmt.Sprintf("user_%d.json", userID))
+	return nil // Файл не существует, это нормально
 }
 
 // cleanResponse очищает ответ от блоков размышлений и лишнего текста
@@ -112,17 +80,3 @@ func (m *Manager) cleanResponse(response string) string {
 	
 	return cleaned
 }
-
-// fileExists проверяет существование файла
-func (m *Manager) fileExists(filename string) bool {
-	_, err := os.Stat(filename)
-	return !os.IsNotExist(err)
-}
-
-// removeFile удаляет файл
-func (m *Manager) removeFile(filename string) error {
-	if !m.fileExists(filename) {
-		return nil // Файл не существует, это нормально
-	}
-	return os.Remove(filename)
-}
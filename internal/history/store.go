@@ -0,0 +1,111 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/config"
+)
+
+// Store абстрагирует хранилище истории чатов и записей дневника.
+// Реализации обязаны быть безопасными для конкурентного использования.
+type Store interface {
+	// AppendChatMessage добавляет сообщение в историю чата, оставляя не более maxHistory последних
+	AppendChatMessage(msg ChatMessage, maxHistory int) error
+	// ChatHistory возвращает последние limit сообщений пользователя (0 - все) в хронологическом порядке
+	ChatHistory(userID int64, limit int) ([]ChatMessage, error)
+	// ClearChatHistory удаляет историю чата пользователя
+	ClearChatHistory(userID int64) error
+
+	// ChatSummary возвращает сжатое содержание старой переписки (nil, если его нет)
+	ChatSummary(userID int64) (*ChatSummary, error)
+	// SaveChatSummary сохраняет сжатое содержание переписки пользователя
+	SaveChatSummary(summary ChatSummary) error
+	// ClearChatSummary удаляет сжатое содержание переписки пользователя
+	ClearChatSummary(userID int64) error
+
+	// AppendDiaryEntry добавляет запись в дневник и возвращает ее идентификатор
+	AppendDiaryEntry(entry DiaryEntry) (string, error)
+	// DiaryEntries возвращает записи дневника, подходящие под фильтр, в хронологическом порядке
+	DiaryEntries(query DiaryQuery) ([]DiaryEntry, error)
+	// UpdateDiaryEntry заменяет текст записи пользователя, прежний текст попадает в историю правок
+	UpdateDiaryEntry(userID int64, id, text string, editedAt time.Time) error
+	// SetDiaryMood сохраняет оценку настроения записи пользователя
+	SetDiaryMood(userID int64, id, mood string) error
+	// SetDiaryTags заменяет теги записи пользователя
+	SetDiaryTags(userID int64, id string, tags []string) error
+	// SetDiaryAttachmentDescription сохраняет описание вложения записи пользователя
+	SetDiaryAttachmentDescription(userID int64, id, description string) error
+	// DeleteDiaryEntry удаляет одну запись дневника пользователя
+	DeleteDiaryEntry(userID int64, id string) error
+	// ClearDiary удаляет все записи дневника пользователя
+	ClearDiary(userID int64) error
+
+	// Close освобождает ресурсы хранилища
+	Close() error
+}
+
+// ErrDiaryEntryNotFound запись дневника с указанным идентификатором не найдена у пользователя
+var ErrDiaryEntryNotFound = errors.New("diary entry not found")
+
+// DiaryQuery описывает фильтр выборки записей дневника.
+// Нулевые значения полей означают "без ограничения".
+type DiaryQuery struct {
+	ID     string
+	UserID int64
+	Week   int
+	Gender string
+	Type   string
+	Limit  int // последние N записей
+}
+
+// matches проверяет, подходит ли запись под фильтр
+func (q DiaryQuery) matches(entry DiaryEntry) bool {
+	if q.ID != "" && entry.ID != q.ID {
+		return false
+	}
+	if q.UserID != 0 && entry.UserID != q.UserID {
+		return false
+	}
+	if q.Week != 0 && entry.Week != q.Week {
+		return false
+	}
+	if q.Gender != "" && entry.Gender != q.Gender {
+		return false
+	}
+	if q.Type != "" && entry.Type != q.Type {
+		return false
+	}
+	return true
+}
+
+// Драйверы хранилища
+const (
+	DriverJSON   = "json"
+	DriverSQLite = "sqlite"
+)
+
+// NewStore создает хранилище согласно конфигурации базы данных
+func NewStore(cfg config.DatabaseConfig) (Store, error) {
+	switch cfg.Driver {
+	case "", DriverJSON:
+		return NewJSONStore(cfg.ChatsDir, cfg.DiariesDir)
+	case DriverSQLite:
+		return NewSQLiteStore(cfg.SQLitePath)
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %s", cfg.Driver)
+	}
+}
+
+// sortDiaryEntries сортирует записи по времени и применяет лимит
+func sortDiaryEntries(entries []DiaryEntry, limit int) []DiaryEntry {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries
+}
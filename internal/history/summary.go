@@ -0,0 +1,235 @@
+package history
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/tokens"
+)
+
+// SummaryOptions параметры сжатия старой части переписки
+type SummaryOptions struct {
+	TokenThreshold int // токенов несжатой истории, после которых старые обмены сжимаются (0 - сжатие отключено)
+	KeepTurns      int // последние обмены, которые всегда передаются модели дословно
+}
+
+// DefaultSummaryOptions возвращает параметры сжатия по умолчанию
+func DefaultSummaryOptions() SummaryOptions {
+	return SummaryOptions{
+		TokenThreshold: 2000,
+		KeepTurns:      6,
+	}
+}
+
+// Summarizer сжимает переписку с помощью модели и возвращает текст содержания
+type Summarizer func(messages []OpenAIMessage) (string, error)
+
+const (
+	// legacyContextTurns количество обменов в контексте, когда сжатие отключено
+	legacyContextTurns = 10
+	// summaryScanLimit сколько последних обменов просматривается при сборке контекста
+	summaryScanLimit = 200
+	// summaryMaxInputTokens ограничение объема переписки, отправляемой на сжатие за раз
+	summaryMaxInputTokens = 8000
+)
+
+// summaryInstruction системный промпт для сжатия переписки
+const summaryInstruction = "Ты ведешь память ассистента, который помогает парам в отношениях. " +
+	"Составь краткое содержание переписки на русском языке (не более 200 слов): " +
+	"важные факты о пользователе и паре, обсуждавшиеся темы и трудности, " +
+	"данные советы и договоренности, эмоциональное состояние. " +
+	"Пиши от третьего лица, без вступлений и оценок, ничего не выдумывай."
+
+// SetSummaryOptions задает параметры сжатия переписки
+func (m *Manager) SetSummaryOptions(opts SummaryOptions) {
+	if opts.KeepTurns < 0 {
+		opts.KeepTurns = 0
+	}
+	m.summary = opts
+}
+
+// GetChatContext собирает контекст для модели: системный промпт, сжатое содержание
+// старой переписки и последние обмены, не вошедшие в содержание
+func (m *Manager) GetChatContext(userID int64, systemPrompt string) ([]OpenAIMessage, error) {
+	if m.summary.TokenThreshold <= 0 {
+		return m.GetOpenAIHistory(userID, systemPrompt, legacyContextTurns)
+	}
+
+	summary, recent, err := m.unsummarized(userID)
+	if err != nil {
+		return nil, err
+	}
+	recent = m.fitContext(recent)
+
+	var messages []OpenAIMessage
+	if systemPrompt != "" {
+		messages = append(messages, OpenAIMessage{Role: "system", Content: systemPrompt})
+	}
+	if summary != nil {
+		messages = append(messages, OpenAIMessage{
+			Role:    "system",
+			Content: "Краткое содержание предыдущих разговоров с пользователем:\n" + summary.Summary,
+		})
+	}
+	for _, msg := range recent {
+		messages = append(messages,
+			OpenAIMessage{Role: "user", Content: msg.Message},
+			OpenAIMessage{Role: "assistant", Content: msg.Response},
+		)
+	}
+	return messages, nil
+}
+
+// SummarizeIfNeeded сжимает старые обмены, если несжатая история превысила порог.
+// Возвращает true, если содержание было обновлено.
+func (m *Manager) SummarizeIfNeeded(userID int64, summarize Summarizer) (bool, error) {
+	if m.summary.TokenThreshold <= 0 || summarize == nil {
+		return false, nil
+	}
+
+	// Не запускаем параллельное сжатие одной переписки
+	if _, running := m.summarizing.LoadOrStore(userID, struct{}{}); running {
+		return false, nil
+	}
+	defer m.summarizing.Delete(userID)
+
+	summary, pending, err := m.unsummarized(userID)
+	if err != nil {
+		return false, err
+	}
+	if len(pending) <= m.summary.KeepTurns || countTokens(pending) <= m.summary.TokenThreshold {
+		return false, nil
+	}
+
+	// Длинную переписку сжимаем в несколько проходов, от старых обменов к новым:
+	// содержание продвигается только до последнего обмена, вошедшего в запрос
+	fold := pending[:len(pending)-m.summary.KeepTurns]
+	updated := false
+	for len(fold) > 0 {
+		chunk := fold[:summaryChunk(fold)]
+		text, err := summarize(buildSummaryPrompt(summary, chunk))
+		if err != nil {
+			return updated, fmt.Errorf("failed to summarize chat: %w", err)
+		}
+		text = strings.TrimSpace(m.cleanResponse(text))
+		if text == "" {
+			return updated, fmt.Errorf("failed to summarize chat: empty summary")
+		}
+
+		next := ChatSummary{
+			UserID:          userID,
+			Summary:         text,
+			SummarizedUntil: chunk[len(chunk)-1].Timestamp,
+			MessagesCount:   len(chunk),
+			UpdatedAt:       time.Now(),
+		}
+		if summary != nil {
+			next.MessagesCount += summary.MessagesCount
+		}
+		if err := m.store.SaveChatSummary(next); err != nil {
+			return updated, fmt.Errorf("failed to save chat summary: %w", err)
+		}
+		summary = &next
+		updated = true
+		fold = fold[len(chunk):]
+	}
+	return true, nil
+}
+
+// GetChatSummary возвращает сжатое содержание переписки пользователя (nil, если его нет)
+func (m *Manager) GetChatSummary(userID int64) (*ChatSummary, error) {
+	return m.store.ChatSummary(userID)
+}
+
+// ResetChatSummary удаляет сжатое содержание, старые обмены снова попадают в контекст
+func (m *Manager) ResetChatSummary(userID int64) error {
+	return m.store.ClearChatSummary(userID)
+}
+
+// unsummarized возвращает содержание и обмены, которые в него еще не вошли
+func (m *Manager) unsummarized(userID int64) (*ChatSummary, []ChatMessage, error) {
+	summary, err := m.store.ChatSummary(userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load chat summary: %w", err)
+	}
+
+	history, err := m.GetUserHistory(userID, summaryScanLimit)
+	if err != nil {
+		return nil, nil, err
+	}
+	if summary == nil {
+		return nil, history, nil
+	}
+
+	start := len(history)
+	for start > 0 && history[start-1].Timestamp.After(summary.SummarizedUntil) {
+		start--
+	}
+	return summary, history[start:], nil
+}
+
+// fitContext оставляет последние обмены в пределах порога токенов, но не меньше KeepTurns
+func (m *Manager) fitContext(messages []ChatMessage) []ChatMessage {
+	tokens := 0
+	start := len(messages)
+	for start > 0 {
+		tokens += exchangeTokens(messages[start-1])
+		if len(messages)-start >= m.summary.KeepTurns && tokens > m.summary.TokenThreshold {
+			break
+		}
+		start--
+	}
+	return messages[start:]
+}
+
+// summaryChunk возвращает число старейших обменов, которые помещаются в один запрос на сжатие
+// (не меньше одного, чтобы слишком длинный обмен не остановил сжатие)
+func summaryChunk(fold []ChatMessage) int {
+	total := 0
+	for i, msg := range fold {
+		total += exchangeTokens(msg)
+		if i > 0 && total > summaryMaxInputTokens {
+			return i
+		}
+	}
+	return len(fold)
+}
+
+// buildSummaryPrompt формирует запрос на обновление содержания
+func buildSummaryPrompt(previous *ChatSummary, fold []ChatMessage) []OpenAIMessage {
+	var prompt strings.Builder
+	if previous != nil {
+		prompt.WriteString("Предыдущее содержание:\n")
+		prompt.WriteString(previous.Summary)
+		prompt.WriteString("\n\n")
+	}
+	prompt.WriteString("Новая часть переписки:\n")
+	for _, msg := range fold {
+		fmt.Fprintf(&prompt, "Пользователь: %s\nАссистент: %s\n\n", msg.Message, msg.Response)
+	}
+	if previous != nil {
+		prompt.WriteString("Обнови содержание, объединив предыдущее с новой частью переписки.")
+	} else {
+		prompt.WriteString("Составь содержание этой переписки.")
+	}
+
+	return []OpenAIMessage{
+		{Role: "system", Content: summaryInstruction},
+		{Role: "user", Content: prompt.String()},
+	}
+}
+
+// exchangeTokens оценивает размер одного обмена в токенах
+func exchangeTokens(msg ChatMessage) int {
+	return tokens.Estimate(msg.Message) + tokens.Estimate(msg.Response)
+}
+
+// countTokens оценивает размер переписки в токенах
+func countTokens(messages []ChatMessage) int {
+	total := 0
+	for _, msg := range messages {
+		total += exchangeTokens(msg)
+	}
+	return total
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/godofphonk/lovifyy-bot/internal/ai"
 	"github.com/godofphonk/lovifyy-bot/internal/history"
 	"github.com/godofphonk/lovifyy-bot/internal/models"
+	"github.com/godofphonk/lovifyy-bot/internal/services"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -35,22 +36,22 @@ type HistoryManager interface {
 
 // UserManager интерфейс для управления пользователями
 type UserManager interface {
-	SetState(userID int64, state string)
+	SetState(userID int64, state string) error
 	GetState(userID int64) string
-	SetStateData(userID int64, state, data string)
+	SetStateData(userID int64, state, data string) error
 	GetStateData(userID int64) (string, string)
 	IsAdmin(userID int64) bool
 	IsRateLimited(userID int64, limit time.Duration) bool
-	ClearState(userID int64)
+	ClearState(userID int64) error
 	GetAdminIDs() []int64
 }
 
 // NotificationService интерфейс для уведомлений
 type NotificationService interface {
 	GenerateNotification(notificationType models.NotificationType) (string, error)
-	SendNotificationToAll(message string) error
+	SendNotificationToAll(message string) (*services.BroadcastReport, error)
 	SendNotificationToUser(userID int64, message string) error
-	SendInstantNotification(notificationType models.NotificationType, recipients []int64) error
+	SendInstantNotification(notificationType models.NotificationType, recipients []int64) (*services.BroadcastReport, error)
 	GetTemplates() []models.NotificationTemplate
 	UpdateTemplate(notificationType models.NotificationType, prompt string, isActive bool) error
 	AddTemplate(template models.NotificationTemplate)
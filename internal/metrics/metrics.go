@@ -15,6 +15,7 @@ type Metrics struct {
 	CommandsTotal     *prometheus.CounterVec
 	ErrorsTotal       *prometheus.CounterVec
 	AIRequestsTotal   *prometheus.CounterVec
+	AITokensTotal     *prometheus.CounterVec
 	
 	// Гистограммы
 	ResponseDuration  *prometheus.HistogramVec
@@ -61,7 +62,15 @@ func NewMetrics() *Metrics {
 				Name: "lovifyy_ai_requests_total",
 				Help: "Total number of AI requests",
 			},
-			[]string{"model", "status"},
+			[]string{"model", "feature", "status"},
+		),
+		
+		AITokensTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "lovifyy_ai_tokens_total",
+				Help: "Total number of AI tokens consumed",
+			},
+			[]string{"model", "feature", "type"},
 		),
 		
 		ResponseDuration: prometheus.NewHistogramVec(
@@ -119,6 +128,7 @@ func NewMetrics() *Metrics {
 		m.CommandsTotal,
 		m.ErrorsTotal,
 		m.AIRequestsTotal,
+		m.AITokensTotal,
 		m.ResponseDuration,
 		m.AIResponseTime,
 		m.ActiveUsers,
@@ -155,11 +165,17 @@ func (m *Metrics) RecordError(errorType, component string) {
 }
 
 // RecordAIRequest записывает метрику AI запроса
-func (m *Metrics) RecordAIRequest(model, status string, duration time.Duration) {
-	m.AIRequestsTotal.WithLabelValues(model, status).Inc()
+func (m *Metrics) RecordAIRequest(model, feature, status string, duration time.Duration) {
+	m.AIRequestsTotal.WithLabelValues(model, feature, status).Inc()
 	m.AIResponseTime.WithLabelValues(model).Observe(duration.Seconds())
 }
 
+// RecordAITokens записывает расход токенов AI запроса
+func (m *Metrics) RecordAITokens(model, feature string, promptTokens, completionTokens int) {
+	m.AITokensTotal.WithLabelValues(model, feature, "prompt").Add(float64(promptTokens))
+	m.AITokensTotal.WithLabelValues(model, feature, "completion").Add(float64(completionTokens))
+}
+
 // RecordResponseDuration записывает время ответа
 func (m *Metrics) RecordResponseDuration(handler, method string, duration time.Duration) {
 	m.ResponseDuration.WithLabelValues(handler, method).Observe(duration.Seconds())
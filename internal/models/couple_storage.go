@@ -0,0 +1,364 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ошибки связывания пары
+var (
+	ErrAlreadyPaired  = errors.New("user is already paired")
+	ErrInviteNotFound = errors.New("invite not found")
+	ErrInviteExpired  = errors.New("invite expired")
+	ErrSelfInvite     = errors.New("cannot accept own invite")
+	ErrNotPaired      = errors.New("user is not paired")
+	ErrInvalidGender  = errors.New("gender must be male or female")
+)
+
+// InviteTTL время жизни кода приглашения
+const InviteTTL = 48 * time.Hour
+
+// inviteAlphabet символы кода приглашения (без похожих 0/O, 1/I)
+const inviteAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// Couple описывает пару из двух пользователей Telegram
+type Couple struct {
+	ID        string    `json:"id"`
+	MaleID    int64     `json:"male_id"`
+	FemaleID  int64     `json:"female_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Members возвращает ID обоих партнеров
+func (c *Couple) Members() []int64 {
+	return []int64{c.MaleID, c.FemaleID}
+}
+
+// GenderOf возвращает гендер участника пары ("" если пользователь не из этой пары)
+func (c *Couple) GenderOf(userID int64) string {
+	switch userID {
+	case c.MaleID:
+		return "male"
+	case c.FemaleID:
+		return "female"
+	}
+	return ""
+}
+
+// PartnerOf возвращает ID партнера
+func (c *Couple) PartnerOf(userID int64) int64 {
+	switch userID {
+	case c.MaleID:
+		return c.FemaleID
+	case c.FemaleID:
+		return c.MaleID
+	}
+	return 0
+}
+
+// PairInvite приглашение в пару
+type PairInvite struct {
+	Code      string    `json:"code"`
+	InviterID int64     `json:"inviter_id"`
+	Gender    string    `json:"gender"` // гендер пригласившего
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// couplesFile формат файла couples.json
+type couplesFile struct {
+	Couples []*Couple              `json:"couples"`
+	Invites map[string]*PairInvite `json:"invites"`
+}
+
+// CoupleStorage управляет хранением пар в JSON файле (рядом с users.json).
+// Файл читается один раз, дальше пары отдаются из памяти: они нужны при каждом сообщении.
+type CoupleStorage struct {
+	filePath string
+	mutex    sync.RWMutex
+	data     *couplesFile // nil - файл еще не загружен
+}
+
+// NewCoupleStorage создает новое хранилище пар
+func NewCoupleStorage(dataDir string) *CoupleStorage {
+	os.MkdirAll(dataDir, 0755)
+	return &CoupleStorage{
+		filePath: filepath.Join(dataDir, "couples.json"),
+	}
+}
+
+// CreateInvite создает код приглашения; прежнее приглашение пользователя заменяется
+func (cs *CoupleStorage) CreateInvite(userID int64, gender string) (*PairInvite, error) {
+	if gender != "male" && gender != "female" {
+		return nil, ErrInvalidGender
+	}
+
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	data, err := cs.current()
+	if err != nil {
+		return nil, err
+	}
+	data = data.clone()
+
+	if data.findCouple(userID) != nil {
+		return nil, ErrAlreadyPaired
+	}
+
+	now := time.Now()
+	for code, invite := range data.Invites {
+		if invite.InviterID == userID || now.After(invite.ExpiresAt) {
+			delete(data.Invites, code)
+		}
+	}
+
+	code, err := generateInviteCode()
+	if err != nil {
+		return nil, err
+	}
+
+	invite := &PairInvite{
+		Code:      code,
+		InviterID: userID,
+		Gender:    gender,
+		CreatedAt: now,
+		ExpiresAt: now.Add(InviteTTL),
+	}
+	data.Invites[code] = invite
+
+	if err := cs.save(data); err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+// AcceptInvite принимает приглашение и создает пару; принявший получает противоположный гендер
+func (cs *CoupleStorage) AcceptInvite(code string, userID int64) (*Couple, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	data, err := cs.current()
+	if err != nil {
+		return nil, err
+	}
+	data = data.clone()
+
+	invite, exists := data.Invites[code]
+	if !exists {
+		return nil, ErrInviteNotFound
+	}
+	if time.Now().After(invite.ExpiresAt) {
+		delete(data.Invites, code)
+		cs.save(data)
+		return nil, ErrInviteExpired
+	}
+	if invite.InviterID == userID {
+		return nil, ErrSelfInvite
+	}
+	if data.findCouple(userID) != nil || data.findCouple(invite.InviterID) != nil {
+		return nil, ErrAlreadyPaired
+	}
+
+	couple := &Couple{
+		ID:        code,
+		CreatedAt: time.Now(),
+	}
+	if invite.Gender == "male" {
+		couple.MaleID, couple.FemaleID = invite.InviterID, userID
+	} else {
+		couple.MaleID, couple.FemaleID = userID, invite.InviterID
+	}
+
+	data.Couples = append(data.Couples, couple)
+	delete(data.Invites, code)
+
+	if err := cs.save(data); err != nil {
+		return nil, err
+	}
+	return couple, nil
+}
+
+// GetCouple возвращает копию пары пользователя (nil если пользователь не в паре)
+func (cs *CoupleStorage) GetCouple(userID int64) *Couple {
+	var found *Couple
+	cs.view(func(data *couplesFile) {
+		if couple := data.findCouple(userID); couple != nil {
+			copied := *couple
+			found = &copied
+		}
+	})
+	return found
+}
+
+// GenderOf возвращает гендер пользователя в паре ("" если пользователь не в паре)
+func (cs *CoupleStorage) GenderOf(userID int64) string {
+	if couple := cs.GetCouple(userID); couple != nil {
+		return couple.GenderOf(userID)
+	}
+	return ""
+}
+
+// PartnerOf возвращает ID партнера (0 если пользователь не в паре)
+func (cs *CoupleStorage) PartnerOf(userID int64) int64 {
+	if couple := cs.GetCouple(userID); couple != nil {
+		return couple.PartnerOf(userID)
+	}
+	return 0
+}
+
+// Members возвращает ID всех участников пары пользователя (только сам пользователь, если пары нет)
+func (cs *CoupleStorage) Members(userID int64) []int64 {
+	if couple := cs.GetCouple(userID); couple != nil {
+		return couple.Members()
+	}
+	return []int64{userID}
+}
+
+// Unpair разрывает пару пользователя и возвращает удаленную пару
+func (cs *CoupleStorage) Unpair(userID int64) (*Couple, error) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	data, err := cs.current()
+	if err != nil {
+		return nil, err
+	}
+	data = data.clone()
+
+	for i, couple := range data.Couples {
+		if couple.MaleID == userID || couple.FemaleID == userID {
+			data.Couples = append(data.Couples[:i], data.Couples[i+1:]...)
+			if err := cs.save(data); err != nil {
+				return nil, err
+			}
+			return couple, nil
+		}
+	}
+	return nil, ErrNotPaired
+}
+
+// GetAllCouples возвращает все пары
+func (cs *CoupleStorage) GetAllCouples() ([]Couple, error) {
+	var couples []Couple
+	err := cs.view(func(data *couplesFile) {
+		couples = make([]Couple, 0, len(data.Couples))
+		for _, couple := range data.Couples {
+			couples = append(couples, *couple)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return couples, nil
+}
+
+// findCouple ищет пару пользователя
+func (f *couplesFile) findCouple(userID int64) *Couple {
+	for _, couple := range f.Couples {
+		if couple.MaleID == userID || couple.FemaleID == userID {
+			return couple
+		}
+	}
+	return nil
+}
+
+// clone копирует списки пар и приглашений: изменения видны остальным только после сохранения
+func (f *couplesFile) clone() *couplesFile {
+	copied := &couplesFile{
+		Couples: append([]*Couple(nil), f.Couples...),
+		Invites: make(map[string]*PairInvite, len(f.Invites)),
+	}
+	for code, invite := range f.Invites {
+		copied.Invites[code] = invite
+	}
+	return copied
+}
+
+// view вызывает fn с парами из памяти, при первом обращении загружая их из файла
+func (cs *CoupleStorage) view(fn func(data *couplesFile)) error {
+	cs.mutex.RLock()
+	if cs.data != nil {
+		defer cs.mutex.RUnlock()
+		fn(cs.data)
+		return nil
+	}
+	cs.mutex.RUnlock()
+
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	data, err := cs.current()
+	if err != nil {
+		return err
+	}
+	fn(data)
+	return nil
+}
+
+// current возвращает пары из памяти, при первом обращении загружая их из файла.
+// Вызывается под блокировкой на запись; возвращенные данные нельзя менять без clone.
+func (cs *CoupleStorage) current() (*couplesFile, error) {
+	if cs.data == nil {
+		data, err := cs.load()
+		if err != nil {
+			return nil, err
+		}
+		cs.data = data
+	}
+	return cs.data, nil
+}
+
+// load загружает пары и приглашения из JSON файла
+func (cs *CoupleStorage) load() (*couplesFile, error) {
+	data := &couplesFile{Invites: make(map[string]*PairInvite)}
+
+	raw, err := os.ReadFile(cs.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return data, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(raw, data); err != nil {
+		return nil, err
+	}
+	if data.Invites == nil {
+		data.Invites = make(map[string]*PairInvite)
+	}
+	return data, nil
+}
+
+// save атомарно сохраняет пары и приглашения в JSON файл и, при успехе, в память
+func (cs *CoupleStorage) save(data *couplesFile) error {
+	raw, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(cs.filePath, raw); err != nil {
+		return err
+	}
+	cs.data = data
+	return nil
+}
+
+// generateInviteCode генерирует случайный код приглашения
+func generateInviteCode() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate invite code: %w", err)
+	}
+	for i, b := range buf {
+		buf[i] = inviteAlphabet[int(b)%len(inviteAlphabet)]
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic записывает файл через временный файл и переименование,
+// чтобы перезапуск посреди записи не оставил файл обрезанным
+func WriteFileAtomic(filename string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmpFile := filename + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", tmpFile, err)
+	}
+	if err := os.Rename(tmpFile, filename); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("failed to replace file %s: %w", filename, err)
+	}
+	return nil
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // NotificationType представляет тип уведомления
 type NotificationType string
@@ -23,6 +26,7 @@ type Notification struct {
 	SentAt      *time.Time       `json:"sent_at,omitempty"`
 	IsActive    bool             `json:"is_active"`
 	Recipients  []int64          `json:"recipients,omitempty"` // Если пусто - всем пользователям
+	RichContent
 }
 
 // NotificationTemplate представляет шаблон для динамической генерации
@@ -33,26 +37,129 @@ type NotificationTemplate struct {
 	IsActive    bool             `json:"is_active"`
 	CreatedAt   time.Time        `json:"created_at"`
 	UpdatedAt   time.Time        `json:"updated_at"`
+	Variant     string           `json:"variant,omitempty"` // Вариант A/B теста (пусто - A)
+	RichContent                  // Медиа и кнопки, которые прикладываются к сгенерированному тексту
+}
+
+// DefaultVariant вариант шаблона, созданного до появления A/B тестов
+const DefaultVariant = "A"
+
+// VariantID возвращает вариант шаблона
+func (t NotificationTemplate) VariantID() string {
+	if t.Variant == "" {
+		return DefaultVariant
+	}
+	return t.Variant
+}
+
+// Виды медиа вложений уведомлений
+const (
+	MediaPhoto    = "photo"
+	MediaDocument = "document"
+)
+
+// NotificationMedia медиа вложение уведомления: file_id уже загруженного в Telegram файла
+type NotificationMedia struct {
+	Kind   string `json:"kind"` // MediaPhoto или MediaDocument
+	FileID string `json:"file_id"`
+}
+
+// NotificationButton кнопка под уведомлением: Data - callback (в том числе deep-link), URL - ссылка
+type NotificationButton struct {
+	Text string `json:"text"`
+	Data string `json:"data,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+// Deep-link callbacks кнопок уведомлений. Без номера недели открывается текущая неделя
+// получателя, с суффиксом _<неделя> - указанная.
+const (
+	DeepLinkDiary    = "open_diary"
+	DeepLinkExercise = "open_exercise"
+)
+
+// Готовые кнопки уведомлений
+var (
+	DiaryButton    = NotificationButton{Text: "📝 Открыть дневник", Data: DeepLinkDiary}
+	ExerciseButton = NotificationButton{Text: "💪 Упражнение недели", Data: DeepLinkExercise}
+)
+
+// RichContent медиа и кнопки уведомления. Текст уведомления становится подписью к медиа.
+type RichContent struct {
+	Media   *NotificationMedia   `json:"media,omitempty"`
+	Buttons []NotificationButton `json:"buttons,omitempty"`
+}
+
+// IsEmpty проверяет, что у уведомления нет ни медиа, ни кнопок
+func (c RichContent) IsEmpty() bool {
+	return c.Media == nil && len(c.Buttons) == 0
+}
+
+// HasButton проверяет, есть ли кнопка с таким callback
+func (c RichContent) HasButton(data string) bool {
+	for _, button := range c.Buttons {
+		if button.Data == data {
+			return true
+		}
+	}
+	return false
+}
+
+// ToggleButton добавляет кнопку или убирает ее, если кнопка с таким callback уже есть
+func (c *RichContent) ToggleButton(button NotificationButton) {
+	for i := range c.Buttons {
+		if c.Buttons[i].Data == button.Data {
+			c.Buttons = append(c.Buttons[:i], c.Buttons[i+1:]...)
+			return
+		}
+	}
+	c.Buttons = append(c.Buttons, button)
+}
+
+// Key возвращает строку, по которой одинаковые вложения группируются в одну рассылку
+func (c RichContent) Key() string {
+	var b strings.Builder
+	if c.Media != nil {
+		b.WriteString(c.Media.Kind + ":" + c.Media.FileID)
+	}
+	for _, button := range c.Buttons {
+		b.WriteString("|" + button.Text + "=" + button.Data + button.URL)
+	}
+	return b.String()
+}
+
+// fallbackNotifications готовые тексты на случай недоступности AI
+var fallbackNotifications = map[NotificationType]string{
+	NotificationDiary:      "💌 Привет, дорогие! Найдите сегодня пару минут для мини-дневника: даже одно честное предложение о вашем дне помогает замечать, как растут ваши отношения. 💗",
+	NotificationExercise:   "💑 Напоминаем об упражнении недели! Выберите спокойный момент, отложите телефоны и уделите друг другу немного внимания — маленькие шаги делают отношения крепче. 🫶🏻",
+	NotificationMotivation: "🌷 Каждый день вы выбираете друг друга — и это уже большое дело. Скажите сегодня партнеру одну благодарность, просто так. 💖",
+}
+
+// FallbackNotificationText возвращает готовый текст уведомления для типа (пусто, если его нет)
+func FallbackNotificationText(notificationType NotificationType) string {
+	return fallbackNotifications[notificationType]
 }
 
 // GetDefaultTemplates возвращает стандартные шаблоны уведомлений
 func GetDefaultTemplates() []NotificationTemplate {
 	return []NotificationTemplate{
 		{
-			Type:      NotificationDiary,
-			Name:      "Напоминание о дневнике",
-			Prompt:    "Создай теплое и мотивирующее напоминание парам о ведении дневника отношений. Сообщение должно быть на русском языке, дружелюбным и вдохновляющим. Используй эмодзи. Длина 50-100 слов.",
-			IsActive:  true,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
+			Type:        NotificationDiary,
+			Name:        "Напоминание о дневнике",
+			Prompt:      "Создай теплое и мотивирующее напоминание парам о ведении дневника отношений. Сообщение должно быть на русском языке, дружелюбным и вдохновляющим. Используй эмодзи. Длина 50-100 слов.",
+			IsActive:    true,
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+			RichContent: RichContent{Buttons: []NotificationButton{DiaryButton}},
 		},
 		{
-			Type:      NotificationExercise,
-			Name:      "Напоминание об упражнениях",
-			Prompt:    "Создай мотивирующее сообщение парам о выполнении ПСИХОЛОГИЧЕСКИХ упражнений для укрепления отношений и эмоциональной близости. НЕ физические упражнения! Речь идет о психологических практиках, упражнениях на доверие, общение, взаимопонимание между партнерами. Сообщение должно быть на русском языке, позитивным и вдохновляющим. Используй эмодзи. Длина 50-100 слов.",
-			IsActive:  true,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
+			Type:        NotificationExercise,
+			Name:        "Напоминание об упражнениях",
+			Prompt:      "Создай мотивирующее сообщение парам о выполнении ПСИХОЛОГИЧЕСКИХ упражнений для укрепления отношений и эмоциональной близости. НЕ физические упражнения! Речь идет о психологических практиках, упражнениях на доверие, общение, взаимопонимание между партнерами. Сообщение должно быть на русском языке, позитивным и вдохновляющим. Используй эмодзи. Длина 50-100 слов.",
+			IsActive:    true,
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+			RichContent: RichContent{Buttons: []NotificationButton{ExerciseButton}},
 		},
 		{
 			Type:      NotificationMotivation,
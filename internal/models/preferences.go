@@ -0,0 +1,162 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// NotificationTypeOption тип уведомления, который пользователь может отключить
+type NotificationTypeOption struct {
+	Type  NotificationType
+	Title string
+}
+
+// NotificationTypeOptions типы уведомлений в порядке показа в /settings
+var NotificationTypeOptions = []NotificationTypeOption{
+	{Type: NotificationDiary, Title: "📝 Дневник"},
+	{Type: NotificationExercise, Title: "👩🏼‍❤️‍👨🏻 Упражнения"},
+	{Type: NotificationMotivation, Title: "💒 Мотивация"},
+	{Type: NotificationCustom, Title: "📢 Объявления"},
+}
+
+// NotificationPreferences пользовательские настройки уведомлений.
+// Время указывается по местному времени пользователя в формате "ЧЧ:ММ".
+type NotificationPreferences struct {
+	Disabled     []NotificationType `json:"disabled,omitempty"`      // отключенные типы уведомлений
+	QuietFrom    string             `json:"quiet_from,omitempty"`    // начало тихих часов
+	QuietTo      string             `json:"quiet_to,omitempty"`      // конец тихих часов
+	ReminderTime string             `json:"reminder_time,omitempty"` // напоминания приходят не раньше этого времени
+}
+
+// Allows проверяет, что пользователь получает уведомления типа typ
+func (p NotificationPreferences) Allows(typ NotificationType) bool {
+	for _, disabled := range p.Disabled {
+		if disabled == typ {
+			return false
+		}
+	}
+	return true
+}
+
+// Toggle включает или отключает уведомления типа typ
+func (p *NotificationPreferences) Toggle(typ NotificationType) {
+	if p.Allows(typ) {
+		p.Disabled = append(p.Disabled, typ)
+		return
+	}
+	var enabled []NotificationType
+	for _, disabled := range p.Disabled {
+		if disabled != typ {
+			enabled = append(enabled, disabled)
+		}
+	}
+	p.Disabled = enabled
+}
+
+// HasQuietHours проверяет, что тихие часы заданы
+func (p NotificationPreferences) HasQuietHours() bool {
+	return p.QuietFrom != "" && p.QuietTo != "" && p.QuietFrom != p.QuietTo
+}
+
+// QuietHours описывает тихие часы: "23:00–08:00"
+func (p NotificationPreferences) QuietHours() string {
+	if !p.HasQuietHours() {
+		return ""
+	}
+	return p.QuietFrom + "–" + p.QuietTo
+}
+
+// QuietEnd возвращает конец тихих часов, если local попадает в них.
+// Тихие часы могут переходить через полночь (23:00–08:00).
+func (p NotificationPreferences) QuietEnd(local time.Time) (time.Time, bool) {
+	if !p.HasQuietHours() {
+		return time.Time{}, false
+	}
+	from, errFrom := ParseClock(p.QuietFrom)
+	to, errTo := ParseClock(p.QuietTo)
+	if errFrom != nil || errTo != nil {
+		return time.Time{}, false
+	}
+
+	minute := local.Hour()*60 + local.Minute()
+	end := atClock(local, to)
+	switch {
+	case from < to && minute >= from && minute < to:
+		return end, true
+	case from > to && minute >= from:
+		return end.AddDate(0, 0, 1), true
+	case from > to && minute < to:
+		return end, true
+	}
+	return time.Time{}, false
+}
+
+// DeliverAt возвращает момент, когда пользователю можно доставить уведомление типа typ,
+// наступившее в now. false - пользователь отключил этот тип. Для напоминаний (reminder)
+// учитывается выбранное время: раньше него в тот же день напоминание не приходит.
+func (p NotificationPreferences) DeliverAt(typ NotificationType, now time.Time, loc *time.Location, reminder bool) (time.Time, bool) {
+	if !p.Allows(typ) {
+		return time.Time{}, false
+	}
+
+	at := now
+	local := now.In(loc)
+	if reminder && p.ReminderTime != "" {
+		if clock, err := ParseClock(p.ReminderTime); err == nil {
+			if target := atClock(local, clock); target.After(at) {
+				at = target
+			}
+		}
+	}
+	if end, quiet := p.QuietEnd(at.In(loc)); quiet {
+		at = end
+	}
+	return at, true
+}
+
+// ParseClock разбирает время "ЧЧ:ММ" и возвращает количество минут от полуночи
+func ParseClock(value string) (int, error) {
+	clock, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("используйте формат ЧЧ:ММ")
+	}
+	return clock.Hour()*60 + clock.Minute(), nil
+}
+
+// ParseQuietHours разбирает тихие часы "23:00-08:00" (допускается длинное тире)
+func ParseQuietHours(value string) (string, string, error) {
+	value = strings.ReplaceAll(strings.TrimSpace(value), "–", "-")
+	from, to, ok := strings.Cut(value, "-")
+	if !ok {
+		return "", "", fmt.Errorf("укажите тихие часы в формате ЧЧ:ММ-ЧЧ:ММ")
+	}
+	fromMinute, errFrom := ParseClock(from)
+	toMinute, errTo := ParseClock(to)
+	if errFrom != nil || errTo != nil {
+		return "", "", fmt.Errorf("укажите тихие часы в формате ЧЧ:ММ-ЧЧ:ММ")
+	}
+	if fromMinute == toMinute {
+		return "", "", fmt.Errorf("начало и конец тихих часов совпадают")
+	}
+	return formatClock(fromMinute), formatClock(toMinute), nil
+}
+
+// NormalizeClock проверяет время "ЧЧ:ММ" и приводит его к виду 09:00
+func NormalizeClock(value string) (string, error) {
+	minute, err := ParseClock(value)
+	if err != nil {
+		return "", err
+	}
+	return formatClock(minute), nil
+}
+
+// formatClock форматирует минуты от полуночи как "ЧЧ:ММ"
+func formatClock(minute int) string {
+	return fmt.Sprintf("%02d:%02d", minute/60, minute%60)
+}
+
+// atClock возвращает момент в тот же день, что и t, в указанное время суток
+func atClock(t time.Time, minute int) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), minute/60, minute%60, 0, 0, t.Location())
+}
@@ -0,0 +1,238 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Отбор по наличию пары
+const (
+	PairingPaired = "paired" // только пользователи в паре
+	PairingSingle = "single" // только пользователи без пары
+)
+
+// SegmentRules условия отбора получателей. Пустое условие не ограничивает выборку,
+// заданные условия должны выполняться одновременно.
+type SegmentRules struct {
+	Week         int        `json:"week,omitempty"`          // текущая неделя программы
+	InactiveDays int        `json:"inactive_days,omitempty"` // не заходили в бота N дней и больше
+	NoDiary      bool       `json:"no_diary,omitempty"`      // нет записей в дневнике за текущую неделю
+	Pairing      string     `json:"pairing,omitempty"`       // PairingPaired или PairingSingle
+	JoinedAfter  *time.Time `json:"joined_after,omitempty"`  // зарегистрировались начиная с этой даты
+}
+
+// Describe описывает условия сегмента для администратора
+func (r SegmentRules) Describe() string {
+	var parts []string
+	if r.Week > 0 {
+		parts = append(parts, fmt.Sprintf("%d-я неделя программы", r.Week))
+	}
+	if r.InactiveDays > 0 {
+		parts = append(parts, fmt.Sprintf("не заходили %d дн.", r.InactiveDays))
+	}
+	if r.NoDiary {
+		parts = append(parts, "нет записей в дневнике за неделю")
+	}
+	switch r.Pairing {
+	case PairingPaired:
+		parts = append(parts, "в паре")
+	case PairingSingle:
+		parts = append(parts, "без пары")
+	}
+	if r.JoinedAfter != nil {
+		parts = append(parts, "пришли с "+r.JoinedAfter.Format("02.01.2006"))
+	}
+	if len(parts) == 0 {
+		return "все активные пользователи"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ParseSegmentRules разбирает условия сегмента:
+// week=N, inactive=N, nodiary, paired, single, joined=ДД.ММ.ГГГГ
+func ParseSegmentRules(spec string) (SegmentRules, error) {
+	var rules SegmentRules
+	for _, field := range strings.Fields(spec) {
+		key, value, _ := strings.Cut(strings.ToLower(field), "=")
+		switch key {
+		case "week":
+			week, err := strconv.Atoi(value)
+			if err != nil || week < 1 {
+				return SegmentRules{}, fmt.Errorf("неверная неделя: %s", value)
+			}
+			rules.Week = week
+		case "inactive":
+			days, err := strconv.Atoi(value)
+			if err != nil || days < 1 {
+				return SegmentRules{}, fmt.Errorf("неверное количество дней: %s", value)
+			}
+			rules.InactiveDays = days
+		case "nodiary":
+			rules.NoDiary = true
+		case PairingPaired, PairingSingle:
+			if rules.Pairing != "" && rules.Pairing != key {
+				return SegmentRules{}, fmt.Errorf("paired и single нельзя указать одновременно")
+			}
+			rules.Pairing = key
+		case "joined":
+			date, err := time.ParseInLocation("02.01.2006", value, UserInfo{}.Location())
+			if err != nil {
+				return SegmentRules{}, fmt.Errorf("дата регистрации в формате ДД.ММ.ГГГГ: %s", value)
+			}
+			rules.JoinedAfter = &date
+		default:
+			return SegmentRules{}, fmt.Errorf("неизвестное условие: %s", field)
+		}
+	}
+	return rules, nil
+}
+
+// Segment сохраненная аудитория уведомлений
+type Segment struct {
+	ID        string       `json:"id"`
+	Name      string       `json:"name"`
+	Rules     SegmentRules `json:"rules"`
+	CreatedBy int64        `json:"created_by,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// segmentStore содержимое файла сегментов
+type segmentStore struct {
+	Next  int       `json:"next"` // последний выданный номер ID, не уменьшается при удалении
+	Items []Segment `json:"items"`
+}
+
+// SegmentStorage хранит сегменты аудитории в JSON файле
+type SegmentStorage struct {
+	filePath string
+	mutex    sync.Mutex
+}
+
+// NewSegmentStorage создает хранилище сегментов
+func NewSegmentStorage(dataDir string) *SegmentStorage {
+	os.MkdirAll(dataDir, 0755)
+	return &SegmentStorage{
+		filePath: filepath.Join(dataDir, "segments.json"),
+	}
+}
+
+// List возвращает сегменты в порядке создания
+func (ss *SegmentStorage) List() ([]Segment, error) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	store, err := ss.load()
+	if err != nil {
+		return nil, err
+	}
+	segments := store.Items
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].CreatedAt.Before(segments[j].CreatedAt)
+	})
+	return segments, nil
+}
+
+// Get возвращает сегмент по ID (nil, если его нет)
+func (ss *SegmentStorage) Get(id string) (*Segment, error) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	store, err := ss.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, segment := range store.Items {
+		if segment.ID == id {
+			return &segment, nil
+		}
+	}
+	return nil, nil
+}
+
+// Create сохраняет новый сегмент с коротким ID (s1, s2, ...), чтобы он помещался в callback data.
+// ID не переиспользуются: задания, ссылающиеся на удаленный сегмент, не получат чужую аудиторию.
+func (ss *SegmentStorage) Create(name string, rules SegmentRules, createdBy int64) (Segment, error) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	store, err := ss.load()
+	if err != nil {
+		return Segment{}, err
+	}
+
+	store.Next++
+	segment := Segment{
+		ID:        fmt.Sprintf("s%d", store.Next),
+		Name:      name,
+		Rules:     rules,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+	store.Items = append(store.Items, segment)
+	if err := ss.save(store); err != nil {
+		return Segment{}, err
+	}
+	return segment, nil
+}
+
+// Delete удаляет сегмент
+func (ss *SegmentStorage) Delete(id string) error {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	store, err := ss.load()
+	if err != nil {
+		return err
+	}
+	for i, segment := range store.Items {
+		if segment.ID == id {
+			store.Items = append(store.Items[:i], store.Items[i+1:]...)
+			return ss.save(store)
+		}
+	}
+	return fmt.Errorf("сегмент %s не найден", id)
+}
+
+// load загружает сегменты из JSON файла
+func (ss *SegmentStorage) load() (segmentStore, error) {
+	var store segmentStore
+	data, err := os.ReadFile(ss.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return store, nil
+		}
+		return store, err
+	}
+
+	// Старый формат - массив сегментов без счетчика
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		err = json.Unmarshal(data, &store.Items)
+	} else {
+		err = json.Unmarshal(data, &store)
+	}
+	if err != nil {
+		return segmentStore{}, err
+	}
+	for _, segment := range store.Items {
+		if n, err := strconv.Atoi(strings.TrimPrefix(segment.ID, "s")); err == nil && n > store.Next {
+			store.Next = n
+		}
+	}
+	return store, nil
+}
+
+// save сохраняет сегменты в JSON файл
+func (ss *SegmentStorage) save(store segmentStore) error {
+	data, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(ss.filePath, data, 0644)
+}
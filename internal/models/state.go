@@ -0,0 +1,125 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StateKind тип диалогового состояния пользователя
+type StateKind string
+
+// Поддерживаемые состояния
+const (
+	StateNone                       StateKind = ""
+	StateChat                       StateKind = "chat"
+	StateDiary                      StateKind = "diary"
+	StateDiaryEdit                  StateKind = "diary_edit"
+	StateCustomNotification         StateKind = "custom_notification"
+	StateCustomNotificationSchedule StateKind = "custom_notification_schedule"
+	StateScheduleCustomText         StateKind = "schedule_custom_text"
+	StateCustomTime                 StateKind = "custom_time"
+	StateCustomDate                 StateKind = "custom_date"
+	StateTimezone                   StateKind = "timezone"
+	StateQuietHours                 StateKind = "quiet_hours"
+	StateReminderTime               StateKind = "reminder_time"
+	StateCustomDraftEdit            StateKind = "custom_draft_edit"
+	StateCustomDraftTime            StateKind = "custom_draft_time"
+	StateCustomDraftMedia           StateKind = "custom_draft_media"
+)
+
+// DiaryContext контекст записи в дневник
+type DiaryContext struct {
+	Gender  string `json:"gender"`
+	Week    int    `json:"week"`
+	Type    string `json:"type"`
+	EntryID string `json:"entry_id,omitempty"` // исправляемая запись
+}
+
+// ScheduleContext контекст планирования уведомления
+type ScheduleContext struct {
+	Date    string `json:"date,omitempty"`     // ДД.ММ.ГГГГ
+	Time    string `json:"time,omitempty"`     // ЧЧ:ММ
+	DraftID string `json:"draft_id,omitempty"` // черновик кастомного уведомления
+}
+
+// State типизированное состояние пользователя
+type State struct {
+	Kind     StateKind        `json:"kind"`
+	Diary    *DiaryContext    `json:"diary,omitempty"`
+	Schedule *ScheduleContext `json:"schedule,omitempty"`
+}
+
+// NewDiaryState создает состояние записи в дневник
+func NewDiaryState(gender string, week int, entryType string) State {
+	return State{
+		Kind:  StateDiary,
+		Diary: &DiaryContext{Gender: gender, Week: week, Type: entryType},
+	}
+}
+
+// NewDiaryEditState создает состояние исправления записи дневника
+func NewDiaryEditState(entryID string) State {
+	return State{
+		Kind:  StateDiaryEdit,
+		Diary: &DiaryContext{EntryID: entryID},
+	}
+}
+
+// NewCustomTimeState создает состояние ввода времени для выбранной даты
+func NewCustomTimeState(date string) State {
+	return State{
+		Kind:     StateCustomTime,
+		Schedule: &ScheduleContext{Date: date},
+	}
+}
+
+// NewDraftState создает состояние правки черновика кастомного уведомления
+func NewDraftState(kind StateKind, draftID string) State {
+	return State{
+		Kind:     kind,
+		Schedule: &ScheduleContext{DraftID: draftID},
+	}
+}
+
+// IsEmpty проверяет, что состояние не установлено
+func (s State) IsEmpty() bool {
+	return s.Kind == StateNone
+}
+
+// String кодирует состояние в строковый формат (совместим с прежними строковыми состояниями)
+func (s State) String() string {
+	switch {
+	case s.Kind == StateDiary && s.Diary != nil:
+		return fmt.Sprintf("diary_%s_%d_%s", s.Diary.Gender, s.Diary.Week, s.Diary.Type)
+	case s.Kind == StateCustomTime && s.Schedule != nil:
+		return fmt.Sprintf("custom_time_%s", s.Schedule.Date)
+	}
+	return string(s.Kind)
+}
+
+// ParseState разбирает строковое состояние (diary_male_2_questions, custom_time_13.10.2025 и т.д.)
+func ParseState(raw string) State {
+	switch StateKind(raw) {
+	case StateNone, StateChat, StateDiary, StateDiaryEdit, StateCustomNotification, StateCustomNotificationSchedule,
+		StateScheduleCustomText, StateCustomDate, StateTimezone, StateQuietHours, StateReminderTime,
+		StateCustomDraftEdit, StateCustomDraftTime, StateCustomDraftMedia:
+		return State{Kind: StateKind(raw)}
+	}
+
+	if date, ok := strings.CutPrefix(raw, "custom_time_"); ok {
+		return NewCustomTimeState(date)
+	}
+
+	if rest, ok := strings.CutPrefix(raw, "diary_"); ok {
+		parts := strings.Split(rest, "_")
+		if len(parts) == 3 {
+			if week, err := strconv.Atoi(parts[1]); err == nil {
+				return NewDiaryState(parts[0], week, parts[2])
+			}
+		}
+	}
+
+	// Неизвестное состояние сохраняем как есть
+	return State{Kind: StateKind(raw)}
+}
@@ -0,0 +1,166 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// DefaultTimezone часовой пояс пользователей, которые его еще не выбрали (UTC+5)
+const DefaultTimezone = "Asia/Tashkent"
+
+// TimezoneOption часовой пояс, предлагаемый пользователю при выборе
+type TimezoneOption struct {
+	Zone  string // IANA имя
+	Title string // подпись кнопки
+}
+
+// TimezoneOptions часовые поясы, которые предлагаются кнопками при онбординге
+var TimezoneOptions = []TimezoneOption{
+	{Zone: "Europe/Kaliningrad", Title: "Калининград"},
+	{Zone: "Europe/Moscow", Title: "Москва"},
+	{Zone: "Europe/Samara", Title: "Самара"},
+	{Zone: "Asia/Yekaterinburg", Title: "Екатеринбург"},
+	{Zone: "Asia/Tashkent", Title: "Ташкент"},
+	{Zone: "Asia/Almaty", Title: "Алматы"},
+	{Zone: "Asia/Omsk", Title: "Омск"},
+	{Zone: "Asia/Novosibirsk", Title: "Новосибирск"},
+	{Zone: "Asia/Irkutsk", Title: "Иркутск"},
+	{Zone: "Asia/Vladivostok", Title: "Владивосток"},
+	{Zone: "Europe/Kyiv", Title: "Киев"},
+	{Zone: "Europe/Minsk", Title: "Минск"},
+}
+
+// LoadTimezone проверяет IANA имя часового пояса и возвращает его
+func LoadTimezone(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	// time.LoadLocation принимает "" и "UTC"/"Local" - для пользователя это не выбор пояса
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("часовой пояс не указан")
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("неизвестный часовой пояс: %s", name)
+	}
+	return loc, nil
+}
+
+// Location возвращает часовой пояс пользователя (DefaultTimezone, если он не выбран)
+func (u UserInfo) Location() *time.Location {
+	if u.Timezone != "" {
+		if loc, err := time.LoadLocation(u.Timezone); err == nil {
+			return loc
+		}
+	}
+	loc, err := time.LoadLocation(DefaultTimezone)
+	if err != nil {
+		return time.FixedZone("UTC+5", 5*60*60)
+	}
+	return loc
+}
+
+// Пояса существуют в диапазоне UTC-12..UTC+14
+const (
+	minUTCOffset = -12 * 3600
+	maxUTCOffset = 14 * 3600
+)
+
+// DetectTimezone определяет часовой пояс по местному времени пользователя "ЧЧ:ММ" или "ДД.ММ ЧЧ:ММ".
+// Одно время не различает пояса, у которых сейчас разные даты (UTC-10 и UTC+14),
+// в этом случае нужна и дата. Предпочитает пояса из TimezoneOptions, иначе возвращает Etc/GMT±N.
+func DetectTimezone(localClock string, now time.Time) (string, error) {
+	offset, err := detectUTCOffset(strings.TrimSpace(localClock), now.UTC())
+	if err != nil {
+		return "", err
+	}
+
+	for _, option := range TimezoneOptions {
+		loc, err := time.LoadLocation(option.Zone)
+		if err != nil {
+			continue
+		}
+		if _, zoneOffset := now.In(loc).Zone(); zoneOffset == offset {
+			return option.Zone, nil
+		}
+	}
+
+	if offset%3600 != 0 || offset > maxUTCOffset || offset < minUTCOffset {
+		return "", fmt.Errorf("не удалось определить часовой пояс, выберите его из списка")
+	}
+	// В зоне Etc/GMT знак инвертирован: Etc/GMT-3 соответствует UTC+3
+	hours := offset / 3600
+	switch {
+	case hours == 0:
+		return "Etc/UTC", nil
+	case hours > 0:
+		return fmt.Sprintf("Etc/GMT-%d", hours), nil
+	default:
+		return fmt.Sprintf("Etc/GMT+%d", -hours), nil
+	}
+}
+
+// detectUTCOffset вычисляет смещение местного времени от UTC в секундах, округленное до 15 минут
+// (пользователь мог ответить с небольшой задержкой)
+func detectUTCOffset(local string, utc time.Time) (int, error) {
+	if clock, err := time.Parse("15:04", local); err == nil {
+		// Без даты подходят оба смещения, отличающиеся на сутки, если они в диапазоне поясов
+		diff := roundOffset(time.Duration(clock.Hour()*60+clock.Minute()-utc.Hour()*60-utc.Minute()) * time.Minute)
+		diff = ((diff % (24 * 3600)) + 24*3600) % (24 * 3600)
+		if diff > maxUTCOffset {
+			diff -= 24 * 3600
+		} else if diff-24*3600 >= minUTCOffset {
+			return 0, fmt.Errorf("по времени не понять, какое у вас сейчас число: напишите дату и время в формате ДД.ММ ЧЧ:ММ")
+		}
+		return diff, nil
+	}
+
+	date, err := time.Parse("02.01 15:04", local)
+	if err != nil {
+		return 0, fmt.Errorf("используйте формат ЧЧ:ММ или ДД.ММ ЧЧ:ММ")
+	}
+	// Год не указан: берем ближайший к текущему моменту (на стыке годов даты расходятся)
+	offset, found := 0, false
+	for _, year := range []int{utc.Year(), utc.Year() - 1, utc.Year() + 1} {
+		wall := time.Date(year, date.Month(), date.Day(), date.Hour(), date.Minute(), 0, 0, time.UTC)
+		if wall.Day() != date.Day() {
+			continue // 29.02 в невисокосном году
+		}
+		diff := roundOffset(wall.Sub(utc))
+		if !found || abs(diff) < abs(offset) {
+			offset, found = diff, true
+		}
+	}
+	if offset > maxUTCOffset || offset < minUTCOffset {
+		return 0, fmt.Errorf("не удалось определить часовой пояс, проверьте дату и время")
+	}
+	return offset, nil
+}
+
+// roundOffset округляет разницу времени до 15 минут и возвращает ее в секундах
+func roundOffset(diff time.Duration) int {
+	return int(math.Round(diff.Minutes()/15)) * 15 * 60
+}
+
+// abs возвращает модуль числа
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// FormatUTCOffset форматирует смещение пояса от UTC в момент t: "UTC+5", "UTC-3:30"
+func FormatUTCOffset(loc *time.Location, t time.Time) string {
+	_, offset := t.In(loc).Zone()
+	result := "UTC+"
+	if offset < 0 {
+		result = "UTC-"
+		offset = -offset
+	}
+	result += fmt.Sprintf("%d", offset/3600)
+	if minutes := offset % 3600 / 60; minutes != 0 {
+		result += fmt.Sprintf(":%02d", minutes)
+	}
+	return result
+}
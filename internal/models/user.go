@@ -1,6 +1,9 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
 	"sync"
 	"time"
 )
@@ -8,7 +11,7 @@ import (
 // UserState представляет состояние пользователя в боте.
 // Содержит информацию о текущем состоянии пользователя и дополнительные данные.
 type UserState struct {
-	State     string    `json:"state"`
+	State     State     `json:"state"`
 	Data      string    `json:"data,omitempty"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -36,6 +39,13 @@ type UserManager struct {
 	rateLimits  map[int64]*RateLimitEntry
 	adminIDs    []int64
 	mutex       sync.RWMutex
+
+	// Персистентность состояний (пустой statesFile - только в памяти)
+	statesFile string
+	stateTTL   time.Duration
+	version    uint64     // номер последнего изменения состояний
+	flushMu    sync.Mutex // одна запись файла за раз: ожидающие изменения попадают в следующую
+	flushed    uint64     // номер последнего изменения, записанного в файл
 }
 
 // NewUserManager создает новый менеджер пользователей
@@ -47,49 +57,172 @@ func NewUserManager(adminIDs []int64) *UserManager {
 	}
 }
 
-// SetState устанавливает состояние пользователя
-func (um *UserManager) SetState(userID int64, state string) {
+// EnablePersistence включает сохранение состояний в файл и восстанавливает ранее сохраненные.
+// Состояния старше ttl считаются истекшими (ttl <= 0 - без ограничения).
+func (um *UserManager) EnablePersistence(filePath string, ttl time.Duration) error {
 	um.mutex.Lock()
 	defer um.mutex.Unlock()
-	
-	um.states[userID] = &UserState{
-		State:     state,
-		UpdatedAt: time.Now(),
+
+	um.statesFile = filePath
+	um.stateTTL = ttl
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read states file: %w", err)
+	}
+
+	var states map[int64]*UserState
+	if err := json.Unmarshal(data, &states); err != nil {
+		return fmt.Errorf("failed to parse states file: %w", err)
 	}
+
+	for userID, state := range states {
+		if !um.isExpired(state) {
+			um.states[userID] = state
+		}
+	}
+	return nil
+}
+
+// SetUserState устанавливает типизированное состояние пользователя.
+// При ошибке сохранения состояние не меняется.
+func (um *UserManager) SetUserState(userID int64, state State) error {
+	return um.setState(userID, state, "")
+}
+
+// GetUserState получает типизированное состояние пользователя
+func (um *UserManager) GetUserState(userID int64) State {
+	state, _ := um.getState(userID)
+	return state
+}
+
+// SetState устанавливает состояние пользователя
+func (um *UserManager) SetState(userID int64, state string) error {
+	return um.setState(userID, ParseState(state), "")
 }
 
 // GetState получает состояние пользователя
 func (um *UserManager) GetState(userID int64) string {
-	um.mutex.RLock()
-	defer um.mutex.RUnlock()
-	
-	if state, exists := um.states[userID]; exists {
-		return state.State
-	}
-	return ""
+	state, _ := um.getState(userID)
+	return state.String()
 }
 
 // SetStateData устанавливает данные состояния пользователя
-func (um *UserManager) SetStateData(userID int64, state, data string) {
-	um.mutex.Lock()
-	defer um.mutex.Unlock()
-	
-	um.states[userID] = &UserState{
+func (um *UserManager) SetStateData(userID int64, state, data string) error {
+	return um.setState(userID, ParseState(state), data)
+}
+
+// GetStateData получает данные состояния пользователя
+func (um *UserManager) GetStateData(userID int64) (string, string) {
+	state, data := um.getState(userID)
+	return state.String(), data
+}
+
+// setState сохраняет состояние и данные пользователя
+func (um *UserManager) setState(userID int64, state State, data string) error {
+	next := &UserState{
 		State:     state,
 		Data:      data,
 		UpdatedAt: time.Now(),
 	}
+
+	um.mutex.Lock()
+	prev, existed := um.states[userID]
+	um.states[userID] = next
+	version := um.touch()
+	um.mutex.Unlock()
+
+	return um.persist(version, func() {
+		if um.states[userID] != next {
+			return
+		}
+		if existed {
+			um.states[userID] = prev
+		} else {
+			delete(um.states, userID)
+		}
+	})
 }
 
-// GetStateData получает данные состояния пользователя
-func (um *UserManager) GetStateData(userID int64) (string, string) {
+// getState возвращает актуальное (не истекшее) состояние пользователя
+func (um *UserManager) getState(userID int64) (State, string) {
 	um.mutex.RLock()
 	defer um.mutex.RUnlock()
-	
-	if state, exists := um.states[userID]; exists {
+
+	if state, exists := um.states[userID]; exists && !um.isExpired(state) {
 		return state.State, state.Data
 	}
-	return "", ""
+	return State{}, ""
+}
+
+// isExpired проверяет истечение TTL состояния
+func (um *UserManager) isExpired(state *UserState) bool {
+	return um.stateTTL > 0 && time.Since(state.UpdatedAt) > um.stateTTL
+}
+
+// CleanupExpiredStates удаляет истекшие состояния.
+// Истекшие состояния и так не действуют, поэтому из памяти они удаляются даже при ошибке записи.
+func (um *UserManager) CleanupExpiredStates() (int, error) {
+	um.mutex.Lock()
+	removed := 0
+	for userID, state := range um.states {
+		if um.isExpired(state) {
+			delete(um.states, userID)
+			removed++
+		}
+	}
+	var version uint64
+	if removed > 0 {
+		version = um.touch()
+	}
+	um.mutex.Unlock()
+
+	return removed, um.persist(version, nil)
+}
+
+// touch отмечает изменение состояний и возвращает его номер, 0 - сохранять не нужно (вызывается под блокировкой)
+func (um *UserManager) touch() uint64 {
+	if um.statesFile == "" {
+		return 0
+	}
+	um.version++
+	return um.version
+}
+
+// persist записывает в файл состояния вместе с изменением version.
+// Изменения, накопившиеся за время чужой записи, сохраняются одной записью,
+// а изменения, уже попавшие в файл, повторно не пишутся.
+// При ошибке undo откатывает изменение в памяти до того, как файл запишет кто-то еще.
+func (um *UserManager) persist(version uint64, undo func()) error {
+	if version == 0 {
+		return nil
+	}
+	um.flushMu.Lock()
+	defer um.flushMu.Unlock()
+	if um.flushed >= version {
+		return nil
+	}
+
+	um.mutex.RLock()
+	data, err := json.MarshalIndent(um.states, "", "  ")
+	latest := um.version
+	um.mutex.RUnlock()
+	if err == nil {
+		err = WriteFileAtomic(um.statesFile, data)
+	}
+	if err != nil {
+		if undo != nil {
+			um.mutex.Lock()
+			undo()
+			um.mutex.Unlock()
+		}
+		return fmt.Errorf("failed to save user states: %w", err)
+	}
+	um.flushed = latest
+	return nil
 }
 
 // IsAdmin проверяет, является ли пользователь администратором
@@ -129,11 +262,24 @@ func (um *UserManager) IsRateLimited(userID int64, limit time.Duration) bool {
 	return false
 }
 
-// ClearState очищает состояние пользователя
-func (um *UserManager) ClearState(userID int64) {
+// ClearState очищает состояние пользователя.
+// При ошибке сохранения состояние не меняется.
+func (um *UserManager) ClearState(userID int64) error {
 	um.mutex.Lock()
-	defer um.mutex.Unlock()
+	prev, exists := um.states[userID]
+	if !exists {
+		um.mutex.Unlock()
+		return nil
+	}
 	delete(um.states, userID)
+	version := um.touch()
+	um.mutex.Unlock()
+
+	return um.persist(version, func() {
+		if _, exists := um.states[userID]; !exists {
+			um.states[userID] = prev
+		}
+	})
 }
 
 // GetAdminIDs возвращает список ID администраторов
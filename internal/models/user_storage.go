@@ -2,6 +2,7 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
@@ -15,6 +16,46 @@ type UserInfo struct {
 	IsActive bool      `json:"is_active"`
 	JoinedAt time.Time `json:"joined_at"`
 	LastSeen time.Time `json:"last_seen"`
+	Timezone string    `json:"timezone,omitempty"` // IANA имя часового пояса
+
+	// Причина и время деактивации (пользователь недоступен для отправки)
+	InactiveReason string     `json:"inactive_reason,omitempty"`
+	InactiveSince  *time.Time `json:"inactive_since,omitempty"`
+	// Когда пользователь в последний раз вернулся после деактивации
+	ReactivatedAt *time.Time `json:"reactivated_at,omitempty"`
+
+	// Настройки уведомлений (nil - все уведомления включены, без тихих часов)
+	Preferences *NotificationPreferences `json:"preferences,omitempty"`
+}
+
+// Prefs возвращает настройки уведомлений пользователя
+func (u UserInfo) Prefs() NotificationPreferences {
+	if u.Preferences == nil {
+		return NotificationPreferences{}
+	}
+	return *u.Preferences
+}
+
+// Причины деактивации пользователя
+const (
+	InactiveBlocked      = "blocked"        // пользователь заблокировал бота
+	InactiveChatNotFound = "chat_not_found" // чат не найден
+	InactiveDeactivated  = "deactivated"    // аккаунт Telegram удален
+)
+
+// InactiveReasonTitle возвращает описание причины деактивации для администратора
+func InactiveReasonTitle(reason string) string {
+	switch reason {
+	case InactiveBlocked:
+		return "заблокировали бота"
+	case InactiveChatNotFound:
+		return "чат не найден"
+	case InactiveDeactivated:
+		return "аккаунт удален"
+	case "":
+		return "причина не указана"
+	}
+	return reason
 }
 
 // UserStorage управляет хранением пользователей в JSON файле
@@ -46,7 +87,14 @@ func (us *UserStorage) AddUser(userID int64, username string) error {
 		// Обновляем информацию
 		existingUser.Username = username
 		existingUser.LastSeen = time.Now()
-		existingUser.IsActive = true
+		if !existingUser.IsActive {
+			// Пользователь снова написал боту (обычно через /start после разблокировки)
+			now := time.Now()
+			existingUser.IsActive = true
+			existingUser.InactiveReason = ""
+			existingUser.InactiveSince = nil
+			existingUser.ReactivatedAt = &now
+		}
 	} else {
 		// Создаем нового пользователя
 		users[userID] = &UserInfo{
@@ -114,8 +162,20 @@ func (us *UserStorage) UpdateLastSeen(userID int64) error {
 	return nil
 }
 
-// DeactivateUser деактивирует пользователя
-func (us *UserStorage) DeactivateUser(userID int64) error {
+// GetUser возвращает пользователя по ID (nil, если он не зарегистрирован)
+func (us *UserStorage) GetUser(userID int64) (*UserInfo, error) {
+	us.mutex.RLock()
+	defer us.mutex.RUnlock()
+
+	users, err := us.loadUsers()
+	if err != nil {
+		return nil, err
+	}
+	return users[userID], nil
+}
+
+// SetTimezone сохраняет часовой пояс пользователя
+func (us *UserStorage) SetTimezone(userID int64, timezone string) error {
 	us.mutex.Lock()
 	defer us.mutex.Unlock()
 
@@ -124,14 +184,119 @@ func (us *UserStorage) DeactivateUser(userID int64) error {
 		return err
 	}
 
-	if user, exists := users[userID]; exists {
+	user, exists := users[userID]
+	if !exists {
+		return fmt.Errorf("пользователь %d не зарегистрирован", userID)
+	}
+	user.Timezone = timezone
+	return us.saveUsers(users)
+}
+
+// SetPreferences сохраняет настройки уведомлений пользователя
+func (us *UserStorage) SetPreferences(userID int64, prefs NotificationPreferences) error {
+	us.mutex.Lock()
+	defer us.mutex.Unlock()
+
+	users, err := us.loadUsers()
+	if err != nil {
+		return err
+	}
+
+	user, exists := users[userID]
+	if !exists {
+		return fmt.Errorf("пользователь %d не зарегистрирован", userID)
+	}
+	user.Preferences = &prefs
+	return us.saveUsers(users)
+}
+
+// DeactivateUser деактивирует пользователя с указанием причины
+func (us *UserStorage) DeactivateUser(userID int64, reason string) error {
+	us.mutex.Lock()
+	defer us.mutex.Unlock()
+
+	users, err := us.loadUsers()
+	if err != nil {
+		return err
+	}
+
+	if user, exists := users[userID]; exists && user.IsActive {
+		now := time.Now()
 		user.IsActive = false
+		user.InactiveReason = reason
+		user.InactiveSince = &now
 		return us.saveUsers(users)
 	}
 
 	return nil
 }
 
+// GetAllUsers возвращает всех пользователей, включая неактивных
+func (us *UserStorage) GetAllUsers() ([]UserInfo, error) {
+	us.mutex.RLock()
+	defer us.mutex.RUnlock()
+
+	users, err := us.loadUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]UserInfo, 0, len(users))
+	for _, user := range users {
+		result = append(result, *user)
+	}
+	return result, nil
+}
+
+// ChurnStats статистика оттока пользователей
+type ChurnStats struct {
+	Active     int
+	Inactive   int
+	ByReason   map[string]int // неактивные по причинам деактивации
+	Left7d     int            // деактивированы за последние 7 дней
+	Left30d    int            // деактивированы за последние 30 дней
+	Returned30 int            // вернулись за последние 30 дней
+}
+
+// ChurnRate доля неактивных пользователей в процентах
+func (s ChurnStats) ChurnRate() float64 {
+	total := s.Active + s.Inactive
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Inactive) * 100 / float64(total)
+}
+
+// GetChurnStats считает статистику оттока на момент now
+func (us *UserStorage) GetChurnStats(now time.Time) (ChurnStats, error) {
+	users, err := us.GetAllUsers()
+	if err != nil {
+		return ChurnStats{}, err
+	}
+
+	stats := ChurnStats{ByReason: make(map[string]int)}
+	for _, user := range users {
+		if user.ReactivatedAt != nil && now.Sub(*user.ReactivatedAt) <= 30*24*time.Hour {
+			stats.Returned30++
+		}
+		if user.IsActive {
+			stats.Active++
+			continue
+		}
+		stats.Inactive++
+		stats.ByReason[user.InactiveReason]++
+		if user.InactiveSince != nil {
+			if since := now.Sub(*user.InactiveSince); since <= 7*24*time.Hour {
+				stats.Left7d++
+				stats.Left30d++
+			} else if since <= 30*24*time.Hour {
+				stats.Left30d++
+			}
+		}
+	}
+	return stats, nil
+}
+
 // loadUsers загружает пользователей из JSON файла
 func (us *UserStorage) loadUsers() (map[int64]*UserInfo, error) {
 	data, err := os.ReadFile(us.filePath)
@@ -0,0 +1,596 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// MessageSender отправляет сообщения Telegram (реализуется *tgbotapi.BotAPI)
+type MessageSender interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+}
+
+// Статусы рассылки
+const (
+	BroadcastPending = "pending"
+	BroadcastDone    = "done"
+)
+
+const (
+	// broadcastMaxAttempts попыток отправки одному получателю при 429 и временных ошибках
+	broadcastMaxAttempts = 3
+	// broadcastHistory сколько завершенных рассылок хранится в файле
+	broadcastHistory = 20
+	// captionLimit максимальная длина подписи к медиа в Telegram
+	captionLimit = 1024
+)
+
+// BroadcastJob рассылка одного сообщения списку получателей. Хранится в файле,
+// чтобы после перезапуска продолжить отправку с получателя Next (и части Part его уведомления).
+// Прогресс незавершенной рассылки дописывается в отдельный журнал после каждой отправки.
+type BroadcastJob struct {
+	ID          string           `json:"id"`
+	Message     string           `json:"message"`
+	Messages    map[int64]string `json:"messages,omitempty"` // персональные тексты получателей (вместо Message)
+	ParseMode   string           `json:"parse_mode,omitempty"`
+	Recipients  []int64          `json:"recipients"`
+	Next        int              `json:"next"`
+	Part        int              `json:"part,omitempty"` // сколько сообщений уведомления уже получил получатель Next
+	Sent        int              `json:"sent"`
+	Failed      int              `json:"failed"`
+	Blocked     int              `json:"blocked"`
+	RequestedBy int64            `json:"requested_by,omitempty"` // кому отправить отчет, если рассылка продолжена после перезапуска
+	Status      string           `json:"status"`
+	CreatedAt   time.Time        `json:"created_at"`
+	FinishedAt  *time.Time       `json:"finished_at,omitempty"`
+
+	models.RichContent // медиа и кнопки, общие для всех получателей
+}
+
+// BroadcastReport итог рассылки
+type BroadcastReport struct {
+	ID       string
+	Total    int
+	Sent     int
+	Failed   int
+	Blocked  int
+	Duration time.Duration
+	Resumed  bool // рассылка продолжена после перезапуска бота
+	Muted    int  // получатели, отключившие этот тип уведомлений
+	Deferred int  // получатели, которым уведомление придет после тихих часов или в выбранное время
+}
+
+// Summary описывает итог рассылки для администратора
+func (r BroadcastReport) Summary() string {
+	text := fmt.Sprintf("📊 Отчет о рассылке\n\n"+
+		"✅ Доставлено: %d\n"+
+		"🚫 Недоступны (заблокировали бота или удалены): %d\n"+
+		"❌ Ошибки: %d\n"+
+		"👥 Всего получателей: %d\n"+
+		"⏱ Длительность: %s",
+		r.Sent, r.Blocked, r.Failed, r.Total, r.Duration.Round(time.Second))
+	if r.Muted > 0 {
+		text += fmt.Sprintf("\n🔕 Отключили этот тип уведомлений: %d", r.Muted)
+	}
+	if r.Deferred > 0 {
+		text += fmt.Sprintf("\n🌙 Отложено до удобного времени: %d", r.Deferred)
+	}
+	if r.Resumed {
+		text += "\n\n🔄 Рассылка была продолжена после перезапуска бота."
+	}
+	return text
+}
+
+// MessageFor возвращает текст рассылки для получателя
+func (job BroadcastJob) MessageFor(userID int64) string {
+	if message, ok := job.Messages[userID]; ok {
+		return message
+	}
+	return job.Message
+}
+
+// RichMessages собирает сообщения уведомления для получателя: медиа с текстом в подписи и кнопками.
+// Если текст не помещается в подпись, медиа отправляется отдельно перед текстом с кнопками.
+func RichMessages(chatID int64, text, parseMode string, rich models.RichContent) []tgbotapi.Chattable {
+	keyboard := richKeyboard(rich.Buttons)
+	textMessage := func() tgbotapi.Chattable {
+		msg := tgbotapi.NewMessage(chatID, text)
+		msg.ParseMode = parseMode
+		if keyboard != nil {
+			msg.ReplyMarkup = *keyboard
+		}
+		return msg
+	}
+	if rich.Media == nil {
+		return []tgbotapi.Chattable{textMessage()}
+	}
+
+	fitsCaption := utf8.RuneCountInString(text) <= captionLimit
+	file := tgbotapi.FileID(rich.Media.FileID)
+	var media tgbotapi.Chattable
+	switch rich.Media.Kind {
+	case models.MediaDocument:
+		doc := tgbotapi.NewDocument(chatID, file)
+		if fitsCaption {
+			doc.Caption, doc.ParseMode = text, parseMode
+			if keyboard != nil {
+				doc.ReplyMarkup = *keyboard
+			}
+		}
+		media = doc
+	default:
+		photo := tgbotapi.NewPhoto(chatID, file)
+		if fitsCaption {
+			photo.Caption, photo.ParseMode = text, parseMode
+			if keyboard != nil {
+				photo.ReplyMarkup = *keyboard
+			}
+		}
+		media = photo
+	}
+	if fitsCaption {
+		return []tgbotapi.Chattable{media}
+	}
+	return []tgbotapi.Chattable{media, textMessage()}
+}
+
+// richKeyboard строит клавиатуру уведомления: по кнопке в строке (nil, если кнопок нет)
+func richKeyboard(buttons []models.NotificationButton) *tgbotapi.InlineKeyboardMarkup {
+	if len(buttons) == 0 {
+		return nil
+	}
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(buttons))
+	for _, button := range buttons {
+		if button.URL != "" {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonURL(button.Text, button.URL)))
+		} else {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(button.Text, button.Data)))
+		}
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &keyboard
+}
+
+// broadcastStore содержимое файла рассылок
+type broadcastStore struct {
+	Jobs []BroadcastJob `json:"jobs"`
+}
+
+// BroadcastQueue очередь рассылок: отправляет сообщения по одному с общим ограничением
+// частоты, учитывает retry_after от Telegram и сохраняет прогресс в JSON файл
+type BroadcastQueue struct {
+	sender   MessageSender
+	limiter  *TokenBucket
+	filePath string
+
+	mutex   sync.Mutex
+	waiters map[string]chan BroadcastReport
+	wake    chan struct{}
+	running atomic.Bool
+
+	// onReport вызывается для рассылок, итог которых никто не ждет (продолженных после перезапуска)
+	onReport func(job BroadcastJob, report BroadcastReport)
+	// onUnreachable вызывается для получателей, которым отправка больше невозможна
+	onUnreachable func(userID int64, reason string)
+}
+
+// NewBroadcastQueue создает очередь рассылок
+func NewBroadcastQueue(sender MessageSender, limiter *TokenBucket, dataDir string) *BroadcastQueue {
+	os.MkdirAll(dataDir, 0755)
+	return &BroadcastQueue{
+		sender:   sender,
+		limiter:  limiter,
+		filePath: filepath.Join(dataDir, "broadcasts.json"),
+		waiters:  make(map[string]chan BroadcastReport),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// SetReportHandler задает обработчик отчетов рассылок, итог которых никто не ждет
+func (q *BroadcastQueue) SetReportHandler(fn func(job BroadcastJob, report BroadcastReport)) {
+	q.onReport = fn
+}
+
+// SetUnreachableHandler задает обработчик получателей, заблокировавших бота или удаленных
+func (q *BroadcastQueue) SetUnreachableHandler(fn func(userID int64, reason string)) {
+	q.onUnreachable = fn
+}
+
+// Running проверяет, что очередь обрабатывается
+func (q *BroadcastQueue) Running() bool {
+	return q.running.Load()
+}
+
+// Enqueue ставит рассылку в очередь. Канал получит отчет после отправки последнему получателю.
+func (q *BroadcastQueue) Enqueue(message, parseMode string, recipients []int64, requestedBy int64) (BroadcastJob, <-chan BroadcastReport, error) {
+	return q.EnqueuePersonal(message, nil, parseMode, recipients, requestedBy)
+}
+
+// EnqueuePersonal ставит в очередь рассылку с персональными текстами: получатели,
+// которых нет в messages, получают общий текст message
+func (q *BroadcastQueue) EnqueuePersonal(message string, messages map[int64]string, parseMode string, recipients []int64, requestedBy int64) (BroadcastJob, <-chan BroadcastReport, error) {
+	return q.Submit(BroadcastJob{
+		Message:     message,
+		Messages:    messages,
+		ParseMode:   parseMode,
+		Recipients:  recipients,
+		RequestedBy: requestedBy,
+	})
+}
+
+// Submit ставит в очередь подготовленную рассылку (текст, получатели, медиа и кнопки).
+// ID, статус и время создания задаются очередью.
+func (q *BroadcastQueue) Submit(job BroadcastJob) (BroadcastJob, <-chan BroadcastReport, error) {
+	now := time.Now()
+	job.ID = fmt.Sprintf("bc_%d", now.UnixNano())
+	job.Recipients = uniqueIDs(job.Recipients)
+	job.Next, job.Part, job.Sent, job.Failed, job.Blocked = 0, 0, 0, 0, 0
+	job.Status = BroadcastPending
+	job.CreatedAt = now
+	job.FinishedAt = nil
+	done := make(chan BroadcastReport, 1)
+
+	q.mutex.Lock()
+	store, err := q.load()
+	if err == nil {
+		store.Jobs = append(store.Jobs, job)
+		err = q.save(store)
+	}
+	if err == nil {
+		q.waiters[job.ID] = done
+	}
+	q.mutex.Unlock()
+	if err != nil {
+		return BroadcastJob{}, nil, fmt.Errorf("failed to enqueue broadcast: %w", err)
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return job, done, nil
+}
+
+// Jobs возвращает рассылки из файла: незавершенные и последние завершенные
+func (q *BroadcastQueue) Jobs() ([]BroadcastJob, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	store, err := q.load()
+	if err != nil {
+		return nil, err
+	}
+	return store.Jobs, nil
+}
+
+// Run обрабатывает очередь до закрытия stop. Незавершенные рассылки из файла продолжаются.
+func (q *BroadcastQueue) Run(stop <-chan struct{}) {
+	q.running.Store(true)
+	defer q.running.Store(false)
+
+	for {
+		job, found, err := q.nextPending()
+		if err != nil {
+			log.Printf("❌ Ошибка загрузки очереди рассылок: %v", err)
+		}
+		if err != nil || !found {
+			select {
+			case <-stop:
+				return
+			case <-q.wake:
+			case <-time.After(time.Minute):
+			}
+			continue
+		}
+
+		if !q.process(job, stop) {
+			return
+		}
+	}
+}
+
+// process отправляет рассылку. Возвращает false, если отправка прервана через stop.
+func (q *BroadcastQueue) process(job BroadcastJob, stop <-chan struct{}) bool {
+	started := time.Now()
+	if job.Next > 0 {
+		log.Printf("🔄 Продолжаем рассылку %s с получателя %d из %d", job.ID, job.Next+1, len(job.Recipients))
+	}
+
+	// Журнал дописывается после каждой отправки: после перезапуска никто не получит сообщение повторно
+	progress, err := os.OpenFile(q.progressFile(job.ID), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		log.Printf("❌ Ошибка открытия журнала рассылки %s: %v", job.ID, err)
+	} else {
+		defer progress.Close()
+	}
+	record := func(entry progressEntry) {
+		if progress == nil {
+			return
+		}
+		if err := writeProgress(progress, entry); err != nil {
+			log.Printf("❌ Ошибка сохранения прогресса рассылки %s: %v", job.ID, err)
+		}
+	}
+
+	for job.Next < len(job.Recipients) {
+		outcome := q.deliver(job, job.Recipients[job.Next], stop, func(part int) {
+			record(progressEntry{Recipient: job.Next, Part: part})
+		})
+		if outcome == deliveryInterrupted {
+			return false
+		}
+		record(progressEntry{Recipient: job.Next, Outcome: outcome})
+		job.apply(progressEntry{Recipient: job.Next, Outcome: outcome})
+	}
+
+	finished := time.Now()
+	job.Status = BroadcastDone
+	job.FinishedAt = &finished
+	q.saveProgress(job)
+	if progress != nil {
+		progress.Close()
+		os.Remove(q.progressFile(job.ID))
+	}
+
+	report := BroadcastReport{
+		ID:       job.ID,
+		Total:    len(job.Recipients),
+		Sent:     job.Sent,
+		Failed:   job.Failed,
+		Blocked:  job.Blocked,
+		Duration: finished.Sub(started),
+	}
+	log.Printf("📊 Рассылка %s завершена: доставлено %d, заблокировали %d, ошибок %d, всего %d",
+		job.ID, report.Sent, report.Blocked, report.Failed, report.Total)
+
+	q.mutex.Lock()
+	waiter, ok := q.waiters[job.ID]
+	delete(q.waiters, job.ID)
+	q.mutex.Unlock()
+
+	if ok {
+		waiter <- report
+	} else {
+		report.Resumed = true
+		if q.onReport != nil {
+			q.onReport(job, report)
+		}
+	}
+	return true
+}
+
+// deliveryOutcome результат отправки одному получателю
+type deliveryOutcome string
+
+const (
+	deliverySent        deliveryOutcome = "sent"
+	deliveryBlocked     deliveryOutcome = "blocked"
+	deliveryFailed      deliveryOutcome = "failed"
+	deliveryInterrupted deliveryOutcome = "interrupted"
+)
+
+// progressEntry строка журнала рассылки: итог отправки получателю с индексом Recipient
+// или, если итога еще нет, сколько сообщений его уведомления уже отправлено
+type progressEntry struct {
+	Recipient int             `json:"r"`
+	Part      int             `json:"p,omitempty"`
+	Outcome   deliveryOutcome `json:"o,omitempty"`
+}
+
+// apply учитывает строку журнала в прогрессе рассылки
+func (job *BroadcastJob) apply(entry progressEntry) {
+	if entry.Outcome == "" {
+		job.Next, job.Part = entry.Recipient, entry.Part
+		return
+	}
+	switch entry.Outcome {
+	case deliverySent:
+		job.Sent++
+	case deliveryBlocked:
+		job.Blocked++
+	default:
+		job.Failed++
+	}
+	job.Next, job.Part = entry.Recipient+1, 0
+}
+
+// deliver отправляет сообщение рассылки одному получателю с повторами при 429 и временных ошибках.
+// Если уведомление состоит из нескольких сообщений, отправка начинается с части job.Part и
+// повторяется только неотправленная часть; sentPart сообщает, сколько частей уже доставлено.
+func (q *BroadcastQueue) deliver(job BroadcastJob, userID int64, stop <-chan struct{}, sentPart func(part int)) deliveryOutcome {
+	parts := RichMessages(userID, job.MessageFor(userID), job.ParseMode, job.RichContent)
+	part := min(job.Part, len(parts)-1)
+	for attempt := 1; ; attempt++ {
+		if !q.limiter.Wait(stop) {
+			return deliveryInterrupted
+		}
+
+		_, err := q.sender.Send(parts[part])
+		if err == nil {
+			part++
+			if part == len(parts) {
+				return deliverySent
+			}
+			sentPart(part)
+			attempt = 0
+			continue
+		}
+
+		failure := ClassifySendError(err)
+		switch failure.Kind {
+		case SendErrorUnreachable:
+			log.Printf("🚫 Пользователь %d недоступен для рассылки %s (%s): %v", userID, job.ID, failure.Reason, err)
+			if q.onUnreachable != nil {
+				q.onUnreachable(userID, failure.Reason)
+			}
+			return deliveryBlocked
+		case SendErrorRateLimited:
+			// Telegram просит подождать: останавливаем всю очередь, а не только этого получателя
+			log.Printf("⏳ Лимит Telegram, пауза %s (рассылка %s)", failure.RetryAfter, job.ID)
+			q.limiter.Pause(failure.RetryAfter)
+		case SendErrorTemporary:
+			if attempt < broadcastMaxAttempts {
+				q.limiter.Pause(time.Duration(attempt) * time.Second)
+			}
+		default:
+			log.Printf("❌ Ошибка отправки рассылки %s пользователю %d: %v", job.ID, userID, err)
+			return deliveryFailed
+		}
+
+		if attempt >= broadcastMaxAttempts {
+			log.Printf("❌ Не удалось отправить рассылку %s пользователю %d после %d попыток: %v", job.ID, userID, attempt, err)
+			return deliveryFailed
+		}
+	}
+}
+
+// nextPending возвращает самую старую незавершенную рассылку
+func (q *BroadcastQueue) nextPending() (BroadcastJob, bool, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	store, err := q.load()
+	if err != nil {
+		return BroadcastJob{}, false, err
+	}
+	for _, job := range store.Jobs {
+		if job.Status == BroadcastPending {
+			return job, true, nil
+		}
+	}
+	return BroadcastJob{}, false, nil
+}
+
+// saveProgress сохраняет состояние рассылки и удаляет старые завершенные
+func (q *BroadcastQueue) saveProgress(job BroadcastJob) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	store, err := q.load()
+	if err != nil {
+		log.Printf("❌ Ошибка сохранения прогресса рассылки %s: %v", job.ID, err)
+		return
+	}
+
+	done := 0
+	for i := len(store.Jobs) - 1; i >= 0; i-- {
+		if store.Jobs[i].ID == job.ID {
+			store.Jobs[i] = job
+		}
+		if store.Jobs[i].Status == BroadcastDone {
+			done++
+			if done > broadcastHistory {
+				store.Jobs = append(store.Jobs[:i], store.Jobs[i+1:]...)
+			}
+		}
+	}
+
+	if err := q.save(store); err != nil {
+		log.Printf("❌ Ошибка сохранения прогресса рассылки %s: %v", job.ID, err)
+	}
+}
+
+// load загружает рассылки из JSON файла
+func (q *BroadcastQueue) load() (broadcastStore, error) {
+	var store broadcastStore
+
+	data, err := os.ReadFile(q.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return store, nil
+		}
+		return store, fmt.Errorf("failed to read broadcasts file: %w", err)
+	}
+	if err := json.Unmarshal(data, &store); err != nil {
+		return store, fmt.Errorf("failed to parse broadcasts file: %w", err)
+	}
+	for i := range store.Jobs {
+		if store.Jobs[i].Status == BroadcastPending {
+			if err := q.replayProgress(&store.Jobs[i]); err != nil {
+				return store, err
+			}
+		}
+	}
+	return store, nil
+}
+
+// progressFile путь к журналу прогресса рассылки
+func (q *BroadcastQueue) progressFile(id string) string {
+	return filepath.Join(filepath.Dir(q.filePath), "broadcast_"+id+".progress")
+}
+
+// writeProgress дописывает строку в журнал рассылки
+func writeProgress(w io.Writer, entry progressEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// replayProgress восстанавливает прогресс рассылки по журналу. Оборванная при
+// перезапуске последняя строка пропускается.
+func (q *BroadcastQueue) replayProgress(job *BroadcastJob) error {
+	data, err := os.ReadFile(q.progressFile(job.ID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read broadcast progress: %w", err)
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var entry progressEntry
+		if len(line) == 0 || json.Unmarshal(line, &entry) != nil {
+			continue
+		}
+		if entry.Recipient >= job.Next {
+			job.apply(entry)
+		}
+	}
+	return nil
+}
+
+// save сохраняет рассылки в JSON файл
+func (q *BroadcastQueue) save(store broadcastStore) error {
+	data, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal broadcasts: %w", err)
+	}
+
+	// Пишем во временный файл и переименовываем: перезапуск посреди записи не обрежет очередь
+	tmpFile := q.filePath + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write broadcasts: %w", err)
+	}
+	if err := os.Rename(tmpFile, q.filePath); err != nil {
+		return fmt.Errorf("failed to replace broadcasts file: %w", err)
+	}
+	return nil
+}
+
+// uniqueIDs убирает повторяющихся получателей, сохраняя порядок
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	result := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
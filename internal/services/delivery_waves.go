@@ -0,0 +1,137 @@
+package services
+
+import (
+	"log"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+)
+
+// localTimeSpread на сколько позже ScheduleZone наступает то же время суток в самом западном поясе (UTC-12)
+const localTimeSpread = 17 * time.Hour
+
+// earliestZone самый восточный пояс: раньше этого момента ни одной волне отправлять нечего
+var earliestZone = time.FixedZone("UTC+14", 14*60*60)
+
+// DeliveryWave получатели одного часового пояса, которым уведомление отправляется одновременно
+type DeliveryWave struct {
+	Zone    string
+	At      time.Time
+	UserIDs []int64
+}
+
+// PlanWaves группирует пользователей по часовым поясам. Время суток берется из wall в ScheduleZone
+// и для каждой волны отсчитывается в поясе ее получателей. Волны упорядочены по времени отправки.
+func PlanWaves(wall time.Time, users []models.UserInfo) []DeliveryWave {
+	byZone := make(map[string]*DeliveryWave)
+	for _, user := range users {
+		loc := user.Location()
+		wave, ok := byZone[loc.String()]
+		if !ok {
+			wave = &DeliveryWave{Zone: loc.String(), At: wallClockIn(wall, loc)}
+			byZone[loc.String()] = wave
+		}
+		wave.UserIDs = append(wave.UserIDs, user.UserID)
+	}
+
+	waves := make([]DeliveryWave, 0, len(byZone))
+	for _, wave := range byZone {
+		waves = append(waves, *wave)
+	}
+	sort.Slice(waves, func(i, j int) bool {
+		if !waves[i].At.Equal(waves[j].At) {
+			return waves[i].At.Before(waves[j].At)
+		}
+		return waves[i].Zone < waves[j].Zone
+	})
+	return waves
+}
+
+// wallClockIn переносит дату и время суток wall (в ScheduleZone) в пояс loc
+func wallClockIn(wall time.Time, loc *time.Location) time.Time {
+	w := wall.In(ScheduleZone)
+	return time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), 0, 0, loc)
+}
+
+// dueWaves возвращает наступившие и еще не отправленные волны уведомления по местному времени
+// и признак, что остались волны на будущее
+func (ns *NotificationService) dueWaves(it ScheduledNotification, now time.Time) ([]DeliveryWave, bool, error) {
+	if now.Before(wallClockIn(it.SendAt, earliestZone)) {
+		return nil, true, nil
+	}
+
+	recipients, ok := ns.jobRecipients(it)
+	if !ok {
+		// Отправлять некому: считаем запуск выполненным
+		return nil, false, nil
+	}
+	users, err := ns.recipientUsers(recipients)
+	if err != nil {
+		return nil, true, err
+	}
+
+	var due []DeliveryWave
+	pending := false
+	for _, wave := range PlanWaves(it.SendAt, users) {
+		if slices.Contains(it.SentZones, wave.Zone) {
+			continue
+		}
+		if wave.At.After(now) {
+			pending = true
+			continue
+		}
+		due = append(due, wave)
+	}
+	return due, pending, nil
+}
+
+// deliverWaves ставит в очередь рассылки наступивших волн, текст один на все волны
+func (ns *NotificationService) deliverWaves(it ScheduledNotification, waves []DeliveryWave) {
+	if len(waves) == 0 {
+		return
+	}
+	message, err := ns.scheduledMessage(it)
+	if err != nil {
+		log.Printf("❌ Не удалось сгенерировать уведомление %s: %v", it.ID, err)
+		return
+	}
+	for _, wave := range waves {
+		d := delivery{typ: it.Type, message: message, rich: ns.scheduledRich(it), recipients: wave.UserIDs, reminder: true, personal: it.Personalized, background: true}
+		if _, err := ns.deliver(d); err != nil {
+			log.Printf("❌ Ошибка отправки уведомления %s поясу %s: %v", it.ID, wave.Zone, err)
+		} else {
+			log.Printf("🌍 Уведомление %s отправлено поясу %s: %d получателей", it.ID, wave.Zone, len(wave.UserIDs))
+		}
+	}
+}
+
+// recipientUsers возвращает активных получателей уведомления (всех активных, если список пуст)
+func (ns *NotificationService) recipientUsers(recipients []int64) ([]models.UserInfo, error) {
+	if len(recipients) == 0 {
+		return ns.userStorage.GetAllActiveUsers()
+	}
+
+	all, err := ns.userStorage.GetAllUsers()
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[int64]models.UserInfo, len(all))
+	for _, user := range all {
+		known[user.UserID] = user
+	}
+
+	users := make([]models.UserInfo, 0, len(recipients))
+	for _, userID := range uniqueIDs(recipients) {
+		user, ok := known[userID]
+		if !ok {
+			// Незарегистрированный получатель получает уведомление по поясу по умолчанию
+			user = models.UserInfo{UserID: userID, IsActive: true}
+		}
+		if user.IsActive {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
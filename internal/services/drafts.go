@@ -0,0 +1,269 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+)
+
+// draftTTL сколько хранится незавершенный черновик
+const draftTTL = 7 * 24 * time.Hour
+
+// NotificationDraft черновик кастомного уведомления. Текст, дата и время хранятся на сервере,
+// в callback data передается только короткий ID черновика.
+type NotificationDraft struct {
+	ID        string    `json:"id"`
+	AdminID   int64     `json:"admin_id"`
+	Text      string    `json:"text"`           // текст в HTML разметке Telegram
+	Date      string    `json:"date,omitempty"` // ДД.ММ.ГГГГ
+	Time      string    `json:"time,omitempty"` // ЧЧ:ММ (UTC+5)
+	CreatedAt time.Time `json:"created_at"`
+
+	models.RichContent // фото или документ и кнопки уведомления
+}
+
+// HasSendTime проверяет, что дата и время отправки выбраны
+func (d NotificationDraft) HasSendTime() bool {
+	return d.Date != "" && d.Time != ""
+}
+
+// SendAt возвращает момент отправки черновика
+func (d NotificationDraft) SendAt() (time.Time, error) {
+	if !d.HasSendTime() {
+		return time.Time{}, fmt.Errorf("время отправки не выбрано")
+	}
+	sendAt, err := time.ParseInLocation("02.01.2006 15:04", d.Date+" "+d.Time, ScheduleZone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("неверные дата или время отправки: %s %s", d.Date, d.Time)
+	}
+	return sendAt, nil
+}
+
+// IsEmpty проверяет, что в черновике нечего отправлять
+func (d NotificationDraft) IsEmpty() bool {
+	return d.Text == "" && d.Media == nil
+}
+
+// SetSendAt задает дату и время отправки
+func (d *NotificationDraft) SetSendAt(at time.Time) {
+	at = at.In(ScheduleZone)
+	d.Date = at.Format("02.01.2006")
+	d.Time = at.Format("15:04")
+}
+
+// draftStore содержимое файла черновиков
+type draftStore struct {
+	Next  int                 `json:"next"`
+	Items []NotificationDraft `json:"items"`
+}
+
+// draftFile путь к файлу черновиков
+func (ns *NotificationService) draftFile() string {
+	return filepath.Join(ns.dataDir, "drafts.json")
+}
+
+// loadDrafts загружает черновики (вызывается под draftMu)
+func (ns *NotificationService) loadDrafts() (draftStore, error) {
+	var store draftStore
+	data, err := os.ReadFile(ns.draftFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return store, nil
+		}
+		return store, err
+	}
+	err = json.Unmarshal(data, &store)
+	return store, err
+}
+
+// saveDrafts сохраняет черновики, удаляя устаревшие (вызывается под draftMu)
+func (ns *NotificationService) saveDrafts(store draftStore) error {
+	cutoff := time.Now().Add(-draftTTL)
+	items := store.Items[:0]
+	for _, draft := range store.Items {
+		if draft.CreatedAt.After(cutoff) {
+			items = append(items, draft)
+		}
+	}
+	store.Items = items
+
+	data, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Черновик берется ровно один раз, поэтому файл не должен обрезаться при перезапуске
+	return models.WriteFileAtomic(ns.draftFile(), data)
+}
+
+// CreateDraft сохраняет новый черновик кастомного уведомления (date и sendTime могут быть пустыми)
+func (ns *NotificationService) CreateDraft(adminID int64, text, date, sendTime string) (NotificationDraft, error) {
+	ns.draftMu.Lock()
+	defer ns.draftMu.Unlock()
+
+	store, err := ns.loadDrafts()
+	if err != nil {
+		return NotificationDraft{}, err
+	}
+	store.Next++
+	draft := NotificationDraft{
+		ID:        fmt.Sprintf("d%d", store.Next),
+		AdminID:   adminID,
+		Text:      text,
+		Date:      date,
+		Time:      sendTime,
+		CreatedAt: time.Now(),
+	}
+	store.Items = append(store.Items, draft)
+	if err := ns.saveDrafts(store); err != nil {
+		return NotificationDraft{}, err
+	}
+	return draft, nil
+}
+
+// GetDraft возвращает черновик по ID
+func (ns *NotificationService) GetDraft(id string) (NotificationDraft, error) {
+	ns.draftMu.Lock()
+	defer ns.draftMu.Unlock()
+
+	store, err := ns.loadDrafts()
+	if err != nil {
+		return NotificationDraft{}, err
+	}
+	for _, draft := range store.Items {
+		if draft.ID == id {
+			return draft, nil
+		}
+	}
+	return NotificationDraft{}, fmt.Errorf("черновик %s не найден или устарел", id)
+}
+
+// UpdateDraft сохраняет изменения черновика
+func (ns *NotificationService) UpdateDraft(draft NotificationDraft) error {
+	ns.draftMu.Lock()
+	defer ns.draftMu.Unlock()
+
+	store, err := ns.loadDrafts()
+	if err != nil {
+		return err
+	}
+	for i := range store.Items {
+		if store.Items[i].ID == draft.ID {
+			store.Items[i] = draft
+			return ns.saveDrafts(store)
+		}
+	}
+	return fmt.Errorf("черновик %s не найден или устарел", draft.ID)
+}
+
+// DeleteDraft удаляет черновик
+func (ns *NotificationService) DeleteDraft(id string) error {
+	ns.draftMu.Lock()
+	defer ns.draftMu.Unlock()
+
+	store, err := ns.loadDrafts()
+	if err != nil {
+		return err
+	}
+	for i := range store.Items {
+		if store.Items[i].ID == id {
+			store.Items = append(store.Items[:i], store.Items[i+1:]...)
+			return ns.saveDrafts(store)
+		}
+	}
+	return nil
+}
+
+// TakeDraft находит, удаляет и возвращает черновик за одно взятие блокировки.
+// Из параллельных вызовов с одним ID черновик получает только первый, остальные - ошибку.
+func (ns *NotificationService) TakeDraft(id string) (NotificationDraft, error) {
+	ns.draftMu.Lock()
+	defer ns.draftMu.Unlock()
+
+	store, err := ns.loadDrafts()
+	if err != nil {
+		return NotificationDraft{}, err
+	}
+	for i := range store.Items {
+		if store.Items[i].ID == id {
+			draft := store.Items[i]
+			store.Items = append(store.Items[:i], store.Items[i+1:]...)
+			if err := ns.saveDrafts(store); err != nil {
+				return NotificationDraft{}, err
+			}
+			return draft, nil
+		}
+	}
+	return NotificationDraft{}, fmt.Errorf("черновик %s не найден или уже отправлен", id)
+}
+
+// restoreDraft возвращает взятый черновик, если отправить его не удалось
+func (ns *NotificationService) restoreDraft(draft NotificationDraft) error {
+	ns.draftMu.Lock()
+	defer ns.draftMu.Unlock()
+
+	store, err := ns.loadDrafts()
+	if err != nil {
+		return err
+	}
+	store.Items = append(store.Items, draft)
+	return ns.saveDrafts(store)
+}
+
+// ScheduleDraft планирует кастомное уведомление из черновика.
+// Черновик забирается до планирования, чтобы повторное нажатие не создало второе задание;
+// если запланировать не удалось, черновик возвращается для исправления.
+func (ns *NotificationService) ScheduleDraft(id string, now time.Time) (string, time.Time, error) {
+	draft, err := ns.TakeDraft(id)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	item, sendAt, err := ns.scheduleDraft(draft, now)
+	if err != nil {
+		if restoreErr := ns.restoreDraft(draft); restoreErr != nil {
+			return "", time.Time{}, fmt.Errorf("%w (черновик не восстановлен: %v)", err, restoreErr)
+		}
+		return "", time.Time{}, err
+	}
+	return item.ID, sendAt, nil
+}
+
+// scheduleDraft проверяет взятый черновик и создает задание отправки
+func (ns *NotificationService) scheduleDraft(draft NotificationDraft, now time.Time) (ScheduledNotification, time.Time, error) {
+	if draft.IsEmpty() {
+		return ScheduledNotification{}, time.Time{}, fmt.Errorf("в черновике нет ни текста, ни вложения")
+	}
+	sendAt, err := draft.SendAt()
+	if err != nil {
+		return ScheduledNotification{}, time.Time{}, err
+	}
+	if !sendAt.After(now) {
+		return ScheduledNotification{}, time.Time{}, fmt.Errorf("время отправки %s %s уже прошло", draft.Date, draft.Time)
+	}
+
+	item, err := ns.Schedule(sendAt.UTC(), models.NotificationCustom, ScheduleOptions{
+		CustomText: draft.Text,
+		Rich:       draft.RichContent,
+	})
+	return item, sendAt, err
+}
+
+// SendDraftNow сразу рассылает уведомление из черновика всем пользователям и ждет отчета.
+// Черновик забирается до отправки, чтобы повторное нажатие не запустило вторую рассылку.
+func (ns *NotificationService) SendDraftNow(id string, requestedBy int64) (*BroadcastReport, error) {
+	draft, err := ns.TakeDraft(id)
+	if err != nil {
+		return nil, err
+	}
+	if draft.IsEmpty() {
+		if err := ns.restoreDraft(draft); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("в черновике нет ни текста, ни вложения")
+	}
+	return ns.BroadcastRich(models.NotificationCustom, draft.Text, draft.RichContent, nil, requestedBy)
+}
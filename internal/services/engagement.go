@@ -0,0 +1,213 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+)
+
+// Действия получателя после уведомления
+const (
+	EngagementOpen  = "open"  // зашел в бота
+	EngagementDiary = "diary" // сделал запись в дневнике
+	EngagementClick = "click" // нажал кнопку под уведомлением
+)
+
+const (
+	// EngagementMaxWindow позже этого действия не связываются с уведомлением
+	EngagementMaxWindow = 72 * time.Hour
+	// engagementKeep сколько хранятся сведения о доставках
+	engagementKeep = 60 * 24 * time.Hour
+)
+
+// EngagementRecord доставка уведомления получателю и время его первых действий после нее
+type EngagementRecord struct {
+	UserID    int64                   `json:"user_id"`
+	Type      models.NotificationType `json:"type"`
+	Variant   string                  `json:"variant"`
+	SentAt    time.Time               `json:"sent_at"`
+	OpenedAt  *time.Time              `json:"opened_at,omitempty"`
+	DiaryAt   *time.Time              `json:"diary_at,omitempty"`
+	ClickedAt *time.Time              `json:"clicked_at,omitempty"`
+}
+
+// within проверяет, что действие at произошло в пределах window после отправки
+func (r EngagementRecord) within(at *time.Time, window time.Duration) bool {
+	return at != nil && at.Sub(r.SentAt) <= window
+}
+
+// VariantEngagement вовлеченность получателей одного варианта шаблона
+type VariantEngagement struct {
+	Type    models.NotificationType
+	Variant string
+	Sent    int // доставки, для которых окно наблюдения уже закончилось
+	Opened  int
+	Diary   int
+	Clicked int
+	Pending int // доставки, окно наблюдения которых еще идет
+}
+
+// Rate доля получателей, совершивших действие, в процентах
+func (v VariantEngagement) Rate(count int) float64 {
+	if v.Sent == 0 {
+		return 0
+	}
+	return float64(count) * 100 / float64(v.Sent)
+}
+
+// EngagementTracker запоминает, кому какой вариант уведомления доставлен, и отмечает
+// действия получателей после доставки. Данные держатся в памяти и сохраняются в JSON файл.
+type EngagementTracker struct {
+	mutex    sync.Mutex
+	filePath string
+	records  []EngagementRecord
+	latest   map[int64]int // индекс последней доставки пользователю
+}
+
+// NewEngagementTracker создает трекер и загружает сохраненные доставки
+func NewEngagementTracker(dataDir string) *EngagementTracker {
+	t := &EngagementTracker{filePath: filepath.Join(dataDir, "engagement.json")}
+	if data, err := os.ReadFile(t.filePath); err == nil {
+		json.Unmarshal(data, &t.records)
+	}
+	t.reindex()
+	return t
+}
+
+// RecordSent отмечает доставку уведомления типа typ: variants - вариант шаблона каждого получателя
+func (t *EngagementTracker) RecordSent(typ models.NotificationType, variants map[int64]string, at time.Time) error {
+	if len(variants) == 0 {
+		return nil
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// Старые доставки убираем при записи, чтобы файл не рос бесконечно
+	cutoff := at.Add(-engagementKeep)
+	records := t.records[:0]
+	for _, record := range t.records {
+		if record.SentAt.After(cutoff) {
+			records = append(records, record)
+		}
+	}
+	t.records = records
+
+	userIDs := make([]int64, 0, len(variants))
+	for userID := range variants {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Slice(userIDs, func(i, j int) bool { return userIDs[i] < userIDs[j] })
+	for _, userID := range userIDs {
+		t.records = append(t.records, EngagementRecord{UserID: userID, Type: typ, Variant: variants[userID], SentAt: at})
+	}
+	t.reindex()
+	return t.save()
+}
+
+// RecordEvent отмечает действие пользователя. Действие засчитывается последнему уведомлению,
+// доставленному ему не раньше чем за EngagementMaxWindow.
+func (t *EngagementTracker) RecordEvent(userID int64, kind string, at time.Time) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	i, ok := t.latest[userID]
+	if !ok {
+		return nil
+	}
+	record := &t.records[i]
+	if at.Before(record.SentAt) || at.Sub(record.SentAt) > EngagementMaxWindow {
+		return nil
+	}
+
+	var field **time.Time
+	switch kind {
+	case EngagementOpen:
+		field = &record.OpenedAt
+	case EngagementDiary:
+		field = &record.DiaryAt
+	case EngagementClick:
+		field = &record.ClickedAt
+	default:
+		return fmt.Errorf("неизвестное действие: %s", kind)
+	}
+	// Запоминаем только первое действие каждого вида
+	if *field != nil {
+		return nil
+	}
+	*field = &at
+	return t.save()
+}
+
+// Report считает вовлеченность по типам и вариантам: действие засчитывается, если оно
+// произошло в течение window после доставки
+func (t *EngagementTracker) Report(window time.Duration, now time.Time) []VariantEngagement {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	type key struct {
+		typ     models.NotificationType
+		variant string
+	}
+	byKey := make(map[key]*VariantEngagement)
+	var order []key
+	for _, record := range t.records {
+		k := key{record.Type, record.Variant}
+		stats, ok := byKey[k]
+		if !ok {
+			stats = &VariantEngagement{Type: record.Type, Variant: record.Variant}
+			byKey[k] = stats
+			order = append(order, k)
+		}
+		if now.Sub(record.SentAt) < window {
+			stats.Pending++
+			continue
+		}
+		stats.Sent++
+		if record.within(record.OpenedAt, window) {
+			stats.Opened++
+		}
+		if record.within(record.DiaryAt, window) {
+			stats.Diary++
+		}
+		if record.within(record.ClickedAt, window) {
+			stats.Clicked++
+		}
+	}
+
+	sort.Slice(order, func(i, j int) bool {
+		if order[i].typ != order[j].typ {
+			return order[i].typ < order[j].typ
+		}
+		return order[i].variant < order[j].variant
+	})
+	report := make([]VariantEngagement, 0, len(order))
+	for _, k := range order {
+		report = append(report, *byKey[k])
+	}
+	return report
+}
+
+// reindex пересчитывает последнюю доставку каждому пользователю (вызывается под mutex)
+func (t *EngagementTracker) reindex() {
+	t.latest = make(map[int64]int)
+	for i, record := range t.records {
+		if j, ok := t.latest[record.UserID]; !ok || !record.SentAt.Before(t.records[j].SentAt) {
+			t.latest[record.UserID] = i
+		}
+	}
+}
+
+// save сохраняет доставки в файл (вызывается под mutex)
+func (t *EngagementTracker) save() error {
+	data, err := json.MarshalIndent(t.records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(t.filePath, data, 0644)
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// entityTags возвращает HTML теги для форматирования Telegram (пустые - не поддерживается)
+func entityTags(entity tgbotapi.MessageEntity) (string, string) {
+	switch entity.Type {
+	case "bold":
+		return "<b>", "</b>"
+	case "italic":
+		return "<i>", "</i>"
+	case "underline":
+		return "<u>", "</u>"
+	case "strikethrough":
+		return "<s>", "</s>"
+	case "spoiler":
+		return "<tg-spoiler>", "</tg-spoiler>"
+	case "code":
+		return "<code>", "</code>"
+	case "pre":
+		if entity.Language != "" {
+			return `<pre><code class="language-` + html.EscapeString(entity.Language) + `">`, "</code></pre>"
+		}
+		return "<pre>", "</pre>"
+	case "blockquote":
+		return "<blockquote>", "</blockquote>"
+	case "text_link":
+		return `<a href="` + html.EscapeString(entity.URL) + `">`, "</a>"
+	}
+	return "", ""
+}
+
+// FormatEntities переводит текст сообщения с форматированием Telegram в HTML разметку
+// для отправки с ParseMode HTML. Смещения сущностей считаются в UTF-16, как в Bot API.
+func FormatEntities(text string, entities []tgbotapi.MessageEntity) string {
+	type tag struct {
+		opening, closing string
+		start, end       int
+	}
+	var tags []tag
+	for _, entity := range entities {
+		opening, closing := entityTags(entity)
+		if opening == "" || entity.Length <= 0 {
+			continue
+		}
+		tags = append(tags, tag{opening: opening, closing: closing, start: entity.Offset, end: entity.Offset + entity.Length})
+	}
+	// Внешние сущности открываются раньше вложенных
+	sort.SliceStable(tags, func(i, j int) bool {
+		if tags[i].start != tags[j].start {
+			return tags[i].start < tags[j].start
+		}
+		return tags[i].end > tags[j].end
+	})
+
+	var b strings.Builder
+	var stack []tag
+	next, pos := 0, 0
+	closeUntil := func(pos int) {
+		for len(stack) > 0 && stack[len(stack)-1].end <= pos {
+			b.WriteString(stack[len(stack)-1].closing)
+			stack = stack[:len(stack)-1]
+		}
+	}
+	for _, r := range text {
+		closeUntil(pos)
+		for next < len(tags) && tags[next].start <= pos {
+			b.WriteString(tags[next].opening)
+			stack = append(stack, tags[next])
+			next++
+		}
+		b.WriteString(html.EscapeString(string(r)))
+		pos += utf16.RuneLen(r)
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		b.WriteString(stack[i].closing)
+	}
+	return b.String()
+}
+
+// MessageMedia возвращает фото (в наибольшем размере) или документ сообщения, nil - вложения нет
+func MessageMedia(message *tgbotapi.Message) *models.NotificationMedia {
+	switch {
+	case len(message.Photo) > 0:
+		return &models.NotificationMedia{Kind: models.MediaPhoto, FileID: message.Photo[len(message.Photo)-1].FileID}
+	case message.Document != nil:
+		return &models.NotificationMedia{Kind: models.MediaDocument, FileID: message.Document.FileID}
+	}
+	return nil
+}
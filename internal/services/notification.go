@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/godofphonk/lovifyy-bot/internal/ai"
@@ -14,17 +15,27 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+// DefaultBroadcastRate сообщений в секунду при рассылках (с запасом до лимита Telegram ~30)
+const DefaultBroadcastRate = 25
+
 // NotificationService управляет уведомлениями
 type NotificationService struct {
 	bot         *tgbotapi.BotAPI
-	ai          *ai.OpenAIClient
+	ai          ai.AIClient
 	templates   []models.NotificationTemplate
+	templatesMu sync.RWMutex // защищает templates: планировщик читает шаблоны, пока администратор их меняет
 	dataDir     string
 	userStorage *models.UserStorage
+	segments    *models.SegmentStorage
+	audience    AudienceSource
+	personal    personalCache
+	engagement  *EngagementTracker
+	broadcasts  *BroadcastQueue
+	draftMu     sync.Mutex // защищает файл черновиков этого сервиса
 }
 
 // NewNotificationService создает новый сервис уведомлений
-func NewNotificationService(bot *tgbotapi.BotAPI, ai *ai.OpenAIClient) *NotificationService {
+func NewNotificationService(bot *tgbotapi.BotAPI, ai ai.AIClient) *NotificationService {
 	dataDir := "data/notifications"
 	service := &NotificationService{
 		bot:         bot,
@@ -32,10 +43,16 @@ func NewNotificationService(bot *tgbotapi.BotAPI, ai *ai.OpenAIClient) *Notifica
 		templates:   models.GetDefaultTemplates(),
 		dataDir:     dataDir,
 		userStorage: models.NewUserStorage("data"),
+		segments:    models.NewSegmentStorage(dataDir),
 	}
 	
 	// Создаем директорию для данных
 	os.MkdirAll(service.dataDir, 0755)
+	service.engagement = NewEngagementTracker(dataDir)
+
+	service.broadcasts = NewBroadcastQueue(bot, NewTokenBucket(DefaultBroadcastRate, DefaultBroadcastRate), dataDir)
+	service.broadcasts.SetReportHandler(service.reportResumedBroadcast)
+	service.broadcasts.SetUnreachableHandler(service.deactivateUnreachable)
 	
 	// Загружаем шаблоны из файла
 	service.loadTemplates()
@@ -45,6 +62,9 @@ func NewNotificationService(bot *tgbotapi.BotAPI, ai *ai.OpenAIClient) *Notifica
 
 // loadTemplates загружает шаблоны из файла
 func (ns *NotificationService) loadTemplates() {
+	ns.templatesMu.Lock()
+	defer ns.templatesMu.Unlock()
+
 	filePath := filepath.Join(ns.dataDir, "templates.json")
 	
 	data, err := os.ReadFile(filePath)
@@ -63,7 +83,7 @@ func (ns *NotificationService) loadTemplates() {
 	ns.templates = templates
 }
 
-// saveTemplates сохраняет шаблоны в файл
+// saveTemplates сохраняет шаблоны в файл (вызывается под templatesMu)
 func (ns *NotificationService) saveTemplates() {
 	filePath := filepath.Join(ns.dataDir, "templates.json")
 	
@@ -81,115 +101,296 @@ func (ns *NotificationService) saveTemplates() {
 // GenerateNotification генерирует уведомление с помощью AI
 func (ns *NotificationService) GenerateNotification(notificationType models.NotificationType) (string, error) {
 	// Находим шаблон для данного типа
-	var template *models.NotificationTemplate
-	for i := range ns.templates {
-		if ns.templates[i].Type == notificationType && ns.templates[i].IsActive {
-			template = &ns.templates[i]
-			break
-		}
-	}
-	
+	template := ns.activeTemplate(notificationType)
 	if template == nil {
 		return "", fmt.Errorf("шаблон для типа %s не найден или неактивен", notificationType)
 	}
-	
+	return ns.generate(template)
+}
+
+// generate генерирует текст уведомления по шаблону
+func (ns *NotificationService) generate(template *models.NotificationTemplate) (string, error) {
+	notificationType := template.Type
+	if ns.ai == nil {
+		if fallback := models.FallbackNotificationText(notificationType); fallback != "" {
+			return fallback, nil
+		}
+		return "", fmt.Errorf("AI сервис недоступен")
+	}
+
 	// Генерируем сообщение с помощью AI
 	response, err := ns.ai.Generate(template.Prompt)
 	if err != nil {
+		// При недоступности AI отправляем готовый текст
+		if fallback := models.FallbackNotificationText(notificationType); fallback != "" && ai.IsUnavailable(err) {
+			log.Printf("⚠️ AI недоступен, используем запасной текст уведомления %s: %v", notificationType, err)
+			return fallback, nil
+		}
 		return "", fmt.Errorf("ошибка генерации уведомления: %v", err)
 	}
 	
 	return response, nil
 }
 
-// SendNotificationToAll отправляет уведомление всем пользователям
-func (ns *NotificationService) SendNotificationToAll(message string) error {
-	log.Printf("📢 Отправка уведомления всем пользователям: %s", message)
-	
-	// Получаем список всех активных пользователей из JSON файла
-	userIDs, err := ns.userStorage.GetUserIDs()
-	if err != nil {
-		log.Printf("❌ Ошибка получения списка пользователей: %v", err)
-		// Fallback: отправляем только админам
-		userIDs = []int64{1805441944, 1243795198} // ID админов
+// activeTemplate возвращает копию активного шаблона для типа (nil, если его нет)
+func (ns *NotificationService) activeTemplate(notificationType models.NotificationType) *models.NotificationTemplate {
+	ns.templatesMu.RLock()
+	defer ns.templatesMu.RUnlock()
+
+	for _, template := range ns.templates {
+		if template.Type == notificationType && template.IsActive {
+			return &template
+		}
 	}
-	
-	if len(userIDs) == 0 {
-		log.Printf("⚠️ Нет активных пользователей для отправки уведомлений")
-		return nil
+	return nil
+}
+
+// templateRich возвращает медиа и кнопки активного шаблона типа (пусто для кастомных уведомлений)
+func (ns *NotificationService) templateRich(notificationType models.NotificationType) models.RichContent {
+	if template := ns.activeTemplate(notificationType); template != nil {
+		return template.RichContent
 	}
-	
-	successCount := 0
-	errorCount := 0
-	
-	for _, userID := range userIDs {
-		msg := tgbotapi.NewMessage(userID, message)
-		msg.ParseMode = "HTML"
-		
-		_, err := ns.bot.Send(msg)
-		if err != nil {
-			log.Printf("❌ Ошибка отправки уведомления пользователю %d: %v", userID, err)
-			errorCount++
-			continue
+	return models.RichContent{}
+}
+
+// SetTemplateRich задает медиа и кнопки, которые прикладываются к уведомлениям шаблона (всем вариантам)
+func (ns *NotificationService) SetTemplateRich(notificationType models.NotificationType, rich models.RichContent) error {
+	ns.templatesMu.Lock()
+	defer ns.templatesMu.Unlock()
+
+	found := false
+	for i := range ns.templates {
+		if ns.templates[i].Type == notificationType {
+			ns.templates[i].RichContent = rich
+			ns.templates[i].UpdatedAt = time.Now()
+			found = true
 		}
-		log.Printf("✅ Уведомление отправлено пользователю %d", userID)
-		successCount++
 	}
-	
-	log.Printf("📊 Статистика отправки: успешно %d, ошибок %d, всего %d", successCount, errorCount, len(userIDs))
+	if !found {
+		return fmt.Errorf("шаблон для типа %s не найден", notificationType)
+	}
+	ns.saveTemplates()
 	return nil
 }
 
+// SetBroadcastRate задает частоту отправки сообщений (вызывается до StartBroadcasts)
+func (ns *NotificationService) SetBroadcastRate(perSecond int) {
+	if perSecond > 0 {
+		ns.broadcasts.limiter = NewTokenBucket(float64(perSecond), perSecond)
+	}
+}
+
+// StartBroadcasts обрабатывает очередь рассылок до закрытия stop,
+// продолжая рассылки, прерванные перезапуском
+func (ns *NotificationService) StartBroadcasts(stop <-chan struct{}) {
+	ns.broadcasts.Run(stop)
+}
+
+// Broadcast рассылает уведомление типа typ с учетом настроек получателей и ждет отчета о доставке.
+// Пустой список получателей - всем активным пользователям.
+func (ns *NotificationService) Broadcast(typ models.NotificationType, message string, recipients []int64, requestedBy int64) (*BroadcastReport, error) {
+	return ns.deliver(delivery{typ: typ, message: message, rich: ns.templateRich(typ), recipients: recipients, requestedBy: requestedBy})
+}
+
+// BroadcastRich рассылает уведомление с медиа и кнопками: текст становится подписью к медиа
+func (ns *NotificationService) BroadcastRich(typ models.NotificationType, message string, rich models.RichContent, recipients []int64, requestedBy int64) (*BroadcastReport, error) {
+	return ns.deliver(delivery{typ: typ, message: message, rich: rich, recipients: recipients, requestedBy: requestedBy})
+}
+
+// BroadcastPersonalized рассылает уведомление типа typ, генерируя для каждого получателя
+// персональный текст по его прогрессу. message - общий текст для тех, кому персональный
+// текст получить не удалось.
+func (ns *NotificationService) BroadcastPersonalized(typ models.NotificationType, message string, recipients []int64, requestedBy int64) (*BroadcastReport, error) {
+	return ns.deliver(delivery{typ: typ, message: message, rich: ns.templateRich(typ), recipients: recipients, requestedBy: requestedBy, personal: true})
+}
+
+// delivery параметры отправки уведомления
+type delivery struct {
+	typ         models.NotificationType
+	message     string
+	rich        models.RichContent // медиа и кнопки уведомления
+	recipients  []int64            // пусто - всем активным пользователям
+	requestedBy int64
+	reminder    bool   // запланированное напоминание: учитывается выбранное пользователем время
+	personal    bool   // сгенерировать каждому получателю персональный текст
+	variant     string // вариант шаблона уже выбран (отложенные уведомления): тексты не генерируются заново
+	background  bool   // не ждать окончания рассылки: планировщик не простаивает, пока она идет
+}
+
+// deliver распределяет получателей по их настройкам: отключившие тип пропускаются,
+// попавшие в тихие часы (и ждущие выбранного времени для напоминаний) откладываются,
+// остальным уведомление ставится в очередь рассылок. Получатели уведомлений по шаблону
+// делятся между вариантами A/B теста, доставка каждому запоминается для отчета о вовлеченности.
+func (ns *NotificationService) deliver(d delivery) (*BroadcastReport, error) {
+	if !ns.broadcasts.Running() {
+		return nil, fmt.Errorf("очередь рассылок не запущена")
+	}
+
+	users, err := ns.recipientUsers(d.recipients)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения списка пользователей: %w", err)
+	}
+	plan := PlanDelivery(users, d.typ, d.message, time.Now(), d.reminder)
+
+	var messages, variants map[int64]string
+	if d.typ != models.NotificationCustom {
+		ids := append([]int64(nil), plan.Now...)
+		for _, item := range plan.Deferred {
+			ids = append(ids, item.UserID)
+		}
+		if d.variant != "" {
+			variants = make(map[int64]string, len(ids))
+			for _, userID := range ids {
+				variants[userID] = d.variant
+			}
+		} else {
+			if d.personal {
+				messages = ns.PersonalizeNotifications(d.typ, ids)
+			}
+			messages, variants = ns.applyVariants(d.typ, d.message, ids, messages)
+		}
+	}
+	for i := range plan.Deferred {
+		item := &plan.Deferred[i]
+		item.RichContent = d.rich
+		item.Variant = variants[item.UserID]
+		if text, ok := messages[item.UserID]; ok {
+			item.Message = text
+		}
+	}
+
+	if len(plan.Deferred) > 0 {
+		if err := ns.addDeferred(plan.Deferred); err != nil {
+			return nil, fmt.Errorf("ошибка сохранения отложенных уведомлений: %w", err)
+		}
+		log.Printf("🌙 Уведомление отложено для %d получателей", len(plan.Deferred))
+	}
+
+	report := &BroadcastReport{}
+	if len(plan.Now) > 0 {
+		if len(variants) > 0 {
+			sent := make(map[int64]string, len(plan.Now))
+			for _, userID := range plan.Now {
+				sent[userID] = variants[userID]
+			}
+			if err := ns.engagement.RecordSent(d.typ, sent, time.Now()); err != nil {
+				log.Printf("❌ Ошибка сохранения доставок уведомления %s: %v", d.typ, err)
+			}
+		}
+		if report, err = ns.enqueue(d.message, messages, d.rich, plan.Now, d.requestedBy, !d.background); err != nil {
+			return nil, err
+		}
+	} else if len(users) == 0 {
+		log.Printf("⚠️ Нет активных пользователей для отправки уведомлений")
+	}
+	report.Muted = plan.Muted
+	report.Deferred = len(plan.Deferred)
+	return report, nil
+}
+
+// enqueue ставит рассылку в очередь и, если wait, ждет отчета о доставке.
+// Без ожидания отчет содержит только ID рассылки и число получателей.
+func (ns *NotificationService) enqueue(message string, messages map[int64]string, rich models.RichContent, recipients []int64, requestedBy int64, wait bool) (*BroadcastReport, error) {
+	job, done, err := ns.broadcasts.Submit(BroadcastJob{
+		Message:     message,
+		Messages:    messages,
+		ParseMode:   "HTML",
+		Recipients:  recipients,
+		RequestedBy: requestedBy,
+		RichContent: rich,
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("📢 Рассылка %s поставлена в очередь: %d получателей", job.ID, len(job.Recipients))
+	if !wait {
+		return &BroadcastReport{ID: job.ID, Total: len(job.Recipients)}, nil
+	}
+
+	report := <-done
+	return &report, nil
+}
+
+// SendNotificationToAll отправляет уведомление всем пользователям
+func (ns *NotificationService) SendNotificationToAll(message string) (*BroadcastReport, error) {
+	return ns.Broadcast(models.NotificationCustom, message, nil, 0)
+}
+
 // SendCustomNotification отправляет кастомное уведомление всем пользователям
-func (ns *NotificationService) SendCustomNotification(message string) error {
-	// Используем существующий метод для отправки всем
-	return ns.SendNotificationToAll(message)
+func (ns *NotificationService) SendCustomNotification(message string, requestedBy int64) (*BroadcastReport, error) {
+	return ns.Broadcast(models.NotificationCustom, message, nil, requestedBy)
 }
 
 // SendNotificationToUser отправляет уведомление конкретному пользователю
 func (ns *NotificationService) SendNotificationToUser(userID int64, message string) error {
 	msg := tgbotapi.NewMessage(userID, message)
 	msg.ParseMode = "HTML"
-	
+
+	// Одиночные отправки делят лимит частоты с рассылками
+	ns.broadcasts.limiter.Wait(nil)
 	_, err := ns.bot.Send(msg)
 	if err != nil {
+		if failure := ClassifySendError(err); failure.Kind == SendErrorUnreachable {
+			ns.deactivateUnreachable(userID, failure.Reason)
+		}
 		return fmt.Errorf("ошибка отправки уведомления пользователю %d: %v", userID, err)
 	}
-	
+
 	log.Printf("📤 Уведомление отправлено пользователю %d", userID)
 	return nil
 }
 
+// deactivateUnreachable помечает неактивным пользователя, которому больше нельзя писать.
+// При следующем /start пользователь активируется снова.
+func (ns *NotificationService) deactivateUnreachable(userID int64, reason string) {
+	if err := ns.userStorage.DeactivateUser(userID, reason); err != nil {
+		log.Printf("❌ Ошибка деактивации пользователя %d: %v", userID, err)
+		return
+	}
+	log.Printf("💤 Пользователь %d деактивирован: %s", userID, models.InactiveReasonTitle(reason))
+}
+
+// GetChurnStats возвращает статистику оттока пользователей
+func (ns *NotificationService) GetChurnStats() (models.ChurnStats, error) {
+	return ns.userStorage.GetChurnStats(time.Now())
+}
+
+// reportResumedBroadcast отправляет отчет о рассылке, продолженной после перезапуска
+func (ns *NotificationService) reportResumedBroadcast(job BroadcastJob, report BroadcastReport) {
+	if job.RequestedBy == 0 {
+		return
+	}
+	msg := tgbotapi.NewMessage(job.RequestedBy, report.Summary())
+	if _, err := ns.bot.Send(msg); err != nil {
+		log.Printf("❌ Ошибка отправки отчета о рассылке %s: %v", job.ID, err)
+	}
+}
+
 // SendInstantNotification отправляет мгновенное уведомление
-func (ns *NotificationService) SendInstantNotification(notificationType models.NotificationType, recipients []int64) error {
+func (ns *NotificationService) SendInstantNotification(notificationType models.NotificationType, recipients []int64) (*BroadcastReport, error) {
 	// Генерируем сообщение
 	message, err := ns.GenerateNotification(notificationType)
 	if err != nil {
-		return fmt.Errorf("ошибка генерации уведомления: %v", err)
+		return nil, fmt.Errorf("ошибка генерации уведомления: %v", err)
 	}
-	
+
 	// Если получатели не указаны, отправляем всем
-	if len(recipients) == 0 {
-		return ns.SendNotificationToAll(message)
-	}
-	
-	// Отправляем конкретным пользователям
-	for _, userID := range recipients {
-		if err := ns.SendNotificationToUser(userID, message); err != nil {
-			log.Printf("❌ Ошибка отправки уведомления пользователю %d: %v", userID, err)
-		}
-	}
-	
-	return nil
+	return ns.Broadcast(notificationType, message, recipients, 0)
 }
 
-// GetTemplates возвращает все шаблоны
+// GetTemplates возвращает копию всех шаблонов
 func (ns *NotificationService) GetTemplates() []models.NotificationTemplate {
-	return ns.templates
+	ns.templatesMu.RLock()
+	defer ns.templatesMu.RUnlock()
+
+	return append([]models.NotificationTemplate(nil), ns.templates...)
 }
 
 // UpdateTemplate обновляет шаблон
 func (ns *NotificationService) UpdateTemplate(notificationType models.NotificationType, prompt string, isActive bool) error {
+	ns.templatesMu.Lock()
+	defer ns.templatesMu.Unlock()
+
 	for i := range ns.templates {
 		if ns.templates[i].Type == notificationType {
 			ns.templates[i].Prompt = prompt
@@ -205,6 +406,9 @@ func (ns *NotificationService) UpdateTemplate(notificationType models.Notificati
 
 // AddTemplate добавляет новый шаблон
 func (ns *NotificationService) AddTemplate(template models.NotificationTemplate) {
+	ns.templatesMu.Lock()
+	defer ns.templatesMu.Unlock()
+
 	template.CreatedAt = time.Now()
 	template.UpdatedAt = time.Now()
 	ns.templates = append(ns.templates, template)
@@ -218,6 +422,7 @@ func (ns *NotificationService) RegisterUser(userID int64, username string) error
 
 // UpdateUserActivity обновляет активность пользователя
 func (ns *NotificationService) UpdateUserActivity(userID int64) error {
+	ns.RecordEngagement(userID, EngagementOpen)
 	return ns.userStorage.UpdateLastSeen(userID)
 }
 
@@ -230,3 +435,16 @@ func (ns *NotificationService) GetUserCount() (int, error) {
 func (ns *NotificationService) GetAllUsers() ([]models.UserInfo, error) {
 	return ns.userStorage.GetAllActiveUsers()
 }
+
+// GetUser возвращает данные пользователя (nil, если он не зарегистрирован)
+func (ns *NotificationService) GetUser(userID int64) (*models.UserInfo, error) {
+	return ns.userStorage.GetUser(userID)
+}
+
+// SetUserTimezone проверяет и сохраняет часовой пояс пользователя
+func (ns *NotificationService) SetUserTimezone(userID int64, timezone string) error {
+	if _, err := models.LoadTimezone(timezone); err != nil {
+		return err
+	}
+	return ns.userStorage.SetTimezone(userID, timezone)
+}
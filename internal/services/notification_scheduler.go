@@ -3,8 +3,10 @@ package services
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 
@@ -12,13 +14,51 @@ import (
 )
 
 type ScheduledNotification struct {
-	ID         string                 `json:"id"`
-	Type       models.NotificationType `json:"type"`
-	SendAt     time.Time              `json:"send_at"`
-	Recipients []int64                `json:"recipients"`
-	CreatedAt  time.Time              `json:"created_at"`
-	Message    string                 `json:"message,omitempty"`    // Предварительно сгенерированное сообщение
-	CustomText string                 `json:"custom_text,omitempty"` // Кастомный текст для кастомных уведомлений
+	ID           string                  `json:"id"`
+	Type         models.NotificationType `json:"type"`
+	SendAt       time.Time               `json:"send_at"`
+	Recipients   []int64                 `json:"recipients"`
+	CreatedAt    time.Time               `json:"created_at"`
+	Message      string                  `json:"message,omitempty"`      // Предварительно сгенерированное сообщение
+	CustomText   string                  `json:"custom_text,omitempty"`  // Кастомный текст для кастомных уведомлений
+	Recurrence   *Recurrence             `json:"recurrence,omitempty"`   // Правило повторения (nil - однократно)
+	SentCount    int                     `json:"sent_count,omitempty"`   // Сколько раз уже отправлено
+	LastSentAt   *time.Time              `json:"last_sent_at,omitempty"`
+	LocalTime    bool                    `json:"local_time,omitempty"`   // SendAt задает время суток по местному времени каждого получателя
+	SentZones    []string                `json:"sent_zones,omitempty"`   // Пояса, которым уже отправлен текущий запуск
+	Segment      string                  `json:"segment,omitempty"`      // Сегмент аудитории, получатели отбираются в момент отправки
+	Personalized bool                    `json:"personalized,omitempty"` // Персональный текст для каждого получателя
+
+	models.RichContent // Медиа и кнопки (пусто - берутся из шаблона типа)
+}
+
+// advance переносит задачу на следующий запуск после отправки в момент now.
+// Возвращает false, если задача выполнена полностью и ее нужно удалить.
+func (it *ScheduledNotification) advance(now time.Time) bool {
+	it.SentCount++
+	it.LastSentAt = &now
+	if it.Recurrence == nil {
+		return false
+	}
+	if it.Recurrence.MaxCount > 0 && it.SentCount >= it.Recurrence.MaxCount {
+		return false
+	}
+
+	after := now
+	if it.LocalTime {
+		// Западные пояса получают запуск позже: не пропускаем запуски, которые для них еще актуальны
+		after = now.Add(-localTimeSpread)
+		it.SentZones = nil
+	}
+	if it.SendAt.After(after) {
+		after = it.SendAt
+	}
+	next, ok := it.Recurrence.Next(it.SendAt, after)
+	if !ok {
+		return false
+	}
+	it.SendAt = next
+	return true
 }
 
 // scheduler state
@@ -36,7 +76,11 @@ var schedMu sync.Mutex
 func (ns *NotificationService) LoadSchedule() ([]ScheduledNotification, error) {
 	schedMu.Lock()
 	defer schedMu.Unlock()
+	return ns.readSchedule()
+}
 
+// readSchedule читает расписание из файла, вызывается под schedMu
+func (ns *NotificationService) readSchedule() ([]ScheduledNotification, error) {
 	file := ns.scheduleFile()
 	data, err := os.ReadFile(file)
 	if err != nil {
@@ -52,10 +96,8 @@ func (ns *NotificationService) LoadSchedule() ([]ScheduledNotification, error) {
 	return store.Items, nil
 }
 
-func (ns *NotificationService) saveSchedule(items []ScheduledNotification) error {
-	schedMu.Lock()
-	defer schedMu.Unlock()
-
+// writeSchedule сохраняет расписание в файл, вызывается под schedMu
+func (ns *NotificationService) writeSchedule(items []ScheduledNotification) error {
 	store := scheduleStore{Items: items}
 	data, err := json.MarshalIndent(store, "", "  ")
 	if err != nil {
@@ -64,63 +106,131 @@ func (ns *NotificationService) saveSchedule(items []ScheduledNotification) error
 	return os.WriteFile(ns.scheduleFile(), data, 0644)
 }
 
+// modifySchedule читает, изменяет и сохраняет расписание под одной блокировкой,
+// чтобы параллельные изменения не затирали друг друга
+func (ns *NotificationService) modifySchedule(modify func([]ScheduledNotification) []ScheduledNotification) error {
+	schedMu.Lock()
+	defer schedMu.Unlock()
+
+	items, err := ns.readSchedule()
+	if err != nil {
+		return err
+	}
+	return ns.writeSchedule(modify(items))
+}
+
 func (ns *NotificationService) ListScheduled() ([]ScheduledNotification, error) {
 	return ns.LoadSchedule()
 }
 
 func (ns *NotificationService) ScheduleNotification(sendAt time.Time, typ models.NotificationType, recipients []int64) (string, error) {
-	items, err := ns.LoadSchedule()
-	if err != nil { return "", err }
-	
 	id := fmt.Sprintf("job_%d", time.Now().UnixNano())
 	
 	// НЕ генерируем сообщение заранее - будем генерировать при отправке
 	// Это обеспечит уникальность каждого уведомления
 	
-	items = append(items, ScheduledNotification{
+	item := ScheduledNotification{
 		ID: id,
 		Type: typ,
 		SendAt: sendAt,
 		Recipients: recipients,
 		CreatedAt: time.Now(),
 		Message: "", // Пустое сообщение - будет генерироваться при отправке
-	})
-	if err := ns.saveSchedule(items); err != nil { return "", err }
+	}
+	if err := ns.modifySchedule(func(items []ScheduledNotification) []ScheduledNotification {
+		return append(items, item)
+	}); err != nil { return "", err }
 	return id, nil
 }
 
 // ScheduleCustomNotification планирует кастомное уведомление с заданным текстом
 func (ns *NotificationService) ScheduleCustomNotification(sendAt time.Time, customText string, recipients []int64) (string, error) {
-	items, err := ns.LoadSchedule()
-	if err != nil { return "", err }
-	
 	id := fmt.Sprintf("job_%d", time.Now().UnixNano())
 	
-	items = append(items, ScheduledNotification{
+	item := ScheduledNotification{
 		ID: id,
 		Type: "custom", // Специальный тип для кастомных уведомлений
 		SendAt: sendAt,
 		Recipients: recipients,
 		CreatedAt: time.Now(),
 		CustomText: customText,
-	})
-	if err := ns.saveSchedule(items); err != nil { return "", err }
+	}
+	if err := ns.modifySchedule(func(items []ScheduledNotification) []ScheduledNotification {
+		return append(items, item)
+	}); err != nil { return "", err }
 	return id, nil
 }
 
-func (ns *NotificationService) CancelScheduled(id string) error {
-	items, err := ns.LoadSchedule()
-	if err != nil { return err }
-	var out []ScheduledNotification
-	for _, it := range items {
-		if it.ID != id { out = append(out, it) }
+// ScheduleOptions дополнительные параметры запланированного уведомления
+type ScheduleOptions struct {
+	CustomText   string
+	Recipients   []int64            // nil - всем активным пользователям
+	Recurrence   *Recurrence        // nil - однократно
+	LocalTime    bool               // отправлять в указанное время по местному времени каждого получателя
+	Segment      string             // ID сегмента аудитории (вместо Recipients)
+	Personalized bool               // генерировать каждому получателю персональный текст
+	Rich         models.RichContent // медиа и кнопки (пусто - из шаблона типа)
+}
+
+// Schedule планирует уведомление. Для повторяющихся start задает дату начала и время суток,
+// первый запуск - ближайший подходящий под правило после текущего момента.
+func (ns *NotificationService) Schedule(start time.Time, typ models.NotificationType, opts ScheduleOptions) (ScheduledNotification, error) {
+	now := time.Now()
+	item := ScheduledNotification{
+		ID:           fmt.Sprintf("job_%d", now.UnixNano()),
+		Type:         typ,
+		SendAt:       start,
+		Recipients:   opts.Recipients,
+		CreatedAt:    now,
+		CustomText:   opts.CustomText,
+		LocalTime:    opts.LocalTime,
+		Segment:      opts.Segment,
+		Personalized: opts.Personalized,
+		RichContent:  opts.Rich,
 	}
-	return ns.saveSchedule(out)
+	if opts.Segment != "" {
+		if _, err := ns.GetSegment(opts.Segment); err != nil {
+			return ScheduledNotification{}, err
+		}
+	}
+
+	if opts.Recurrence != nil {
+		rule := *opts.Recurrence
+		if err := rule.Validate(); err != nil {
+			return ScheduledNotification{}, err
+		}
+		after := now
+		if opts.LocalTime {
+			after = now.Add(-localTimeSpread)
+		}
+		first, ok := rule.Next(start, after)
+		if !ok {
+			return ScheduledNotification{}, fmt.Errorf("по правилу \"%s\" нет ни одного запуска", rule.Describe())
+		}
+		item.SendAt = first
+		item.Recurrence = &rule
+	}
+
+	if err := ns.modifySchedule(func(items []ScheduledNotification) []ScheduledNotification {
+		return append(items, item)
+	}); err != nil {
+		return ScheduledNotification{}, err
+	}
+	return item, nil
+}
+
+func (ns *NotificationService) CancelScheduled(id string) error {
+	return ns.modifySchedule(func(items []ScheduledNotification) []ScheduledNotification {
+		var out []ScheduledNotification
+		for _, it := range items {
+			if it.ID != id { out = append(out, it) }
+		}
+		return out
+	})
 }
 
-// StartScheduler runs background loop to deliver notifications
+// StartScheduler запускает фоновую отправку запланированных уведомлений
 func (ns *NotificationService) StartScheduler(stop <-chan struct{}) {
-	// tick every 30s
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 	for {
@@ -128,44 +238,152 @@ func (ns *NotificationService) StartScheduler(stop <-chan struct{}) {
 		case <-stop:
 			return
 		case <-ticker.C:
-			items, err := ns.LoadSchedule()
-			if err != nil { continue }
-			now := time.Now()
-			var remaining []ScheduledNotification
-			for _, it := range items {
-				if it.SendAt.After(now) {
-					remaining = append(remaining, it)
-					continue
-				}
-				// due: send notification
-				var message string
-				var err error
-				
-				// Если есть кастомный текст, используем его
-				if it.CustomText != "" {
-					message = it.CustomText
-				} else if it.Message != "" {
-					// Если есть заранее сгенерированное сообщение, используем его
-					message = it.Message
-				} else {
-					// Если нет заранее сгенерированного сообщения, генерируем новое
-					message, err = ns.GenerateNotification(it.Type)
-					if err != nil {
-						// Если не удалось сгенерировать, пропускаем
-						continue
-					}
-				}
-				
-				// Отправляем сообщение
-				if len(it.Recipients) == 0 {
-					_ = ns.SendNotificationToAll(message)
-				} else {
-					for _, userID := range it.Recipients {
-						_ = ns.SendNotificationToUser(userID, message)
-					}
+			ns.RunDueNotifications(time.Now())
+		}
+	}
+}
+
+// RunDueNotifications отправляет наступившие уведомления и переносит повторяющиеся на следующий запуск.
+// Задача переносится до постановки рассылки в очередь: если бот перезапустится во время рассылки,
+// ее продолжит очередь, а запуск не сработает повторно. Рассылки идут в фоне и не задерживают другие задачи.
+func (ns *NotificationService) RunDueNotifications(now time.Time) {
+	if !ns.broadcasts.Running() {
+		// Запуски не переносим, пока их некому отправить
+		return
+	}
+	ns.deliverDeferred(now)
+
+	items, err := ns.LoadSchedule()
+	if err != nil {
+		log.Printf("❌ Ошибка загрузки расписания: %v", err)
+		return
+	}
+
+	for _, occurrence := range items {
+		it := occurrence
+		var waves []DeliveryWave
+		pending := false
+		if it.LocalTime {
+			if waves, pending, err = ns.dueWaves(it, now); err != nil {
+				log.Printf("❌ Ошибка получения получателей уведомления %s: %v", it.ID, err)
+				continue
+			}
+			if len(waves) == 0 && pending {
+				continue
+			}
+			it.SentZones = slices.Clone(it.SentZones)
+			for _, wave := range waves {
+				it.SentZones = append(it.SentZones, wave.Zone)
+			}
+		} else if it.SendAt.After(now) {
+			continue
+		}
+
+		// nil - задача выполнена полностью и удаляется
+		var updated *ScheduledNotification
+		switch {
+		case pending:
+			// Часть поясов еще ждет своего времени
+			updated = &it
+		case it.advance(now):
+			updated = &it
+		}
+		claimed, err := ns.claimOccurrence(occurrence, updated)
+		if err != nil {
+			log.Printf("❌ Ошибка сохранения расписания: %v", err)
+			continue
+		}
+		if !claimed {
+			// Задачу отменили или этот запуск уже обработан
+			continue
+		}
+		if updated != nil && !pending {
+			log.Printf("🔁 Уведомление %s перенесено на %s", it.ID, it.SendAt.In(ScheduleZone).Format("02.01.2006 15:04"))
+		}
+
+		if occurrence.LocalTime {
+			ns.deliverWaves(occurrence, waves)
+		} else {
+			ns.deliverScheduled(occurrence)
+		}
+	}
+}
+
+// claimOccurrence заменяет запуск задачи occurrence на updated (nil - удаляет задачу).
+// Запуск определяется ID, временем и отправленными поясами: false - задачу отменили
+// или этот запуск уже обработан.
+func (ns *NotificationService) claimOccurrence(occurrence ScheduledNotification, updated *ScheduledNotification) (bool, error) {
+	claimed := false
+	err := ns.modifySchedule(func(current []ScheduledNotification) []ScheduledNotification {
+		var remaining []ScheduledNotification
+		for _, it := range current {
+			if !claimed && it.ID == occurrence.ID && it.SendAt.Equal(occurrence.SendAt) && slices.Equal(it.SentZones, occurrence.SentZones) {
+				claimed = true
+				if updated != nil {
+					remaining = append(remaining, *updated)
 				}
+				continue
 			}
-			_ = ns.saveSchedule(remaining)
+			remaining = append(remaining, it)
 		}
+		return remaining
+	})
+	return claimed && err == nil, err
+}
+
+// scheduledMessage возвращает текст запланированного уведомления
+func (ns *NotificationService) scheduledMessage(it ScheduledNotification) (string, error) {
+	// Кастомное уведомление может состоять только из фото или документа без подписи
+	if it.CustomText != "" || it.Type == models.NotificationCustom {
+		return it.CustomText, nil
+	}
+	if it.Message != "" {
+		return it.Message, nil
+	}
+	// Генерируем текст при каждой отправке, чтобы уведомления не повторялись
+	return ns.GenerateNotification(it.Type)
+}
+
+// scheduledRich возвращает медиа и кнопки запланированного уведомления:
+// заданные при планировании, иначе - из шаблона типа
+func (ns *NotificationService) scheduledRich(it ScheduledNotification) models.RichContent {
+	if !it.RichContent.IsEmpty() {
+		return it.RichContent
+	}
+	return ns.templateRich(it.Type)
+}
+
+// deliverScheduled ставит в очередь рассылку одного запланированного уведомления
+func (ns *NotificationService) deliverScheduled(it ScheduledNotification) {
+	message, err := ns.scheduledMessage(it)
+	if err != nil {
+		log.Printf("❌ Не удалось сгенерировать уведомление %s: %v", it.ID, err)
+		return
+	}
+
+	recipients, ok := ns.jobRecipients(it)
+	if !ok {
+		return
+	}
+	if _, err := ns.deliver(delivery{typ: it.Type, message: message, rich: ns.scheduledRich(it), recipients: recipients, reminder: true, personal: it.Personalized, background: true}); err != nil {
+		log.Printf("❌ Ошибка отправки уведомления %s: %v", it.ID, err)
+	}
+}
+
+// jobRecipients возвращает получателей задачи: сегмент отбирается заново при каждой отправке.
+// false - отправлять некому (сегмент пуст или удален).
+func (ns *NotificationService) jobRecipients(it ScheduledNotification) ([]int64, bool) {
+	if it.Segment == "" {
+		return it.Recipients, true
+	}
+	recipients, err := ns.SegmentRecipients(it.Segment)
+	if err != nil {
+		log.Printf("❌ Не удалось отобрать получателей уведомления %s: %v", it.ID, err)
+		return nil, false
+	}
+	if len(recipients) == 0 {
+		log.Printf("⚠️ В сегменте %s уведомления %s нет получателей", it.Segment, it.ID)
+		return nil, false
 	}
+	return recipients, true
 }
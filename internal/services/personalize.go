@@ -0,0 +1,180 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/ai"
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+)
+
+const (
+	// personalizeWorkers одновременных запросов к AI при персональной генерации
+	personalizeWorkers = 4
+	// personalCacheTTL сколько живет сгенерированный персональный текст
+	personalCacheTTL = time.Hour
+)
+
+// RecipientProfile сведения о получателе для персонального уведомления
+type RecipientProfile struct {
+	Week           int
+	WeekTitle      string
+	DaysSinceDiary int // -1 - записей в дневнике еще нет
+	PartnerName    string
+}
+
+// PersonalPrompt дополняет промпт шаблона сведениями о получателе
+func PersonalPrompt(base string, profile RecipientProfile) string {
+	var b strings.Builder
+	b.WriteString(base)
+	b.WriteString("\n\nСообщение получит один конкретный человек. Учти его прогресс:\n")
+
+	week := fmt.Sprintf("%d", profile.Week)
+	if profile.WeekTitle != "" {
+		week += fmt.Sprintf(" «%s»", profile.WeekTitle)
+	}
+	fmt.Fprintf(&b, "- текущая неделя программы: %s\n", week)
+
+	switch {
+	case profile.DaysSinceDiary < 0:
+		b.WriteString("- в дневник еще не было записей\n")
+	case profile.DaysSinceDiary == 0:
+		b.WriteString("- последняя запись в дневнике: сегодня\n")
+	default:
+		fmt.Fprintf(&b, "- последняя запись в дневнике: %d дн. назад\n", profile.DaysSinceDiary)
+	}
+
+	if profile.PartnerName != "" {
+		fmt.Fprintf(&b, "- партнера зовут %s, можно обратиться к паре\n", profile.PartnerName)
+	} else {
+		b.WriteString("- пользователь проходит программу без партнера в боте\n")
+	}
+	b.WriteString("Не перечисляй эти сведения дословно, используй их естественно.")
+	return b.String()
+}
+
+// personalCache кэш персональных текстов по итоговому промпту
+type personalCache struct {
+	mutex sync.Mutex
+	items map[string]personalCacheItem
+}
+
+type personalCacheItem struct {
+	text    string
+	expires time.Time
+}
+
+func (c *personalCache) get(prompt string, now time.Time) (string, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	item, ok := c.items[prompt]
+	if !ok || now.After(item.expires) {
+		return "", false
+	}
+	return item.text, true
+}
+
+func (c *personalCache) put(prompt, text string, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.items == nil {
+		c.items = make(map[string]personalCacheItem)
+	}
+	// Устаревшие тексты убираем при записи, чтобы кэш не рос бесконечно
+	for key, item := range c.items {
+		if now.After(item.expires) {
+			delete(c.items, key)
+		}
+	}
+	c.items[prompt] = personalCacheItem{text: text, expires: now.Add(personalCacheTTL)}
+}
+
+// recipientProfile собирает сведения о получателе из источника сведений
+func (ns *NotificationService) recipientProfile(userID int64, now time.Time) RecipientProfile {
+	week := ns.audience.CurrentWeek(userID)
+	profile := RecipientProfile{
+		Week:           week,
+		WeekTitle:      ns.audience.WeekTitle(week),
+		DaysSinceDiary: -1,
+	}
+	if last, ok := ns.audience.LastDiaryEntry(userID); ok {
+		profile.DaysSinceDiary = int(now.Sub(last).Hours() / 24)
+	}
+	if partnerID := ns.audience.PartnerOf(userID); partnerID != 0 {
+		if partner, err := ns.userStorage.GetUser(partnerID); err == nil && partner != nil {
+			profile.PartnerName = partner.Username
+		}
+	}
+	return profile
+}
+
+// PersonalizeNotifications генерирует персональные тексты уведомления типа typ для получателей.
+// Получатели, для которых текст получить не удалось, в результат не попадают и получат общий текст.
+func (ns *NotificationService) PersonalizeNotifications(typ models.NotificationType, userIDs []int64) map[int64]string {
+	if ns.activeTemplate(typ) == nil || ns.ai == nil || ns.audience == nil || len(userIDs) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	var (
+		mutex       sync.Mutex
+		wg          sync.WaitGroup
+		unavailable atomic.Bool
+		failed      atomic.Int32
+	)
+	messages := make(map[int64]string, len(userIDs))
+	slots := make(chan struct{}, personalizeWorkers)
+
+	for _, userID := range userIDs {
+		// Персональный текст строится по варианту шаблона, назначенному получателю
+		template := ns.variantTemplate(typ, userID)
+		if template == nil {
+			continue // шаблон отключили во время подготовки рассылки - получатель получит общий текст
+		}
+		prompt := PersonalPrompt(template.Prompt, ns.recipientProfile(userID, now))
+		if text, ok := ns.personal.get(prompt, now); ok {
+			mutex.Lock()
+			messages[userID] = text
+			mutex.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(userID int64, prompt string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			// После отказа AI остальных получателей не ждем: они получат общий текст
+			if unavailable.Load() {
+				failed.Add(1)
+				return
+			}
+			text, err := ns.ai.Generate(prompt)
+			if err != nil {
+				if ai.IsUnavailable(err) {
+					unavailable.Store(true)
+				}
+				failed.Add(1)
+				return
+			}
+			ns.personal.put(prompt, text, now)
+
+			mutex.Lock()
+			messages[userID] = text
+			mutex.Unlock()
+		}(userID, prompt)
+	}
+	wg.Wait()
+
+	if n := failed.Load(); n > 0 {
+		log.Printf("⚠️ Персональные уведомления %s: %d получателей получат общий текст", typ, n)
+	}
+	return messages
+}
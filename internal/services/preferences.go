@@ -0,0 +1,202 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+)
+
+// DeferredDelivery уведомление, отложенное до конца тихих часов получателя
+// или до выбранного им времени напоминаний
+type DeferredDelivery struct {
+	UserID  int64                   `json:"user_id"`
+	Type    models.NotificationType `json:"type"`
+	Message string                  `json:"message"`
+	SendAt  time.Time               `json:"send_at"`
+	Variant string                  `json:"variant,omitempty"` // вариант шаблона (A/B тест)
+
+	models.RichContent // медиа и кнопки уведомления
+}
+
+// DeliveryPlan распределение получателей уведомления с учетом их настроек
+type DeliveryPlan struct {
+	Now      []int64            // получают уведомление сразу
+	Deferred []DeferredDelivery // получат позже
+	Muted    int                // отключили этот тип уведомлений
+}
+
+// PlanDelivery распределяет получателей уведомления типа typ, наступившего в now.
+// reminder - запланированное напоминание: для него учитывается выбранное пользователем время
+// (кроме объявлений администратора).
+func PlanDelivery(users []models.UserInfo, typ models.NotificationType, message string, now time.Time, reminder bool) DeliveryPlan {
+	reminder = reminder && typ != models.NotificationCustom
+
+	var plan DeliveryPlan
+	for _, user := range users {
+		at, ok := user.Prefs().DeliverAt(typ, now, user.Location(), reminder)
+		switch {
+		case !ok:
+			plan.Muted++
+		case at.After(now):
+			plan.Deferred = append(plan.Deferred, DeferredDelivery{
+				UserID:  user.UserID,
+				Type:    typ,
+				Message: message,
+				SendAt:  at,
+			})
+		default:
+			plan.Now = append(plan.Now, user.UserID)
+		}
+	}
+	return plan
+}
+
+// GetPreferences возвращает настройки уведомлений пользователя
+func (ns *NotificationService) GetPreferences(userID int64) (models.NotificationPreferences, error) {
+	user, err := ns.userStorage.GetUser(userID)
+	if err != nil {
+		return models.NotificationPreferences{}, err
+	}
+	if user == nil {
+		return models.NotificationPreferences{}, nil
+	}
+	return user.Prefs(), nil
+}
+
+// SetPreferences проверяет и сохраняет настройки уведомлений пользователя
+func (ns *NotificationService) SetPreferences(userID int64, prefs models.NotificationPreferences) error {
+	if prefs.ReminderTime != "" {
+		if _, err := models.ParseClock(prefs.ReminderTime); err != nil {
+			return err
+		}
+	}
+	if (prefs.QuietFrom == "") != (prefs.QuietTo == "") {
+		return fmt.Errorf("укажите начало и конец тихих часов")
+	}
+	if prefs.QuietFrom != "" {
+		if _, _, err := models.ParseQuietHours(prefs.QuietFrom + "-" + prefs.QuietTo); err != nil {
+			return err
+		}
+	}
+	return ns.userStorage.SetPreferences(userID, prefs)
+}
+
+// deferredStore содержимое файла отложенных уведомлений
+type deferredStore struct {
+	Items []DeferredDelivery `json:"items"`
+}
+
+var deferredMu sync.Mutex
+
+// deferredFile путь к файлу отложенных уведомлений
+func (ns *NotificationService) deferredFile() string {
+	return filepath.Join(ns.dataDir, "deferred.json")
+}
+
+// loadDeferred загружает отложенные уведомления (вызывается под deferredMu)
+func (ns *NotificationService) loadDeferred() ([]DeferredDelivery, error) {
+	data, err := os.ReadFile(ns.deferredFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var store deferredStore
+	if err := json.Unmarshal(data, &store); err != nil {
+		return nil, err
+	}
+	return store.Items, nil
+}
+
+// saveDeferred сохраняет отложенные уведомления (вызывается под deferredMu)
+func (ns *NotificationService) saveDeferred(items []DeferredDelivery) error {
+	data, err := json.MarshalIndent(deferredStore{Items: items}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(ns.deferredFile(), data, 0644)
+}
+
+// addDeferred добавляет отложенные уведомления
+func (ns *NotificationService) addDeferred(items []DeferredDelivery) error {
+	deferredMu.Lock()
+	defer deferredMu.Unlock()
+
+	current, err := ns.loadDeferred()
+	if err != nil {
+		return err
+	}
+	return ns.saveDeferred(append(current, items...))
+}
+
+// takeDueDeferred забирает из файла отложенные уведомления, время которых наступило
+func (ns *NotificationService) takeDueDeferred(now time.Time) ([]DeferredDelivery, error) {
+	deferredMu.Lock()
+	defer deferredMu.Unlock()
+
+	items, err := ns.loadDeferred()
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+	var due, remaining []DeferredDelivery
+	for _, item := range items {
+		if item.SendAt.After(now) {
+			remaining = append(remaining, item)
+		} else {
+			due = append(due, item)
+		}
+	}
+	if len(due) == 0 {
+		return nil, nil
+	}
+	return due, ns.saveDeferred(remaining)
+}
+
+// deliverDeferred отправляет отложенные уведомления, время которых наступило.
+// Настройки проверяются повторно: пользователь мог отключить тип или изменить тихие часы.
+func (ns *NotificationService) deliverDeferred(now time.Time) {
+	if !ns.broadcasts.Running() {
+		return
+	}
+	due, err := ns.takeDueDeferred(now)
+	if err != nil {
+		log.Printf("❌ Ошибка загрузки отложенных уведомлений: %v", err)
+		return
+	}
+	if len(due) == 0 {
+		return
+	}
+
+	// Одинаковые уведомления отправляем одной рассылкой
+	type group struct {
+		typ     models.NotificationType
+		message string
+		rich    string
+		variant string
+	}
+	var order []group
+	byGroup := make(map[group][]int64)
+	richOf := make(map[group]models.RichContent)
+	for _, item := range due {
+		key := group{typ: item.Type, message: item.Message, rich: item.RichContent.Key(), variant: item.Variant}
+		if _, ok := byGroup[key]; !ok {
+			order = append(order, key)
+			richOf[key] = item.RichContent
+		}
+		byGroup[key] = append(byGroup[key], item.UserID)
+	}
+
+	for _, key := range order {
+		d := delivery{typ: key.typ, message: key.message, rich: richOf[key], recipients: byGroup[key], variant: key.variant, background: true}
+		if _, err := ns.deliver(d); err != nil {
+			log.Printf("❌ Ошибка отправки отложенного уведомления: %v", err)
+		}
+	}
+}
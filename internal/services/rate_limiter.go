@@ -0,0 +1,86 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenBucket ограничивает частоту отправки сообщений: rate токенов в секунду, не больше burst подряд.
+// Pause приостанавливает выдачу токенов (например, по retry_after от Telegram).
+type TokenBucket struct {
+	mutex       sync.Mutex
+	rate        float64
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+	now         func() time.Time
+}
+
+// NewTokenBucket создает ограничитель частоты
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if rate <= 0 {
+		rate = 1
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// Wait ждет свободный токен. Возвращает false, если ожидание прервано через stop.
+func (b *TokenBucket) Wait(stop <-chan struct{}) bool {
+	for {
+		delay := b.reserve()
+		if delay <= 0 {
+			return true
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-stop:
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+}
+
+// Pause запрещает выдачу токенов на d (более длинная пауза не сокращается)
+func (b *TokenBucket) Pause(d time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if until := b.now().Add(d); until.After(b.pausedUntil) {
+		b.pausedUntil = until
+		// После паузы не отправляем накопленную пачку разом
+		b.tokens = 0
+	}
+}
+
+// reserve забирает токен или возвращает, сколько нужно подождать
+func (b *TokenBucket) reserve() time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.now()
+	if now.Before(b.pausedUntil) {
+		return b.pausedUntil.Sub(now)
+	}
+	if b.last.Before(b.pausedUntil) {
+		b.last = b.pausedUntil
+	}
+
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
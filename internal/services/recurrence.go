@@ -0,0 +1,437 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ScheduleZone часовой пояс, в котором администраторы задают расписание (UTC+5, Алматы/Ташкент)
+var ScheduleZone = time.FixedZone("UTC+5", 5*60*60)
+
+// Виды повторения уведомлений
+const (
+	RepeatDaily    = "daily"    // каждый день
+	RepeatWeekdays = "weekdays" // по выбранным дням недели
+	RepeatEvery    = "every"    // каждые N дней
+	RepeatCron     = "cron"     // cron-выражение
+)
+
+// cronSearchLimit на сколько вперед ищется следующий запуск cron-выражения
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// Recurrence правило повторения запланированного уведомления.
+// Время суток для daily/weekdays/every берется из SendAt задачи.
+type Recurrence struct {
+	Kind     string         `json:"kind"`
+	Weekdays []time.Weekday `json:"weekdays,omitempty"`  // для weekdays
+	Interval int            `json:"interval,omitempty"`  // для every: шаг в днях
+	Cron     string         `json:"cron,omitempty"`      // для cron: "мин час день месяц день_недели"
+	Until    *time.Time     `json:"until,omitempty"`     // последняя допустимая отправка
+	MaxCount int            `json:"max_count,omitempty"` // максимум отправок (0 - без ограничения)
+}
+
+// Validate проверяет корректность правила
+func (r Recurrence) Validate() error {
+	switch r.Kind {
+	case RepeatDaily:
+	case RepeatWeekdays:
+		if len(r.Weekdays) == 0 {
+			return fmt.Errorf("не выбраны дни недели")
+		}
+	case RepeatEvery:
+		if r.Interval < 1 {
+			return fmt.Errorf("интервал должен быть не меньше 1 дня")
+		}
+	case RepeatCron:
+		if _, err := parseCron(r.Cron); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("неизвестный вид повторения: %s", r.Kind)
+	}
+	if r.MaxCount < 0 {
+		return fmt.Errorf("количество отправок не может быть отрицательным")
+	}
+	return nil
+}
+
+// Next возвращает ближайший запуск не раньше anchor и строго позже after.
+// false означает, что запусков больше нет (истек срок или правило некорректно).
+func (r Recurrence) Next(anchor, after time.Time) (time.Time, bool) {
+	anchor = anchor.In(ScheduleZone)
+	after = after.In(ScheduleZone)
+
+	var next time.Time
+	switch r.Kind {
+	case RepeatDaily:
+		next = stepDays(anchor, after, 1)
+	case RepeatEvery:
+		if r.Interval < 1 {
+			return time.Time{}, false
+		}
+		next = stepDays(anchor, after, r.Interval)
+	case RepeatWeekdays:
+		if len(r.Weekdays) == 0 {
+			return time.Time{}, false
+		}
+		next = stepDays(anchor, after, 1)
+		for i := 0; i < 7 && !r.hasWeekday(next.Weekday()); i++ {
+			next = next.AddDate(0, 0, 1)
+		}
+	case RepeatCron:
+		schedule, err := parseCron(r.Cron)
+		if err != nil {
+			return time.Time{}, false
+		}
+		from := after
+		if anchor.After(from) {
+			from = anchor.Add(-time.Minute)
+		}
+		var ok bool
+		if next, ok = schedule.next(from); !ok {
+			return time.Time{}, false
+		}
+	default:
+		return time.Time{}, false
+	}
+
+	if r.Until != nil && next.After(*r.Until) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+// Describe описывает правило для администратора
+func (r Recurrence) Describe() string {
+	var text string
+	switch r.Kind {
+	case RepeatDaily:
+		text = "каждый день"
+	case RepeatWeekdays:
+		names := make([]string, 0, len(r.Weekdays))
+		for _, day := range r.Weekdays {
+			names = append(names, weekdayNames[day])
+		}
+		text = "по дням: " + strings.Join(names, ", ")
+	case RepeatEvery:
+		text = fmt.Sprintf("каждые %d дн.", r.Interval)
+	case RepeatCron:
+		text = "cron " + r.Cron
+	default:
+		text = r.Kind
+	}
+
+	if r.Until != nil {
+		text += ", до " + r.Until.In(ScheduleZone).Format("02.01.2006")
+	}
+	if r.MaxCount > 0 {
+		text += fmt.Sprintf(", не более %d раз", r.MaxCount)
+	}
+	return text
+}
+
+// hasWeekday проверяет, входит ли день недели в правило
+func (r Recurrence) hasWeekday(day time.Weekday) bool {
+	for _, d := range r.Weekdays {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// stepDays сдвигает anchor на кратное days количество дней, пока он не станет позже after
+func stepDays(anchor, after time.Time, days int) time.Time {
+	next := anchor
+	if !next.After(after) {
+		skip := int(after.Sub(next) / (time.Duration(days) * 24 * time.Hour))
+		next = next.AddDate(0, 0, skip*days)
+	}
+	for !next.After(after) {
+		next = next.AddDate(0, 0, days)
+	}
+	return next
+}
+
+// weekdayNames короткие названия дней недели
+var weekdayNames = map[time.Weekday]string{
+	time.Monday:    "пн",
+	time.Tuesday:   "вт",
+	time.Wednesday: "ср",
+	time.Thursday:  "чт",
+	time.Friday:    "пт",
+	time.Saturday:  "сб",
+	time.Sunday:    "вс",
+}
+
+// ParseWeekdays разбирает список дней недели: "пн,ср,пт", "mon,wed" или "1-5" (0 и 7 - воскресенье)
+func ParseWeekdays(spec string) ([]time.Weekday, error) {
+	aliases := map[string]time.Weekday{
+		"mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday, "thu": time.Thursday,
+		"fri": time.Friday, "sat": time.Saturday, "sun": time.Sunday,
+	}
+	for day, name := range weekdayNames {
+		aliases[name] = day
+	}
+
+	var days []time.Weekday
+	seen := make(map[time.Weekday]bool)
+	add := func(day time.Weekday) {
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+
+	for _, part := range strings.Split(strings.ToLower(spec), ",") {
+		part = strings.TrimSpace(part)
+		if day, ok := aliases[part]; ok {
+			add(day)
+			continue
+		}
+		low, high, err := parseRange(part, 0, 7)
+		if err != nil {
+			return nil, fmt.Errorf("неизвестный день недели: %s", part)
+		}
+		for n := low; n <= high; n++ {
+			add(time.Weekday(n % 7))
+		}
+	}
+	if len(days) == 0 {
+		return nil, fmt.Errorf("не выбраны дни недели")
+	}
+	return days, nil
+}
+
+// cronSchedule разобранное cron-выражение из пяти полей
+type cronSchedule struct {
+	minutes, hours, days, months, weekdays map[int]bool
+	anyDay, anyWeekday                     bool
+}
+
+// parseCron разбирает выражение "минута час день месяц день_недели"
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron-выражение должно содержать 5 полей, получено %d", len(fields))
+	}
+
+	var err error
+	schedule := &cronSchedule{
+		anyDay:     fields[2] == "*",
+		anyWeekday: fields[4] == "*",
+	}
+	if schedule.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("минуты: %w", err)
+	}
+	if schedule.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("часы: %w", err)
+	}
+	if schedule.days, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("день месяца: %w", err)
+	}
+	if schedule.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("месяц: %w", err)
+	}
+	if schedule.weekdays, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("день недели: %w", err)
+	}
+	if schedule.weekdays[7] {
+		schedule.weekdays[0] = true
+	}
+	return schedule, nil
+}
+
+// parseCronField разбирает поле cron: "*", "5", "1-5", "*/15", "1-10/2" и списки через запятую
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return nil, fmt.Errorf("некорректный шаг: %s", part)
+			}
+		}
+
+		low, high := min, max
+		if rangePart != "*" {
+			var err error
+			if low, high, err = parseRange(rangePart, min, max); err != nil {
+				return nil, err
+			}
+			if hasStep && !strings.Contains(rangePart, "-") {
+				high = max
+			}
+		}
+		for n := low; n <= high; n += step {
+			values[n] = true
+		}
+	}
+	return values, nil
+}
+
+// parseRange разбирает число или диапазон "a-b" в границах [min, max]
+func parseRange(part string, min, max int) (int, int, error) {
+	lowStr, highStr, isRange := strings.Cut(part, "-")
+	low, err := strconv.Atoi(lowStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("некорректное значение: %s", part)
+	}
+	high := low
+	if isRange {
+		if high, err = strconv.Atoi(highStr); err != nil {
+			return 0, 0, fmt.Errorf("некорректное значение: %s", part)
+		}
+	}
+	if low < min || high > max || low > high {
+		return 0, 0, fmt.Errorf("значение вне диапазона %d-%d: %s", min, max, part)
+	}
+	return low, high, nil
+}
+
+// next ищет ближайшую минуту после after, подходящую под выражение
+func (c *cronSchedule) next(after time.Time) (time.Time, bool) {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := after.Add(cronSearchLimit)
+
+	for t.Before(limit) {
+		if !c.months[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.hours[t.Hour()] {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if !c.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// matchDay проверяет день по правилам cron: если заданы и день месяца, и день недели,
+// достаточно совпадения любого из них
+func (c *cronSchedule) matchDay(t time.Time) bool {
+	dayMatch := c.days[t.Day()]
+	weekdayMatch := c.weekdays[int(t.Weekday())]
+	switch {
+	case c.anyDay && c.anyWeekday:
+		return true
+	case c.anyDay:
+		return weekdayMatch
+	case c.anyWeekday:
+		return dayMatch
+	default:
+		return dayMatch || weekdayMatch
+	}
+}
+
+// ParseRecurrenceSpec разбирает текстовое правило повторения:
+//
+//	daily <ЧЧ:ММ>
+//	weekdays <пн,ср,пт> <ЧЧ:ММ>
+//	every <N> <ЧЧ:ММ>
+//	cron <мин> <час> <день> <месяц> <день_недели>
+//
+// с необязательными параметрами from=ДД.ММ.ГГГГ, until=ДД.ММ.ГГГГ и count=N.
+// Возвращает правило и момент начала (дата и время суток первого запуска).
+func ParseRecurrenceSpec(spec string, now time.Time) (Recurrence, time.Time, error) {
+	var rule Recurrence
+	var args []string
+	from := now.In(ScheduleZone)
+	hasFrom := false
+
+	for _, field := range strings.Fields(spec) {
+		key, value, isOption := strings.Cut(field, "=")
+		if !isOption {
+			args = append(args, field)
+			continue
+		}
+		switch key {
+		case "from", "until":
+			date, err := time.ParseInLocation("02.01.2006", value, ScheduleZone)
+			if err != nil {
+				return rule, time.Time{}, fmt.Errorf("некорректная дата %s: используйте формат ДД.ММ.ГГГГ", value)
+			}
+			if key == "from" {
+				from, hasFrom = date, true
+			} else {
+				until := date.Add(24*time.Hour - time.Second)
+				rule.Until = &until
+			}
+		case "count":
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 1 {
+				return rule, time.Time{}, fmt.Errorf("count должен быть положительным числом")
+			}
+			rule.MaxCount = count
+		default:
+			return rule, time.Time{}, fmt.Errorf("неизвестный параметр: %s", key)
+		}
+	}
+	if len(args) == 0 {
+		return rule, time.Time{}, fmt.Errorf("не указан вид повторения")
+	}
+
+	rule.Kind = args[0]
+	var clock string
+	switch rule.Kind {
+	case RepeatDaily:
+		if len(args) != 2 {
+			return rule, time.Time{}, fmt.Errorf("формат: daily <ЧЧ:ММ>")
+		}
+		clock = args[1]
+	case RepeatWeekdays:
+		if len(args) != 3 {
+			return rule, time.Time{}, fmt.Errorf("формат: weekdays <пн,ср,пт> <ЧЧ:ММ>")
+		}
+		days, err := ParseWeekdays(args[1])
+		if err != nil {
+			return rule, time.Time{}, err
+		}
+		rule.Weekdays = days
+		clock = args[2]
+	case RepeatEvery:
+		if len(args) != 3 {
+			return rule, time.Time{}, fmt.Errorf("формат: every <N> <ЧЧ:ММ>")
+		}
+		interval, err := strconv.Atoi(args[1])
+		if err != nil {
+			return rule, time.Time{}, fmt.Errorf("интервал должен быть числом дней")
+		}
+		rule.Interval = interval
+		clock = args[2]
+	case RepeatCron:
+		rule.Cron = strings.Join(args[1:], " ")
+		if err := rule.Validate(); err != nil {
+			return rule, time.Time{}, err
+		}
+		if hasFrom {
+			return rule, from, nil
+		}
+		return rule, now, nil
+	default:
+		return rule, time.Time{}, fmt.Errorf("неизвестный вид повторения: %s", rule.Kind)
+	}
+
+	if err := rule.Validate(); err != nil {
+		return rule, time.Time{}, err
+	}
+	at, err := time.Parse("15:04", clock)
+	if err != nil {
+		return rule, time.Time{}, fmt.Errorf("некорректное время %s: используйте формат ЧЧ:ММ", clock)
+	}
+	start := time.Date(from.Year(), from.Month(), from.Day(), at.Hour(), at.Minute(), 0, 0, ScheduleZone)
+	return rule, start, nil
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+)
+
+// AudienceSource сведения о пользователях, нужные для отбора по сегментам и персональных уведомлений
+type AudienceSource interface {
+	// CurrentWeek возвращает текущую неделю программы пользователя
+	CurrentWeek(userID int64) int
+	// PartnerOf возвращает ID партнера (0 - пользователь без пары)
+	PartnerOf(userID int64) int64
+	// DiaryEntryCount возвращает количество записей пользователя в дневнике за неделю программы
+	DiaryEntryCount(userID int64, week int) int
+	// LastDiaryEntry возвращает время последней записи пользователя в дневнике (false - записей нет)
+	LastDiaryEntry(userID int64) (time.Time, bool)
+	// WeekTitle возвращает заголовок недели программы (пусто, если неделя не настроена)
+	WeekTitle(week int) string
+}
+
+// SetAudienceSource подключает источник сведений для сегментов и персональных уведомлений
+func (ns *NotificationService) SetAudienceSource(source AudienceSource) {
+	ns.audience = source
+}
+
+// MatchesSegment проверяет, что пользователь подходит под условия сегмента в момент now.
+// Без источника сведений условия по неделе, дневнику и паре не выполняются.
+func MatchesSegment(user models.UserInfo, rules models.SegmentRules, source AudienceSource, now time.Time) bool {
+	if rules.InactiveDays > 0 && now.Sub(user.LastSeen) < time.Duration(rules.InactiveDays)*24*time.Hour {
+		return false
+	}
+	if rules.JoinedAfter != nil && user.JoinedAt.Before(*rules.JoinedAfter) {
+		return false
+	}
+	if rules.Week == 0 && !rules.NoDiary && rules.Pairing == "" {
+		return true
+	}
+	if source == nil {
+		return false
+	}
+
+	week := source.CurrentWeek(user.UserID)
+	if rules.Week > 0 && week != rules.Week {
+		return false
+	}
+	if rules.NoDiary && source.DiaryEntryCount(user.UserID, week) > 0 {
+		return false
+	}
+	switch rules.Pairing {
+	case models.PairingPaired:
+		return source.PartnerOf(user.UserID) != 0
+	case models.PairingSingle:
+		return source.PartnerOf(user.UserID) == 0
+	}
+	return true
+}
+
+// Segments возвращает сохраненные сегменты аудитории
+func (ns *NotificationService) Segments() ([]models.Segment, error) {
+	return ns.segments.List()
+}
+
+// GetSegment возвращает сегмент по ID
+func (ns *NotificationService) GetSegment(id string) (*models.Segment, error) {
+	segment, err := ns.segments.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if segment == nil {
+		return nil, fmt.Errorf("сегмент %s не найден", id)
+	}
+	return segment, nil
+}
+
+// CreateSegment сохраняет новый сегмент аудитории
+func (ns *NotificationService) CreateSegment(name string, rules models.SegmentRules, createdBy int64) (models.Segment, error) {
+	if name == "" {
+		return models.Segment{}, fmt.Errorf("укажите название сегмента")
+	}
+	return ns.segments.Create(name, rules, createdBy)
+}
+
+// DeleteSegment удаляет сегмент аудитории
+func (ns *NotificationService) DeleteSegment(id string) error {
+	return ns.segments.Delete(id)
+}
+
+// SegmentRecipients возвращает активных пользователей, подходящих под сегмент сейчас
+func (ns *NotificationService) SegmentRecipients(id string) ([]int64, error) {
+	segment, err := ns.GetSegment(id)
+	if err != nil {
+		return nil, err
+	}
+	users, err := ns.userStorage.GetAllActiveUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var recipients []int64
+	for _, user := range users {
+		if MatchesSegment(user, segment.Rules, ns.audience, now) {
+			recipients = append(recipients, user.UserID)
+		}
+	}
+	return recipients, nil
+}
+
+// BroadcastToSegment рассылает уведомление пользователям сегмента (personal - с персональными текстами)
+func (ns *NotificationService) BroadcastToSegment(typ models.NotificationType, message, segmentID string, requestedBy int64, personal bool) (*BroadcastReport, error) {
+	recipients, err := ns.SegmentRecipients(segmentID)
+	if err != nil {
+		return nil, err
+	}
+	if len(recipients) == 0 {
+		// Пустой список получателей означает "всем", поэтому пустой сегмент не рассылаем
+		return &BroadcastReport{}, nil
+	}
+	return ns.deliver(delivery{typ: typ, message: message, rich: ns.templateRich(typ), recipients: recipients, requestedBy: requestedBy, personal: personal})
+}
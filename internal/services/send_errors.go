@@ -0,0 +1,62 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// SendErrorKind категория ошибки отправки сообщения
+type SendErrorKind int
+
+const (
+	SendErrorPermanent   SendErrorKind = iota // повтор не поможет (например, некорректный текст)
+	SendErrorUnreachable                      // пользователю больше нельзя писать: заблокировал бота, удален, чат не найден
+	SendErrorRateLimited                      // 429, нужно подождать RetryAfter
+	SendErrorTemporary                        // сетевая ошибка или ошибка сервера Telegram
+)
+
+// SendFailure разобранная ошибка отправки
+type SendFailure struct {
+	Kind       SendErrorKind
+	Reason     string // причина деактивации для SendErrorUnreachable (models.Inactive*)
+	RetryAfter time.Duration
+}
+
+// ClassifySendError определяет категорию ошибки отправки по коду и описанию ответа Telegram
+func ClassifySendError(err error) SendFailure {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		var valueErr tgbotapi.Error
+		if !errors.As(err, &valueErr) {
+			return SendFailure{Kind: SendErrorTemporary}
+		}
+		apiErr = &valueErr
+	}
+
+	description := strings.ToLower(apiErr.Message)
+	switch {
+	case apiErr.Code == 429:
+		retryAfter := time.Duration(apiErr.RetryAfter) * time.Second
+		if retryAfter <= 0 {
+			retryAfter = time.Second
+		}
+		return SendFailure{Kind: SendErrorRateLimited, RetryAfter: retryAfter}
+	case apiErr.Code == 403 && strings.Contains(description, "user is deactivated"):
+		return SendFailure{Kind: SendErrorUnreachable, Reason: models.InactiveDeactivated}
+	case apiErr.Code == 403:
+		// bot was blocked by the user, bot can't initiate conversation with a user и т.п.
+		return SendFailure{Kind: SendErrorUnreachable, Reason: models.InactiveBlocked}
+	case apiErr.Code == 400 && (strings.Contains(description, "chat not found") ||
+		strings.Contains(description, "user not found") ||
+		strings.Contains(description, "peer_id_invalid")):
+		return SendFailure{Kind: SendErrorUnreachable, Reason: models.InactiveChatNotFound}
+	case apiErr.Code >= 500:
+		return SendFailure{Kind: SendErrorTemporary}
+	}
+	return SendFailure{Kind: SendErrorPermanent}
+}
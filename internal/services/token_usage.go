@@ -0,0 +1,311 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/ai"
+	"github.com/godofphonk/lovifyy-bot/internal/metrics"
+)
+
+// Периоды бюджета токенов
+const (
+	BudgetPeriodDay   = "day"
+	BudgetPeriodMonth = "month"
+)
+
+// TokenBudget лимиты токенов на пользователя (0 - без ограничения)
+type TokenBudget struct {
+	Daily   int `json:"daily"`
+	Monthly int `json:"monthly"`
+}
+
+// TokenCounter счетчик запросов и токенов
+type TokenCounter struct {
+	Requests         int `json:"requests"`
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// TotalTokens возвращает общее количество токенов
+func (tc TokenCounter) TotalTokens() int {
+	return tc.PromptTokens + tc.CompletionTokens
+}
+
+// add учитывает расход одного запроса
+func (tc *TokenCounter) add(usage ai.Usage) {
+	tc.Requests++
+	tc.PromptTokens += usage.PromptTokens
+	tc.CompletionTokens += usage.CompletionTokens
+}
+
+// UserTokenUsage расход токенов пользователя
+type UserTokenUsage struct {
+	Day         string                   `json:"day"`          // ГГГГ-ММ-ДД текущего дневного периода
+	DayTokens   int                      `json:"day_tokens"`   // Токенов за день
+	Month       string                   `json:"month"`        // ГГГГ-ММ текущего месячного периода
+	MonthTokens int                      `json:"month_tokens"` // Токенов за месяц
+	Total       TokenCounter             `json:"total"`        // За все время
+	Features    map[string]*TokenCounter `json:"features"`     // По функциям бота
+	Models      map[string]*TokenCounter `json:"models"`       // По моделям
+	UpdatedAt   time.Time                `json:"updated_at"`
+}
+
+// BudgetExceededError возвращается, когда пользователь исчерпал лимит токенов
+type BudgetExceededError struct {
+	Period  string
+	Limit   int
+	Used    int
+	ResetAt time.Time
+}
+
+// Error реализует интерфейс error
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("token budget exceeded for %s: used %d of %d", e.Period, e.Used, e.Limit)
+}
+
+// tokenUsageData содержимое файла учета токенов
+type tokenUsageData struct {
+	Budget      *TokenBudget               `json:"budget,omitempty"`       // Лимиты, заданные администратором
+	UserBudgets map[string]TokenBudget     `json:"user_budgets,omitempty"` // Индивидуальные лимиты
+	Users       map[string]*UserTokenUsage `json:"users"`
+}
+
+// TokenUsageService учитывает расход токенов AI и следит за бюджетами пользователей
+type TokenUsageService struct {
+	filePath string
+	defaults TokenBudget
+	metrics  *metrics.Metrics
+	mutex    sync.Mutex
+	now      func() time.Time
+}
+
+// NewTokenUsageService создает сервис учета токенов; defaults - лимиты из конфигурации
+func NewTokenUsageService(dataDir string, defaults TokenBudget, m *metrics.Metrics) *TokenUsageService {
+	os.MkdirAll(dataDir, 0755)
+	return &TokenUsageService{
+		filePath: filepath.Join(dataDir, "token_usage.json"),
+		defaults: defaults,
+		metrics:  m,
+		now:      time.Now,
+	}
+}
+
+// RecordUsage учитывает расход токенов запроса (реализует ai.UsageRecorder)
+func (ts *TokenUsageService) RecordUsage(userID int64, feature string, usage ai.Usage, err error) {
+	if ts.metrics != nil {
+		status := "success"
+		if err != nil {
+			status = "error"
+		}
+		ts.metrics.RecordAIRequest(usage.Model, feature, status, usage.Duration)
+		ts.metrics.RecordAITokens(usage.Model, feature, usage.PromptTokens, usage.CompletionTokens)
+	}
+
+	// Системные запросы (рассылки) и запросы без расхода не относятся к пользователю
+	if userID == 0 || usage.TotalTokens() == 0 {
+		return
+	}
+
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	data, loadErr := ts.load()
+	if loadErr != nil {
+		return
+	}
+
+	userUsage := ts.userUsage(data, userID)
+	userUsage.DayTokens += usage.TotalTokens()
+	userUsage.MonthTokens += usage.TotalTokens()
+	userUsage.Total.add(usage)
+	counterFor(userUsage.Features, feature).add(usage)
+	counterFor(userUsage.Models, usage.Model).add(usage)
+	userUsage.UpdatedAt = ts.now()
+
+	ts.save(data)
+}
+
+// CheckBudget возвращает *BudgetExceededError, если пользователь исчерпал дневной или месячный лимит
+func (ts *TokenUsageService) CheckBudget(userID int64) error {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	data, err := ts.load()
+	if err != nil {
+		return err
+	}
+
+	budget := ts.budgetFor(data, userID)
+	usage := ts.userUsage(data, userID)
+	now := ts.now()
+
+	if budget.Daily > 0 && usage.DayTokens >= budget.Daily {
+		year, month, day := now.Date()
+		return &BudgetExceededError{
+			Period:  BudgetPeriodDay,
+			Limit:   budget.Daily,
+			Used:    usage.DayTokens,
+			ResetAt: time.Date(year, month, day+1, 0, 0, 0, 0, now.Location()),
+		}
+	}
+	if budget.Monthly > 0 && usage.MonthTokens >= budget.Monthly {
+		year, month, _ := now.Date()
+		return &BudgetExceededError{
+			Period:  BudgetPeriodMonth,
+			Limit:   budget.Monthly,
+			Used:    usage.MonthTokens,
+			ResetAt: time.Date(year, month+1, 1, 0, 0, 0, 0, now.Location()),
+		}
+	}
+	return nil
+}
+
+// GetUsage возвращает расход токенов пользователя и действующий для него бюджет
+func (ts *TokenUsageService) GetUsage(userID int64) (UserTokenUsage, TokenBudget, error) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	data, err := ts.load()
+	if err != nil {
+		return UserTokenUsage{}, TokenBudget{}, err
+	}
+	return *ts.userUsage(data, userID), ts.budgetFor(data, userID), nil
+}
+
+// GetBudget возвращает общий бюджет на пользователя
+func (ts *TokenUsageService) GetBudget() TokenBudget {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	data, err := ts.load()
+	if err != nil || data.Budget == nil {
+		return ts.defaults
+	}
+	return *data.Budget
+}
+
+// SetBudget изменяет общий бюджет на пользователя
+func (ts *TokenUsageService) SetBudget(budget TokenBudget) error {
+	return ts.update(func(data *tokenUsageData) {
+		data.Budget = &budget
+	})
+}
+
+// SetUserBudget задает индивидуальный бюджет пользователя
+func (ts *TokenUsageService) SetUserBudget(userID int64, budget TokenBudget) error {
+	return ts.update(func(data *tokenUsageData) {
+		data.UserBudgets[strconv.FormatInt(userID, 10)] = budget
+	})
+}
+
+// ClearUserBudget возвращает пользователю общий бюджет
+func (ts *TokenUsageService) ClearUserBudget(userID int64) error {
+	return ts.update(func(data *tokenUsageData) {
+		delete(data.UserBudgets, strconv.FormatInt(userID, 10))
+	})
+}
+
+// update изменяет данные под блокировкой и сохраняет их
+func (ts *TokenUsageService) update(apply func(data *tokenUsageData)) error {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	data, err := ts.load()
+	if err != nil {
+		return err
+	}
+	apply(data)
+	return ts.save(data)
+}
+
+// budgetFor возвращает действующий бюджет пользователя
+func (ts *TokenUsageService) budgetFor(data *tokenUsageData, userID int64) TokenBudget {
+	if budget, exists := data.UserBudgets[strconv.FormatInt(userID, 10)]; exists {
+		return budget
+	}
+	if data.Budget != nil {
+		return *data.Budget
+	}
+	return ts.defaults
+}
+
+// userUsage возвращает расход пользователя, сбрасывая счетчики истекших периодов
+func (ts *TokenUsageService) userUsage(data *tokenUsageData, userID int64) *UserTokenUsage {
+	key := strconv.FormatInt(userID, 10)
+	usage, exists := data.Users[key]
+	if !exists {
+		usage = &UserTokenUsage{
+			Features: make(map[string]*TokenCounter),
+			Models:   make(map[string]*TokenCounter),
+		}
+		data.Users[key] = usage
+	}
+	if usage.Features == nil {
+		usage.Features = make(map[string]*TokenCounter)
+	}
+	if usage.Models == nil {
+		usage.Models = make(map[string]*TokenCounter)
+	}
+
+	now := ts.now()
+	if day := now.Format("2006-01-02"); usage.Day != day {
+		usage.Day = day
+		usage.DayTokens = 0
+	}
+	if month := now.Format("2006-01"); usage.Month != month {
+		usage.Month = month
+		usage.MonthTokens = 0
+	}
+	return usage
+}
+
+// counterFor возвращает счетчик по ключу, создавая его при необходимости
+func counterFor(counters map[string]*TokenCounter, key string) *TokenCounter {
+	if key == "" {
+		key = "unknown"
+	}
+	counter, exists := counters[key]
+	if !exists {
+		counter = &TokenCounter{}
+		counters[key] = counter
+	}
+	return counter
+}
+
+// load загружает данные учета токенов
+func (ts *TokenUsageService) load() (*tokenUsageData, error) {
+	data := &tokenUsageData{}
+
+	content, err := os.ReadFile(ts.filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read token usage: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(content, data); err != nil {
+			return nil, fmt.Errorf("failed to parse token usage: %w", err)
+		}
+	}
+
+	if data.UserBudgets == nil {
+		data.UserBudgets = make(map[string]TokenBudget)
+	}
+	if data.Users == nil {
+		data.Users = make(map[string]*UserTokenUsage)
+	}
+	return data, nil
+}
+
+// save сохраняет данные учета токенов
+func (ts *TokenUsageService) save(data *tokenUsageData) error {
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal token usage: %w", err)
+	}
+	return os.WriteFile(ts.filePath, content, 0644)
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+)
+
+// AssignVariant выбирает получателю один из вариантов шаблона. Выбор выглядит случайным,
+// но постоянен для пары пользователь-тип, чтобы получатель не переходил между группами теста.
+func AssignVariant(userID int64, typ models.NotificationType, variants []string) string {
+	if len(variants) == 0 {
+		return ""
+	}
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%d:%s", userID, typ)
+	return variants[h.Sum32()%uint32(len(variants))]
+}
+
+// activeVariants возвращает копии активных вариантов шаблона типа; первый - основной
+func (ns *NotificationService) activeVariants(notificationType models.NotificationType) []*models.NotificationTemplate {
+	ns.templatesMu.RLock()
+	defer ns.templatesMu.RUnlock()
+
+	var variants []*models.NotificationTemplate
+	for _, template := range ns.templates {
+		if template.Type == notificationType && template.IsActive {
+			variants = append(variants, &template)
+		}
+	}
+	return variants
+}
+
+// variantTemplate возвращает копию варианта шаблона, назначенного получателю (nil, если шаблона нет)
+func (ns *NotificationService) variantTemplate(notificationType models.NotificationType, userID int64) *models.NotificationTemplate {
+	return pickVariant(ns.activeVariants(notificationType), notificationType, userID)
+}
+
+// pickVariant выбирает вариант получателя из активных вариантов типа
+func pickVariant(variants []*models.NotificationTemplate, notificationType models.NotificationType, userID int64) *models.NotificationTemplate {
+	if len(variants) == 0 {
+		return nil
+	}
+	ids := make([]string, len(variants))
+	for i, variant := range variants {
+		ids[i] = variant.VariantID()
+	}
+	chosen := AssignVariant(userID, notificationType, ids)
+	for _, variant := range variants {
+		if variant.VariantID() == chosen {
+			return variant
+		}
+	}
+	return variants[0]
+}
+
+// applyVariants раздает получателям варианты шаблона. primary - уже сгенерированный текст основного
+// варианта, messages - готовые персональные тексты (их получатели остаются со своим текстом).
+// Возвращает тексты получателей, которым нужен не основной текст, и вариант каждого получателя.
+func (ns *NotificationService) applyVariants(typ models.NotificationType, primary string, userIDs []int64, messages map[int64]string) (map[int64]string, map[int64]string) {
+	variants := ns.activeVariants(typ)
+	if len(variants) == 0 {
+		return messages, nil
+	}
+	main := variants[0].VariantID()
+
+	assigned := make(map[int64]string, len(userIDs))
+	texts := map[string]string{main: primary}
+	for _, userID := range userIDs {
+		// Варианты выбираются из одного снимка шаблонов, даже если администратор меняет их во время рассылки
+		template := pickVariant(variants, typ, userID)
+		variant := template.VariantID()
+		if _, personal := messages[userID]; !personal && variant != main {
+			text, ok := texts[variant]
+			if !ok {
+				var err error
+				if text, err = ns.generate(template); err != nil {
+					log.Printf("⚠️ Вариант %s уведомления %s не сгенерирован, получатели получат основной: %v", variant, typ, err)
+				}
+				texts[variant] = text
+			}
+			if text == "" {
+				variant = main
+			} else {
+				if messages == nil {
+					messages = make(map[int64]string)
+				}
+				messages[userID] = text
+			}
+		}
+		assigned[userID] = variant
+	}
+	return messages, assigned
+}
+
+// AddTemplateVariant добавляет вариант шаблона типа для A/B теста. Медиа и кнопки
+// копируются из основного варианта.
+func (ns *NotificationService) AddTemplateVariant(notificationType models.NotificationType, prompt string) (models.NotificationTemplate, error) {
+	ns.templatesMu.Lock()
+	defer ns.templatesMu.Unlock()
+
+	var base *models.NotificationTemplate
+	used := make(map[string]bool)
+	for i := range ns.templates {
+		if ns.templates[i].Type == notificationType {
+			if base == nil {
+				base = &ns.templates[i]
+			}
+			used[ns.templates[i].VariantID()] = true
+		}
+	}
+	if base == nil {
+		return models.NotificationTemplate{}, fmt.Errorf("шаблон для типа %s не найден", notificationType)
+	}
+
+	variant := ""
+	for letter := 'A'; letter <= 'Z'; letter++ {
+		if !used[string(letter)] {
+			variant = string(letter)
+			break
+		}
+	}
+	if variant == "" {
+		return models.NotificationTemplate{}, fmt.Errorf("слишком много вариантов шаблона %s", notificationType)
+	}
+
+	now := time.Now()
+	template := models.NotificationTemplate{
+		Type:        notificationType,
+		Name:        base.Name,
+		Prompt:      prompt,
+		IsActive:    true,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Variant:     variant,
+		RichContent: base.RichContent,
+	}
+	ns.templates = append(ns.templates, template)
+	ns.saveTemplates()
+	return template, nil
+}
+
+// DeleteTemplateVariant удаляет вариант шаблона. Последний вариант типа удалить нельзя.
+func (ns *NotificationService) DeleteTemplateVariant(notificationType models.NotificationType, variant string) error {
+	ns.templatesMu.Lock()
+	defer ns.templatesMu.Unlock()
+
+	index, count := -1, 0
+	for i := range ns.templates {
+		if ns.templates[i].Type == notificationType {
+			count++
+			if ns.templates[i].VariantID() == variant {
+				index = i
+			}
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("вариант %s шаблона %s не найден", variant, notificationType)
+	}
+	if count == 1 {
+		return fmt.Errorf("нельзя удалить единственный вариант шаблона %s", notificationType)
+	}
+	ns.templates = append(ns.templates[:index], ns.templates[index+1:]...)
+	ns.saveTemplates()
+	return nil
+}
+
+// PickTemplateWinner завершает A/B тест: оставляет активным только вариант variant
+func (ns *NotificationService) PickTemplateWinner(notificationType models.NotificationType, variant string) error {
+	ns.templatesMu.Lock()
+	defer ns.templatesMu.Unlock()
+
+	found := false
+	for i := range ns.templates {
+		if ns.templates[i].Type == notificationType && ns.templates[i].VariantID() == variant {
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("вариант %s шаблона %s не найден", variant, notificationType)
+	}
+
+	now := time.Now()
+	for i := range ns.templates {
+		if ns.templates[i].Type == notificationType {
+			ns.templates[i].IsActive = ns.templates[i].VariantID() == variant
+			ns.templates[i].UpdatedAt = now
+		}
+	}
+	ns.saveTemplates()
+	return nil
+}
+
+// RecordEngagement отмечает действие пользователя после уведомления
+func (ns *NotificationService) RecordEngagement(userID int64, kind string) {
+	if err := ns.engagement.RecordEvent(userID, kind, time.Now()); err != nil {
+		log.Printf("❌ Ошибка сохранения действия %s пользователя %d: %v", kind, userID, err)
+	}
+}
+
+// EngagementReport возвращает вовлеченность по вариантам шаблонов за окно window после доставки
+func (ns *NotificationService) EngagementReport(window time.Duration) []VariantEngagement {
+	return ns.engagement.Report(window, time.Now())
+}
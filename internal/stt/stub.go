@@ -0,0 +1,33 @@
+package stt
+
+import (
+	"context"
+	"io"
+)
+
+// StubText ответ провайдера stub по умолчанию
+const StubText = "Тестовая расшифровка голосового сообщения"
+
+// Stub распознаватель с фиксированным ответом для разработки и тестов
+type Stub struct {
+	text string
+}
+
+// NewStub создает распознаватель, всегда возвращающий text (пусто - ответ по умолчанию)
+func NewStub(text string) *Stub {
+	if text == "" {
+		text = StubText
+	}
+	return &Stub{text: text}
+}
+
+// Transcribe вычитывает аудио и возвращает фиксированный текст
+func (s *Stub) Transcribe(ctx context.Context, audio io.Reader, filename string) (string, error) {
+	if _, err := io.Copy(io.Discard, audio); err != nil {
+		return "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return s.text, nil
+}
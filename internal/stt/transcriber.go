@@ -0,0 +1,53 @@
+package stt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/godofphonk/lovifyy-bot/internal/config"
+)
+
+// Поддерживаемые провайдеры распознавания речи
+const (
+	ProviderWhisperCLI  = "whisper_cli"  // Локальный бинарник whisper.cpp
+	ProviderWhisperHTTP = "whisper_http" // whisper.cpp server (/inference)
+	ProviderStub        = "stub"         // Фиксированный ответ для разработки и тестов
+)
+
+// ErrEmptyTranscript речь в записи не распознана
+var ErrEmptyTranscript = errors.New("empty transcript")
+
+// Transcriber переводит аудио в текст
+type Transcriber interface {
+	// Transcribe распознает аудио; filename нужен для определения формата (voice.oga, song.mp3)
+	Transcribe(ctx context.Context, audio io.Reader, filename string) (string, error)
+}
+
+// NewTranscriber создает распознаватель по конфигурации.
+// Пустой провайдер означает, что распознавание отключено: возвращается nil без ошибки.
+func NewTranscriber(cfg config.STTConfig) (Transcriber, error) {
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case ProviderWhisperCLI:
+		return NewWhisperCLI(cfg), nil
+	case ProviderWhisperHTTP:
+		return NewWhisperHTTP(cfg), nil
+	case ProviderStub:
+		return NewStub(cfg.StubText), nil
+	default:
+		return nil, fmt.Errorf("неизвестный провайдер распознавания речи: %s", cfg.Provider)
+	}
+}
+
+// cleanTranscript убирает переводы строк и пробелы по краям, которые whisper.cpp добавляет к сегментам
+func cleanTranscript(text string) (string, error) {
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return "", ErrEmptyTranscript
+	}
+	return text, nil
+}
@@ -0,0 +1,100 @@
+package stt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/godofphonk/lovifyy-bot/internal/config"
+)
+
+// maxStderrLength сколько последних байт stderr попадает в ошибку
+const maxStderrLength = 500
+
+// WhisperCLI распознаватель через локальный бинарник whisper.cpp
+type WhisperCLI struct {
+	binary   string
+	model    string
+	ffmpeg   string
+	language string
+}
+
+// NewWhisperCLI создает распознаватель на whisper.cpp.
+// Если указан ffmpeg, аудио (голосовые Telegram приходят в OGG/Opus) сначала переводится в WAV 16 кГц моно.
+func NewWhisperCLI(cfg config.STTConfig) *WhisperCLI {
+	return &WhisperCLI{
+		binary:   cfg.BinaryPath,
+		model:    cfg.ModelPath,
+		ffmpeg:   cfg.FFmpegPath,
+		language: cfg.Language,
+	}
+}
+
+// Transcribe сохраняет аудио во временный каталог и запускает whisper.cpp
+func (w *WhisperCLI) Transcribe(ctx context.Context, audio io.Reader, filename string) (string, error) {
+	dir, err := os.MkdirTemp("", "lovifyy-stt-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input"+filepath.Ext(filename))
+	file, err := os.Create(input)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	_, err = io.Copy(file, audio)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to save audio: %w", err)
+	}
+
+	if w.ffmpeg != "" {
+		wav := filepath.Join(dir, "input.wav")
+		if _, err := run(ctx, w.ffmpeg, "-nostdin", "-loglevel", "error", "-y",
+			"-i", input, "-ar", "16000", "-ac", "1", "-c:a", "pcm_s16le", wav); err != nil {
+			return "", fmt.Errorf("failed to convert audio: %w", err)
+		}
+		input = wav
+	}
+
+	args := []string{"-m", w.model, "-f", input, "-nt", "-np"}
+	if w.language != "" {
+		args = append(args, "-l", w.language)
+	}
+	output, err := run(ctx, w.binary, args...)
+	if err != nil {
+		return "", fmt.Errorf("whisper failed: %w", err)
+	}
+	return cleanTranscript(output)
+}
+
+// run запускает команду и возвращает stdout; в ошибку попадает stderr
+func run(ctx context.Context, name string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", ctxErr
+		}
+		// whisper.cpp пишет в stderr журнал загрузки модели - причина ошибки в конце
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > maxStderrLength {
+			msg = "..." + msg[len(msg)-maxStderrLength:]
+		}
+		if msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
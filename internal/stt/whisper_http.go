@@ -0,0 +1,93 @@
+package stt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/godofphonk/lovifyy-bot/internal/config"
+)
+
+// WhisperHTTP распознаватель через whisper.cpp server
+type WhisperHTTP struct {
+	url        string
+	language   string
+	httpClient *http.Client
+}
+
+// NewWhisperHTTP создает клиент whisper.cpp server.
+// Время запроса ограничивается контекстом Transcribe.
+func NewWhisperHTTP(cfg config.STTConfig) *WhisperHTTP {
+	return &WhisperHTTP{
+		url:        strings.TrimRight(cfg.ServerURL, "/") + "/inference",
+		language:   cfg.Language,
+		httpClient: &http.Client{},
+	}
+}
+
+// whisperResponse ответ whisper.cpp server с response_format=json
+type whisperResponse struct {
+	Text  string `json:"text"`
+	Error string `json:"error"`
+}
+
+// Transcribe отправляет аудио на сервер multipart-запросом
+func (w *WhisperHTTP) Transcribe(ctx context.Context, audio io.Reader, filename string) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+
+	part, err := form.CreateFormFile("file", filepath.Base(filename))
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(part, audio); err != nil {
+		return "", fmt.Errorf("failed to read audio: %w", err)
+	}
+	fields := map[string]string{"response_format": "json", "temperature": "0"}
+	if w.language != "" {
+		fields["language"] = w.language
+	}
+	for name, value := range fields {
+		if err := form.WriteField(name, value); err != nil {
+			return "", err
+		}
+	}
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("whisper server request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read whisper server response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("whisper server returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var result whisperResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("failed to decode whisper server response: %w", err)
+	}
+	if result.Error != "" {
+		return "", fmt.Errorf("whisper server error: %s", result.Error)
+	}
+	return cleanTranscript(result.Text)
+}
@@ -0,0 +1,11 @@
+package tokens
+
+import "unicode/utf8"
+
+// Estimate грубо оценивает количество токенов текста (~4 символа на токен)
+func Estimate(text string) int {
+	if text == "" {
+		return 0
+	}
+	return utf8.RuneCountInString(text)/4 + 1
+}
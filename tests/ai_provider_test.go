@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/ai"
+	"github.com/godofphonk/lovifyy-bot/internal/config"
+)
+
+func TestEchoProvider(t *testing.T) {
+	client, err := ai.NewClient(config.OpenAIConfig{Provider: ai.ProviderEcho, EchoTemplate: "Ответ: %s"})
+	if err != nil {
+		t.Fatalf("Ошибка создания echo провайдера: %v", err)
+	}
+
+	messages := []ai.OpenAIMessage{
+		{Role: "system", Content: "Ты помощник"},
+		{Role: "user", Content: "Как дела?"},
+	}
+	response, err := client.GenerateWithHistory(messages)
+	if err != nil || response != "Ответ: Как дела?" {
+		t.Errorf("Ожидали детерминированный ответ, получили '%s' / %v", response, err)
+	}
+
+	var streamed strings.Builder
+	full, err := client.GenerateStream(context.Background(), messages, func(delta string) error {
+		streamed.WriteString(delta)
+		return nil
+	})
+	if err != nil || full != response || streamed.String() != response {
+		t.Errorf("Потоковый ответ должен совпадать с обычным: '%s' / '%s' / %v", full, streamed.String(), err)
+	}
+
+	if _, err := ai.NewClient(config.OpenAIConfig{Provider: "unknown"}); err == nil {
+		t.Errorf("Ожидали ошибку для неизвестного провайдера")
+	}
+}
+
+func TestUnknownProviderFailsConfigLoad(t *testing.T) {
+	t.Setenv("TELEGRAM_BOT_TOKEN", "123456:test-token")
+	t.Setenv("AI_PROVIDER", "unknown")
+
+	_, loadErr := config.Load()
+	_, clientErr := ai.NewClient(config.OpenAIConfig{Provider: "unknown"})
+	if loadErr == nil || clientErr == nil {
+		t.Fatalf("Неизвестный провайдер должен отсекаться при загрузке конфигурации: %v / %v", loadErr, clientErr)
+	}
+	if !strings.Contains(loadErr.Error(), clientErr.Error()) {
+		t.Errorf("Ошибки конфигурации и клиента должны совпадать: %q / %q", loadErr, clientErr)
+	}
+}
+
+func TestOpenAICompatibleBaseURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("Неожиданный путь запроса: %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			t.Errorf("Без ключа заголовок авторизации не нужен, получили '%s'", auth)
+		}
+		fmt.Fprint(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"local"}}]}`)
+	}))
+	defer server.Close()
+
+	cfg := config.OpenAIConfig{
+		Provider:    ai.ProviderOpenAI,
+		Model:       "llama3",
+		BaseURL:     server.URL + "/v1/",
+		MaxTokens:   100,
+		Temperature: 0.5,
+		Timeout:     time.Second,
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Локальному серверу не нужен API ключ, получили ошибку: %v", err)
+	}
+
+	client, err := ai.NewClient(cfg)
+	if err != nil {
+		t.Fatalf("Ошибка создания клиента: %v", err)
+	}
+	response, err := client.Generate("Привет")
+	if err != nil || response != "local" {
+		t.Errorf("Ожидали ответ локального сервера, получили '%s' / %v", response, err)
+	}
+}
@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/ai"
+	"github.com/godofphonk/lovifyy-bot/internal/config"
+)
+
+// newFlakyServer отвечает статусами из statuses по очереди, затем успешным ответом
+func newFlakyServer(statuses []int, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(atomic.AddInt32(calls, 1)) - 1
+		if call < len(statuses) {
+			if statuses[call] == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "0")
+			}
+			http.Error(w, `{"error":"temporary"}`, statuses[call])
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"Работаю!"}}]}`)
+	}))
+}
+
+func newRetryClient(t *testing.T, baseURL string, retries int) *ai.OpenAIClient {
+	client := ai.NewOpenAIClientFromConfig(config.OpenAIConfig{APIKey: "test-key", Model: "gpt-4o-mini", BaseURL: baseURL})
+	client.SetTimeout(time.Second)
+	client.SetRetryPolicy(ai.RetryPolicy{MaxRetries: retries, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond})
+	client.SetCircuitBreaker(nil)
+	return client
+}
+
+func TestGenerateRetriesTransientErrors(t *testing.T) {
+	var calls int32
+	server := newFlakyServer([]int{http.StatusTooManyRequests, http.StatusBadGateway}, &calls)
+	defer server.Close()
+
+	client := newRetryClient(t, server.URL, 3)
+	response, err := client.Generate("Привет")
+	if err != nil {
+		t.Fatalf("Ожидали успешный ответ после повторов, получили ошибку: %v", err)
+	}
+	if response != "Работаю!" {
+		t.Errorf("Неожиданный ответ: %s", response)
+	}
+	if atomic.LoadInt32(&calls) != 3 {
+		t.Errorf("Ожидали 3 попытки, получили %d", calls)
+	}
+}
+
+func TestGenerateDoesNotRetryClientErrors(t *testing.T) {
+	var calls int32
+	server := newFlakyServer([]int{http.StatusBadRequest}, &calls)
+	defer server.Close()
+
+	client := newRetryClient(t, server.URL, 3)
+	_, err := client.Generate("Привет")
+
+	var apiErr *ai.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Ожидали APIError 400, получили %v", err)
+	}
+	if ai.IsUnavailable(err) {
+		t.Errorf("Ошибка запроса 400 не должна считаться недоступностью AI")
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("Ошибка 400 не должна повторяться, попыток: %d", calls)
+	}
+}
+
+func TestCircuitBreakerFailsFast(t *testing.T) {
+	var calls int32
+	server := newFlakyServer([]int{500, 500, 500, 500}, &calls)
+	defer server.Close()
+
+	client := newRetryClient(t, server.URL, 0)
+	client.SetCircuitBreaker(ai.NewCircuitBreaker(2, 50*time.Millisecond))
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Generate("Привет"); err == nil {
+			t.Fatalf("Ожидали ошибку сервера на попытке %d", i+1)
+		}
+	}
+	if state := client.BreakerState(); state != ai.BreakerOpen {
+		t.Fatalf("Ожидали разомкнутый автомат, получили %s", state)
+	}
+
+	_, err := client.Generate("Привет")
+	if !errors.Is(err, ai.ErrCircuitOpen) || !ai.IsUnavailable(err) {
+		t.Errorf("Ожидали ErrCircuitOpen, получили %v", err)
+	}
+	if atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("Разомкнутый автомат не должен обращаться к API, запросов: %d", calls)
+	}
+
+	// После паузы пробный запрос проходит; сервер все еще сбоит - автомат снова размыкается
+	time.Sleep(60 * time.Millisecond)
+	client.Generate("Привет")
+	if state := client.BreakerState(); state != ai.BreakerOpen {
+		t.Errorf("Неудачный пробный запрос должен разомкнуть автомат, получили %s", state)
+	}
+
+	// Сервер восстановился
+	time.Sleep(60 * time.Millisecond)
+	atomic.StoreInt32(&calls, 4)
+	if _, err := client.Generate("Привет"); err != nil {
+		t.Fatalf("Ожидали успешный пробный запрос, получили %v", err)
+	}
+	if state := client.BreakerState(); state != ai.BreakerClosed {
+		t.Errorf("Успешный запрос должен замкнуть автомат, получили %s", state)
+	}
+}
@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/ai"
+	"github.com/godofphonk/lovifyy-bot/internal/config"
+)
+
+// newSSEServer создает тестовый сервер, отдающий ответ chat/completions фрагментами
+func newSSEServer(t *testing.T, chunks []string, done bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			t.Errorf("Неожиданный путь запроса: %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			t.Errorf("Ожидали заголовок авторизации, получили '%s'", r.Header.Get("Authorization"))
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\"}}]}\n\n")
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", chunk)
+			flusher.Flush()
+		}
+		if done {
+			fmt.Fprint(w, "data: [DONE]\n\n")
+		}
+	}))
+}
+
+func newStreamingClient(t *testing.T, baseURL string) *ai.OpenAIClient {
+	client := ai.NewOpenAIClientFromConfig(config.OpenAIConfig{APIKey: "test-key", Model: "gpt-4o-mini", BaseURL: baseURL})
+	client.SetRetryPolicy(ai.RetryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond})
+	return client
+}
+
+func TestGenerateStream(t *testing.T) {
+	chunks := []string{"Привет", ", ", "дорогие", "! 💖"}
+	server := newSSEServer(t, chunks, true)
+	defer server.Close()
+
+	client := newStreamingClient(t, server.URL)
+
+	var received []string
+	response, err := client.GenerateStream(context.Background(), []ai.OpenAIMessage{{Role: "user", Content: "Привет"}}, func(delta string) error {
+		received = append(received, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Ошибка потоковой генерации: %v", err)
+	}
+	if response != strings.Join(chunks, "") {
+		t.Errorf("Ожидали полный ответ '%s', получили '%s'", strings.Join(chunks, ""), response)
+	}
+	if len(received) != len(chunks) {
+		t.Errorf("Ожидали %d фрагментов, получили %d", len(chunks), len(received))
+	}
+}
+
+func TestGenerateStreamErrors(t *testing.T) {
+	// Поток без [DONE] возвращает полученную часть
+	server := newSSEServer(t, []string{"Частичный ", "ответ"}, false)
+	defer server.Close()
+
+	client := newStreamingClient(t, server.URL)
+	response, err := client.GenerateStream(context.Background(), nil, nil)
+	if err != nil || response != "Частичный ответ" {
+		t.Errorf("Ожидали частичный ответ без ошибки, получили '%s' / %v", response, err)
+	}
+
+	// Ошибка обработчика прерывает поток
+	_, err = client.GenerateStream(context.Background(), nil, func(string) error {
+		return fmt.Errorf("stop")
+	})
+	if err == nil {
+		t.Errorf("Ожидали ошибку от обработчика фрагментов")
+	}
+
+	// Статус ошибки API
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"rate limit"}`, http.StatusTooManyRequests)
+	}))
+	defer failing.Close()
+
+	client.SetBaseURL(failing.URL)
+	if _, err := client.GenerateStream(context.Background(), nil, nil); err == nil {
+		t.Errorf("Ожидали ошибку при статусе 429")
+	}
+}
@@ -0,0 +1,341 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+	"github.com/godofphonk/lovifyy-bot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeSender имитирует Telegram: ошибки задаются по получателю на каждую попытку
+type fakeSender struct {
+	mutex    sync.Mutex
+	errors   map[int64][]error
+	sent     []int64
+	attempts map[int64]int
+	onSend   func(count int)
+}
+
+func newFakeSender() *fakeSender {
+	return &fakeSender{errors: make(map[int64][]error), attempts: make(map[int64]int)}
+}
+
+func (f *fakeSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	f.mutex.Lock()
+	msg := c.(tgbotapi.MessageConfig)
+	attempt := f.attempts[msg.ChatID]
+	f.attempts[msg.ChatID]++
+	var err error
+	if queue := f.errors[msg.ChatID]; attempt < len(queue) {
+		err = queue[attempt]
+	}
+	if err == nil {
+		f.sent = append(f.sent, msg.ChatID)
+	}
+	count := len(f.sent)
+	onSend := f.onSend
+	f.mutex.Unlock()
+
+	if err == nil && onSend != nil {
+		onSend(count)
+	}
+	return tgbotapi.Message{}, err
+}
+
+func TestBroadcastQueueReport(t *testing.T) {
+	sender := newFakeSender()
+	sender.errors[2] = []error{&tgbotapi.Error{Code: 429, Message: "Too Many Requests", ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 1}}}
+	sender.errors[3] = []error{&tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}}
+	sender.errors[4] = []error{&tgbotapi.Error{Code: 400, Message: "Bad Request: message text is empty"}}
+	sender.errors[5] = []error{errors.New("connection reset"), errors.New("connection reset"), errors.New("connection reset")}
+
+	queue := services.NewBroadcastQueue(sender, services.NewTokenBucket(1000, 1000), t.TempDir())
+	stop := make(chan struct{})
+	defer close(stop)
+	go queue.Run(stop)
+
+	started := time.Now()
+	_, done, err := queue.Enqueue("Привет", "", []int64{1, 2, 3, 4, 5, 6, 1}, 0)
+	if err != nil {
+		t.Fatalf("Ошибка постановки в очередь: %v", err)
+	}
+
+	var report services.BroadcastReport
+	select {
+	case report = <-done:
+	case <-time.After(15 * time.Second):
+		t.Fatal("Рассылка не завершилась")
+	}
+
+	if report.Total != 6 || report.Sent != 3 || report.Blocked != 1 || report.Failed != 2 {
+		t.Errorf("Неверный отчет: %+v", report)
+	}
+	// retry_after должен приостановить очередь
+	if elapsed := time.Since(started); elapsed < time.Second {
+		t.Errorf("Очередь не выдержала паузу retry_after: %s", elapsed)
+	}
+	if sender.attempts[2] != 2 {
+		t.Errorf("После 429 ожидали повторную попытку, попыток: %d", sender.attempts[2])
+	}
+	if sender.attempts[4] != 1 {
+		t.Errorf("Постоянную ошибку не нужно повторять, попыток: %d", sender.attempts[4])
+	}
+}
+
+func TestBroadcastQueueResume(t *testing.T) {
+	dir := t.TempDir()
+	recipients := []int64{1, 2, 3, 4, 5}
+
+	// Первый запуск прерывается после двух отправок
+	first := newFakeSender()
+	stop := make(chan struct{})
+	var once sync.Once
+	first.onSend = func(count int) {
+		if count == 2 {
+			once.Do(func() { close(stop) })
+		}
+	}
+	queue := services.NewBroadcastQueue(first, services.NewTokenBucket(1000, 1), dir)
+	finished := make(chan struct{})
+	go func() {
+		queue.Run(stop)
+		close(finished)
+	}()
+	if _, _, err := queue.Enqueue("Привет", "", recipients, 42); err != nil {
+		t.Fatalf("Ошибка постановки в очередь: %v", err)
+	}
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Очередь не остановилась")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "broadcasts.json.tmp")); !os.IsNotExist(err) {
+		t.Errorf("Временный файл очереди не должен оставаться после записи: %v", err)
+	}
+
+	// После перезапуска рассылка продолжается с места остановки
+	second := newFakeSender()
+	resumed := services.NewBroadcastQueue(second, services.NewTokenBucket(1000, 1000), dir)
+	reports := make(chan services.BroadcastReport, 1)
+	resumed.SetReportHandler(func(job services.BroadcastJob, report services.BroadcastReport) {
+		if job.RequestedBy != 42 {
+			t.Errorf("Отчет должен уйти администратору 42, получили %d", job.RequestedBy)
+		}
+		reports <- report
+	})
+	stopResumed := make(chan struct{})
+	defer close(stopResumed)
+	go resumed.Run(stopResumed)
+
+	select {
+	case report := <-reports:
+		if !report.Resumed || report.Total != 5 || report.Sent != 5 {
+			t.Errorf("Неверный отчет продолженной рассылки: %+v", report)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Продолженная рассылка не завершилась")
+	}
+
+	// Каждый получатель получает сообщение ровно один раз
+	received := make(map[int64]int)
+	for _, userID := range append(append([]int64(nil), first.sent...), second.sent...) {
+		received[userID]++
+	}
+	for _, userID := range recipients {
+		if received[userID] != 1 {
+			t.Errorf("Получатель %d получил сообщение %d раз: %v + %v", userID, received[userID], first.sent, second.sent)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "broadcast_"+singleJob(t, resumed).ID+".progress")); !os.IsNotExist(err) {
+		t.Errorf("Журнал завершенной рассылки должен удаляться: %v", err)
+	}
+}
+
+// singleJob возвращает единственную рассылку очереди
+func singleJob(t *testing.T, queue *services.BroadcastQueue) services.BroadcastJob {
+	t.Helper()
+	jobs, err := queue.Jobs()
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("Ожидали одну рассылку, получили %d (%v)", len(jobs), err)
+	}
+	return jobs[0]
+}
+
+// partSender запоминает, какие сообщения получил каждый получатель
+type partSender struct {
+	mutex  sync.Mutex
+	parts  map[int64][]string
+	onSend func()
+}
+
+func (p *partSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	p.mutex.Lock()
+	switch msg := c.(type) {
+	case tgbotapi.PhotoConfig:
+		p.parts[msg.ChatID] = append(p.parts[msg.ChatID], "photo")
+	case tgbotapi.MessageConfig:
+		p.parts[msg.ChatID] = append(p.parts[msg.ChatID], "text")
+	}
+	onSend := p.onSend
+	p.mutex.Unlock()
+	if onSend != nil {
+		onSend()
+	}
+	return tgbotapi.Message{}, nil
+}
+
+func TestBroadcastQueueResumeRichPart(t *testing.T) {
+	dir := t.TempDir()
+	// Текст не помещается в подпись: фото и текст уходят двумя сообщениями
+	job := services.BroadcastJob{
+		Message:     strings.Repeat("Длинный текст уведомления. ", 60),
+		Recipients:  []int64{1, 2},
+		RichContent: models.RichContent{Media: &models.NotificationMedia{Kind: models.MediaPhoto, FileID: "photo"}},
+	}
+
+	// Остановка после фото первому получателю, до отправки текста
+	stop := make(chan struct{})
+	var once sync.Once
+	first := &partSender{parts: make(map[int64][]string)}
+	first.onSend = func() { once.Do(func() { close(stop) }) }
+	queue := services.NewBroadcastQueue(first, services.NewTokenBucket(1, 1), dir)
+	finished := make(chan struct{})
+	go func() {
+		queue.Run(stop)
+		close(finished)
+	}()
+	if _, _, err := queue.Submit(job); err != nil {
+		t.Fatalf("Ошибка постановки в очередь: %v", err)
+	}
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Очередь не остановилась")
+	}
+
+	second := &partSender{parts: make(map[int64][]string)}
+	resumed := services.NewBroadcastQueue(second, services.NewTokenBucket(1000, 1000), dir)
+	if pending := singleJob(t, resumed); pending.Next != 0 || pending.Part != 1 {
+		t.Errorf("Ожидали продолжение с текста первому получателю, получили next=%d part=%d", pending.Next, pending.Part)
+	}
+	reports := make(chan services.BroadcastReport, 1)
+	resumed.SetReportHandler(func(_ services.BroadcastJob, report services.BroadcastReport) { reports <- report })
+	stopResumed := make(chan struct{})
+	defer close(stopResumed)
+	go resumed.Run(stopResumed)
+
+	select {
+	case report := <-reports:
+		if report.Sent != 2 {
+			t.Errorf("Неверный отчет: %+v", report)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Продолженная рассылка не завершилась")
+	}
+	if got := append(first.parts[1], second.parts[1]...); !reflect.DeepEqual(got, []string{"photo", "text"}) {
+		t.Errorf("Первый получатель должен получить фото и текст по одному разу, получил %v", got)
+	}
+	if !reflect.DeepEqual(second.parts[2], []string{"photo", "text"}) {
+		t.Errorf("Второй получатель должен получить фото и текст, получил %v", second.parts[2])
+	}
+}
+
+func TestClassifySendError(t *testing.T) {
+	tests := []struct {
+		err    error
+		kind   services.SendErrorKind
+		reason string
+	}{
+		{&tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}, services.SendErrorUnreachable, models.InactiveBlocked},
+		{&tgbotapi.Error{Code: 403, Message: "Forbidden: user is deactivated"}, services.SendErrorUnreachable, models.InactiveDeactivated},
+		{&tgbotapi.Error{Code: 400, Message: "Bad Request: chat not found"}, services.SendErrorUnreachable, models.InactiveChatNotFound},
+		{&tgbotapi.Error{Code: 400, Message: "Bad Request: message text is empty"}, services.SendErrorPermanent, ""},
+		{&tgbotapi.Error{Code: 502, Message: "Bad Gateway"}, services.SendErrorTemporary, ""},
+		{errors.New("connection reset"), services.SendErrorTemporary, ""},
+	}
+	for _, tt := range tests {
+		got := services.ClassifySendError(tt.err)
+		if got.Kind != tt.kind || got.Reason != tt.reason {
+			t.Errorf("%v: ожидали %d/%q, получили %d/%q", tt.err, tt.kind, tt.reason, got.Kind, got.Reason)
+		}
+	}
+}
+
+func TestBroadcastDeactivatesUnreachable(t *testing.T) {
+	storage := models.NewUserStorage(t.TempDir())
+	for _, userID := range []int64{1, 2, 3} {
+		if err := storage.AddUser(userID, ""); err != nil {
+			t.Fatalf("Ошибка добавления пользователя: %v", err)
+		}
+	}
+
+	sender := newFakeSender()
+	sender.errors[2] = []error{&tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}}
+	sender.errors[3] = []error{&tgbotapi.Error{Code: 400, Message: "Bad Request: chat not found"}}
+
+	queue := services.NewBroadcastQueue(sender, services.NewTokenBucket(1000, 1000), t.TempDir())
+	queue.SetUnreachableHandler(func(userID int64, reason string) {
+		if err := storage.DeactivateUser(userID, reason); err != nil {
+			t.Errorf("Ошибка деактивации: %v", err)
+		}
+	})
+	stop := make(chan struct{})
+	defer close(stop)
+	go queue.Run(stop)
+
+	_, done, err := queue.Enqueue("Привет", "", []int64{1, 2, 3}, 0)
+	if err != nil {
+		t.Fatalf("Ошибка постановки в очередь: %v", err)
+	}
+	select {
+	case report := <-done:
+		if report.Sent != 1 || report.Blocked != 2 {
+			t.Errorf("Неверный отчет: %+v", report)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Рассылка не завершилась")
+	}
+	if sender.attempts[2] != 1 || sender.attempts[3] != 1 {
+		t.Errorf("Недоступным пользователям не нужно писать повторно: %v", sender.attempts)
+	}
+
+	ids, _ := storage.GetUserIDs()
+	if len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("В рассылку должен попадать только активный пользователь, получили %v", ids)
+	}
+	user, _ := storage.GetUser(2)
+	if user.IsActive || user.InactiveReason != models.InactiveBlocked || user.InactiveSince == nil {
+		t.Errorf("Пользователь 2 должен быть деактивирован с причиной: %+v", user)
+	}
+
+	now := time.Now()
+	stats, err := storage.GetChurnStats(now)
+	if err != nil {
+		t.Fatalf("Ошибка статистики: %v", err)
+	}
+	if stats.Active != 1 || stats.Inactive != 2 || stats.Left7d != 2 ||
+		stats.ByReason[models.InactiveBlocked] != 1 || stats.ByReason[models.InactiveChatNotFound] != 1 {
+		t.Errorf("Неверная статистика оттока: %+v", stats)
+	}
+
+	// /start после разблокировки возвращает пользователя в рассылки
+	if err := storage.AddUser(2, "bob"); err != nil {
+		t.Fatalf("Ошибка повторной регистрации: %v", err)
+	}
+	user, _ = storage.GetUser(2)
+	if !user.IsActive || user.InactiveReason != "" || user.ReactivatedAt == nil {
+		t.Errorf("Пользователь 2 должен быть активирован снова: %+v", user)
+	}
+	if stats, _ := storage.GetChurnStats(now.Add(time.Minute)); stats.Active != 2 || stats.Returned30 != 1 {
+		t.Errorf("Неверная статистика после возвращения: %+v", stats)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"testing"
 	"github.com/godofphonk/lovifyy-bot/internal/ai"
+	"github.com/godofphonk/lovifyy-bot/internal/config"
 )
 
 func TestOpenAIClient(t *testing.T) {
@@ -17,7 +18,7 @@ func TestOpenAIClient(t *testing.T) {
 		return
 	}
 	
-	client := ai.NewOpenAIClient("gpt-4o-mini")
+	client := ai.NewOpenAIClientFromConfig(config.OpenAIConfig{APIKey: apiKey, Model: "gpt-4o-mini", MaxTokens: 1500, Temperature: 0.7})
 	
 	// Проверяем подключение
 	if err := client.TestConnection(); err != nil {
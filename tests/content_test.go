@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/godofphonk/lovifyy-bot/internal/content"
+	"github.com/godofphonk/lovifyy-bot/internal/handlers"
+)
+
+func TestContentStoreVersions(t *testing.T) {
+	store := content.NewStore(t.TempDir(), func(key string) string {
+		if key == content.KeyWelcome {
+			return "Привет!"
+		}
+		return ""
+	})
+
+	if got := store.Get(content.KeyWelcome); got != "Привет!" {
+		t.Errorf("До изменений должен возвращаться исходный текст, получили '%s'", got)
+	}
+
+	v, err := store.Set(content.KeyWelcome, "Здравствуйте!", 42)
+	if err != nil {
+		t.Fatalf("Ошибка сохранения: %v", err)
+	}
+	// Первое изменение сохраняет исходный текст как версию 1
+	if v.Number != 2 {
+		t.Errorf("Ожидали версию 2, получили %d", v.Number)
+	}
+	if _, err := store.Set(content.KeyWelcome, "Здравствуйте!", 42); !errors.Is(err, content.ErrUnchanged) {
+		t.Errorf("Повторное сохранение того же текста должно вернуть ErrUnchanged, получили %v", err)
+	}
+
+	rolled, err := store.Rollback(content.KeyWelcome, 1, 42)
+	if err != nil {
+		t.Fatalf("Ошибка отката: %v", err)
+	}
+	if rolled.Number != 3 || rolled.RollbackOf != 1 || store.Get(content.KeyWelcome) != "Привет!" {
+		t.Errorf("Откат должен создать версию 3 с исходным текстом, получили %+v", rolled)
+	}
+
+	history, _ := store.History(content.KeyWelcome)
+	if len(history) != 3 {
+		t.Errorf("Ожидали 3 версии, получили %d", len(history))
+	}
+	if _, err := store.Rollback(content.KeyWelcome, 10, 42); err == nil {
+		t.Error("Откат к несуществующей версии должен вернуть ошибку")
+	}
+
+	// Без исходного текста первая версия - сам новый текст
+	v, _ = store.Set(content.KeySystemPrompt, "Ты психолог", 42)
+	if v.Number != 1 {
+		t.Errorf("Ожидали версию 1, получили %d", v.Number)
+	}
+}
+
+func TestContentDiff(t *testing.T) {
+	diff := content.Diff("один\nдва\nтри\nчетыре\nпять", "один\nдва\n3\nчетыре\nпять")
+
+	for _, want := range []string{"- три", "+ 3", "  два", "  четыре"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("Diff должен содержать '%s', получили:\n%s", want, diff)
+		}
+	}
+	if strings.Contains(diff, "один") {
+		t.Errorf("Далекие неизмененные строки должны сворачиваться, получили:\n%s", diff)
+	}
+}
+
+func TestParseWeekKey(t *testing.T) {
+	week, field, ok := content.ParseWeekKey(content.WeekKey(3, "joint"))
+	if !ok || week != 3 || field != "joint" {
+		t.Errorf("Ожидали неделю 3 и поле joint, получили %d %s %v", week, field, ok)
+	}
+	if _, _, ok := content.ParseWeekKey(content.KeyWelcome); ok {
+		t.Error("welcome не является ключом недели")
+	}
+}
+
+func TestCommandHandlerWelcomeText(t *testing.T) {
+	store := content.NewStore(t.TempDir(), func(key string) string {
+		if key == content.KeyWelcome {
+			return "Привет!"
+		}
+		return ""
+	})
+	handler := handlers.NewCommandHandler(nil, nil, nil, nil, nil, nil, nil, nil, store, nil)
+
+	if got := handler.WelcomeText(); got != "Привет!" {
+		t.Errorf("Ожидали приветствие из хранилища текстов, получили '%s'", got)
+	}
+	if _, err := store.Set(content.KeyWelcome, "Здравствуйте!", 42); err != nil {
+		t.Fatalf("Ошибка сохранения: %v", err)
+	}
+	if got := handler.WelcomeText(); got != "Здравствуйте!" {
+		t.Errorf("Приветствие должно учитывать правку /setwelcome, получили '%s'", got)
+	}
+}
@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/godofphonk/lovifyy-bot/internal/history"
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+)
+
+func TestCouplePairing(t *testing.T) {
+	dir := t.TempDir()
+	storage := models.NewCoupleStorage(dir)
+
+	inviterID, partnerID := int64(100), int64(200)
+
+	invite, err := storage.CreateInvite(inviterID, "female")
+	if err != nil {
+		t.Fatalf("Ошибка создания приглашения: %v", err)
+	}
+
+	if _, err := storage.AcceptInvite(invite.Code, inviterID); !errors.Is(err, models.ErrSelfInvite) {
+		t.Errorf("Ожидали ErrSelfInvite, получили %v", err)
+	}
+	if _, err := storage.AcceptInvite("UNKNOWN", partnerID); !errors.Is(err, models.ErrInviteNotFound) {
+		t.Errorf("Ожидали ErrInviteNotFound, получили %v", err)
+	}
+
+	couple, err := storage.AcceptInvite(invite.Code, partnerID)
+	if err != nil {
+		t.Fatalf("Ошибка принятия приглашения: %v", err)
+	}
+	if couple.FemaleID != inviterID || couple.MaleID != partnerID {
+		t.Errorf("Неверное распределение ролей: %+v", couple)
+	}
+
+	// Данные должны пережить перезапуск
+	reloaded := models.NewCoupleStorage(dir)
+	if reloaded.GenderOf(partnerID) != "male" || reloaded.PartnerOf(inviterID) != partnerID {
+		t.Error("Пара не восстановилась из файла")
+	}
+
+	if _, err := reloaded.CreateInvite(inviterID, "female"); !errors.Is(err, models.ErrAlreadyPaired) {
+		t.Errorf("Ожидали ErrAlreadyPaired, получили %v", err)
+	}
+
+	if _, err := reloaded.Unpair(partnerID); err != nil {
+		t.Fatalf("Ошибка разрыва пары: %v", err)
+	}
+	if reloaded.GetCouple(inviterID) != nil {
+		t.Error("Пара должна быть удалена")
+	}
+}
+
+func TestCoupleStorageCache(t *testing.T) {
+	dir := t.TempDir()
+	storage := models.NewCoupleStorage(dir)
+	invite, _ := storage.CreateInvite(100, "male")
+	if _, err := storage.AcceptInvite(invite.Code, 200); err != nil {
+		t.Fatalf("Ошибка принятия приглашения: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "couples.json.tmp")); !os.IsNotExist(err) {
+		t.Errorf("Временный файл не должен оставаться после записи: %v", err)
+	}
+
+	// Пары читаются из памяти, а не из файла при каждом обращении
+	if err := os.Remove(filepath.Join(dir, "couples.json")); err != nil {
+		t.Fatalf("Не удалось удалить файл: %v", err)
+	}
+	if storage.GenderOf(200) != "female" || len(storage.Members(100)) != 2 {
+		t.Error("Пара должна оставаться в памяти")
+	}
+
+	// Изменение копии не влияет на сохраненную пару
+	storage.GetCouple(100).MaleID = 999
+	if storage.PartnerOf(200) != 100 {
+		t.Error("GetCouple должен возвращать копию пары")
+	}
+}
+
+func TestCoupleDiaryEntries(t *testing.T) {
+	dir := t.TempDir()
+	storage := models.NewCoupleStorage(dir)
+	store, err := history.NewJSONStore(dir+"/chats", dir+"/diaries")
+	if err != nil {
+		t.Fatalf("Ошибка создания хранилища: %v", err)
+	}
+	manager := history.NewManagerWithStore(store)
+	manager.SetCoupleResolver(storage)
+
+	maleID, femaleID := int64(1), int64(2)
+	invite, _ := storage.CreateInvite(maleID, "male")
+	if _, err := storage.AcceptInvite(invite.Code, femaleID); err != nil {
+		t.Fatalf("Ошибка принятия приглашения: %v", err)
+	}
+
+	manager.SaveDiaryEntryWithGender(maleID, "him", "его ответ", 1, "joint", "male")
+	manager.SaveDiaryEntryWithGender(femaleID, "her", "ее ответ", 1, "joint", "female")
+
+	// Девушка должна видеть записи партнера
+	entries, err := manager.GetAllDiaryEntriesForWeekAndGender(femaleID, "male", 1)
+	if err != nil {
+		t.Fatalf("Ошибка выборки: %v", err)
+	}
+	if len(entries) != 1 || entries[0].UserID != maleID {
+		t.Errorf("Ожидали запись партнера, получили %+v", entries)
+	}
+}
@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/godofphonk/lovifyy-bot/internal/history"
+)
+
+func TestSortDiaryForView(t *testing.T) {
+	base := time.Date(2025, 10, 20, 10, 0, 0, 0, time.UTC)
+	entries := []history.DiaryEntry{
+		{ID: "1", Type: "joint", Timestamp: base},
+		{ID: "2", Type: "personal", Timestamp: base.Add(2 * time.Hour)},
+		{ID: "3", Type: "general", Timestamp: base},
+		{ID: "4", Type: "questions", Timestamp: base.Add(time.Hour)},
+		{ID: "5", Type: "personal", Timestamp: base.Add(time.Hour)},
+		{ID: "6", Type: "custom", Timestamp: base},
+	}
+	history.SortDiaryForView(entries)
+
+	want := []string{"5", "2", "4", "1", "6", "3"}
+	for i, entry := range entries {
+		if entry.ID != want[i] {
+			t.Fatalf("Неверный порядок записей: позиция %d - %s (%s), ожидали %s", i, entry.ID, entry.Type, want[i])
+		}
+	}
+}
+
+func TestTruncateRunes(t *testing.T) {
+	text := "Сегодня мы долго говорили о планах"
+	truncated := history.TruncateRunes(text, 10)
+	if !utf8.ValidString(truncated) {
+		t.Fatalf("Обрезанный текст содержит невалидный UTF-8: %q", truncated)
+	}
+	if truncated != "Сегодня м…" {
+		t.Errorf("Ожидали 'Сегодня м…', получили %q", truncated)
+	}
+	if history.TruncateRunes(text, 100) != text {
+		t.Error("Короткий текст не должен меняться")
+	}
+	if history.TruncateRunes(text, 0) != "" {
+		t.Error("Нулевой лимит должен давать пустую строку")
+	}
+}
+
+func TestUTF16Limits(t *testing.T) {
+	// Telegram считает длину в единицах UTF-16: эмодзи занимает две
+	text := strings.Repeat("❤️😊", 3000)
+	if got := history.UTF16Len("Привет😊"); got != 8 {
+		t.Errorf("Ожидали длину 8, получили %d", got)
+	}
+
+	truncated := history.TruncateUTF16(text, 4096)
+	if history.UTF16Len(truncated) > 4096 || !utf8.ValidString(truncated) {
+		t.Errorf("Обрезанный текст длиной %d не помещается в лимит", history.UTF16Len(truncated))
+	}
+	if history.TruncateUTF16("Коротко", 4096) != "Коротко" {
+		t.Error("Короткий текст не должен меняться")
+	}
+
+	parts := history.SplitUTF16(text, 4096)
+	if strings.Join(parts, "") != text {
+		t.Error("Части должны складываться в исходный текст")
+	}
+	for i, part := range parts {
+		if history.UTF16Len(part) > 4096 {
+			t.Errorf("Часть %d длиной %d превышает лимит", i, history.UTF16Len(part))
+		}
+	}
+
+	// Разрыв предпочитается на границе строки
+	lines := history.SplitUTF16(strings.Repeat("😊", 6)+"\n"+strings.Repeat("😊", 3), 16)
+	if len(lines) != 2 || lines[0] != strings.Repeat("😊", 6) {
+		t.Errorf("Ожидали разрыв по строке, получили %q", lines)
+	}
+}
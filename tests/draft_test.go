@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+	"github.com/godofphonk/lovifyy-bot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestFormatEntities(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		entities []tgbotapi.MessageEntity
+		want     string
+	}{
+		{"без форматирования", "a < b & c", nil, "a &lt; b &amp; c"},
+		{"жирный и курсив", "Привет мир", []tgbotapi.MessageEntity{
+			{Type: "bold", Offset: 0, Length: 6},
+			{Type: "italic", Offset: 7, Length: 3},
+		}, "<b>Привет</b> <i>мир</i>"},
+		{"вложенные сущности", "ab", []tgbotapi.MessageEntity{
+			{Type: "italic", Offset: 1, Length: 1},
+			{Type: "bold", Offset: 0, Length: 2},
+		}, "<b>a<i>b</i></b>"},
+		{"смещения в UTF-16 после эмодзи", "💌 дневник", []tgbotapi.MessageEntity{
+			{Type: "underline", Offset: 3, Length: 7},
+		}, "💌 <u>дневник</u>"},
+		{"ссылка", "сайт", []tgbotapi.MessageEntity{
+			{Type: "text_link", Offset: 0, Length: 4, URL: "https://example.com/?a=1&b=2"},
+		}, `<a href="https://example.com/?a=1&amp;b=2">сайт</a>`},
+		{"неподдерживаемые сущности", "@user", []tgbotapi.MessageEntity{
+			{Type: "mention", Offset: 0, Length: 5},
+		}, "@user"},
+	}
+	for _, tt := range tests {
+		if got := services.FormatEntities(tt.text, tt.entities); got != tt.want {
+			t.Errorf("%s: ожидали %q, получили %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestNotificationDraftSendAt(t *testing.T) {
+	var draft services.NotificationDraft
+	if draft.HasSendTime() {
+		t.Error("У нового черновика не должно быть времени отправки")
+	}
+	if _, err := draft.SendAt(); err == nil {
+		t.Error("Ожидали ошибку для черновика без времени")
+	}
+
+	at := time.Date(2025, 10, 15, 9, 30, 0, 0, time.UTC)
+	draft.SetSendAt(at)
+	if draft.Date != "15.10.2025" || draft.Time != "14:30" {
+		t.Errorf("Время должно сохраняться в UTC+5, получили %s %s", draft.Date, draft.Time)
+	}
+	sendAt, err := draft.SendAt()
+	if err != nil || !sendAt.Equal(at) {
+		t.Errorf("Ожидали %s, получили %s (%v)", at, sendAt, err)
+	}
+}
+
+// newTestNotificationService создает сервис уведомлений во временном каталоге:
+// сервис хранит данные по относительному пути data/
+func newTestNotificationService(t *testing.T) *services.NotificationService {
+	t.Helper()
+	chdirTemp(t)
+	return services.NewNotificationService(nil, nil)
+}
+
+// chdirTemp делает рабочим каталогом теста временный каталог
+func chdirTemp(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Не удалось определить рабочий каталог: %v", err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Не удалось перейти во временный каталог: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestScheduleDraftOnce(t *testing.T) {
+	ns := newTestNotificationService(t)
+	now := time.Now()
+	sendAt := now.Add(48 * time.Hour).In(services.ScheduleZone)
+
+	draft, err := ns.CreateDraft(1, "Привет!", sendAt.Format("02.01.2006"), sendAt.Format("15:04"))
+	if err != nil {
+		t.Fatalf("Ошибка создания черновика: %v", err)
+	}
+
+	// Параллельные нажатия «✅» планируют рассылку только один раз
+	var wg sync.WaitGroup
+	var scheduled atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := ns.ScheduleDraft(draft.ID, now); err == nil {
+				scheduled.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := scheduled.Load(); got != 1 {
+		t.Errorf("Черновик должен быть запланирован один раз, запланирован %d", got)
+	}
+	jobs, err := ns.LoadSchedule()
+	if err != nil || len(jobs) != 1 {
+		t.Errorf("Ожидали одно задание, получили %d (%v)", len(jobs), err)
+	}
+	if _, err := ns.GetDraft(draft.ID); err == nil {
+		t.Error("Запланированный черновик должен быть удален")
+	}
+}
+
+func TestScheduleDraftRejectsInvalid(t *testing.T) {
+	ns := newTestNotificationService(t)
+	now := time.Now()
+	sendAt := now.Add(time.Hour).In(services.ScheduleZone)
+
+	empty, _ := ns.CreateDraft(1, "", sendAt.Format("02.01.2006"), sendAt.Format("15:04"))
+	if _, _, err := ns.ScheduleDraft(empty.ID, now); err == nil {
+		t.Error("Пустой черновик не должен планироваться")
+	}
+	past, _ := ns.CreateDraft(1, "Привет!", "01.01.2020", "10:00")
+	if _, _, err := ns.ScheduleDraft(past.ID, now); err == nil {
+		t.Error("Черновик с прошедшим временем не должен планироваться")
+	}
+
+	// Отклоненные черновики остаются для исправления
+	for _, id := range []string{empty.ID, past.ID} {
+		if _, err := ns.GetDraft(id); err != nil {
+			t.Errorf("Черновик %s должен сохраниться: %v", id, err)
+		}
+	}
+	if jobs, _ := ns.LoadSchedule(); len(jobs) != 0 {
+		t.Errorf("Не должно быть заданий, получили %d", len(jobs))
+	}
+}
+
+func TestScheduleConcurrent(t *testing.T) {
+	ns := newTestNotificationService(t)
+	sendAt := time.Now().Add(time.Hour)
+
+	// Параллельное планирование не должно терять задания
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ns.Schedule(sendAt, models.NotificationCustom, services.ScheduleOptions{CustomText: "Привет!"}); err != nil {
+				t.Errorf("Ошибка планирования: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	jobs, err := ns.LoadSchedule()
+	if err != nil || len(jobs) != 20 {
+		t.Fatalf("Ожидали 20 заданий, получили %d (%v)", len(jobs), err)
+	}
+	if err := ns.CancelScheduled(jobs[0].ID); err != nil {
+		t.Fatalf("Ошибка отмены: %v", err)
+	}
+	if jobs, _ := ns.LoadSchedule(); len(jobs) >= 20 {
+		t.Errorf("Задание не отменено, осталось %d", len(jobs))
+	}
+}
@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+	"github.com/godofphonk/lovifyy-bot/internal/services"
+)
+
+func TestAssignVariant(t *testing.T) {
+	variants := []string{"A", "B"}
+	counts := make(map[string]int)
+	for userID := int64(1); userID <= 1000; userID++ {
+		variant := services.AssignVariant(userID, models.NotificationDiary, variants)
+		if again := services.AssignVariant(userID, models.NotificationDiary, variants); again != variant {
+			t.Fatalf("Пользователь %d получил разные варианты: %s и %s", userID, variant, again)
+		}
+		counts[variant]++
+	}
+	// Распределение близко к равному
+	if counts["A"] < 400 || counts["B"] < 400 {
+		t.Errorf("Неравномерное распределение вариантов: %v", counts)
+	}
+	if services.AssignVariant(1, models.NotificationDiary, nil) != "" {
+		t.Error("Без вариантов ожидали пустой результат")
+	}
+}
+
+func TestEngagementTracker(t *testing.T) {
+	dir := t.TempDir()
+	tracker := services.NewEngagementTracker(dir)
+	sentAt := time.Date(2025, 10, 20, 10, 0, 0, 0, time.UTC)
+
+	if err := tracker.RecordSent(models.NotificationDiary, map[int64]string{1: "A", 2: "A", 3: "B"}, sentAt); err != nil {
+		t.Fatalf("Ошибка записи доставки: %v", err)
+	}
+	tracker.RecordEvent(1, services.EngagementOpen, sentAt.Add(time.Hour))
+	tracker.RecordEvent(1, services.EngagementDiary, sentAt.Add(2*time.Hour))
+	tracker.RecordEvent(2, services.EngagementOpen, sentAt.Add(30*time.Hour))
+	tracker.RecordEvent(3, services.EngagementClick, sentAt.Add(time.Hour))
+	// Действие после окна наблюдения не засчитывается
+	tracker.RecordEvent(3, services.EngagementDiary, sentAt.Add(services.EngagementMaxWindow+time.Hour))
+	// Пользователю без доставок засчитывать нечего
+	tracker.RecordEvent(42, services.EngagementOpen, sentAt.Add(time.Hour))
+
+	// Данные переживают перезапуск
+	tracker = services.NewEngagementTracker(dir)
+
+	now := sentAt.Add(80 * time.Hour)
+	report := tracker.Report(24*time.Hour, now)
+	if len(report) != 2 {
+		t.Fatalf("Ожидали отчет по двум вариантам, получили %+v", report)
+	}
+	a, b := report[0], report[1]
+	if a.Variant != "A" || a.Sent != 2 || a.Opened != 1 || a.Diary != 1 || a.Clicked != 0 {
+		t.Errorf("Неверная статистика варианта A за 24 часа: %+v", a)
+	}
+	if b.Variant != "B" || b.Sent != 1 || b.Clicked != 1 || b.Diary != 0 {
+		t.Errorf("Неверная статистика варианта B: %+v", b)
+	}
+	if rate := a.Rate(a.Opened); rate != 50 {
+		t.Errorf("Ожидали 50%% заходов, получили %.1f", rate)
+	}
+
+	// В окне 48 часов засчитывается и поздний заход второго пользователя
+	if a := tracker.Report(48*time.Hour, now)[0]; a.Opened != 2 {
+		t.Errorf("За 48 часов ожидали 2 захода, получили %d", a.Opened)
+	}
+	// Пока окно не закончилось, доставка не попадает в долю
+	if a := tracker.Report(24*time.Hour, sentAt.Add(time.Hour))[0]; a.Sent != 0 || a.Pending != 2 {
+		t.Errorf("Ожидали 2 доставки в ожидании, получили %+v", a)
+	}
+}
+
+func TestTemplateVariantsConcurrentEdits(t *testing.T) {
+	ns := newTestNotificationService(t)
+
+	// Администратор меняет варианты, пока рассылки читают шаблоны
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			variant, err := ns.AddTemplateVariant(models.NotificationDiary, "Вариант")
+			if err != nil {
+				t.Errorf("Ошибка добавления варианта: %v", err)
+				return
+			}
+			if err := ns.DeleteTemplateVariant(models.NotificationDiary, variant.Variant); err != nil {
+				t.Errorf("Ошибка удаления варианта: %v", err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			if _, err := ns.GenerateNotification(models.NotificationDiary); err != nil {
+				t.Errorf("Ошибка генерации уведомления: %v", err)
+				return
+			}
+			ns.GetTemplates()
+		}
+	}()
+	wg.Wait()
+
+	count := 0
+	for _, template := range ns.GetTemplates() {
+		if template.Type == models.NotificationDiary {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("После удаления вариантов должен остаться один шаблон, осталось %d", count)
+	}
+}
@@ -0,0 +1,293 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/config"
+	"github.com/godofphonk/lovifyy-bot/internal/history"
+)
+
+// newTestStores создает хранилища всех поддерживаемых драйверов во временной папке
+func newTestStores(t *testing.T) map[string]history.Store {
+	dir := t.TempDir()
+	stores := make(map[string]history.Store)
+
+	for _, driver := range []string{history.DriverJSON, history.DriverSQLite} {
+		store, err := history.NewStore(config.DatabaseConfig{
+			Driver:     driver,
+			ChatsDir:   filepath.Join(dir, driver, "chats"),
+			DiariesDir: filepath.Join(dir, driver, "diaries"),
+			SQLitePath: filepath.Join(dir, driver, "lovifyy.db"),
+		})
+		if err != nil {
+			t.Fatalf("Не удалось создать хранилище %s: %v", driver, err)
+		}
+		t.Cleanup(func() { store.Close() })
+		stores[driver] = store
+	}
+	return stores
+}
+
+func TestHistoryStoreChat(t *testing.T) {
+	for driver, store := range newTestStores(t) {
+		t.Run(driver, func(t *testing.T) {
+			manager := history.NewManagerWithStore(store)
+			userID := int64(12345)
+
+			for i := 0; i < 5; i++ {
+				if err := manager.SaveMessage(userID, "user", fmt.Sprintf("msg %d", i), "<think>x</think>ответ", "test"); err != nil {
+					t.Fatalf("Ошибка сохранения сообщения: %v", err)
+				}
+			}
+
+			messages, err := manager.GetUserHistory(userID, 3)
+			if err != nil {
+				t.Fatalf("Ошибка получения истории: %v", err)
+			}
+			if len(messages) != 3 || messages[0].Message != "msg 2" || messages[2].Message != "msg 4" {
+				t.Errorf("Ожидали последние 3 сообщения по порядку, получили %+v", messages)
+			}
+			if messages[0].Response != "ответ" {
+				t.Errorf("Ответ должен быть очищен от блока размышлений, получили '%s'", messages[0].Response)
+			}
+
+			if err := manager.ClearUserHistory(userID); err != nil {
+				t.Fatalf("Ошибка очистки истории: %v", err)
+			}
+			messages, _ = manager.GetUserHistory(userID, 0)
+			if len(messages) != 0 {
+				t.Errorf("История должна быть пустой, получили %d сообщений", len(messages))
+			}
+		})
+	}
+}
+
+func TestHistoryStoreDiaryQueries(t *testing.T) {
+	for driver, store := range newTestStores(t) {
+		t.Run(driver, func(t *testing.T) {
+			manager := history.NewManagerWithStore(store)
+			userID := int64(777)
+
+			manager.SaveDiaryEntryWithGender(userID, "user", "мысли 1", 1, "personal", "male")
+			manager.SaveDiaryEntryWithGender(userID, "user", "ответ 1", 1, "questions", "male")
+			manager.SaveDiaryEntryWithGender(userID, "user", "ответ 2", 2, "questions", "female")
+			manager.SaveDiaryEntry(userID, "user", "общая запись", 1, "general")
+			manager.SaveDiaryEntryWithGender(888, "other", "чужая запись", 1, "personal", "male")
+
+			entries, err := manager.GetAllDiaryEntriesForWeekAndGender(userID, "male", 1)
+			if err != nil {
+				t.Fatalf("Ошибка выборки: %v", err)
+			}
+			if len(entries) != 2 {
+				t.Errorf("Ожидали 2 записи за неделю 1 (male), получили %d", len(entries))
+			}
+			for _, entry := range entries {
+				if entry.Gender != "male" || entry.Week != 1 {
+					t.Errorf("Запись не соответствует фильтру: %+v", entry)
+				}
+			}
+
+			byType, _ := manager.GetDiaryEntriesByType(userID, "questions")
+			if len(byType) != 2 {
+				t.Errorf("Ожидали 2 записи типа questions, получили %d", len(byType))
+			}
+
+			byWeek, _ := manager.QueryDiary(history.DiaryQuery{Week: 1, Gender: "male"})
+			if len(byWeek) != 3 {
+				t.Errorf("Ожидали 3 записи недели 1 (male) всех пользователей, получили %d", len(byWeek))
+			}
+
+			all, _ := manager.GetUserDiary(userID, 0)
+			if len(all) != 4 {
+				t.Errorf("Ожидали 4 записи пользователя, получили %d", len(all))
+			}
+
+			if err := manager.ClearUserDiary(userID); err != nil {
+				t.Fatalf("Ошибка очистки дневника: %v", err)
+			}
+			all, _ = manager.GetUserDiary(userID, 0)
+			if len(all) != 0 {
+				t.Errorf("Дневник должен быть пустым, получили %d записей", len(all))
+			}
+		})
+	}
+}
+
+func TestHistoryStoreDiaryEditDelete(t *testing.T) {
+	for driver, store := range newTestStores(t) {
+		t.Run(driver, func(t *testing.T) {
+			manager := history.NewManagerWithStore(store)
+			userID := int64(555)
+
+			manager.SaveDiaryEntryWithGender(userID, "user", "первая", 1, "personal", "male")
+			manager.SaveDiaryEntryWithGender(userID, "user", "вторая с опечткой", 1, "questions", "male")
+			entries, _ := manager.GetUserDiary(userID, 0)
+			if len(entries) != 2 || entries[0].ID == "" || entries[0].ID == entries[1].ID {
+				t.Fatalf("Ожидали 2 записи с разными идентификаторами, получили %+v", entries)
+			}
+			id := entries[1].ID
+
+			if err := manager.EditDiaryEntry(userID, id, "вторая с опечаткой"); err != nil {
+				t.Fatalf("Ошибка исправления: %v", err)
+			}
+			entry, err := manager.GetDiaryEntry(userID, id)
+			if err != nil {
+				t.Fatalf("Запись не найдена после исправления: %v", err)
+			}
+			if entry.Entry != "вторая с опечаткой" || len(entry.Edits) != 1 || entry.Edits[0].Entry != "вторая с опечткой" {
+				t.Errorf("Неверная запись после исправления: %+v", entry)
+			}
+			if _, ok := entry.EditedAt(); !ok {
+				t.Error("Ожидали время правки")
+			}
+
+			// Чужую запись исправить и удалить нельзя
+			if err := manager.EditDiaryEntry(999, id, "взлом"); !errors.Is(err, history.ErrDiaryEntryNotFound) {
+				t.Errorf("Ожидали ErrDiaryEntryNotFound для чужой записи, получили %v", err)
+			}
+			if err := manager.DeleteDiaryEntry(999, id); !errors.Is(err, history.ErrDiaryEntryNotFound) {
+				t.Errorf("Ожидали ErrDiaryEntryNotFound при удалении чужой записи, получили %v", err)
+			}
+
+			if err := manager.DeleteDiaryEntry(userID, entries[0].ID); err != nil {
+				t.Fatalf("Ошибка удаления: %v", err)
+			}
+			entries, _ = manager.GetUserDiary(userID, 0)
+			if len(entries) != 1 || entries[0].ID != id {
+				t.Errorf("После удаления ожидали только исправленную запись, получили %+v", entries)
+			}
+		})
+	}
+}
+
+func TestHistoryStoreDiarySameTimestamp(t *testing.T) {
+	for driver, store := range newTestStores(t) {
+		t.Run(driver, func(t *testing.T) {
+			userID := int64(556)
+			timestamp := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+			// Записи с одним временем попадают в разные файлы (тип и неделя)
+			first, err := store.AppendDiaryEntry(history.DiaryEntry{Timestamp: timestamp, UserID: userID, Entry: "первая", Week: 1, Type: "personal", Gender: "male"})
+			if err != nil {
+				t.Fatalf("Ошибка записи: %v", err)
+			}
+			second, err := store.AppendDiaryEntry(history.DiaryEntry{Timestamp: timestamp, UserID: userID, Entry: "вторая", Week: 2, Type: "questions", Gender: "male"})
+			if err != nil {
+				t.Fatalf("Ошибка записи: %v", err)
+			}
+			if first == second {
+				t.Fatalf("Записи с одинаковым временем получили один ID %q", first)
+			}
+
+			if err := store.UpdateDiaryEntry(userID, second, "вторая исправленная", timestamp.Add(time.Minute)); err != nil {
+				t.Fatalf("Ошибка исправления: %v", err)
+			}
+			if err := store.SetDiaryMood(userID, second, "4"); err != nil {
+				t.Fatalf("Ошибка оценки: %v", err)
+			}
+			if err := store.SetDiaryTags(userID, second, []string{"работа"}); err != nil {
+				t.Fatalf("Ошибка тегов: %v", err)
+			}
+
+			entries, _ := store.DiaryEntries(history.DiaryQuery{UserID: userID})
+			byID := make(map[string]history.DiaryEntry)
+			for _, entry := range entries {
+				byID[entry.ID] = entry
+			}
+			if entry := byID[first]; entry.Entry != "первая" || entry.Mood != "" || len(entry.Tags) != 0 {
+				t.Errorf("Правки второй записи задели первую: %+v", entry)
+			}
+			if entry := byID[second]; entry.Entry != "вторая исправленная" || entry.Mood != "4" || len(entry.Tags) != 1 {
+				t.Errorf("Неверная вторая запись: %+v", entry)
+			}
+
+			if err := store.DeleteDiaryEntry(userID, second); err != nil {
+				t.Fatalf("Ошибка удаления: %v", err)
+			}
+			entries, _ = store.DiaryEntries(history.DiaryQuery{UserID: userID})
+			if len(entries) != 1 || entries[0].ID != first {
+				t.Errorf("После удаления ожидали только первую запись, получили %+v", entries)
+			}
+		})
+	}
+}
+
+func TestHistoryStoreDiaryEditRetags(t *testing.T) {
+	for driver, store := range newTestStores(t) {
+		t.Run(driver, func(t *testing.T) {
+			manager := history.NewManagerWithStore(store)
+			userID := int64(556)
+
+			entry, err := manager.AddDiaryEntry(history.DiaryEntry{UserID: userID, Entry: "#ссора вчера", Week: 1, Type: "personal"})
+			if err != nil {
+				t.Fatalf("Ошибка сохранения записи: %v", err)
+			}
+
+			// Хэштеги нового текста заменяют прежние
+			if err := manager.EditDiaryEntry(userID, entry.ID, "#примирение сегодня"); err != nil {
+				t.Fatalf("Ошибка исправления: %v", err)
+			}
+			edited, _ := manager.GetDiaryEntry(userID, entry.ID)
+			if len(edited.Tags) != 1 || edited.Tags[0] != "примирение" {
+				t.Errorf("Ожидали тег примирение, получили %v", edited.Tags)
+			}
+
+			// Без хэштегов теги очищаются и выделяются моделью по новому тексту
+			if err := manager.EditDiaryEntry(userID, entry.ID, "гуляли в парке"); err != nil {
+				t.Fatalf("Ошибка исправления: %v", err)
+			}
+			edited, _ = manager.GetDiaryEntry(userID, entry.ID)
+			if len(edited.Tags) != 0 {
+				t.Errorf("Устаревшие теги должны очиститься, получили %v", edited.Tags)
+			}
+			tags, err := manager.TagDiaryEntry(userID, entry.ID, func(prompt string) (string, error) {
+				return "прогулка", nil
+			})
+			if err != nil || len(tags) != 1 || tags[0] != "прогулка" {
+				t.Errorf("Ожидали тег прогулка, получили %v (%v)", tags, err)
+			}
+		})
+	}
+}
+
+func TestHistoryStoreConcurrentWrites(t *testing.T) {
+	for driver, store := range newTestStores(t) {
+		t.Run(driver, func(t *testing.T) {
+			userID := int64(4242)
+			const writers = 20
+
+			var wg sync.WaitGroup
+			for i := 0; i < writers; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					entry := history.DiaryEntry{
+						Timestamp: time.Now(),
+						UserID:    userID,
+						Entry:     fmt.Sprintf("entry %d", i),
+						Week:      1,
+						Type:      "joint",
+						Gender:    "female",
+					}
+					if _, err := store.AppendDiaryEntry(entry); err != nil {
+						t.Errorf("Ошибка конкурентной записи: %v", err)
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			entries, err := store.DiaryEntries(history.DiaryQuery{UserID: userID})
+			if err != nil {
+				t.Fatalf("Ошибка выборки: %v", err)
+			}
+			if len(entries) != writers {
+				t.Errorf("Ожидали %d записей, получили %d", writers, len(entries))
+			}
+		})
+	}
+}
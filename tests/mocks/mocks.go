@@ -6,6 +6,7 @@ import (
 	"github.com/godofphonk/lovifyy-bot/internal/ai"
 	"github.com/godofphonk/lovifyy-bot/internal/history"
 	"github.com/godofphonk/lovifyy-bot/internal/models"
+	"github.com/godofphonk/lovifyy-bot/internal/services"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -166,9 +167,9 @@ func (m *MockHistoryManager) GetDiaryEntries(userID int64, week int, entryType s
 // MockNotificationService мок для сервиса уведомлений
 type MockNotificationService struct {
 	GenerateNotificationFunc      func(notificationType models.NotificationType) (string, error)
-	SendNotificationToAllFunc     func(message string) error
+	SendNotificationToAllFunc     func(message string) (*services.BroadcastReport, error)
 	SendNotificationToUserFunc    func(userID int64, message string) error
-	SendInstantNotificationFunc   func(notificationType models.NotificationType, recipients []int64) error
+	SendInstantNotificationFunc   func(notificationType models.NotificationType, recipients []int64) (*services.BroadcastReport, error)
 	GetTemplatesFunc              func() []models.NotificationTemplate
 	UpdateTemplateFunc            func(notificationType models.NotificationType, prompt string, isActive bool) error
 	AddTemplateFunc               func(template models.NotificationTemplate)
@@ -184,12 +185,12 @@ func (m *MockNotificationService) GenerateNotification(notificationType models.N
 	return "Mock notification: " + string(notificationType), nil
 }
 
-func (m *MockNotificationService) SendNotificationToAll(message string) error {
+func (m *MockNotificationService) SendNotificationToAll(message string) (*services.BroadcastReport, error) {
 	if m.SendNotificationToAllFunc != nil {
 		return m.SendNotificationToAllFunc(message)
 	}
 	m.SentNotifications = append(m.SentNotifications, message)
-	return nil
+	return &services.BroadcastReport{}, nil
 }
 
 func (m *MockNotificationService) SendNotificationToUser(userID int64, message string) error {
@@ -201,14 +202,14 @@ func (m *MockNotificationService) SendNotificationToUser(userID int64, message s
 	return nil
 }
 
-func (m *MockNotificationService) SendInstantNotification(notificationType models.NotificationType, recipients []int64) error {
+func (m *MockNotificationService) SendInstantNotification(notificationType models.NotificationType, recipients []int64) (*services.BroadcastReport, error) {
 	if m.SendInstantNotificationFunc != nil {
 		return m.SendInstantNotificationFunc(notificationType, recipients)
 	}
 	message := "Mock notification: " + string(notificationType)
 	m.SentNotifications = append(m.SentNotifications, message)
 	m.Recipients = append(m.Recipients, recipients...)
-	return nil
+	return &services.BroadcastReport{Total: len(recipients), Sent: len(recipients)}, nil
 }
 
 func (m *MockNotificationService) GetTemplates() []models.NotificationTemplate {
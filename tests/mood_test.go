@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/godofphonk/lovifyy-bot/internal/history"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	tags := history.ExtractHashtags("Поговорили о #Планах и #быт, снова #планах. Без тега: # и email@test")
+	if want := []string{"планах", "быт"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("Ожидали %v, получили %v", want, tags)
+	}
+	if tags := history.ExtractHashtags("без тегов"); len(tags) != 0 {
+		t.Errorf("Ожидали пустой список, получили %v", tags)
+	}
+}
+
+func TestDiaryMoodAndTags(t *testing.T) {
+	for driver, store := range newTestStores(t) {
+		t.Run(driver, func(t *testing.T) {
+			manager := history.NewManagerWithStore(store)
+			userID := int64(321)
+
+			add := func(text string, week int) history.DiaryEntry {
+				entry, err := manager.AddDiaryEntry(history.DiaryEntry{UserID: userID, Entry: text, Week: week, Type: "personal", Gender: "female"})
+				if err != nil {
+					t.Fatalf("Ошибка сохранения записи: %v", err)
+				}
+				return entry
+			}
+
+			tagged := add("Спокойный вечер #отдых", 1)
+			if !reflect.DeepEqual(tagged.Tags, []string{"отдых"}) {
+				t.Errorf("Хэштеги должны стать тегами записи, получили %v", tagged.Tags)
+			}
+			// Отмеченные пользователем теги модель не переписывает
+			tags, err := manager.TagDiaryEntry(userID, tagged.ID, func(string) (string, error) {
+				t.Error("Модель не должна вызываться для записи с хэштегами")
+				return "", nil
+			})
+			if err != nil || !reflect.DeepEqual(tags, []string{"отдых"}) {
+				t.Errorf("Ожидали сохраненные теги, получили %v (%v)", tags, err)
+			}
+
+			plain := add("Поругались из-за уборки, потом помирились", 1)
+			tags, err = manager.TagDiaryEntry(userID, plain.ID, func(string) (string, error) {
+				return "<think>...</think>#Ссора, быт; примирение, лишний", nil
+			})
+			if err != nil {
+				t.Fatalf("Ошибка выделения тегов: %v", err)
+			}
+			if want := []string{"ссора", "быт", "примирение"}; !reflect.DeepEqual(tags, want) {
+				t.Errorf("Ожидали теги %v, получили %v", want, tags)
+			}
+
+			late := add("Неделя прошла хорошо", 3)
+			for id, score := range map[string]int{tagged.ID: 4, plain.ID: 2, late.ID: 5} {
+				if err := manager.SetDiaryMood(userID, id, score); err != nil {
+					t.Fatalf("Ошибка сохранения настроения: %v", err)
+				}
+			}
+			if err := manager.SetDiaryMood(999, late.ID, 1); err == nil {
+				t.Error("Чужой записи нельзя ставить настроение")
+			}
+			if err := manager.SetDiaryMood(userID, late.ID, 6); err == nil {
+				t.Error("Оценка вне шкалы должна отклоняться")
+			}
+
+			trends, err := manager.MoodTrends(userID, 4)
+			if err != nil || len(trends) != 1 {
+				t.Fatalf("Ожидали динамику одного пользователя, получили %+v (%v)", trends, err)
+			}
+			trend := trends[0]
+			if trend.Gender != "female" {
+				t.Errorf("Ожидали гендер female, получили %q", trend.Gender)
+			}
+			if w := trend.Weeks[0]; w.Count != 2 || w.Average != 3 {
+				t.Errorf("Неделя 1: ожидали 2 оценки со средним 3, получили %+v", w)
+			}
+			if w := trend.Weeks[1]; w.Count != 0 {
+				t.Errorf("Неделя 2 должна быть без оценок, получили %+v", w)
+			}
+			if change, ok := trend.Change(); !ok || change != 2 {
+				t.Errorf("Ожидали рост настроения на 2, получили %.1f (%v)", change, ok)
+			}
+			if len(trend.TopTags) != 3 {
+				t.Errorf("Ожидали 3 частых тега, получили %v", trend.TopTags)
+			}
+		})
+	}
+}
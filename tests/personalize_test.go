@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// textSender запоминает текст, отправленный каждому получателю
+type textSender struct {
+	mutex sync.Mutex
+	texts map[int64]string
+}
+
+func (s *textSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	msg := c.(tgbotapi.MessageConfig)
+	s.mutex.Lock()
+	s.texts[msg.ChatID] = msg.Text
+	s.mutex.Unlock()
+	return tgbotapi.Message{}, nil
+}
+
+func TestPersonalPrompt(t *testing.T) {
+	prompt := services.PersonalPrompt("Напомни о дневнике.", services.RecipientProfile{
+		Week:           2,
+		WeekTitle:      "Доверие",
+		DaysSinceDiary: 5,
+		PartnerName:    "Маша",
+	})
+	for _, want := range []string{"Напомни о дневнике.", "2 «Доверие»", "5 дн. назад", "Маша"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("В промпте нет %q:\n%s", want, prompt)
+		}
+	}
+
+	prompt = services.PersonalPrompt("Напомни о дневнике.", services.RecipientProfile{Week: 1, DaysSinceDiary: -1})
+	if !strings.Contains(prompt, "еще не было записей") || !strings.Contains(prompt, "без партнера") {
+		t.Errorf("Промпт без записей и без пары описан неверно:\n%s", prompt)
+	}
+}
+
+func TestBroadcastQueuePersonalMessages(t *testing.T) {
+	sender := &textSender{texts: make(map[int64]string)}
+	queue := services.NewBroadcastQueue(sender, services.NewTokenBucket(1000, 1000), t.TempDir())
+	stop := make(chan struct{})
+	defer close(stop)
+	go queue.Run(stop)
+
+	messages := map[int64]string{1: "Привет, Аня", 2: "Привет, Петя"}
+	_, done, err := queue.EnqueuePersonal("Привет всем", messages, "", []int64{1, 2, 3}, 0)
+	if err != nil {
+		t.Fatalf("Ошибка постановки в очередь: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Рассылка не завершилась")
+	}
+
+	want := map[int64]string{1: "Привет, Аня", 2: "Привет, Петя", 3: "Привет всем"}
+	for userID, text := range want {
+		if sender.texts[userID] != text {
+			t.Errorf("Получатель %d: ожидали %q, получили %q", userID, text, sender.texts[userID])
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/godofphonk/lovifyy-bot/internal/ai"
+	"github.com/godofphonk/lovifyy-bot/internal/history"
+)
+
+func TestImageMessageJSON(t *testing.T) {
+	data, err := json.Marshal(ai.ImageMessage("Опиши фото", []byte{0xff, 0xd8}, "image/jpeg"))
+	if err != nil {
+		t.Fatalf("Ошибка кодирования: %v", err)
+	}
+	want := `{"role":"user","content":[{"type":"text","text":"Опиши фото"},{"type":"image_url","image_url":{"url":"data:image/jpeg;base64,/9g="}}]}`
+	if string(data) != want {
+		t.Errorf("Неверный JSON сообщения с изображением:\n%s", data)
+	}
+
+	// Обычные сообщения кодируются как раньше
+	data, _ = json.Marshal(ai.OpenAIMessage{Role: "user", Content: "Привет"})
+	if string(data) != `{"role":"user","content":"Привет"}` {
+		t.Errorf("Неверный JSON текстового сообщения: %s", data)
+	}
+}
+
+func TestDiaryEntryInsightText(t *testing.T) {
+	photo := &history.DiaryAttachment{Kind: history.AttachmentPhoto, FileID: "photo", Description: "Пара ужинает при свечах"}
+	cases := []struct {
+		entry history.DiaryEntry
+		want  string
+	}{
+		{history.DiaryEntry{Entry: "Текст"}, "Текст"},
+		{history.DiaryEntry{Entry: "Наше свидание", Attachment: photo}, "Наше свидание [Фото: Пара ужинает при свечах]"},
+		{history.DiaryEntry{Attachment: &history.DiaryAttachment{Kind: history.AttachmentPhoto}}, "[Фото]"},
+		{history.DiaryEntry{Entry: "Расшифровка", Attachment: &history.DiaryAttachment{Kind: history.AttachmentVoice}}, "Расшифровка"},
+	}
+	for _, c := range cases {
+		if got := c.entry.InsightText(); got != c.want {
+			t.Errorf("Ожидали %q, получили %q", c.want, got)
+		}
+	}
+}
+
+func TestDescribeDiaryPhoto(t *testing.T) {
+	for driver, store := range newTestStores(t) {
+		t.Run(driver, func(t *testing.T) {
+			manager := history.NewManagerWithStore(store)
+			userID := int64(55)
+
+			entry, err := manager.AddDiaryEntry(history.DiaryEntry{
+				UserID:     userID,
+				Entry:      "Наше свидание",
+				Week:       2,
+				Type:       "personal",
+				Attachment: &history.DiaryAttachment{Kind: history.AttachmentPhoto, FileID: "AgACAgIAAxkBAAI"},
+			})
+			if err != nil {
+				t.Fatalf("Ошибка сохранения записи: %v", err)
+			}
+
+			// Без описания фото без подписи не отправляется модели для тегов
+			untitled, _ := manager.AddDiaryEntry(history.DiaryEntry{
+				UserID:     userID,
+				Week:       2,
+				Type:       "personal",
+				Attachment: &history.DiaryAttachment{Kind: history.AttachmentPhoto, FileID: "AgACAgIAAxkBAAJ"},
+			})
+			tags, err := manager.TagDiaryEntry(userID, untitled.ID, func(string) (string, error) {
+				t.Error("Модель не должна вызываться для фото без подписи и описания")
+				return "", nil
+			})
+			if err != nil || len(tags) != 0 {
+				t.Errorf("Ожидали отсутствие тегов, получили %v (%v)", tags, err)
+			}
+
+			description, err := manager.DescribeDiaryPhoto(userID, entry.ID, func(prompt string) (string, error) {
+				if !strings.Contains(prompt, "Подпись автора: Наше свидание") {
+					t.Errorf("Промпт должен содержать подпись: %q", prompt)
+				}
+				return "<think>...</think>\n  Пара ужинает\nпри свечах в ресторане. ", nil
+			})
+			if err != nil {
+				t.Fatalf("Ошибка описания фото: %v", err)
+			}
+			if description != "Пара ужинает при свечах в ресторане." {
+				t.Errorf("Неверное описание: %q", description)
+			}
+
+			// Повторно фото не описывается
+			again, err := manager.DescribeDiaryPhoto(userID, entry.ID, func(string) (string, error) {
+				return "", errors.New("модель не должна вызываться повторно")
+			})
+			if err != nil || again != description {
+				t.Errorf("Ожидали сохраненное описание, получили %q (%v)", again, err)
+			}
+
+			saved, err := manager.GetDiaryEntry(userID, entry.ID)
+			if err != nil {
+				t.Fatalf("Запись не найдена: %v", err)
+			}
+			if saved.Attachment == nil || saved.Attachment.FileID != "AgACAgIAAxkBAAI" || saved.Attachment.Description != description {
+				t.Errorf("Вложение сохранилось неверно: %+v", saved.Attachment)
+			}
+
+			// Теги строятся по подписи вместе с описанием фото
+			tags, err = manager.TagDiaryEntry(userID, entry.ID, func(prompt string) (string, error) {
+				if !strings.Contains(prompt, "[Фото: Пара ужинает при свечах в ресторане.]") {
+					t.Errorf("Промпт должен содержать описание фото: %q", prompt)
+				}
+				return "свидание, ужин", nil
+			})
+			if err != nil || !reflect.DeepEqual(tags, []string{"свидание", "ужин"}) {
+				t.Errorf("Неверные теги: %v (%v)", tags, err)
+			}
+
+			text, _ := manager.AddDiaryEntry(history.DiaryEntry{UserID: userID, Entry: "Текст", Week: 2, Type: "personal"})
+			if _, err := manager.DescribeDiaryPhoto(userID, text.ID, func(string) (string, error) { return "x", nil }); err == nil {
+				t.Error("Ожидали ошибку для записи без фото")
+			}
+		})
+	}
+}
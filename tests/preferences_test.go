@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+	"github.com/godofphonk/lovifyy-bot/internal/services"
+)
+
+func TestNotificationPreferencesDeliverAt(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skip("нет базы часовых поясов")
+	}
+	local := func(value string) time.Time {
+		parsed, err := time.ParseInLocation("02.01.2006 15:04", value, moscow)
+		if err != nil {
+			t.Fatalf("Некорректное время %s: %v", value, err)
+		}
+		return parsed
+	}
+
+	prefs := models.NotificationPreferences{QuietFrom: "23:00", QuietTo: "08:00", ReminderTime: "20:00"}
+	prefs.Toggle(models.NotificationMotivation)
+
+	tests := []struct {
+		name     string
+		typ      models.NotificationType
+		now      string
+		reminder bool
+		want     string
+		muted    bool
+	}{
+		{"днем без напоминания", models.NotificationCustom, "13.10.2025 12:00", false, "13.10.2025 12:00", false},
+		{"тихие часы до полуночи", models.NotificationCustom, "13.10.2025 23:30", false, "14.10.2025 08:00", false},
+		{"тихие часы после полуночи", models.NotificationCustom, "14.10.2025 02:00", false, "14.10.2025 08:00", false},
+		{"напоминание ждет выбранного времени", models.NotificationDiary, "13.10.2025 10:00", true, "13.10.2025 20:00", false},
+		{"напоминание после выбранного времени", models.NotificationDiary, "13.10.2025 21:00", true, "13.10.2025 21:00", false},
+		{"отключенный тип", models.NotificationMotivation, "13.10.2025 12:00", false, "", true},
+	}
+	for _, tt := range tests {
+		at, ok := prefs.DeliverAt(tt.typ, local(tt.now), moscow, tt.reminder)
+		if ok == tt.muted {
+			t.Errorf("%s: ожидали muted=%v", tt.name, tt.muted)
+			continue
+		}
+		if !tt.muted && !at.Equal(local(tt.want)) {
+			t.Errorf("%s: ожидали %s, получили %s", tt.name, tt.want, at.In(moscow).Format("02.01.2006 15:04"))
+		}
+	}
+
+	prefs.Toggle(models.NotificationMotivation)
+	if !prefs.Allows(models.NotificationMotivation) {
+		t.Error("Повторное переключение должно включить тип обратно")
+	}
+	if _, _, err := models.ParseQuietHours("23:00-23:00"); err == nil {
+		t.Error("Ожидали ошибку для пустого интервала тихих часов")
+	}
+}
+
+func TestPlanDelivery(t *testing.T) {
+	storage := models.NewUserStorage(t.TempDir())
+	for _, userID := range []int64{1, 2, 3} {
+		if err := storage.AddUser(userID, ""); err != nil {
+			t.Fatalf("Ошибка добавления пользователя: %v", err)
+		}
+		if err := storage.SetTimezone(userID, "Europe/Moscow"); err != nil {
+			t.Fatalf("Ошибка сохранения пояса: %v", err)
+		}
+	}
+	muted := models.NotificationPreferences{}
+	muted.Toggle(models.NotificationDiary)
+	if err := storage.SetPreferences(2, muted); err != nil {
+		t.Fatalf("Ошибка сохранения настроек: %v", err)
+	}
+	if err := storage.SetPreferences(3, models.NotificationPreferences{QuietFrom: "22:00", QuietTo: "09:00"}); err != nil {
+		t.Fatalf("Ошибка сохранения настроек: %v", err)
+	}
+
+	users, err := storage.GetAllActiveUsers()
+	if err != nil {
+		t.Fatalf("Ошибка получения пользователей: %v", err)
+	}
+	// 23:00 по Москве
+	now := time.Date(2025, 10, 13, 20, 0, 0, 0, time.UTC)
+	plan := services.PlanDelivery(users, models.NotificationDiary, "Привет", now, true)
+
+	if len(plan.Now) != 1 || plan.Now[0] != 1 {
+		t.Errorf("Сразу должен получить только пользователь 1, получили %v", plan.Now)
+	}
+	if plan.Muted != 1 {
+		t.Errorf("Ожидали одного отключившего тип, получили %d", plan.Muted)
+	}
+	if len(plan.Deferred) != 1 || plan.Deferred[0].UserID != 3 ||
+		!plan.Deferred[0].SendAt.Equal(time.Date(2025, 10, 14, 6, 0, 0, 0, time.UTC)) {
+		t.Errorf("Пользователь 3 должен получить уведомление после тихих часов: %+v", plan.Deferred)
+	}
+}
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/exercises"
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+)
+
+func TestProgressCurrentWeek(t *testing.T) {
+	start := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	progress := exercises.Progress{StartedAt: start}
+
+	if week := progress.CurrentWeek(start.Add(6 * 24 * time.Hour)); week != 1 {
+		t.Errorf("Ожидали 1 неделю через 6 дней, получили %d", week)
+	}
+	if week := progress.CurrentWeek(start.Add(8 * 24 * time.Hour)); week != 2 {
+		t.Errorf("Ожидали 2 неделю через 8 дней, получили %d", week)
+	}
+	if week := progress.CurrentWeek(start.Add(100 * 24 * time.Hour)); week != exercises.TotalWeeks {
+		t.Errorf("Неделя не должна превышать %d, получили %d", exercises.TotalWeeks, week)
+	}
+
+	// Завершение совместных вопросов открывает следующую неделю досрочно
+	progress.CompletedWeeks = []int{1, 2}
+	if week := progress.CurrentWeek(start.Add(time.Hour)); week != 3 {
+		t.Errorf("Ожидали 3 неделю после завершения 1 и 2, получили %d", week)
+	}
+
+	progress.OverrideWeek = 2
+	if week := progress.CurrentWeek(start.Add(100 * 24 * time.Hour)); week != 2 {
+		t.Errorf("Ожидали неделю администратора 2, получили %d", week)
+	}
+}
+
+func TestProgressTracker(t *testing.T) {
+	dataDir := t.TempDir()
+	couples := models.NewCoupleStorage(dataDir)
+	tracker := exercises.NewProgressTracker(dataDir, couples)
+
+	male, female := int64(100), int64(200)
+	if !tracker.IsWeekUnlocked(male, 1) || tracker.IsWeekUnlocked(male, 2) {
+		t.Fatalf("Новому пользователю должна быть открыта только 1 неделя")
+	}
+
+	invite, err := couples.CreateInvite(male, "male")
+	if err != nil {
+		t.Fatalf("Ошибка создания приглашения: %v", err)
+	}
+	if _, err := couples.AcceptInvite(invite.Code, female); err != nil {
+		t.Fatalf("Ошибка принятия приглашения: %v", err)
+	}
+
+	unlocked, err := tracker.MarkWeekCompleted(female, 1)
+	if err != nil {
+		t.Fatalf("Ошибка отметки недели: %v", err)
+	}
+	if !unlocked {
+		t.Errorf("Завершение 1 недели должно открыть 2")
+	}
+	if week := tracker.CurrentWeek(male); week != 2 {
+		t.Errorf("Прогресс пары общий: ожидали 2 неделю у партнера, получили %d", week)
+	}
+
+	if err := tracker.SetOverride(male, 4); err != nil {
+		t.Fatalf("Ошибка установки недели: %v", err)
+	}
+	restored := exercises.NewProgressTracker(dataDir, couples)
+	if !restored.IsWeekUnlocked(female, 4) {
+		t.Errorf("Неделя администратора должна сохраняться между перезапусками")
+	}
+
+	if err := restored.Reset(male); err != nil {
+		t.Fatalf("Ошибка сброса прогресса: %v", err)
+	}
+	if week := restored.CurrentWeek(female); week != 1 {
+		t.Errorf("После сброса ожидали 1 неделю, получили %d", week)
+	}
+}
@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/services"
+)
+
+func at(value string) time.Time {
+	t, err := time.ParseInLocation("02.01.2006 15:04", value, services.ScheduleZone)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	// 13.10.2025 - понедельник
+	anchor := at("13.10.2025 20:00")
+
+	tests := []struct {
+		name  string
+		rule  services.Recurrence
+		after time.Time
+		want  time.Time
+	}{
+		{"daily до якоря", services.Recurrence{Kind: services.RepeatDaily}, at("10.10.2025 12:00"), anchor},
+		{"daily в тот же день", services.Recurrence{Kind: services.RepeatDaily}, at("15.10.2025 19:59"), at("15.10.2025 20:00")},
+		{"daily после времени", services.Recurrence{Kind: services.RepeatDaily}, at("15.10.2025 20:00"), at("16.10.2025 20:00")},
+		{"every 3", services.Recurrence{Kind: services.RepeatEvery, Interval: 3}, at("14.10.2025 08:00"), at("16.10.2025 20:00")},
+		{"weekdays пн,ср", services.Recurrence{Kind: services.RepeatWeekdays, Weekdays: []time.Weekday{time.Monday, time.Wednesday}}, at("15.10.2025 21:00"), at("20.10.2025 20:00")},
+		{"cron будни", services.Recurrence{Kind: services.RepeatCron, Cron: "30 9 * * 1-5"}, at("17.10.2025 10:00"), at("20.10.2025 09:30")},
+		{"cron первое число", services.Recurrence{Kind: services.RepeatCron, Cron: "0 12 1 * *"}, at("14.10.2025 10:00"), at("01.11.2025 12:00")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.rule.Next(anchor, tt.after)
+			if !ok {
+				t.Fatalf("Ожидали следующий запуск, правило завершилось")
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("Ожидали %s, получили %s", tt.want.Format(time.RFC3339), got.In(services.ScheduleZone).Format(time.RFC3339))
+			}
+		})
+	}
+}
+
+func TestRecurrenceUntil(t *testing.T) {
+	until := at("15.10.2025 23:59")
+	rule := services.Recurrence{Kind: services.RepeatDaily, Until: &until}
+
+	if _, ok := rule.Next(at("13.10.2025 20:00"), at("15.10.2025 20:00")); ok {
+		t.Error("После даты окончания запусков быть не должно")
+	}
+	if _, ok := rule.Next(at("13.10.2025 20:00"), at("15.10.2025 10:00")); !ok {
+		t.Error("Запуск в день окончания должен состояться")
+	}
+}
+
+func TestParseRecurrenceSpec(t *testing.T) {
+	now := at("13.10.2025 12:00")
+
+	rule, start, err := services.ParseRecurrenceSpec("weekdays пн,чт 10:00 until=31.12.2025 count=8", now)
+	if err != nil {
+		t.Fatalf("Ошибка разбора: %v", err)
+	}
+	if len(rule.Weekdays) != 2 || rule.Weekdays[0] != time.Monday || rule.Weekdays[1] != time.Thursday {
+		t.Errorf("Неверные дни недели: %v", rule.Weekdays)
+	}
+	if rule.MaxCount != 8 || rule.Until == nil || rule.Until.In(services.ScheduleZone).Format("02.01.2006") != "31.12.2025" {
+		t.Errorf("Неверные ограничения: count=%d until=%v", rule.MaxCount, rule.Until)
+	}
+	if !start.Equal(at("13.10.2025 10:00")) {
+		t.Errorf("Неверное начало: %s", start)
+	}
+
+	rule, _, err = services.ParseRecurrenceSpec("cron 0 9 * * 1-5", now)
+	if err != nil || rule.Cron != "0 9 * * 1-5" {
+		t.Errorf("Неверный разбор cron: %+v, %v", rule, err)
+	}
+
+	days, err := services.ParseWeekdays("1-3,вс")
+	if err != nil || len(days) != 4 || days[3] != time.Sunday {
+		t.Errorf("Неверный разбор дней: %v, %v", days, err)
+	}
+
+	invalid := []string{
+		"",
+		"daily",
+		"daily 25:00",
+		"every 0 10:00",
+		"weekdays xx 10:00",
+		"cron 0 9 * *",
+		"cron 60 9 * * *",
+		"daily 10:00 count=0",
+		"daily 10:00 until=31-12-2025",
+		"hourly 10:00",
+	}
+	for _, spec := range invalid {
+		if _, _, err := services.ParseRecurrenceSpec(spec, now); err == nil {
+			t.Errorf("Ожидали ошибку для '%s'", spec)
+		}
+	}
+}
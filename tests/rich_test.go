@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+	"github.com/godofphonk/lovifyy-bot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// recordingSender запоминает все отправленные сообщения по порядку
+type recordingSender struct {
+	mutex sync.Mutex
+	sent  []tgbotapi.Chattable
+}
+
+func (s *recordingSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	s.mutex.Lock()
+	s.sent = append(s.sent, c)
+	s.mutex.Unlock()
+	return tgbotapi.Message{}, nil
+}
+
+func TestRichMessages(t *testing.T) {
+	rich := models.RichContent{
+		Media:   &models.NotificationMedia{Kind: models.MediaPhoto, FileID: "photo-id"},
+		Buttons: []models.NotificationButton{models.DiaryButton, {Text: "Сайт", URL: "https://example.com"}},
+	}
+
+	parts := services.RichMessages(1, "Подпись", "HTML", rich)
+	if len(parts) != 1 {
+		t.Fatalf("Ожидали одно сообщение, получили %d", len(parts))
+	}
+	photo, ok := parts[0].(tgbotapi.PhotoConfig)
+	if !ok {
+		t.Fatalf("Ожидали фото, получили %T", parts[0])
+	}
+	if photo.Caption != "Подпись" || photo.ParseMode != "HTML" {
+		t.Errorf("Неверная подпись: %q (%s)", photo.Caption, photo.ParseMode)
+	}
+	keyboard, ok := photo.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+	if !ok || len(keyboard.InlineKeyboard) != 2 {
+		t.Fatalf("Ожидали клавиатуру из двух строк, получили %#v", photo.ReplyMarkup)
+	}
+	if data := keyboard.InlineKeyboard[0][0].CallbackData; data == nil || *data != models.DeepLinkDiary {
+		t.Errorf("Первая кнопка должна открывать дневник")
+	}
+	if url := keyboard.InlineKeyboard[1][0].URL; url == nil || *url != "https://example.com" {
+		t.Errorf("Вторая кнопка должна быть ссылкой")
+	}
+
+	// Длинный текст не помещается в подпись: документ отдельно, текст с кнопками следом
+	rich.Media.Kind = models.MediaDocument
+	long := strings.Repeat("а", 1100)
+	parts = services.RichMessages(1, long, "HTML", rich)
+	if len(parts) != 2 {
+		t.Fatalf("Ожидали два сообщения, получили %d", len(parts))
+	}
+	if doc, ok := parts[0].(tgbotapi.DocumentConfig); !ok || doc.Caption != "" || doc.ReplyMarkup != nil {
+		t.Errorf("Документ должен уйти без подписи и кнопок: %#v", parts[0])
+	}
+	if msg, ok := parts[1].(tgbotapi.MessageConfig); !ok || msg.Text != long || msg.ReplyMarkup == nil {
+		t.Errorf("Текст должен уйти вторым сообщением с кнопками: %#v", parts[1])
+	}
+
+	// Без вложений - обычное текстовое сообщение
+	parts = services.RichMessages(1, "Текст", "", models.RichContent{})
+	if msg, ok := parts[0].(tgbotapi.MessageConfig); len(parts) != 1 || !ok || msg.ReplyMarkup != nil {
+		t.Errorf("Ожидали одно текстовое сообщение без кнопок: %#v", parts)
+	}
+}
+
+func TestBroadcastQueueRichJob(t *testing.T) {
+	sender := &recordingSender{}
+	queue := services.NewBroadcastQueue(sender, services.NewTokenBucket(1000, 1000), t.TempDir())
+	stop := make(chan struct{})
+	defer close(stop)
+	go queue.Run(stop)
+
+	_, done, err := queue.Submit(services.BroadcastJob{
+		Message:    "Новое упражнение",
+		Recipients: []int64{1, 2, 2},
+		RichContent: models.RichContent{
+			Media:   &models.NotificationMedia{Kind: models.MediaDocument, FileID: "pdf-id"},
+			Buttons: []models.NotificationButton{models.ExerciseButton},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Ошибка постановки в очередь: %v", err)
+	}
+	var report services.BroadcastReport
+	select {
+	case report = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Рассылка не завершилась")
+	}
+
+	if report.Sent != 2 || len(sender.sent) != 2 {
+		t.Fatalf("Ожидали отправку двум получателям, отчет %+v, сообщений %d", report, len(sender.sent))
+	}
+	for _, c := range sender.sent {
+		doc, ok := c.(tgbotapi.DocumentConfig)
+		if !ok || doc.Caption != "Новое упражнение" || doc.ReplyMarkup == nil {
+			t.Errorf("Ожидали документ с подписью и кнопкой, получили %#v", c)
+		}
+	}
+}
+
+func TestRichContentToggleButton(t *testing.T) {
+	var rich models.RichContent
+	rich.ToggleButton(models.DiaryButton)
+	rich.ToggleButton(models.ExerciseButton)
+	if !rich.HasButton(models.DeepLinkDiary) || len(rich.Buttons) != 2 {
+		t.Fatalf("Кнопки не добавлены: %+v", rich.Buttons)
+	}
+	rich.ToggleButton(models.DiaryButton)
+	if rich.HasButton(models.DeepLinkDiary) || len(rich.Buttons) != 1 {
+		t.Errorf("Кнопка дневника должна убраться: %+v", rich.Buttons)
+	}
+	if rich.IsEmpty() {
+		t.Error("С кнопкой вложение не пустое")
+	}
+}
@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+	"github.com/godofphonk/lovifyy-bot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// newFakeTelegram создает клиента Bot API, который отвечает успехом на любую отправку
+// и сообщает получателя в onSend
+func newFakeTelegram(t *testing.T, onSend func(chatID int64)) *tgbotapi.BotAPI {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/getMe") {
+			fmt.Fprint(w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"Lovifyy","username":"lovifyy_bot"}}`)
+			return
+		}
+		chatID, _ := strconv.ParseInt(r.FormValue("chat_id"), 10, 64)
+		onSend(chatID)
+		fmt.Fprintf(w, `{"ok":true,"result":{"message_id":1,"date":0,"chat":{"id":%d,"type":"private"}}}`, chatID)
+	}))
+	t.Cleanup(server.Close)
+
+	bot, err := tgbotapi.NewBotAPIWithClient("test-token", server.URL+"/bot%s/%s", server.Client())
+	if err != nil {
+		t.Fatalf("Ошибка создания клиента Bot API: %v", err)
+	}
+	return bot
+}
+
+// runScheduler вызывает планировщик, пока задача не уйдет из расписания
+func runScheduler(t *testing.T, ns *services.NotificationService) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		ns.RunDueNotifications(time.Now())
+		if jobs, _ := ns.LoadSchedule(); len(jobs) == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Задача не ушла из расписания")
+}
+
+func TestScheduledBroadcastRestartDeliversOnce(t *testing.T) {
+	chdirTemp(t)
+	recipients := []int64{1, 2, 3}
+
+	var mu sync.Mutex
+	deliveries := make(map[int64]int)
+	record := func(chatID int64) int {
+		mu.Lock()
+		defer mu.Unlock()
+		deliveries[chatID]++
+		total := 0
+		for _, count := range deliveries {
+			total += count
+		}
+		return total
+	}
+
+	// Первый запуск бота останавливается после первой отправки, посреди рассылки
+	stopFirst := make(chan struct{})
+	var once sync.Once
+	first := services.NewNotificationService(newFakeTelegram(t, func(chatID int64) {
+		record(chatID)
+		once.Do(func() { close(stopFirst) })
+	}), nil)
+	// Одно сообщение в секунду: остановка успевает прервать рассылку перед вторым получателем
+	first.SetBroadcastRate(1)
+	firstDone := make(chan struct{})
+	go func() {
+		first.StartBroadcasts(stopFirst)
+		close(firstDone)
+	}()
+
+	if _, err := first.Schedule(time.Now().Add(-time.Minute), models.NotificationCustom, services.ScheduleOptions{CustomText: "Привет!", Recipients: recipients}); err != nil {
+		t.Fatalf("Ошибка планирования: %v", err)
+	}
+	runScheduler(t, first)
+	select {
+	case <-firstDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Очередь не остановилась")
+	}
+
+	// После перезапуска рассылку продолжает очередь, а планировщик не запускает задачу повторно
+	finished := make(chan struct{})
+	second := services.NewNotificationService(newFakeTelegram(t, func(chatID int64) {
+		if record(chatID) == len(recipients) {
+			close(finished)
+		}
+	}), nil)
+	stopSecond := make(chan struct{})
+	defer close(stopSecond)
+	go second.StartBroadcasts(stopSecond)
+	runScheduler(t, second)
+
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Продолженная рассылка не завершилась")
+	}
+	second.RunDueNotifications(time.Now())
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, userID := range recipients {
+		if deliveries[userID] != 1 {
+			t.Errorf("Пользователь %d получил уведомление %d раз, ожидали один", userID, deliveries[userID])
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+	"github.com/godofphonk/lovifyy-bot/internal/services"
+)
+
+// fakeAudience отдает заранее заданные неделю, партнеров и записи дневника
+type fakeAudience struct {
+	weeks    map[int64]int
+	partners map[int64]int64
+	diary    map[int64]int
+}
+
+func (f fakeAudience) CurrentWeek(userID int64) int            { return f.weeks[userID] }
+func (f fakeAudience) PartnerOf(userID int64) int64            { return f.partners[userID] }
+func (f fakeAudience) DiaryEntryCount(userID int64, _ int) int { return f.diary[userID] }
+func (f fakeAudience) LastDiaryEntry(int64) (time.Time, bool)  { return time.Time{}, false }
+func (f fakeAudience) WeekTitle(week int) string               { return "" }
+
+func TestParseSegmentRules(t *testing.T) {
+	rules, err := models.ParseSegmentRules("week=2 inactive=7 nodiary paired joined=01.10.2025")
+	if err != nil {
+		t.Fatalf("Ошибка разбора условий: %v", err)
+	}
+	if rules.Week != 2 || rules.InactiveDays != 7 || !rules.NoDiary || rules.Pairing != models.PairingPaired || rules.JoinedAfter == nil {
+		t.Errorf("Неверно разобраны условия: %+v", rules)
+	}
+
+	for _, spec := range []string{"week=0", "inactive=x", "paired single", "joined=2025-10-01", "vip"} {
+		if _, err := models.ParseSegmentRules(spec); err == nil {
+			t.Errorf("Ожидали ошибку для %q", spec)
+		}
+	}
+}
+
+func TestMatchesSegment(t *testing.T) {
+	now := time.Date(2025, 10, 20, 12, 0, 0, 0, time.UTC)
+	joined := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	source := fakeAudience{
+		weeks:    map[int64]int{1: 2, 2: 2, 3: 1},
+		partners: map[int64]int64{1: 2, 2: 1},
+		diary:    map[int64]int{2: 3},
+	}
+	user := func(id int64, joinedAt, lastSeen time.Time) models.UserInfo {
+		return models.UserInfo{UserID: id, JoinedAt: joinedAt, LastSeen: lastSeen}
+	}
+	active := user(1, joined.AddDate(0, 0, 5), now.Add(-time.Hour))
+	withDiary := user(2, joined.AddDate(0, 0, 5), now.AddDate(0, 0, -10))
+	single := user(3, joined.AddDate(0, 0, -5), now.AddDate(0, 0, -10))
+
+	tests := []struct {
+		name  string
+		rules models.SegmentRules
+		user  models.UserInfo
+		want  bool
+	}{
+		{"без условий", models.SegmentRules{}, single, true},
+		{"неделя совпадает", models.SegmentRules{Week: 2}, active, true},
+		{"неделя не совпадает", models.SegmentRules{Week: 2}, single, false},
+		{"неактивен", models.SegmentRules{InactiveDays: 7}, withDiary, true},
+		{"недавно заходил", models.SegmentRules{InactiveDays: 7}, active, false},
+		{"нет записей", models.SegmentRules{NoDiary: true}, active, true},
+		{"есть записи", models.SegmentRules{NoDiary: true}, withDiary, false},
+		{"в паре", models.SegmentRules{Pairing: models.PairingPaired}, active, true},
+		{"без пары", models.SegmentRules{Pairing: models.PairingSingle}, active, false},
+		{"пришел после даты", models.SegmentRules{JoinedAfter: &joined}, active, true},
+		{"пришел до даты", models.SegmentRules{JoinedAfter: &joined}, single, false},
+	}
+	for _, tt := range tests {
+		if got := services.MatchesSegment(tt.user, tt.rules, source, now); got != tt.want {
+			t.Errorf("%s: ожидали %v, получили %v", tt.name, tt.want, got)
+		}
+	}
+
+	if services.MatchesSegment(active, models.SegmentRules{Week: 2}, nil, now) {
+		t.Error("Без источника сведений условие по неделе не должно выполняться")
+	}
+}
+
+func TestSegmentStorage(t *testing.T) {
+	dir := t.TempDir()
+	storage := models.NewSegmentStorage(dir)
+
+	first, err := storage.Create("Вторая неделя", models.SegmentRules{Week: 2}, 1)
+	if err != nil {
+		t.Fatalf("Ошибка создания сегмента: %v", err)
+	}
+	second, err := storage.Create("Без пары", models.SegmentRules{Pairing: models.PairingSingle}, 1)
+	if err != nil {
+		t.Fatalf("Ошибка создания сегмента: %v", err)
+	}
+	if first.ID != "s1" || second.ID != "s2" {
+		t.Errorf("Ожидали ID s1 и s2, получили %s и %s", first.ID, second.ID)
+	}
+
+	if err := storage.Delete(first.ID); err != nil {
+		t.Fatalf("Ошибка удаления сегмента: %v", err)
+	}
+	if segment, _ := storage.Get(first.ID); segment != nil {
+		t.Error("Удаленный сегмент не должен находиться")
+	}
+	third, _ := storage.Create("Новые", models.SegmentRules{}, 1)
+	if third.ID != "s3" {
+		t.Errorf("ID не должен повторяться после удаления, получили %s", third.ID)
+	}
+	// Удаление последнего сегмента тоже не освобождает его ID
+	if err := storage.Delete(third.ID); err != nil {
+		t.Fatalf("Ошибка удаления сегмента: %v", err)
+	}
+	if fourth, _ := models.NewSegmentStorage(dir).Create("Неактивные", models.SegmentRules{InactiveDays: 7}, 1); fourth.ID != "s4" {
+		t.Errorf("ID удаленного последним сегмента не должен повторяться, получили %s", fourth.ID)
+	}
+	if err := storage.Delete("s42"); err == nil {
+		t.Error("Ожидали ошибку удаления несуществующего сегмента")
+	}
+}
@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+)
+
+func TestParseState(t *testing.T) {
+	state := models.ParseState("diary_male_2_questions")
+	if state.Kind != models.StateDiary || state.Diary == nil {
+		t.Fatalf("Ожидали состояние дневника, получили %+v", state)
+	}
+	if state.Diary.Gender != "male" || state.Diary.Week != 2 || state.Diary.Type != "questions" {
+		t.Errorf("Неверный контекст дневника: %+v", state.Diary)
+	}
+
+	state = models.ParseState("custom_time_13.10.2025")
+	if state.Kind != models.StateCustomTime || state.Schedule == nil || state.Schedule.Date != "13.10.2025" {
+		t.Errorf("Неверный разбор custom_time: %+v", state)
+	}
+
+	for _, raw := range []string{"chat", "diary", "diary_female_4_joint", "custom_time_01.01.2026", "custom_date", ""} {
+		if encoded := models.ParseState(raw).String(); encoded != raw {
+			t.Errorf("Состояние '%s' после разбора кодируется как '%s'", raw, encoded)
+		}
+	}
+}
+
+func TestUserStatePersistence(t *testing.T) {
+	statesFile := filepath.Join(t.TempDir(), "states.json")
+	userID := int64(12345)
+
+	userManager := models.NewUserManager(nil)
+	if err := userManager.EnablePersistence(statesFile, time.Hour); err != nil {
+		t.Fatalf("Ошибка включения персистентности: %v", err)
+	}
+	userManager.SetUserState(userID, models.NewDiaryState("female", 3, "personal"))
+	userManager.SetStateData(int64(777), "chat", "payload")
+
+	// Имитируем перезапуск
+	restored := models.NewUserManager(nil)
+	if err := restored.EnablePersistence(statesFile, time.Hour); err != nil {
+		t.Fatalf("Ошибка восстановления состояний: %v", err)
+	}
+	if state := restored.GetState(userID); state != "diary_female_3_personal" {
+		t.Errorf("Ожидали восстановленное состояние дневника, получили '%s'", state)
+	}
+	if state, data := restored.GetStateData(777); state != "chat" || data != "payload" {
+		t.Errorf("Ожидали состояние chat с данными, получили '%s' / '%s'", state, data)
+	}
+
+	restored.ClearState(userID)
+	again := models.NewUserManager(nil)
+	again.EnablePersistence(statesFile, time.Hour)
+	if state := again.GetState(userID); state != "" {
+		t.Errorf("Очищенное состояние не должно восстанавливаться, получили '%s'", state)
+	}
+
+	// Истекшие состояния не восстанавливаются
+	expired := models.NewUserManager(nil)
+	expired.EnablePersistence(statesFile, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if state := expired.GetState(777); state != "" {
+		t.Errorf("Истекшее состояние не должно восстанавливаться, получили '%s'", state)
+	}
+}
+
+func TestUserStatePersistenceError(t *testing.T) {
+	statesFile := filepath.Join(t.TempDir(), "states.json")
+	userManager := models.NewUserManager(nil)
+	if err := userManager.EnablePersistence(statesFile, time.Hour); err != nil {
+		t.Fatalf("Ошибка включения персистентности: %v", err)
+	}
+
+	// На месте файла состояний непустой каталог, поэтому заменить его нельзя
+	if err := os.MkdirAll(filepath.Join(statesFile, "busy"), 0755); err != nil {
+		t.Fatalf("Не удалось создать каталог: %v", err)
+	}
+	if err := userManager.SetState(1, "chat"); err == nil {
+		t.Error("Ожидали ошибку записи состояний")
+	}
+	if _, err := os.Stat(statesFile + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Временный файл не должен оставаться после ошибки: %v", err)
+	}
+	if state := userManager.GetState(1); state != "" {
+		t.Errorf("Несохраненное состояние должно откатиться, получили '%s'", state)
+	}
+}
+
+func TestUserStatePersistenceConcurrent(t *testing.T) {
+	statesFile := filepath.Join(t.TempDir(), "states.json")
+	userManager := models.NewUserManager(nil)
+	if err := userManager.EnablePersistence(statesFile, time.Hour); err != nil {
+		t.Fatalf("Ошибка включения персистентности: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := int64(1); i <= 50; i++ {
+		wg.Add(1)
+		go func(userID int64) {
+			defer wg.Done()
+			if err := userManager.SetStateData(userID, "chat", fmt.Sprint(userID)); err != nil {
+				t.Errorf("Ошибка сохранения состояния: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Каждое успешно установленное состояние уже в файле
+	restored := models.NewUserManager(nil)
+	if err := restored.EnablePersistence(statesFile, time.Hour); err != nil {
+		t.Fatalf("Ошибка восстановления состояний: %v", err)
+	}
+	for i := int64(1); i <= 50; i++ {
+		if state, data := restored.GetStateData(i); state != "chat" || data != fmt.Sprint(i) {
+			t.Errorf("Состояние пользователя %d не сохранилось: '%s' / '%s'", i, state, data)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/godofphonk/lovifyy-bot/internal/config"
+	"github.com/godofphonk/lovifyy-bot/internal/history"
+	"github.com/godofphonk/lovifyy-bot/internal/stt"
+)
+
+func TestNewTranscriber(t *testing.T) {
+	transcriber, err := stt.NewTranscriber(config.STTConfig{})
+	if err != nil || transcriber != nil {
+		t.Errorf("Без провайдера распознавание должно быть отключено, получили %v (%v)", transcriber, err)
+	}
+	if _, err := stt.NewTranscriber(config.STTConfig{Provider: "unknown"}); err == nil {
+		t.Error("Ожидали ошибку для неизвестного провайдера")
+	}
+
+	stub, err := stt.NewTranscriber(config.STTConfig{Provider: stt.ProviderStub, StubText: "Привет"})
+	if err != nil {
+		t.Fatalf("Ошибка создания stub: %v", err)
+	}
+	text, err := stub.Transcribe(context.Background(), strings.NewReader("audio"), "voice.oga")
+	if err != nil || text != "Привет" {
+		t.Errorf("Ожидали 'Привет', получили %q (%v)", text, err)
+	}
+}
+
+func TestWhisperHTTPTranscriber(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/inference" {
+			t.Errorf("Ожидали запрос к /inference, получили %s", r.URL.Path)
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("В запросе нет файла: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		if string(data) != "ogg-data" || header.Filename != "voice.oga" {
+			t.Errorf("Неверный файл: %q (%s)", data, header.Filename)
+		}
+		if r.FormValue("language") != "ru" || r.FormValue("response_format") != "json" {
+			t.Errorf("Неверные параметры: language=%q response_format=%q", r.FormValue("language"), r.FormValue("response_format"))
+		}
+		w.Write([]byte(`{"text": "\n Сегодня мы\n гуляли в парке \n"}`))
+	}))
+	defer server.Close()
+
+	transcriber := stt.NewWhisperHTTP(config.STTConfig{ServerURL: server.URL + "/", Language: "ru"})
+	text, err := transcriber.Transcribe(context.Background(), strings.NewReader("ogg-data"), "voice.oga")
+	if err != nil {
+		t.Fatalf("Ошибка распознавания: %v", err)
+	}
+	if text != "Сегодня мы гуляли в парке" {
+		t.Errorf("Ожидали очищенную расшифровку, получили %q", text)
+	}
+
+	silent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"text": " "}`))
+	}))
+	defer silent.Close()
+	_, err = stt.NewWhisperHTTP(config.STTConfig{ServerURL: silent.URL}).Transcribe(context.Background(), strings.NewReader("x"), "voice.oga")
+	if !errors.Is(err, stt.ErrEmptyTranscript) {
+		t.Errorf("Ожидали ErrEmptyTranscript, получили %v", err)
+	}
+}
+
+func TestWhisperCLITranscriber(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Тест использует shell-скрипт вместо whisper.cpp")
+	}
+
+	// Скрипт имитирует whisper-cli: печатает аргументы и содержимое файла из -f
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "whisper-cli")
+	script := "#!/bin/sh\n" +
+		"while [ $# -gt 0 ]; do\n" +
+		"  case \"$1\" in\n" +
+		"    -m) model=\"$2\"; shift ;;\n" +
+		"    -f) file=\"$2\"; shift ;;\n" +
+		"    -l) lang=\"$2\"; shift ;;\n" +
+		"  esac\n" +
+		"  shift\n" +
+		"done\n" +
+		"echo \" [$model/$lang] $(cat \"$file\")\"\n"
+	if err := os.WriteFile(binary, []byte(script), 0o755); err != nil {
+		t.Fatalf("Не удалось создать скрипт: %v", err)
+	}
+
+	transcriber := stt.NewWhisperCLI(config.STTConfig{BinaryPath: binary, ModelPath: "base.bin", Language: "ru"})
+	text, err := transcriber.Transcribe(context.Background(), strings.NewReader("голос"), "voice.wav")
+	if err != nil {
+		t.Fatalf("Ошибка распознавания: %v", err)
+	}
+	if text != "[base.bin/ru] голос" {
+		t.Errorf("Неверная расшифровка: %q", text)
+	}
+
+	failing := stt.NewWhisperCLI(config.STTConfig{BinaryPath: filepath.Join(dir, "missing"), ModelPath: "base.bin"})
+	if _, err := failing.Transcribe(context.Background(), strings.NewReader("x"), "voice.wav"); err == nil {
+		t.Error("Ожидали ошибку при отсутствии бинарника")
+	}
+}
+
+func TestHistoryStoreDiaryAttachment(t *testing.T) {
+	for driver, store := range newTestStores(t) {
+		t.Run(driver, func(t *testing.T) {
+			manager := history.NewManagerWithStore(store)
+			voice, err := manager.AddDiaryEntry(history.DiaryEntry{
+				UserID:     7,
+				Entry:      "Расшифровка",
+				Week:       1,
+				Type:       "personal",
+				Attachment: &history.DiaryAttachment{Kind: history.AttachmentVoice, FileID: "AwACAgIAAxkBAAI"},
+			})
+			if err != nil {
+				t.Fatalf("Ошибка сохранения записи: %v", err)
+			}
+			text, err := manager.AddDiaryEntry(history.DiaryEntry{UserID: 7, Entry: "Текст", Week: 1, Type: "personal"})
+			if err != nil {
+				t.Fatalf("Ошибка сохранения записи: %v", err)
+			}
+
+			saved, err := manager.GetDiaryEntry(7, voice.ID)
+			if err != nil {
+				t.Fatalf("Запись не найдена: %v", err)
+			}
+			if saved.Attachment == nil || *saved.Attachment != *voice.Attachment {
+				t.Errorf("Вложение не сохранилось: %+v", saved.Attachment)
+			}
+			if saved, _ := manager.GetDiaryEntry(7, text.ID); saved.Attachment != nil {
+				t.Errorf("У текстовой записи не должно быть вложения: %+v", saved.Attachment)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/godofphonk/lovifyy-bot/internal/history"
+)
+
+func TestChatSummarization(t *testing.T) {
+	for driver, store := range newTestStores(t) {
+		t.Run(driver, func(t *testing.T) {
+			manager := history.NewManagerWithStore(store)
+			manager.SetSummaryOptions(history.SummaryOptions{TokenThreshold: 100, KeepTurns: 2})
+			userID := int64(4242)
+
+			for i := 0; i < 8; i++ {
+				message := fmt.Sprintf("вопрос %d %s", i, strings.Repeat("слово ", 20))
+				if err := manager.SaveMessage(userID, "user", message, "ответ", "test"); err != nil {
+					t.Fatalf("Ошибка сохранения сообщения: %v", err)
+				}
+			}
+
+			var prompt []history.OpenAIMessage
+			updated, err := manager.SummarizeIfNeeded(userID, func(messages []history.OpenAIMessage) (string, error) {
+				prompt = messages
+				return "Пара обсуждала общение", nil
+			})
+			if err != nil || !updated {
+				t.Fatalf("Ожидали сжатие истории, updated=%v err=%v", updated, err)
+			}
+			if len(prompt) != 2 || !strings.Contains(prompt[1].Content, "вопрос 0") || strings.Contains(prompt[1].Content, "вопрос 6") {
+				t.Errorf("В сжатие должны попасть только старые обмены, получили %+v", prompt)
+			}
+
+			summary, err := manager.GetChatSummary(userID)
+			if err != nil || summary == nil {
+				t.Fatalf("Содержание должно сохраниться: %v", err)
+			}
+			if summary.MessagesCount != 6 {
+				t.Errorf("Ожидали 6 сжатых обменов, получили %d", summary.MessagesCount)
+			}
+
+			context, err := manager.GetChatContext(userID, "промпт")
+			if err != nil {
+				t.Fatalf("Ошибка сборки контекста: %v", err)
+			}
+			// системный промпт + содержание + 2 последних обмена
+			if len(context) != 6 || !strings.Contains(context[1].Content, "Пара обсуждала общение") || !strings.HasPrefix(context[2].Content, "вопрос 6") {
+				t.Errorf("Неожиданный контекст: %+v", context)
+			}
+
+			// Повторное сжатие не нужно: несжатая часть меньше порога
+			updated, _ = manager.SummarizeIfNeeded(userID, func([]history.OpenAIMessage) (string, error) {
+				t.Error("Сжатие не должно вызываться")
+				return "", nil
+			})
+			if updated {
+				t.Error("Содержание не должно обновляться")
+			}
+
+			if err := manager.ResetChatSummary(userID); err != nil {
+				t.Fatalf("Ошибка сброса содержания: %v", err)
+			}
+			if summary, _ := manager.GetChatSummary(userID); summary != nil {
+				t.Errorf("Содержание должно быть удалено, получили %+v", summary)
+			}
+		})
+	}
+}
+
+func TestChatSummarizationLongFold(t *testing.T) {
+	for driver, store := range newTestStores(t) {
+		t.Run(driver, func(t *testing.T) {
+			manager := history.NewManagerWithStore(store)
+			manager.SetSummaryOptions(history.SummaryOptions{TokenThreshold: 100, KeepTurns: 1})
+			userID := int64(4243)
+
+			// Каждый обмен ~3000 токенов: все пять старых не помещаются в один запрос на сжатие
+			for i := 0; i < 6; i++ {
+				message := fmt.Sprintf("вопрос %d %s", i, strings.Repeat("слово ", 2000))
+				if err := manager.SaveMessage(userID, "user", message, "ответ", "test"); err != nil {
+					t.Fatalf("Ошибка сохранения сообщения: %v", err)
+				}
+			}
+
+			// Второй проход падает: содержание должно покрыть только обмены первого
+			var prompts []string
+			_, err := manager.SummarizeIfNeeded(userID, func(messages []history.OpenAIMessage) (string, error) {
+				prompts = append(prompts, messages[1].Content)
+				if len(prompts) == 2 {
+					return "", fmt.Errorf("модель недоступна")
+				}
+				return fmt.Sprintf("содержание %d", len(prompts)), nil
+			})
+			if err == nil {
+				t.Fatal("Ожидали ошибку второго прохода")
+			}
+			summary, _ := manager.GetChatSummary(userID)
+			if summary == nil || summary.MessagesCount != 2 || !strings.Contains(prompts[0], "вопрос 1") || strings.Contains(prompts[0], "вопрос 2") {
+				t.Fatalf("Содержание должно покрыть только отправленные обмены, получили %+v", summary)
+			}
+
+			prompts = nil
+			updated, err := manager.SummarizeIfNeeded(userID, func(messages []history.OpenAIMessage) (string, error) {
+				prompts = append(prompts, messages[1].Content)
+				return fmt.Sprintf("содержание %d", len(prompts)), nil
+			})
+			if err != nil || !updated {
+				t.Fatalf("Ожидали сжатие истории, updated=%v err=%v", updated, err)
+			}
+			folded := strings.Join(prompts, "\n")
+			for i := 2; i < 5; i++ {
+				if !strings.Contains(folded, fmt.Sprintf("вопрос %d ", i)) {
+					t.Errorf("Обмен %d не попал в сжатие", i)
+				}
+			}
+			if len(prompts) != 2 || !strings.Contains(prompts[1], "содержание 1") || strings.Contains(folded, "вопрос 5") {
+				t.Errorf("Ожидали два прохода с накоплением содержания, получили %d", len(prompts))
+			}
+			if summary, _ := manager.GetChatSummary(userID); summary == nil || summary.MessagesCount != 5 {
+				t.Errorf("Ожидали 5 сжатых обменов, получили %+v", summary)
+			}
+		})
+	}
+}
+
+func TestChatHistoryLegacyRecords(t *testing.T) {
+	manager := history.NewManagerWithStore(newTestStores(t)[history.DriverJSON])
+	userID := int64(5151)
+
+	// Так сообщения сохраняли старые версии бота
+	if err := manager.SaveMessage(userID, "как помириться?", "поговорите спокойно", "chat", "user"); err != nil {
+		t.Fatalf("Ошибка сохранения сообщения: %v", err)
+	}
+
+	messages, err := manager.GetUserHistory(userID, 0)
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("Ожидали одно сообщение, получили %d (%v)", len(messages), err)
+	}
+	if messages[0].Message != "как помириться?" || messages[0].Response != "поговорите спокойно" {
+		t.Errorf("Старая запись должна быть исправлена при чтении, получили %+v", messages[0])
+	}
+}
@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/godofphonk/lovifyy-bot/internal/models"
+	"github.com/godofphonk/lovifyy-bot/internal/services"
+)
+
+func TestDetectTimezone(t *testing.T) {
+	now := time.Date(2025, 10, 13, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		clock string
+		want  string
+	}{
+		{"15:00", "Europe/Moscow"},
+		{"15:01", "Europe/Moscow"}, // ответ с задержкой
+		{"17:00", "Asia/Yekaterinburg"},
+		{"14:00", "Europe/Kaliningrad"},
+		{"06:00", "Etc/GMT+6"},
+		{"12:00", "Etc/UTC"},
+		{"03:00", "Etc/GMT+9"},
+		{"14.10 01:00", "Etc/GMT-13"}, // UTC+13: у пользователя уже следующий день
+		{"14.10 02:00", "Etc/GMT-14"}, // UTC+14
+		{"13.10 02:00", "Etc/GMT+10"},
+		{"13.10 15:00", "Europe/Moscow"},
+	}
+	for _, tt := range tests {
+		got, err := models.DetectTimezone(tt.clock, now)
+		if err != nil {
+			t.Errorf("%s: ошибка %v", tt.clock, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: ожидали %s, получили %s", tt.clock, tt.want, got)
+		}
+	}
+
+	if _, err := models.DetectTimezone("25:00", now); err == nil {
+		t.Error("Ожидали ошибку для некорректного времени")
+	}
+	if _, err := models.DetectTimezone("12:20", now); err == nil {
+		t.Error("Смещение 20 минут не соответствует ни одному поясу")
+	}
+	// Без даты 02:00 - это и UTC+14, и UTC-10
+	if _, err := models.DetectTimezone("02:00", now); err == nil {
+		t.Error("Ожидали просьбу указать дату для неоднозначного времени")
+	}
+	if _, err := models.DetectTimezone("16.10 02:00", now); err == nil {
+		t.Error("Ожидали ошибку для даты, не соответствующей ни одному поясу")
+	}
+
+	// На стыке годов дата пользователя относится к следующему году
+	newYear := time.Date(2025, 12, 31, 12, 0, 0, 0, time.UTC)
+	if got, err := models.DetectTimezone("01.01 02:00", newYear); err != nil || got != "Etc/GMT-14" {
+		t.Errorf("Ожидали Etc/GMT-14 на стыке годов, получили %s (%v)", got, err)
+	}
+}
+
+func TestUserTimezoneStorage(t *testing.T) {
+	storage := models.NewUserStorage(t.TempDir())
+	if err := storage.AddUser(1, "alice"); err != nil {
+		t.Fatalf("Ошибка добавления пользователя: %v", err)
+	}
+
+	user, err := storage.GetUser(1)
+	if err != nil || user == nil {
+		t.Fatalf("Пользователь не найден: %v", err)
+	}
+	if user.Location().String() != models.DefaultTimezone {
+		t.Errorf("Без выбора должен использоваться пояс по умолчанию, получили %s", user.Location())
+	}
+
+	if err := storage.SetTimezone(1, "Europe/Moscow"); err != nil {
+		t.Fatalf("Ошибка сохранения пояса: %v", err)
+	}
+	user, _ = storage.GetUser(1)
+	if user.Location().String() != "Europe/Moscow" {
+		t.Errorf("Ожидали Europe/Moscow, получили %s", user.Location())
+	}
+
+	if err := storage.SetTimezone(2, "Europe/Moscow"); err == nil {
+		t.Error("Ожидали ошибку для незарегистрированного пользователя")
+	}
+	if _, err := models.LoadTimezone("Mars/Olympus"); err == nil {
+		t.Error("Ожидали ошибку для неизвестного пояса")
+	}
+}
+
+func TestPlanWaves(t *testing.T) {
+	wall := time.Date(2025, 10, 13, 20, 0, 0, 0, services.ScheduleZone)
+	users := []models.UserInfo{
+		{UserID: 1, Timezone: "Europe/Moscow"},
+		{UserID: 2}, // пояс по умолчанию (UTC+5)
+		{UserID: 3, Timezone: "Asia/Vladivostok"},
+		{UserID: 4, Timezone: "Europe/Moscow"},
+	}
+
+	waves := services.PlanWaves(wall, users)
+	if len(waves) != 3 {
+		t.Fatalf("Ожидали 3 волны, получили %d", len(waves))
+	}
+
+	// Восточные пояса получают уведомление раньше
+	want := []struct {
+		zone  string
+		utc   string
+		users int
+	}{
+		{"Asia/Vladivostok", "2025-10-13T10:00:00Z", 1},
+		{models.DefaultTimezone, "2025-10-13T15:00:00Z", 1},
+		{"Europe/Moscow", "2025-10-13T17:00:00Z", 2},
+	}
+	for i, w := range want {
+		if waves[i].Zone != w.zone {
+			t.Errorf("Волна %d: ожидали пояс %s, получили %s", i, w.zone, waves[i].Zone)
+		}
+		if got := waves[i].At.UTC().Format(time.RFC3339); got != w.utc {
+			t.Errorf("Волна %d: ожидали %s, получили %s", i, w.utc, got)
+		}
+		if len(waves[i].UserIDs) != w.users {
+			t.Errorf("Волна %d: ожидали %d получателей, получили %d", i, w.users, len(waves[i].UserIDs))
+		}
+	}
+}
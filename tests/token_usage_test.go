@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/godofphonk/lovifyy-bot/internal/ai"
+	"github.com/godofphonk/lovifyy-bot/internal/services"
+)
+
+func TestTokenUsageBudget(t *testing.T) {
+	dataDir := t.TempDir()
+	usage := services.NewTokenUsageService(dataDir, services.TokenBudget{Daily: 100}, nil)
+	userID := int64(42)
+
+	usage.RecordUsage(userID, ai.FeatureChat, ai.Usage{Model: "gpt-4o-mini", PromptTokens: 60, CompletionTokens: 30}, nil)
+	if err := usage.CheckBudget(userID); err != nil {
+		t.Fatalf("90 из 100 токенов не должны превышать бюджет: %v", err)
+	}
+
+	usage.RecordUsage(userID, ai.FeatureWeeklyInsight, ai.Usage{Model: "gpt-4o", PromptTokens: 5, CompletionTokens: 5}, nil)
+	var exceeded *services.BudgetExceededError
+	if err := usage.CheckBudget(userID); !errors.As(err, &exceeded) || exceeded.Period != services.BudgetPeriodDay {
+		t.Fatalf("Ожидали превышение дневного бюджета, получили %v", err)
+	}
+
+	// Индивидуальный бюджет пользователя сохраняется между перезапусками
+	if err := usage.SetUserBudget(userID, services.TokenBudget{Daily: 1000}); err != nil {
+		t.Fatalf("Ошибка установки бюджета: %v", err)
+	}
+	restored := services.NewTokenUsageService(dataDir, services.TokenBudget{Daily: 100}, nil)
+	if err := restored.CheckBudget(userID); err != nil {
+		t.Errorf("Индивидуальный бюджет должен снять ограничение: %v", err)
+	}
+
+	stats, budget, err := restored.GetUsage(userID)
+	if err != nil {
+		t.Fatalf("Ошибка получения расхода: %v", err)
+	}
+	if budget.Daily != 1000 || stats.DayTokens != 100 || stats.Total.Requests != 2 {
+		t.Errorf("Неверный расход: %+v, бюджет %+v", stats, budget)
+	}
+	if stats.Features[ai.FeatureChat].TotalTokens() != 90 || stats.Models["gpt-4o"].TotalTokens() != 10 {
+		t.Errorf("Неверная разбивка по функциям и моделям: %+v / %+v", stats.Features, stats.Models)
+	}
+
+	// Системные запросы не относятся к пользователям
+	restored.RecordUsage(0, ai.FeatureNotification, ai.Usage{PromptTokens: 500}, nil)
+	if stats, _, _ := restored.GetUsage(0); stats.Total.Requests != 0 {
+		t.Errorf("Системные запросы не должны учитываться как пользовательские")
+	}
+}
+
+func TestMeteredClient(t *testing.T) {
+	usage := services.NewTokenUsageService(t.TempDir(), services.TokenBudget{}, nil)
+	client := ai.Metered(ai.NewEchoClient(""), usage, 7, ai.FeatureChat)
+
+	if _, err := client.Generate("Как нам чаще говорить о чувствах?"); err != nil {
+		t.Fatalf("Ошибка генерации: %v", err)
+	}
+
+	stats, _, _ := usage.GetUsage(7)
+	if stats.Total.Requests != 1 || stats.Total.TotalTokens() == 0 {
+		t.Errorf("Ожидали учтенный запрос с оценкой токенов, получили %+v", stats.Total)
+	}
+	if stats.Models[ai.EchoModel] == nil {
+		t.Errorf("Ожидали учет по модели %s, получили %+v", ai.EchoModel, stats.Models)
+	}
+}